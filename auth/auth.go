@@ -18,13 +18,94 @@ const (
 
 	// NetrcDefaultFilename is the default name of the netrc configuration file.
 	NetrcDefaultFilename string = ".netrc"
+
+	// GitHubHost is the web host of github.com
+	GitHubHost string = "github.com"
+
+	// GitHubAPIHost is the API host of github.com
+	GitHubAPIHost string = "api.github.com"
+
+	// XDGConfigEnv is the environment variable holding the base directory for
+	// user-specific configuration files
+	XDGConfigEnv string = "XDG_CONFIG_HOME"
 )
 
 // Interface is a generic authentication interface
 type Interface interface {
 	// GetAuth retrieves the authentication credentials for a given host, or
 	// throws an error
-	GetUsernamePassword(host string) (string, string, error)
+	GetAuth(host string) (string, string, error)
+}
+
+// Chain looks up credentials in several sources, using the first one that has credentials
+// for a host
+type Chain []Interface
+
+// GetAuth retrieves the credentials for a host from the first source that has them
+func (c Chain) GetAuth(host string) (string, string, error) {
+	for _, source := range c {
+		if username, password, err := source.GetAuth(host); err == nil && password != "" {
+			return username, password, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("no auth for %s configured (set $%s, run stars login or add it to ~/%s)", host, GitHubTokenEnv, NetrcDefaultFilename)
+}
+
+// NewDefault returns the sources credentials are looked up in, in order: the environment,
+// the keyring, the tokens stored by stars login, the gh CLI and the netrc file, if there
+// is one. githubHost is the API host of GitHub or the host of a GitHub Enterprise Server
+// instance.
+func NewDefault(githubHost string) (Chain, error) {
+	chain := Chain{NewEnvAuth(githubHost)}
+
+	if keyring := NewKeyring(); keyring.Available() {
+		chain = append(chain, keyring)
+	}
+
+	tokens, err := NewTokenFile()
+	if err != nil {
+		return nil, err
+	}
+	chain = append(chain, tokens)
+
+	gh, err := NewGhAuth()
+	if err != nil {
+		return nil, err
+	}
+	chain = append(chain, gh)
+
+	cfg, err := NewConfig()
+	if err != nil {
+		// The netrc file is optional now that there are other sources
+		return chain, nil
+	}
+
+	netrcAuth, err := NewNetrc(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(chain, netrcAuth), nil
+}
+
+// configDir returns the directory of the configuration of an application, following the
+// XDG Base Directory Specification
+func configDir(home, app string) string {
+	if dir := os.Getenv(XDGConfigEnv); filepath.IsAbs(dir) {
+		return filepath.Join(dir, app)
+	}
+
+	return filepath.Join(home, ".config", app)
+}
+
+// webHost returns the web host of a GitHub API host, and other hosts as they are
+func webHost(host string) string {
+	if host == GitHubAPIHost {
+		return GitHubHost
+	}
+
+	return host
 }
 
 // Config represents a configuration structure passed to the Netrc object
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"os/user"
@@ -93,3 +94,36 @@ func TestAuthParseNetrcValidUser(t *testing.T) {
 
 	os.Remove(tempName)
 }
+
+// staticAuth has credentials for a single host
+type staticAuth struct {
+	host, username, password string
+}
+
+func (s *staticAuth) GetAuth(host string) (string, string, error) {
+	if host != s.host {
+		return "", "", errors.New("unknown host")
+	}
+
+	return s.username, s.password, nil
+}
+
+func TestChain(t *testing.T) {
+	chain := Chain{
+		&staticAuth{host: "github.com", password: "first"},
+		&staticAuth{host: "github.com", username: "user", password: "second"},
+		&staticAuth{host: "gitlab.com", username: "user", password: "third"},
+	}
+
+	_, password, err := chain.GetAuth("github.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "first", password)
+
+	username, password, err := chain.GetAuth("gitlab.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "third", password)
+
+	_, _, err = chain.GetAuth("codeberg.org")
+	assert.Error(t, err)
+}
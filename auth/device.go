@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// ClientIDEnv is the environment variable holding the client ID of the GitHub OAuth app
+	// stars login authorizes. The app needs the device flow enabled.
+	ClientIDEnv string = "STARS_OAUTH_CLIENT_ID"
+
+	// deviceGrantType is the grant type of the OAuth device flow, see RFC 8628
+	deviceGrantType string = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// slowDown is how much longer to wait between polls when GitHub asks to slow down
+	slowDown time.Duration = 5 * time.Second
+)
+
+// DefaultScopes are the OAuth scopes stars asks for: reading and starring repositories,
+// including private ones
+var DefaultScopes = []string{"repo", "read:user"}
+
+// ErrAccessDenied is returned when the user declines to authorize the app
+var ErrAccessDenied = errors.New("authorization was denied")
+
+// ErrExpired is returned when the user did not enter the code in time
+var ErrExpired = errors.New("the code expired before it was entered")
+
+// DeviceFlow authorizes an OAuth app through the device flow: the user enters a code shown
+// on the command line on the GitHub website, while the command line polls for the token
+type DeviceFlow struct {
+	// ClientID is the client ID of the OAuth app
+	ClientID string
+
+	// BaseURL is the web URL of GitHub, https://github.com if empty
+	BaseURL string
+
+	// Client sends the requests, http.DefaultClient if nil
+	Client *http.Client
+}
+
+// DeviceCode is the code the user enters to authorize the app
+type DeviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+
+	// ExpiresIn and Interval are in seconds: how long the code is valid, and how long to wait
+	// between polls
+	ExpiresIn int `json:"expires_in"`
+	Interval  int `json:"interval"`
+}
+
+// deviceResponse is the response of either endpoint of the device flow
+type deviceResponse struct {
+	DeviceCode
+	AccessToken      string `json:"access_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Start requests a code for the user to enter
+func (d *DeviceFlow) Start(ctx context.Context, scopes []string) (*DeviceCode, error) {
+	if d.ClientID == "" {
+		return nil, fmt.Errorf("no OAuth client ID given (set $%s)", ClientIDEnv)
+	}
+
+	resp, err := d.post(ctx, "/login/device/code", url.Values{
+		"client_id": {d.ClientID},
+		"scope":     {strings.Join(scopes, " ")},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("requesting a device code: %s", resp.describe())
+	}
+
+	return &resp.DeviceCode, nil
+}
+
+// Wait polls until the user entered the code and returns the token, or fails once the code
+// expired or was declined
+func (d *DeviceFlow) Wait(ctx context.Context, code *DeviceCode) (string, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+
+	for {
+		resp, err := d.post(ctx, "/login/oauth/access_token", url.Values{
+			"client_id":   {d.ClientID},
+			"device_code": {code.DeviceCode},
+			"grant_type":  {deviceGrantType},
+		})
+		if err != nil {
+			return "", err
+		}
+
+		switch resp.Error {
+		case "":
+			return resp.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += slowDown
+			if resp.Interval > 0 {
+				interval = time.Duration(resp.Interval) * time.Second
+			}
+		case "expired_token":
+			return "", ErrExpired
+		case "access_denied":
+			return "", ErrAccessDenied
+		default:
+			return "", fmt.Errorf("waiting for authorization: %s", resp.describe())
+		}
+
+		if code.ExpiresIn > 0 && time.Now().Add(interval).After(deadline) {
+			return "", ErrExpired
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// post sends a form to an endpoint of the device flow and decodes the JSON response
+func (d *DeviceFlow) post(ctx context.Context, path string, form url.Values) (*deviceResponse, error) {
+	base := d.BaseURL
+	if base == "" {
+		base = "https://" + GitHubHost
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(base, "/")+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with %s", path, resp.Status)
+	}
+
+	decoded := &deviceResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(decoded); err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
+
+// describe returns the error of a response with its description, if any
+func (r *deviceResponse) describe() string {
+	if r.ErrorDescription != "" {
+		return r.Error + ": " + r.ErrorDescription
+	}
+
+	return r.Error
+}
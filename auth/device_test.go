@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newDeviceServer returns a server implementing the device flow that answers polls with the
+// given errors, in order, before handing out a token
+func newDeviceServer(t *testing.T, errs ...string) *httptest.Server {
+	polls := 0
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client", r.Form.Get("client_id"))
+
+		switch r.URL.Path {
+		case "/login/device/code":
+			assert.Equal(t, "repo read:user", r.Form.Get("scope"))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"device_code":      "device",
+				"user_code":        "ABCD-1234",
+				"verification_uri": "https://github.com/login/device",
+				"expires_in":       900,
+				"interval":         0,
+			})
+		case "/login/oauth/access_token":
+			assert.Equal(t, "device", r.Form.Get("device_code"))
+			assert.Equal(t, deviceGrantType, r.Form.Get("grant_type"))
+
+			if polls < len(errs) {
+				polls++
+				json.NewEncoder(w).Encode(map[string]string{"error": errs[polls-1]})
+				return
+			}
+
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "gho_secret", "token_type": "bearer"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestDeviceFlow(t *testing.T) {
+	server := newDeviceServer(t, "authorization_pending", "authorization_pending")
+	defer server.Close()
+
+	flow := &DeviceFlow{ClientID: "client", BaseURL: server.URL}
+	code, err := flow.Start(context.Background(), DefaultScopes)
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCD-1234", code.UserCode)
+	assert.Equal(t, "https://github.com/login/device", code.VerificationURI)
+
+	token, err := flow.Wait(context.Background(), code)
+	assert.NoError(t, err)
+	assert.Equal(t, "gho_secret", token)
+}
+
+func TestDeviceFlowErrors(t *testing.T) {
+	testCases := []struct {
+		err      string
+		expected error
+	}{
+		{err: "access_denied", expected: ErrAccessDenied},
+		{err: "expired_token", expected: ErrExpired},
+	}
+
+	for _, tc := range testCases {
+		server := newDeviceServer(t, tc.err)
+
+		flow := &DeviceFlow{ClientID: "client", BaseURL: server.URL}
+		code, err := flow.Start(context.Background(), DefaultScopes)
+		assert.NoError(t, err)
+
+		_, err = flow.Wait(context.Background(), code)
+		assert.Equal(t, tc.expected, err)
+
+		server.Close()
+	}
+
+	_, err := (&DeviceFlow{}).Start(context.Background(), DefaultScopes)
+	assert.Error(t, err)
+}
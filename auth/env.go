@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	// GitHubTokenEnv is the environment variable holding a token for github.com
+	GitHubTokenEnv string = "GITHUB_TOKEN"
+
+	// GhTokenEnv is the environment variable the gh CLI reads a token for github.com from,
+	// used if GITHUB_TOKEN is not set
+	GhTokenEnv string = "GH_TOKEN"
+
+	// GitHubEnterpriseTokenEnv is the environment variable holding a token for a GitHub
+	// Enterprise Server instance
+	GitHubEnterpriseTokenEnv string = "GITHUB_ENTERPRISE_TOKEN"
+
+	// GhEnterpriseTokenEnv is the environment variable the gh CLI reads a token for a GitHub
+	// Enterprise Server instance from, used if GITHUB_ENTERPRISE_TOKEN is not set
+	GhEnterpriseTokenEnv string = "GH_ENTERPRISE_TOKEN"
+)
+
+// EnvAuth reads GitHub tokens from the environment, like the gh CLI and GitHub Actions
+type EnvAuth struct {
+	// Enterprise is the host of the GitHub Enterprise Server instance the enterprise tokens
+	// are for, if any. Tokens are never handed out for other hosts, e.g. GitLab ones.
+	Enterprise string
+}
+
+// NewEnvAuth returns the environment auth for the given GitHub API host or GitHub
+// Enterprise Server host
+func NewEnvAuth(githubHost string) *EnvAuth {
+	if githubHost == GitHubAPIHost || githubHost == GitHubHost {
+		return &EnvAuth{}
+	}
+
+	return &EnvAuth{Enterprise: githubHost}
+}
+
+// GetAuth returns the token for a host from the environment. The username is left empty,
+// as tokens identify the user on their own.
+func (e *EnvAuth) GetAuth(host string) (string, string, error) {
+	names := []string{}
+	switch {
+	case webHost(host) == GitHubHost:
+		names = []string{GitHubTokenEnv, GhTokenEnv}
+	case e.Enterprise != "" && host == e.Enterprise:
+		names = []string{GitHubEnterpriseTokenEnv, GhEnterpriseTokenEnv}
+	}
+
+	for _, name := range names {
+		if token := os.Getenv(name); token != "" {
+			return "", token, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("no token for %s in the environment", host)
+}
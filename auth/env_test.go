@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvAuth(t *testing.T) {
+	for _, name := range []string{GitHubTokenEnv, GhTokenEnv, GitHubEnterpriseTokenEnv, GhEnterpriseTokenEnv} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Unsetenv(name)
+	}
+
+	_, _, err := NewEnvAuth(GitHubAPIHost).GetAuth(GitHubAPIHost)
+	assert.Error(t, err)
+
+	os.Setenv(GhTokenEnv, "gh-token")
+	_, token, err := NewEnvAuth(GitHubAPIHost).GetAuth(GitHubAPIHost)
+	assert.NoError(t, err)
+	assert.Equal(t, "gh-token", token)
+
+	os.Setenv(GitHubTokenEnv, "github-token")
+	_, token, err = NewEnvAuth(GitHubAPIHost).GetAuth(GitHubAPIHost)
+	assert.NoError(t, err)
+	assert.Equal(t, "github-token", token)
+
+	// Tokens are not handed out to other forges
+	_, _, err = NewEnvAuth(GitHubAPIHost).GetAuth("gitlab.com")
+	assert.Error(t, err)
+
+	os.Setenv(GhEnterpriseTokenEnv, "enterprise-token")
+	_, token, err = NewEnvAuth("ghe.example.com").GetAuth("ghe.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "enterprise-token", token)
+
+	_, _, err = NewEnvAuth("ghe.example.com").GetAuth("gitlab.com")
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// GhConfigDirEnv is the environment variable overriding the configuration directory of
+	// the gh CLI
+	GhConfigDirEnv string = "GH_CONFIG_DIR"
+
+	// GhHostsFile is the file the gh CLI keeps its credentials in
+	GhHostsFile string = "hosts.yml"
+)
+
+// ghHost is the entry of a host in the hosts file of the gh CLI
+type ghHost struct {
+	User       string `yaml:"user"`
+	OAuthToken string `yaml:"oauth_token"`
+}
+
+// GhAuth reads the credentials the gh CLI stored with gh auth login
+type GhAuth struct {
+	// ConfigDir is the configuration directory of the gh CLI
+	ConfigDir string
+
+	// Token, if set, returns the token of a host the hosts file has no token for. Recent
+	// versions of the gh CLI keep tokens in the system keyring instead.
+	Token func(host string) (string, error)
+}
+
+// NewGhAuth returns the auth of the gh CLI of the current user
+func NewGhAuth() (*GhAuth, error) {
+	dir := os.Getenv(GhConfigDirEnv)
+	if dir == "" {
+		curUser, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		dir = configDir(curUser.HomeDir, "gh")
+	}
+
+	return &GhAuth{ConfigDir: dir, Token: ghAuthToken}, nil
+}
+
+// GetAuth returns the user and token the gh CLI stored for a host
+func (g *GhAuth) GetAuth(host string) (string, string, error) {
+	host = webHost(host)
+
+	data, err := ioutil.ReadFile(filepath.Join(g.ConfigDir, GhHostsFile))
+	if err != nil {
+		return "", "", err
+	}
+
+	hosts := map[string]ghHost{}
+	if err := yaml.Unmarshal(data, &hosts); err != nil {
+		return "", "", fmt.Errorf("reading the gh hosts file: %v", err)
+	}
+
+	entry, ok := hosts[host]
+	if !ok {
+		return "", "", fmt.Errorf("gh is not logged in to %s", host)
+	}
+
+	if entry.OAuthToken == "" && g.Token != nil {
+		token, err := g.Token(host)
+		if err != nil {
+			return "", "", err
+		}
+
+		entry.OAuthToken = token
+	}
+
+	if entry.OAuthToken == "" {
+		return "", "", fmt.Errorf("gh has no token for %s", host)
+	}
+
+	return entry.User, entry.OAuthToken, nil
+}
+
+// ghAuthToken asks the gh CLI for the token of a host
+func ghAuthToken(host string) (string, error) {
+	path, err := exec.LookPath("gh")
+	if err != nil {
+		return "", err
+	}
+
+	stdout := &bytes.Buffer{}
+	cmd := exec.Command(path, "auth", "token", "--hostname", host)
+	cmd.Stdout = stdout
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("gh auth token: %v", err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
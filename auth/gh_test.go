@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// GhHosts is a hosts file of the gh CLI, with the token of one host in the keyring
+var GhHosts = `
+github.com:
+    user: octocat
+    oauth_token: gho_secret
+    git_protocol: https
+ghe.example.com:
+    user: monalisa
+    git_protocol: ssh
+`
+
+func TestGhAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gh")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	gh := &GhAuth{ConfigDir: dir}
+
+	// gh is not set up
+	_, _, err = gh.GetAuth(GitHubAPIHost)
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, GhHostsFile), []byte(GhHosts), 0600))
+
+	user, token, err := gh.GetAuth(GitHubAPIHost)
+	assert.NoError(t, err)
+	assert.Equal(t, "octocat", user)
+	assert.Equal(t, "gho_secret", token)
+
+	_, _, err = gh.GetAuth("ghe.example.com")
+	assert.Error(t, err)
+
+	_, _, err = gh.GetAuth("gitlab.com")
+	assert.Error(t, err)
+
+	gh.Token = func(host string) (string, error) {
+		if host == "ghe.example.com" {
+			return "keyring-secret", nil
+		}
+
+		return "", errors.New("not logged in")
+	}
+
+	user, token, err = gh.GetAuth("ghe.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "monalisa", user)
+	assert.Equal(t, "keyring-secret", token)
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+)
+
+// KeyringService is the service tokens are stored under in the keyring
+const KeyringService string = "stars"
+
+// ErrKeyringUnavailable is returned when the operating system has no keyring stars can use
+var ErrKeyringUnavailable = errors.New("no keyring is available on this system")
+
+// errKeyringNotFound is returned by keyring backends for missing secrets
+var errKeyringNotFound = errors.New("not found in the keyring")
+
+// TokenStore keeps tokens obtained by stars login or given by the user, keyed by host
+type TokenStore interface {
+	Interface
+
+	// SetToken stores the user and token for a host, replacing any stored before
+	SetToken(host, username, token string) error
+
+	// DeleteToken removes the token stored for a host, if any
+	DeleteToken(host string) error
+}
+
+// keyringBackend stores secrets in the credential store of an operating system
+type keyringBackend interface {
+	get(service, account string) (string, error)
+	set(service, account, label, secret string) error
+	delete(service, account string) error
+}
+
+// Keyring keeps tokens in the credential store of the operating system: the Keychain on
+// macOS, the Credential Manager on Windows and the Secret Service (e.g. GNOME Keyring or
+// KWallet) elsewhere, through secret-tool. Tokens are stored per host; usernames are not
+// stored, as tokens identify the user on their own.
+type Keyring struct {
+	backend keyringBackend
+}
+
+// NewKeyring returns the keyring of the operating system
+func NewKeyring() *Keyring {
+	return &Keyring{backend: systemKeyring()}
+}
+
+// Available reports whether the keyring can be used on this system
+func (k *Keyring) Available() bool {
+	return k.backend != nil
+}
+
+// GetAuth returns the token stored for a host
+func (k *Keyring) GetAuth(host string) (string, string, error) {
+	if !k.Available() {
+		return "", "", ErrKeyringUnavailable
+	}
+
+	token, err := k.backend.get(KeyringService, host)
+	if err == errKeyringNotFound || (err == nil && token == "") {
+		return "", "", fmt.Errorf("no token for %s in the keyring", host)
+	} else if err != nil {
+		return "", "", err
+	}
+
+	return "", token, nil
+}
+
+// SetToken stores the token for a host, replacing any stored before
+func (k *Keyring) SetToken(host, username, token string) error {
+	if !k.Available() {
+		return ErrKeyringUnavailable
+	}
+
+	return k.backend.set(KeyringService, host, fmt.Sprintf("stars token for %s", host), token)
+}
+
+// DeleteToken removes the token stored for a host, if any
+func (k *Keyring) DeleteToken(host string) error {
+	if !k.Available() {
+		return ErrKeyringUnavailable
+	}
+
+	if err := k.backend.delete(KeyringService, host); err != nil && err != errKeyringNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// NewTokenStore returns where new tokens are stored: the keyring if there is one, and the
+// tokens file otherwise
+func NewTokenStore() (TokenStore, error) {
+	if keyring := NewKeyring(); keyring.Available() {
+		return keyring, nil
+	}
+
+	return NewTokenFile()
+}
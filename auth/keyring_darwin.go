@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// errSecItemNotFound is the exit status of security(1) for missing items
+const errSecItemNotFound = 44
+
+// keychain stores secrets in the macOS Keychain through security(1)
+type keychain struct{}
+
+// systemKeyring returns the Keychain, if security(1) is available
+func systemKeyring() keyringBackend {
+	if _, err := exec.LookPath("security"); err != nil {
+		return nil
+	}
+
+	return &keychain{}
+}
+
+func (k *keychain) get(service, account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		return "", keychainError(err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// set passes the secret on stdin through the interactive mode of security(1), so that it
+// never shows up in the list of processes
+func (k *keychain) set(service, account, label, secret string) error {
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf(
+		"add-generic-password -U -s %s -a %s -l %s -w %s\n",
+		strconv.Quote(service), strconv.Quote(account), strconv.Quote(label), strconv.Quote(secret),
+	))
+
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("storing in the keychain: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// The interactive mode reports failing commands on stderr only
+	if stderr.Len() > 0 {
+		return fmt.Errorf("storing in the keychain: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+func (k *keychain) delete(service, account string) error {
+	if err := exec.Command("security", "delete-generic-password", "-s", service, "-a", account).Run(); err != nil {
+		return keychainError(err)
+	}
+
+	return nil
+}
+
+// keychainError maps the exit status of security(1) for missing items to errKeyringNotFound
+func keychainError(err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == errSecItemNotFound {
+		return errKeyringNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,9 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package auth
+
+// systemKeyring returns no keyring, as there is none stars can use on this platform
+func systemKeyring() keyringBackend {
+	return nil
+}
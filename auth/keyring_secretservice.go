@@ -0,0 +1,56 @@
+//go:build dragonfly || freebsd || linux || netbsd || openbsd
+// +build dragonfly freebsd linux netbsd openbsd
+
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretService stores secrets through the Secret Service API with secret-tool(1), which
+// comes with libsecret
+type secretService struct{}
+
+// systemKeyring returns the Secret Service, if secret-tool(1) is available
+func systemKeyring() keyringBackend {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return nil
+	}
+
+	return &secretService{}
+}
+
+func (s *secretService) get(service, account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "account", account).Output()
+	if err != nil {
+		// secret-tool fails without output for missing secrets
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) == 0 {
+			return "", errKeyringNotFound
+		}
+
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// set passes the secret on stdin, so that it never shows up in the list of processes
+func (s *secretService) set(service, account, label, secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label", label, "service", service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("storing in the keyring: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+func (s *secretService) delete(service, account string) error {
+	return exec.Command("secret-tool", "clear", "service", service, "account", account).Run()
+}
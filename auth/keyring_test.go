@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryKeyring keeps secrets in memory
+type memoryKeyring map[string]string
+
+func (m memoryKeyring) get(service, account string) (string, error) {
+	secret, ok := m[service+":"+account]
+	if !ok {
+		return "", errKeyringNotFound
+	}
+
+	return secret, nil
+}
+
+func (m memoryKeyring) set(service, account, label, secret string) error {
+	m[service+":"+account] = secret
+	return nil
+}
+
+func (m memoryKeyring) delete(service, account string) error {
+	if _, ok := m[service+":"+account]; !ok {
+		return errKeyringNotFound
+	}
+
+	delete(m, service+":"+account)
+	return nil
+}
+
+func TestKeyring(t *testing.T) {
+	backend := memoryKeyring{}
+	keyring := &Keyring{backend: backend}
+	assert.True(t, keyring.Available())
+
+	_, _, err := keyring.GetAuth(GitHubAPIHost)
+	assert.Error(t, err)
+
+	assert.NoError(t, keyring.SetToken(GitHubAPIHost, "octocat", "gho_old"))
+	assert.NoError(t, keyring.SetToken(GitHubAPIHost, "octocat", "gho_new"))
+	assert.Equal(t, "gho_new", backend[KeyringService+":"+GitHubAPIHost])
+
+	_, token, err := keyring.GetAuth(GitHubAPIHost)
+	assert.NoError(t, err)
+	assert.Equal(t, "gho_new", token)
+
+	assert.NoError(t, keyring.DeleteToken(GitHubAPIHost))
+	assert.NoError(t, keyring.DeleteToken(GitHubAPIHost))
+
+	_, _, err = keyring.GetAuth(GitHubAPIHost)
+	assert.Error(t, err)
+}
+
+func TestKeyringUnavailable(t *testing.T) {
+	keyring := &Keyring{}
+	assert.False(t, keyring.Available())
+
+	_, _, err := keyring.GetAuth(GitHubAPIHost)
+	assert.Equal(t, ErrKeyringUnavailable, err)
+	assert.Equal(t, ErrKeyringUnavailable, keyring.SetToken(GitHubAPIHost, "", "gho_secret"))
+	assert.Equal(t, ErrKeyringUnavailable, keyring.DeleteToken(GitHubAPIHost))
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	// credTypeGeneric and credPersistLocalMachine are CRED_TYPE_GENERIC and
+	// CRED_PERSIST_LOCAL_MACHINE of wincred.h
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+var (
+	advapi32       = windows.NewLazySystemDLL("advapi32.dll")
+	procCredRead   = advapi32.NewProc("CredReadW")
+	procCredWrite  = advapi32.NewProc("CredWriteW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+// credential is CREDENTIALW of wincred.h
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// credentialManager stores secrets as generic credentials in the Windows Credential Manager
+type credentialManager struct{}
+
+// systemKeyring returns the Credential Manager
+func systemKeyring() keyringBackend {
+	if procCredRead.Find() != nil {
+		return nil
+	}
+
+	return &credentialManager{}
+}
+
+// target returns the name of the credential of an account
+func target(service, account string) (*uint16, error) {
+	return windows.UTF16PtrFromString(service + ":" + account)
+}
+
+func (c *credentialManager) get(service, account string) (string, error) {
+	name, err := target(service, account)
+	if err != nil {
+		return "", err
+	}
+
+	var cred *credential
+	r, _, err := procCredRead.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		return "", credentialError(err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	if cred.CredentialBlobSize == 0 {
+		return "", nil
+	}
+
+	blob := (*[1 << 20]byte)(unsafe.Pointer(cred.CredentialBlob))[:cred.CredentialBlobSize:cred.CredentialBlobSize]
+	return string(blob), nil
+}
+
+func (c *credentialManager) set(service, account, label, secret string) error {
+	name, err := target(service, account)
+	if err != nil {
+		return err
+	}
+
+	comment, err := windows.UTF16PtrFromString(label)
+	if err != nil {
+		return err
+	}
+
+	user, err := windows.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(secret)
+	cred := &credential{
+		Type:               credTypeGeneric,
+		TargetName:         name,
+		Comment:            comment,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+
+	if r, _, err := procCredWrite.Call(uintptr(unsafe.Pointer(cred)), 0); r == 0 {
+		return err
+	}
+
+	return nil
+}
+
+func (c *credentialManager) delete(service, account string) error {
+	name, err := target(service, account)
+	if err != nil {
+		return err
+	}
+
+	if r, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0); r == 0 {
+		return credentialError(err)
+	}
+
+	return nil
+}
+
+// credentialError maps ERROR_NOT_FOUND to errKeyringNotFound
+func credentialError(err error) error {
+	if errno, ok := err.(syscall.Errno); ok && errno == syscall.Errno(windows.ERROR_NOT_FOUND) {
+		return errKeyringNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// TokensFile is the file stars login stores tokens in, within the stars configuration
+// directory
+const TokensFile string = "tokens.yml"
+
+// storedToken is the entry of a host in the tokens file
+type storedToken struct {
+	User  string `yaml:"user,omitempty"`
+	Token string `yaml:"token"`
+}
+
+// TokenFile keeps the tokens obtained by stars login in a file only the user can read,
+// keyed by host
+type TokenFile struct {
+	Path string
+}
+
+// NewTokenFile returns the tokens file of the current user
+func NewTokenFile() (*TokenFile, error) {
+	curUser, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenFile{Path: filepath.Join(configDir(curUser.HomeDir, "stars"), TokensFile)}, nil
+}
+
+// read returns the stored tokens, none if the file does not exist
+func (t *TokenFile) read() (map[string]storedToken, error) {
+	tokens := map[string]storedToken{}
+
+	data, err := ioutil.ReadFile(t.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return tokens, nil
+		}
+
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", t.Path, err)
+	}
+
+	return tokens, nil
+}
+
+// write replaces the stored tokens
+func (t *TokenFile) write(tokens map[string]storedToken) error {
+	data, err := yaml.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.Path), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(t.Path, data, 0600)
+}
+
+// GetAuth returns the user and token stored for a host
+func (t *TokenFile) GetAuth(host string) (string, string, error) {
+	tokens, err := t.read()
+	if err != nil {
+		return "", "", err
+	}
+
+	stored, ok := tokens[host]
+	if !ok || stored.Token == "" {
+		return "", "", fmt.Errorf("no token stored for %s", host)
+	}
+
+	return stored.User, stored.Token, nil
+}
+
+// SetToken stores the user and token for a host, replacing any stored before
+func (t *TokenFile) SetToken(host, username, token string) error {
+	tokens, err := t.read()
+	if err != nil {
+		return err
+	}
+
+	tokens[host] = storedToken{User: username, Token: token}
+
+	return t.write(tokens)
+}
+
+// DeleteToken removes the token stored for a host, if any
+func (t *TokenFile) DeleteToken(host string) error {
+	tokens, err := t.read()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := tokens[host]; !ok {
+		return nil
+	}
+
+	delete(tokens, host)
+
+	return t.write(tokens)
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stars")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tokens := &TokenFile{Path: filepath.Join(dir, "stars", TokensFile)}
+
+	_, _, err = tokens.GetAuth(GitHubAPIHost)
+	assert.Error(t, err)
+
+	assert.NoError(t, tokens.SetToken(GitHubAPIHost, "octocat", "gho_secret"))
+	assert.NoError(t, tokens.SetToken("ghe.example.com", "", "ghe_secret"))
+
+	info, err := os.Stat(tokens.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	user, token, err := tokens.GetAuth(GitHubAPIHost)
+	assert.NoError(t, err)
+	assert.Equal(t, "octocat", user)
+	assert.Equal(t, "gho_secret", token)
+
+	assert.NoError(t, tokens.DeleteToken(GitHubAPIHost))
+	assert.NoError(t, tokens.DeleteToken(GitHubAPIHost))
+
+	_, _, err = tokens.GetAuth(GitHubAPIHost)
+	assert.Error(t, err)
+
+	_, token, err = tokens.GetAuth("ghe.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "ghe_secret", token)
+}
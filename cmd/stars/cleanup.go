@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gkze/stars/starmanager"
+	"github.com/spf13/cobra"
+)
+
+// cleanupFlags are the flags selecting the stars to remove, shared by the commands that
+// list or remove them
+type cleanupFlags struct {
+	months     int
+	byStarred  bool
+	archived   bool
+	gone       bool
+	forks      bool
+	scoreBelow int
+	rulesFile  string
+	keepDeps   []string
+}
+
+// register adds the flags to a command. The help of every flag is prefixed with the given
+// condition, e.g. "With --cleanup, " for commands that only select stars to remove with it.
+func (f *cleanupFlags) register(cmd *cobra.Command, condition string) {
+	help := func(s string) string {
+		if condition == "" {
+			return s
+		}
+
+		return condition + strings.ToLower(s[:1]) + s[1:]
+	}
+
+	flags := cmd.PersistentFlags()
+	flags.IntVarP(&f.months, "months", "m", 2, help("Number of months to delete projects older than (0 for any age; not applied with --include-gone, --include-forks or --score-below unless given)"))
+	flags.IntVar(&f.scoreBelow, "score-below", 0, help("Include stars with a health score below this (see health)"))
+	flags.BoolVarP(&f.byStarred, "by-starred", "s", false, help("Measure age by when projects were starred instead of last pushed"))
+	flags.BoolVarP(&f.archived, "include-archived", "a", false, help("Include archived stars"))
+	flags.BoolVarP(&f.forks, "include-forks", "f", false, help("Include forks whose upstream is starred too (see duplicates)"))
+	flags.BoolVarP(&f.gone, "include-gone", "g", false, help("Include stars of repositories that no longer exist (see reconcile)"))
+	flags.StringSliceVar(&f.keepDeps, "keep-deps", nil, help("Keep stars that are dependencies in these go.mod, package.json or requirements.txt files"))
+	flags.StringVar(&f.rulesFile, "rules", "", help("Select stars to remove with the rules in this YAML file instead of --months and --include-archived"))
+}
+
+// options returns the cleanup options the flags of a command select
+func (f *cleanupFlags) options(ctx context.Context, cmd *cobra.Command, sm *starmanager.StarManager) (starmanager.CleanupOptions, error) {
+	opts := starmanager.CleanupOptions{
+		Months:     cleanupMonths(cmd, f.months, f.gone || f.forks || f.scoreBelow > 0),
+		ByStarred:  f.byStarred,
+		Archived:   f.archived,
+		Gone:       f.gone,
+		Forks:      f.forks,
+		ScoreBelow: f.scoreBelow,
+	}
+
+	if f.rulesFile != "" {
+		policy, err := starmanager.LoadPolicy(f.rulesFile)
+		if err != nil {
+			return opts, err
+		}
+
+		opts.Policy = policy
+	}
+
+	if len(f.keepDeps) > 0 {
+		deps, err := readManifests(f.keepDeps)
+		if err != nil {
+			return opts, err
+		}
+
+		report, err := sm.CrossReference(ctx, deps)
+		if err != nil {
+			return opts, err
+		}
+
+		for _, star := range report.Starred {
+			opts.Keep = append(opts.Keep, star.URL)
+		}
+	}
+
+	return opts, nil
+}
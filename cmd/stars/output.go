@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+)
+
+// output is how commands write their results. By default results are written as tables
+// and summaries for people to read; with --json or --ndjson they are written as JSON for
+// other programs instead, while logs keep going to stderr.
+type output struct {
+	w io.Writer
+
+	// json writes every result as a single indented JSON document
+	json bool
+
+	// ndjson writes every element of a list on its own line, so that long listings can be
+	// processed as they are read
+	ndjson bool
+}
+
+// structured reports whether results are written as JSON
+func (o *output) structured() bool {
+	return o.json || o.ndjson
+}
+
+// write writes a result as JSON. Lists are written as arrays even when empty, or one
+// element per line with --ndjson.
+func (o *output) write(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	isList := rv.Kind() == reflect.Slice
+
+	if o.ndjson {
+		enc := json.NewEncoder(o.w)
+		if !isList {
+			return enc.Encode(v)
+		}
+
+		for i := 0; i < rv.Len(); i++ {
+			if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if isList && rv.IsNil() {
+		v = []interface{}{}
+	}
+
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
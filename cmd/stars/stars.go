@@ -1,24 +1,380 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"text/tabwriter"
+	"time"
 
+	"github.com/gkze/stars/auth"
+	"github.com/gkze/stars/server"
 	"github.com/gkze/stars/starmanager"
+	"github.com/gkze/stars/tui"
+	"github.com/gkze/stars/utils"
 	"github.com/pkg/browser"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // Version is version information dynamically injected at build time
 var Version string
 
-func main() {
-	sm, err := starmanager.New()
+// activityWidth is the number of characters used to render commit activity sparklines
+const activityWidth = 13
+
+// showColumns returns the columns of a row of the "show" listing for the given star, or the
+// header row if star is nil. The language column is omitted when filtering by language.
+func showColumns(language string, withActivity bool, star *starmanager.Star) []string {
+	if star == nil {
+		cols := []string{"PUSHED", "STARS"}
+		if language == "" {
+			cols = append(cols, "LANGUAGE")
+		}
+		if withActivity {
+			cols = append(cols, "ACTIVITY")
+		}
+
+		return append(cols, "URL", "DESCRIPTION")
+	}
+
+	cols := []string{star.PushedAt.String(), strconv.Itoa(star.Stargazers)}
+	if language == "" {
+		cols = append(cols, star.Language)
+	}
+	if withActivity {
+		cols = append(cols, utils.Sparkline(star.Activity, activityWidth))
+	}
+
+	return append(cols, star.URL, star.Description)
+}
+
+// printCandidates writes a table of the stars a cleanup matched and why
+func printCandidates(candidates []*starmanager.CleanupCandidate) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
+	fmt.Fprintln(w, "URL\tREASONS")
+
+	for _, c := range candidates {
+		fmt.Fprintf(w, "%s\t%s\n", c.Star.URL, strings.Join(c.Reasons, ", "))
+	}
+
+	return w.Flush()
+}
+
+// cleanupMonths returns the age criterion of a cleanup. --include-gone, --include-forks and
+// --score-below select stars on their own, so the default of --months only applies without
+// them; 0 means no age criterion.
+func cleanupMonths(cmd *cobra.Command, months int, standalone bool) int {
+	if standalone && !cmd.Flags().Changed("months") {
+		return 0
+	}
+
+	return months
+}
+
+// chartWidth is the number of characters used to render the longest bar of a stats chart
+const chartWidth = 30
+
+// printBreakdown writes a table of counts under a heading, optionally with bar charts
+func printBreakdown(w *tabwriter.Writer, heading string, counts []starmanager.KV, top int, chart bool) {
+	if len(counts) == 0 {
+		return
+	}
+
+	if top > 0 && len(counts) > top {
+		counts = counts[:top]
+	}
+
+	max := 0
+	for _, pair := range counts {
+		if pair.Value > max {
+			max = pair.Value
+		}
+	}
+
+	fmt.Fprintf(w, "\n%s\tCOUNT\n", heading)
+	for _, pair := range counts {
+		if chart {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", pair.Key, pair.Value, utils.Bar(pair.Value, max, chartWidth))
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%d\n", pair.Key, pair.Value)
+	}
+}
+
+// printDiff writes what changed between two syncs
+func printDiff(diff *starmanager.SyncDiff) {
+	if diff.Empty() {
+		fmt.Printf("Nothing changed since %s\n", diff.Since.Format("2006-01-02 15:04"))
+		return
+	}
+
+	renamed := []string{}
+	for _, r := range diff.Renamed {
+		renamed = append(renamed, r.From+" -> "+r.To)
+	}
+
+	sections := []struct {
+		heading string
+		urls    []string
+	}{
+		{"Newly starred", diff.Starred},
+		{"Removed (unstarred or deleted)", diff.Removed},
+		{"Archived", diff.Archived},
+		{"Renamed or moved", renamed},
+	}
+
+	fmt.Printf("Changes since %s:\n", diff.Since.Format("2006-01-02 15:04"))
+	for _, section := range sections {
+		if len(section.urls) == 0 {
+			continue
+		}
+
+		fmt.Printf("\n%s (%d):\n", section.heading, len(section.urls))
+		for _, url := range section.urls {
+			fmt.Printf("  %s\n", url)
+		}
+	}
+}
+
+// printDetail writes the detail view of a single star
+func printDetail(detail *starmanager.StarDetail) error {
+	star := detail.Star
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(w, star.URL)
+	if star.Description != "" {
+		fmt.Fprintln(w, star.Description)
+	}
+	fmt.Fprintln(w)
+
+	row := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "%s\t%s\n", name, value)
+		}
+	}
+
+	status := []string{}
+	if !detail.Cached {
+		status = append(status, "not starred")
+	}
+	if star.Archived {
+		status = append(status, "archived")
+	}
+	if star.Gone {
+		status = append(status, "gone")
+	}
+	if detail.Annotation.Protected {
+		status = append(status, "pinned")
+	}
+
+	row("Status", strings.Join(status, ", "))
+	row("Fork of", star.Parent)
+	row("Language", star.Language)
+	row("License", star.License)
+	row("Stars", strconv.Itoa(star.Stargazers))
+	if !detail.FetchedAt.IsZero() {
+		row("Forks", strconv.Itoa(detail.Forks))
+	}
+	row("Open issues", strconv.Itoa(star.OpenIssues))
+	if star.LatestRelease != "" {
+		row("Latest release", fmt.Sprintf("%s (%s)", star.LatestRelease, star.ReleasedAt.Format("2006-01-02")))
+	}
+	if !star.PushedAt.IsZero() {
+		row("Last pushed", star.PushedAt.Format("2006-01-02"))
+	}
+	if !star.StarredAt.IsZero() {
+		row("Starred", star.StarredAt.Format("2006-01-02"))
+	}
+	row("Homepage", detail.Homepage)
+	row("Topics", strings.Join(star.Topics, ", "))
+	row("Lists", strings.Join(star.Lists, ", "))
+	row("Tags", strings.Join(detail.Annotation.Tags, ", "))
+	row("Notes", detail.Annotation.Notes)
+	if len(star.Activity) > 0 {
+		row("Activity", utils.Sparkline(star.Activity, activityWidth))
+	}
+
+	signals := []string{}
+	for _, signal := range detail.Signals {
+		signals = append(signals, signal.Detail)
+	}
+	health := strconv.Itoa(detail.Health)
+	if len(signals) > 0 {
+		health += " (" + strings.Join(signals, ", ") + ")"
+	}
+	row("Health", health)
+
+	if detail.Readme {
+		row("README", fmt.Sprintf("cached, see stars readme %s", star.URL))
+	}
+
+	if detail.FetchError != "" {
+		row("Not refreshed", detail.FetchError)
+	}
+
+	return w.Flush()
+}
+
+// confirm asks a yes / no question on stdin, defaulting to no
+func confirm(in *bufio.Reader, question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, _ := in.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+// loopbackAddr reports whether a listen address only accepts connections from this machine
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// traceToStderr registers a tracer provider writing every span to stderr as it ends, so that
+// the spans of the starmanager package can be inspected without a collector
+func traceToStderr() error {
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
 	if err != nil {
-		log.Printf("Error creating StarManager! %v", err.Error())
+		return err
+	}
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	return nil
+}
+
+// notifiers returns the notifiers described by specs, or by $STARS_NOTIFY if none are given.
+// Webhooks are called with the HTTP client of the StarManager.
+func notifiers(sm *starmanager.StarManager, specs []string) ([]starmanager.Notifier, error) {
+	if len(specs) == 0 && os.Getenv(starmanager.NotifyEnv) != "" {
+		specs = strings.Split(os.Getenv(starmanager.NotifyEnv), ",")
+	}
+
+	parsed := []starmanager.Notifier{}
+	for _, spec := range specs {
+		notifier, err := starmanager.ParseNotifier(strings.TrimSpace(spec))
+		if err != nil {
+			return nil, err
+		}
+
+		if slack, ok := notifier.(*starmanager.SlackNotifier); ok {
+			slack.Client = sm.HTTPClient
+		}
+
+		parsed = append(parsed, notifier)
+	}
+
+	return parsed, nil
+}
+
+// safetyBackup backs up the cache before a destructive operation, keeping the default
+// number of backups
+func safetyBackup(sm *starmanager.StarManager) error {
+	if _, err := sm.Backup(sm.BackupDir()); err != nil {
+		return fmt.Errorf("could not back up the cache: %v", err)
+	}
+
+	_, err := starmanager.PruneBackups(sm.BackupDir(), starmanager.DefaultBackupRetention)
+	return err
+}
+
+// withoutStarManager marks commands that run before there are credentials to create a
+// StarManager with
+const withoutStarManager string = "withoutStarManager"
+
+// readManifests reads the dependencies from every given go.mod, package.json or
+// requirements.txt file
+func readManifests(paths []string) ([]*starmanager.Dependency, error) {
+	deps := []*starmanager.Dependency{}
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		manifestDeps, err := starmanager.ParseManifest(f, path)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+
+		deps = append(deps, manifestDeps...)
+	}
+
+	return deps, nil
+}
+
+func main() {
+	// Cancel long running operations such as syncs on interrupt
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		log.Printf("Interrupted, stopping...")
+		cancel()
+	}()
+
+	var (
+		sm           *starmanager.StarManager
+		debugAddr    string
+		metricsAddr  string
+		trace        bool
+		host         string
+		cachePath    string
+		gitlabHosts  []string
+		giteaHosts   []string
+		graphql      bool
+		concurrency  int
+		proxy        string
+		timeout      time.Duration
+		retries      int
+		logRequests  bool
+		topicAliases map[string]string
+		out          = &output{w: os.Stdout}
+	)
+
+	// httpOptions configures the requests made to the providers
+	httpOptions := func() []starmanager.Option {
+		opts := []starmanager.Option{starmanager.WithRetries(retries)}
+		if proxy != "" {
+			opts = append(opts, starmanager.WithProxy(proxy))
+		}
+		if timeout > 0 {
+			opts = append(opts, starmanager.WithTimeout(timeout))
+		}
+		if logRequests {
+			opts = append(opts, starmanager.WithRequestLogging())
+		}
+
+		return opts
 	}
 
 	starsCmd := &cobra.Command{
@@ -26,47 +382,207 @@ func main() {
 		Short: "Stars is a command-line GitHub Stars manager",
 		Long: `A CLI written in Golang to facilitate efficient management of a user's
 GitHub starred projects / repositories, a.k.a. "Stars"`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// The debug and metrics endpoints are unauthenticated, stars serve guards them with
+			// its token instead
+			if debugAddr != "" && !loopbackAddr(debugAddr) {
+				return fmt.Errorf("--debug-addr serves unauthenticated endpoints and only accepts loopback addresses, not %s", debugAddr)
+			}
+			if metricsAddr != "" && !loopbackAddr(metricsAddr) {
+				return fmt.Errorf("--metrics-addr serves unauthenticated endpoints and only accepts loopback addresses, not %s", metricsAddr)
+			}
+
+			if debugAddr != "" {
+				go func() {
+					log.Printf("Serving debug endpoints on %s", debugAddr)
+					if err := http.ListenAndServe(debugAddr, server.DebugHandler()); err != nil {
+						log.Printf("Debug server stopped: %v", err.Error())
+					}
+				}()
+			}
+
+			if metricsAddr != "" {
+				go func() {
+					log.Printf("Serving metrics on http://%s/metrics", metricsAddr)
+					if err := http.ListenAndServe(metricsAddr, server.MetricsHandler()); err != nil {
+						log.Printf("Metrics server stopped: %v", err.Error())
+					}
+				}()
+			}
+
+			if trace {
+				if err := traceToStderr(); err != nil {
+					return err
+				}
+			}
+
+			if _, ok := cmd.Annotations[withoutStarManager]; ok {
+				return nil
+			}
+
+			opts := []starmanager.Option{}
+			if host != "" {
+				opts = append(opts, starmanager.WithHost(host))
+			}
+			if cachePath != "" {
+				opts = append(opts, starmanager.WithCachePath(cachePath))
+			}
+			for _, h := range gitlabHosts {
+				opts = append(opts, starmanager.WithGitLab(h))
+			}
+			for _, h := range giteaHosts {
+				opts = append(opts, starmanager.WithGitea(h))
+			}
+			if graphql {
+				opts = append(opts, starmanager.WithGraphQL())
+			}
+			if concurrency > 0 {
+				opts = append(opts, starmanager.WithConcurrency(concurrency))
+			}
+			if len(topicAliases) > 0 {
+				opts = append(opts, starmanager.WithTopicAliases(topicAliases))
+			}
+			opts = append(opts, httpOptions()...)
+
+			var err error
+			sm, err = starmanager.New(opts...)
+			if err != nil {
+				// Missing credentials or an unusable cache are no usage errors
+				cmd.SilenceUsage = true
+			}
+
+			return err
+		},
+		Annotations: map[string]string{withoutStarManager: ""},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cmd.Help()
 			return nil
 		},
 	}
 
+	starsCmd.PersistentFlags().StringVar(&host, "host", "", "GitHub API host, for GitHub Enterprise Server (default api.github.com, or $"+starmanager.HostEnv+")")
+	starsCmd.PersistentFlags().StringVar(&cachePath, "cache", "", "Path of the cache db file (default $"+starmanager.XDGCacheEnv+"/stars.db or ~/.cache/stars.db, or $"+starmanager.CacheEnv+")")
+	starsCmd.PersistentFlags().StringSliceVar(&gitlabHosts, "gitlab", nil, "Also sync stars from these self-hosted GitLab hosts")
+	starsCmd.PersistentFlags().StringSliceVar(&giteaHosts, "gitea", nil, "Also sync stars from these Gitea / Forgejo hosts")
+	starsCmd.PersistentFlags().BoolVar(&graphql, "graphql", false, "Fetch GitHub stars through the GraphQL API")
+	starsCmd.PersistentFlags().IntVarP(&concurrency, "concurrency", "j", starmanager.DefaultConcurrency, "Maximum number of concurrent requests")
+	starsCmd.PersistentFlags().StringToStringVar(&topicAliases, "topic-alias", nil, "Treat topics as others, e.g. golang=go (adds to the built-in aliases and $"+starmanager.TopicAliasesEnv+")")
+	starsCmd.PersistentFlags().StringVar(&proxy, "proxy", "", "Send requests through this proxy URL (default $HTTPS_PROXY)")
+	starsCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Give up on requests taking longer than this (e.g. 30s)")
+	starsCmd.PersistentFlags().IntVar(&retries, "retries", starmanager.DefaultHTTPRetries, "Number of times requests hitting a secondary rate limit are retried")
+	starsCmd.PersistentFlags().BoolVar(&logRequests, "log-requests", false, "Log every request, for debugging")
+	starsCmd.PersistentFlags().StringVar(&debugAddr, "debug-addr", "", "Serve pprof and runtime stats without authentication on this loopback address (e.g. localhost:6060)")
+	starsCmd.PersistentFlags().StringVar(&metricsAddr, "metrics-addr", "", "Serve Prometheus metrics under /metrics without authentication on this loopback address (e.g. localhost:9090)")
+	starsCmd.PersistentFlags().BoolVar(&trace, "trace", false, "Write OpenTelemetry spans of API calls, database writes and syncs to stderr")
+	starsCmd.PersistentFlags().BoolVar(&out.json, "json", false, "Write results as JSON to stdout, logs still go to stderr")
+	starsCmd.PersistentFlags().BoolVar(&out.ndjson, "ndjson", false, "Write results as newline-delimited JSON, one list element per line")
+
 	versionCmd := &cobra.Command{
-		Use:   "version",
-		Short: "Show version of stars",
-		Long:  "Displays the version of the currently running stars CLI binary",
+		Use:         "version",
+		Annotations: map[string]string{withoutStarManager: ""},
+		Short:       "Show version of stars",
+		Long:        "Displays the version of the currently running stars CLI binary",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if out.structured() {
+				return out.write(map[string]string{"version": Version})
+			}
+
 			fmt.Printf("stars version %s\n", Version)
 			return nil
 		},
 	}
 
+	var (
+		prune       bool
+		activity    bool
+		syncLangs   bool
+		releases    bool
+		readmes     bool
+		readmeFmt   string
+		syncLists   bool
+		incremental bool
+		resume      bool
+	)
+
 	saveAllStarsCmd := &cobra.Command{
 		Use:   "save",
 		Short: "Save all stars",
 		Long:  "Fetches all of the current user's starred projects to the local filesystem",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if _, err := sm.SaveAllStars(); err != nil {
+			result, err := sm.Sync(ctx, starmanager.SyncOptions{
+				Prune:        prune,
+				Activity:     activity,
+				Languages:    syncLangs,
+				Releases:     releases,
+				Readmes:      readmes,
+				ReadmeFormat: starmanager.ReadmeFormat(readmeFmt),
+				Lists:        syncLists,
+				Incremental:  incremental,
+				Resume:       resume,
+			})
+			if err != nil {
 				return err
 			}
 
-			return nil
+			if out.structured() {
+				if err := out.write(result); err != nil {
+					return err
+				}
+
+				return result.Err()
+			}
+
+			fmt.Printf(
+				"%d added, %d updated, %d removed, %d failed, %d pages unchanged, %d pages resumed in %s\n",
+				result.Added,
+				result.Updated,
+				result.Removed,
+				result.Failed,
+				result.Unchanged,
+				result.Resumed,
+				result.Duration.Round(time.Millisecond),
+			)
+
+			if result.Diff != nil && !result.Diff.Empty() {
+				fmt.Println()
+				printDiff(result.Diff)
+			}
+
+			return result.Err()
 		},
 	}
 
+	saveAllStarsCmd.PersistentFlags().BoolVarP(&prune, "prune", "p", false, "Remove cached stars that are no longer starred")
+	saveAllStarsCmd.PersistentFlags().BoolVarP(&incremental, "incremental", "i", false, "Only fetch pages that changed since the last sync")
+	saveAllStarsCmd.PersistentFlags().BoolVarP(&resume, "resume", "r", false, "Resume an interrupted sync, skipping pages it already saved (GraphQL syncs start over)")
+	saveAllStarsCmd.PersistentFlags().BoolVarP(&activity, "activity", "a", false, "Also fetch weekly commit activity (slow, one request per star)")
+	saveAllStarsCmd.PersistentFlags().BoolVar(&syncLangs, "languages", false, "Also fetch the full language breakdown (slow, one request per star)")
+	saveAllStarsCmd.PersistentFlags().BoolVar(&releases, "releases", false, "Also fetch the latest release (slow, one request per star)")
+	saveAllStarsCmd.PersistentFlags().BoolVar(&readmes, "readmes", false, "Also download READMEs for offline reading (slow, one request per changed star)")
+	saveAllStarsCmd.PersistentFlags().StringVar(&readmeFmt, "readme-format", string(starmanager.ReadmeMarkdown), "Format to download READMEs in: markdown or html")
+	saveAllStarsCmd.PersistentFlags().BoolVarP(&syncLists, "lists", "L", false, "Also sync the GitHub lists stars are organized in")
+
 	topicsCmd := &cobra.Command{
 		Use:   "topics",
 		Short: "List all topics of all stars",
 		Long:  "Displays a list of topics, sorted by occurrece count, for all of a user's starred projects",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := sm.SaveIfEmpty(); err != nil {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			topics, err := sm.GetTopics(ctx)
+			if err != nil {
 				return err
 			}
 
+			if out.structured() {
+				return out.write(topics)
+			}
+
 			w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
 
-			for i, pair := range sm.GetTopics() {
+			for i, pair := range topics {
 				if i == 0 {
 					fmt.Fprintf(w, "TOPIC\tOCCURRENCES\n")
 				}
@@ -78,176 +594,2117 @@ GitHub starred projects / repositories, a.k.a. "Stars"`,
 		},
 	}
 
-	var (
-		count    int
-		language string
-		topic    string
-		random   bool
-		browse   bool
-	)
+	var ownersTop int
 
-	showStarsCmd := &cobra.Command{
-		Use:   "show",
-		Short: "Show stars",
-		Long:  "Displays a tabulated list of stars given query parameters",
+	ownersCmd := &cobra.Command{
+		Use:   "owners",
+		Short: "List the users and organizations owning starred projects",
+		Long:  "Displays the users and organizations owning a user's starred projects, sorted by how many of their projects are starred",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := sm.SaveIfEmpty(); err != nil {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
 				return err
 			}
 
-			stars, err := sm.GetProjects(count, language, topic, random)
+			owners, err := sm.GetOwners(ctx)
 			if err != nil {
-				log.Printf(err.Error())
 				return err
 			}
 
-			wg := sync.WaitGroup{}
-			w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
-
-			for i := 0; i < len(stars); i++ {
-				proj := stars[i]
+			if ownersTop > 0 && len(owners) > ownersTop {
+				owners = owners[:ownersTop]
+			}
 
-				if browse {
-					wg.Add(1)
+			if out.structured() {
+				return out.write(owners)
+			}
 
-					go func(p starmanager.Star) {
-						defer wg.Done()
-						if err := browser.OpenURL(p.URL); err != nil {
-							panic(err)
-						}
-					}(proj)
-				} else {
-					if i == 0 {
-						if language == "" {
-							fmt.Fprintf(w, "PUSHED\tSTARS\tLANGUAGE\tURL\tDESCRIPTION\n")
-						} else {
-							fmt.Fprintf(w, "PUSHED\tSTARS\tURL\tDESCRIPTION\n")
-						}
-					}
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
 
-					if language == "" {
-						fmt.Fprintf(
-							w,
-							"%s\t%d\t%s\t%s\t%s\n",
-							proj.PushedAt,
-							proj.Stargazers,
-							proj.Language,
-							proj.URL,
-							proj.Description,
-						)
-					} else {
-						fmt.Fprintf(
-							w,
-							"%s\t%d\t%s\t%s\n",
-							proj.PushedAt,
-							proj.Stargazers,
-							proj.URL,
-							proj.Description,
-						)
-					}
+			for i, pair := range owners {
+				if i == 0 {
+					fmt.Fprintf(w, "OWNER\tSTARS\n")
 				}
-			}
 
-			if err := w.Flush(); err != nil {
-				return err
+				fmt.Fprintf(w, "%s\t%d\n", pair.Key, pair.Value)
 			}
 
-			wg.Wait()
-
-			return nil
+			return w.Flush()
 		},
 	}
 
-	showStarsCmd.PersistentFlags().IntVarP(&count, "count", "c", 6, "Number of stars to show")
-	showStarsCmd.PersistentFlags().StringVarP(&language, "language", "l", "", "Limit to projects written only in this language")
-	showStarsCmd.PersistentFlags().StringVarP(&topic, "topic", "t", "", "Limit to projects with this topic")
-	showStarsCmd.PersistentFlags().BoolVarP(&random, "random", "r", false, "Randomize results")
-	showStarsCmd.PersistentFlags().BoolVarP(&browse, "browse", "b", false, "Open stars in browser instead of writing them to stdout")
+	ownersCmd.PersistentFlags().IntVarP(&ownersTop, "top", "n", 0, "Only list the most starred owners (0 for all)")
 
-	clearCmd := &cobra.Command{
-		Use:   "clear",
-		Short: "Clear local stars cache",
-		Long:  "Wipe the file on the local filesystem containing the fetched results of all stars",
+	var (
+		statsTop   int
+		statsChart bool
+	)
+
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show statistics about stars",
+		Long:  "Displays breakdowns of starred projects by language, topic, license, owner and year starred, along with how many are archived and how long ago they were pushed to",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := sm.ClearCache(); err != nil {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
 				return err
 			}
 
-			return nil
+			stats, err := sm.Stats(ctx)
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(stats)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+			fmt.Fprintf(w, "Total\t%d\n", stats.Total)
+			fmt.Fprintf(w, "Active\t%d\n", stats.Active)
+			fmt.Fprintf(w, "Archived\t%d\n", stats.Archived)
+			if stats.Gone > 0 {
+				fmt.Fprintf(w, "Gone\t%d\n", stats.Gone)
+			}
+			fmt.Fprintf(w, "Median days since push\t%d\n", stats.MedianDaysSincePush)
+
+			printBreakdown(w, "LANGUAGE", stats.Languages, statsTop, statsChart)
+			printBreakdown(w, "TOPIC", stats.Topics, statsTop, statsChart)
+			printBreakdown(w, "LICENSE", stats.Licenses, statsTop, statsChart)
+			printBreakdown(w, "OWNER", stats.Owners, statsTop, statsChart)
+			printBreakdown(w, "YEAR STARRED", stats.Years, 0, statsChart)
+
+			return w.Flush()
 		},
 	}
 
+	statsCmd.Flags().IntVarP(&statsTop, "top", "t", 10, "Number of languages, topics and owners to show, 0 for all")
+	statsCmd.Flags().BoolVarP(&statsChart, "chart", "c", false, "Render bar charts next to counts")
+
 	var (
-		months          int
-		includeArchived bool
+		healthCount int
+		healthBelow int
 	)
 
-	cleanupCmd := &cobra.Command{
-		Use:   "cleanup",
-		Short: "Clean up old stars",
-		Long:  "Un-stars projects older than n months, optionally also unstarring archived projects",
+	healthCmd := &cobra.Command{
+		Use:   "health",
+		Short: "Show the least healthy stars",
+		Long: `Scores every star from 0 to 100 by whether it is archived, how long ago it was pushed to,
+its open issues per stargazer and the age of its latest release, worst first`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := sm.SaveIfEmpty(); err != nil {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
 				return err
 			}
 
-			if err := sm.Cleanup(months, includeArchived); err != nil {
+			report, err := sm.HealthReport(ctx, starmanager.HealthOptions{Count: healthCount, Below: healthBelow})
+			if err != nil {
 				return err
 			}
 
-			return nil
+			if out.structured() {
+				return out.write(report)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+			fmt.Fprintln(w, "SCORE\tURL\tSIGNALS")
+			for _, h := range report {
+				details := []string{}
+				for _, signal := range h.Signals {
+					details = append(details, fmt.Sprintf("%s (-%d)", signal.Detail, signal.Penalty))
+				}
+
+				fmt.Fprintf(w, "%d\t%s\t%s\n", h.Score, h.Star.URL, strings.Join(details, ", "))
+			}
+
+			return w.Flush()
 		},
 	}
 
-	cleanupCmd.PersistentFlags().IntVarP(&months, "months", "m", 2, "Number of months to delete projects older than")
-	cleanupCmd.PersistentFlags().BoolVarP(&includeArchived, "include-archived", "a", false, "Include archived stars")
+	healthCmd.Flags().IntVarP(&healthCount, "count", "c", 20, "Number of stars to show, 0 for all")
+	healthCmd.Flags().IntVarP(&healthBelow, "below", "b", 0, "Only show stars scoring below this")
 
-	completionCmd := &cobra.Command{
-		Use:   "completion",
-		Short: "Generate completion",
-		Long:  "Outputs an autocompletion script to be sourced by a target shell",
+	var diffNotify []string
+
+	diffCmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Show what changed since the previous sync",
+		Long: `Compares the last two syncs, listing newly starred, removed, archived and renamed projects.
+With --notify or $` + starmanager.NotifyEnv + `, newly archived projects are also announced on the
+desktop, in Slack or by email`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			fmt.Println(`Outputs autocompletion scripts for the CLI. Please refer
-to your shell's documentation on how to configure autocompletion.
-			`)
-			cmd.Help()
+			diff, err := sm.Diff(ctx)
+			if err != nil {
+				return err
+			}
+
+			notify, err := notifiers(sm, diffNotify)
+			if err != nil {
+				return err
+			}
+
+			if err := starmanager.Notify(ctx, notify, starmanager.DiffNotifications(diff)); err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(diff)
+			}
 
+			printDiff(diff)
 			return nil
 		},
 	}
 
-	bashCompletionCmd := &cobra.Command{
-		Use:   "bash",
-		Short: "Generate bash completion",
-		Long:  "Outputs Bash autocompletion script",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			return cmd.GenBashCompletion(os.Stdout)
-		},
-	}
+	diffCmd.Flags().StringSliceVar(&diffNotify, "notify", nil, "Notify about newly archived stars through these channels: desktop, slack:WEBHOOK or smtp://... (default $"+starmanager.NotifyEnv+")")
 
-	zshCompletionCmd := &cobra.Command{
-		Use:   "zsh",
-		Short: "Generate Zsh completion",
-		Long:  "Outputs Zsh autocompletion script",
+	var (
+		trendsSince string
+		trendsCount int
+		trendsQuiet bool
+	)
+
+	trendsCmd := &cobra.Command{
+		Use:   "trends",
+		Short: "Show how stars changed over time",
+		Long:  "Compares stars against the snapshot taken when they were synced at the start of a period, showing the projects that gained the most stars or, with --quiet, the ones that have not been pushed to since",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return cmd.GenZshCompletion(os.Stdout)
-		},
-	}
+			since, err := utils.ParseAge(trendsSince, time.Now())
+			if err != nil {
+				return err
+			}
 
-	completionCmd.AddCommand(bashCompletionCmd, zshCompletionCmd)
+			opts := starmanager.TrendOptions{Kind: starmanager.TrendGained, Since: since, Count: trendsCount}
+			if trendsQuiet {
+				opts.Kind = starmanager.TrendQuiet
+			}
+
+			trends, err := sm.Trends(ctx, opts)
+			if err != nil {
+				return err
+			}
+
+			if len(trends) > 0 && trends[0].Baseline.After(since) {
+				log.Printf("No snapshot that old, comparing against the one from %s", trends[0].Baseline.Format("2006-01-02"))
+			}
+
+			if out.structured() {
+				return out.write(trends)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+			if trendsQuiet {
+				fmt.Fprintln(w, "URL\tLAST PUSHED\tSTARS")
+				for _, trend := range trends {
+					fmt.Fprintf(w, "%s\t%s\t%d\n", trend.Star.URL, trend.Star.PushedAt.Format("2006-01-02"), trend.After)
+				}
+
+				return w.Flush()
+			}
+
+			fmt.Fprintln(w, "URL\tGAINED\tSTARS")
+			for _, trend := range trends {
+				fmt.Fprintf(w, "%s\t%+d\t%d\n", trend.Star.URL, trend.Gained(), trend.After)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	trendsCmd.Flags().StringVarP(&trendsSince, "since", "s", "6m", "Start of the period to compare (e.g. 30d, 6m, 1y)")
+	trendsCmd.Flags().IntVarP(&trendsCount, "count", "c", 10, "Number of projects to show, 0 for all")
+	trendsCmd.Flags().BoolVarP(&trendsQuiet, "quiet", "q", false, "Show projects that have not been pushed to during the period")
+
+	var (
+		releasesSince string
+		releasesFetch bool
+	)
+
+	releasesCmd := &cobra.Command{
+		Use:   "releases",
+		Short: "Show stars that published a release since the last check",
+		Long: `Lists starred projects whose latest release or tag was published since releases were last
+checked, or since --since. Release information comes from syncing, or from --fetch, which
+looks up the latest release of every GitHub star.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			if releasesFetch {
+				if _, err := sm.FetchReleases(ctx); err != nil {
+					return err
+				}
+			}
+
+			now := time.Now()
+			since, err := sm.LastReleaseCheck()
+			if err != nil {
+				return err
+			}
+
+			// An explicit --since does not count as a check
+			if releasesSince != "" {
+				if since, err = utils.ParseAge(releasesSince, now); err != nil {
+					return err
+				}
+			} else if since.IsZero() {
+				since = now.AddDate(0, 0, -30)
+			}
+
+			stars, err := sm.NewReleases(ctx, since)
+			if err != nil {
+				return err
+			}
+
+			// Only a listing that succeeded counts as a check
+			if releasesSince == "" {
+				if err := sm.MarkReleasesChecked(now); err != nil {
+					log.Printf("Could not record the release check: %v", err)
+				}
+			}
+
+			if out.structured() {
+				return out.write(stars)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+			fmt.Fprintln(w, "URL\tRELEASE\tPUBLISHED")
+			for _, star := range stars {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", star.URL, star.LatestRelease, star.ReleasedAt.Format("2006-01-02"))
+			}
+
+			return w.Flush()
+		},
+	}
+
+	releasesCmd.Flags().StringVarP(&releasesSince, "since", "s", "", "Show releases published since then (e.g. 30d, 6m) instead of since the last check")
+	releasesCmd.Flags().BoolVarP(&releasesFetch, "fetch", "f", false, "Fetch the latest release of every GitHub star first (slow, one request per star)")
+
+	var (
+		count            int
+		languages        []string
+		topics           []string
+		allTopics        bool
+		excludeLanguages []string
+		excludeTopics    []string
+		licenses         []string
+		owners           []string
+		langThreshold    float64
+		tags             []string
+		list             string
+		random           bool
+		browse           bool
+		since            string
+		sortBy           string
+		sortOrder        string
+	)
+
+	showStarsCmd := &cobra.Command{
+		Use:   "show [QUERY...]",
+		Short: "Show stars",
+		Long: `Displays a tabulated list of stars given query parameters. A query such as
+"language:go topic:cli stars:>500 pushed:>2023-01-01 archived:false" narrows down the results further`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			opts := starmanager.ProjectOptions{
+				Count:             count,
+				Languages:         languages,
+				Topics:            topics,
+				ExcludeLanguages:  excludeLanguages,
+				ExcludeTopics:     excludeTopics,
+				LanguageThreshold: langThreshold / 100,
+				Licenses:          licenses,
+				Owners:            owners,
+				Tags:              tags,
+				List:              list,
+				Random:            random,
+				Sort:              starmanager.SortKey(sortBy),
+				Order:             starmanager.SortOrder(sortOrder),
+				Query:             strings.Join(args, " "),
+			}
+
+			if allTopics {
+				opts.TopicMatch = starmanager.MatchAll
+			}
+
+			// The language column is redundant when showing a single language
+			language := ""
+			if len(languages) == 1 {
+				language = languages[0]
+			}
+
+			if since != "" {
+				cutoff, err := utils.ParseAge(since, time.Now())
+				if err != nil {
+					return err
+				}
+
+				opts.StarredAfter = cutoff
+			}
+
+			stars, err := sm.GetProjects(ctx, opts)
+			if err != nil {
+				log.Printf(err.Error())
+				return err
+			}
+
+			if out.structured() && !browse {
+				if err := out.write(stars); err != nil {
+					return err
+				}
+
+				return sm.MarkSurfaced(stars)
+			}
+
+			wg := sync.WaitGroup{}
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
+			withActivity := false
+			for _, star := range stars {
+				if len(star.Activity) > 0 {
+					withActivity = true
+				}
+			}
+
+			for i := 0; i < len(stars); i++ {
+				proj := stars[i]
+
+				if browse {
+					wg.Add(1)
+
+					go func(p starmanager.Star) {
+						defer wg.Done()
+						if err := browser.OpenURL(p.URL); err != nil {
+							panic(err)
+						}
+					}(proj)
+				} else {
+					if i == 0 {
+						fmt.Fprintln(w, strings.Join(showColumns(language, withActivity, nil), "\t"))
+					}
+
+					fmt.Fprintln(w, strings.Join(showColumns(language, withActivity, &proj), "\t"))
+				}
+			}
+
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			wg.Wait()
+
+			return sm.MarkSurfaced(stars)
+		},
+	}
+
+	showStarsCmd.PersistentFlags().IntVarP(&count, "count", "c", 6, "Number of stars to show")
+	showStarsCmd.PersistentFlags().StringSliceVarP(&languages, "language", "l", nil, "Limit to projects written in any of these languages")
+	showStarsCmd.PersistentFlags().StringSliceVarP(&topics, "topic", "t", nil, "Limit to projects with any of these topics")
+	showStarsCmd.PersistentFlags().BoolVar(&allTopics, "all-topics", false, "Require projects to have all topics given with --topic")
+	showStarsCmd.PersistentFlags().StringSliceVar(&excludeLanguages, "exclude-language", nil, "Leave out projects written in these languages")
+	showStarsCmd.PersistentFlags().StringSliceVar(&excludeTopics, "exclude-topic", nil, "Leave out projects with any of these topics")
+	showStarsCmd.PersistentFlags().Float64Var(&langThreshold, "language-threshold", 10, "Also match languages making up at least this percentage of a project's code, if its languages were fetched (0 for the primary language only)")
+	showStarsCmd.PersistentFlags().StringSliceVar(&licenses, "license", nil, "Limit to projects under any of these SPDX licenses (e.g. GPL-3.0,MIT)")
+	showStarsCmd.PersistentFlags().StringSliceVarP(&owners, "owner", "o", nil, "Limit to projects of any of these users or organizations")
+	showStarsCmd.PersistentFlags().StringSliceVar(&tags, "tag", nil, "Limit to projects with any of these local tags")
+	showStarsCmd.PersistentFlags().StringVar(&list, "list", "", "Limit to projects on this GitHub list")
+	showStarsCmd.PersistentFlags().BoolVarP(&random, "random", "r", false, "Randomize results")
+	showStarsCmd.PersistentFlags().StringVarP(&since, "since", "s", "", "Limit to projects starred within this age (e.g. 30d, 6m, 1y)")
+	showStarsCmd.PersistentFlags().StringVar(&sortBy, "sort", string(starmanager.SortStargazers), "Sort by stars, starred (date), pushed (date), name or url")
+	showStarsCmd.PersistentFlags().StringVar(&sortOrder, "order", "", "Sort order, asc or desc (default desc, asc for name)")
+	showStarsCmd.PersistentFlags().BoolVarP(&browse, "browse", "b", false, "Open stars in browser instead of writing them to stdout")
+
+	var searchCount int
+
+	searchCmd := &cobra.Command{
+		Use:   "search QUERY...",
+		Short: "Search stars",
+		Long: `Searches the descriptions, topics, languages and names of stars. All terms have to
+match; "quoted phrases" match consecutive words and terms ending in * match prefixes`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			stars, err := sm.Search(ctx, strings.Join(args, " "))
+			if err != nil {
+				return err
+			}
+
+			if searchCount > 0 && len(stars) > searchCount {
+				stars = stars[:searchCount]
+			}
+
+			if out.structured() {
+				return out.write(stars)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
+			for i := range stars {
+				if i == 0 {
+					fmt.Fprintln(w, strings.Join(showColumns("", false, nil), "\t"))
+				}
+
+				fmt.Fprintln(w, strings.Join(showColumns("", false, &stars[i]), "\t"))
+			}
+
+			return w.Flush()
+		},
+	}
+
+	searchCmd.PersistentFlags().IntVarP(&searchCount, "count", "c", 20, "Maximum number of results to show (0 for all)")
+
+	var clearAll bool
+
+	clearCmd := &cobra.Command{
+		Use:        "clear",
+		Deprecated: "use \"stars cache clear\" instead",
+		Short:      "Clear local stars cache",
+		Long: `Wipe the fetched results of all stars from the local cache. Local metadata such
+as tags, notes and protected flags is kept unless --all is passed`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := safetyBackup(sm); err != nil {
+				return err
+			}
+
+			if err := sm.ClearCache(clearAll); err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	clearCmd.PersistentFlags().BoolVar(&clearAll, "all", false, "Also remove local metadata (tags, notes, protected flags)")
+
+	var (
+		clearProvider  string
+		clearLanguage  string
+		clearOlderThan string
+	)
+
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the local stars cache",
+		Long:  "Inspect and manage the local cache of fetched stars",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.Help()
+			return nil
+		},
+	}
+
+	cacheClearCmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Clear cached stars",
+		Long: `Deletes cached stars matching the given filters, leaving everything else intact.
+Without filters all fetched stars are removed. Local metadata such as tags, notes and
+protected flags is kept unless --all is passed. The cache is backed up first, see
+"stars cache restore"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := safetyBackup(sm); err != nil {
+				return err
+			}
+
+			if clearAll {
+				return sm.ClearCache(true)
+			}
+
+			opts := starmanager.ClearOptions{
+				Provider: clearProvider,
+				Language: clearLanguage,
+			}
+
+			if clearOlderThan != "" {
+				cutoff, err := utils.ParseAge(clearOlderThan, time.Now())
+				if err != nil {
+					return err
+				}
+
+				opts.OlderThan = cutoff
+			}
+
+			if _, err := sm.ClearStars(ctx, opts); err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cacheClearCmd.PersistentFlags().StringVar(&clearProvider, "provider", "", "Only clear stars from this provider (e.g. github)")
+	cacheClearCmd.PersistentFlags().StringVarP(&clearLanguage, "language", "l", "", "Only clear stars written in this language")
+	cacheClearCmd.PersistentFlags().StringVar(&clearOlderThan, "older-than", "", "Only clear stars last pushed to longer ago than this (e.g. 30d, 6m, 1y)")
+	cacheClearCmd.PersistentFlags().BoolVar(&clearAll, "all", false, "Remove the entire cache including local metadata")
+
+	cachePathCmd := &cobra.Command{
+		Use:   "path",
+		Short: "Print the path of the cache db file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if out.structured() {
+				return out.write(map[string]string{"path": sm.DB.Bolt.Path()})
+			}
+
+			fmt.Println(sm.DB.Bolt.Path())
+			return nil
+		},
+	}
+
+	var (
+		backupDir  string
+		backupKeep int
+	)
+
+	cacheBackupCmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Back up the cache",
+		Long: `Writes a snapshot of the cache, including local metadata such as tags and notes, to a
+timestamped file while it stays usable, and removes all but the newest --keep backups.
+The cache is also backed up automatically before it is cleared, cleaned up or restored`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if backupDir == "" {
+				backupDir = sm.BackupDir()
+			}
+
+			path, err := sm.Backup(backupDir)
+			if err != nil {
+				return err
+			}
+
+			if _, err := starmanager.PruneBackups(backupDir, backupKeep); err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(map[string]string{"path": path})
+			}
+
+			fmt.Println(path)
+			return nil
+		},
+	}
+
+	cacheBackupCmd.PersistentFlags().StringVarP(&backupDir, "dir", "d", "", "Directory to keep backups in (default stars-backups next to the cache)")
+	cacheBackupCmd.PersistentFlags().IntVarP(&backupKeep, "keep", "k", starmanager.DefaultBackupRetention, "Number of backups to keep")
+
+	cacheBackupsCmd := &cobra.Command{
+		Use:   "backups",
+		Short: "List backups of the cache, newest first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if backupDir == "" {
+				backupDir = sm.BackupDir()
+			}
+
+			backups, err := starmanager.Backups(backupDir)
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(backups)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+			fmt.Fprintln(w, "CREATED\tSIZE\tPATH")
+			for _, backup := range backups {
+				fmt.Fprintf(w, "%s\t%d\t%s\n", backup.CreatedAt.Local().Format("2006-01-02 15:04:05"), backup.Size, backup.Path)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	cacheBackupsCmd.PersistentFlags().StringVarP(&backupDir, "dir", "d", "", "Directory backups are kept in (default stars-backups next to the cache)")
+
+	cacheRestoreCmd := &cobra.Command{
+		Use:   "restore <backup>",
+		Short: "Restore the cache from a backup",
+		Long: `Replaces the cache with a backup, given by path or as "latest" for the newest one in the
+backup directory. The current cache is backed up first, so a restore can be undone too`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if backupDir == "" {
+				backupDir = sm.BackupDir()
+			}
+
+			path := args[0]
+			if path == "latest" {
+				backups, err := starmanager.Backups(backupDir)
+				if err != nil {
+					return err
+				}
+
+				if len(backups) == 0 {
+					return fmt.Errorf("there are no backups in %s", backupDir)
+				}
+
+				path = backups[0].Path
+			}
+
+			// The current cache is backed up after resolving "latest", which would be it
+			if err := safetyBackup(sm); err != nil {
+				return err
+			}
+
+			return sm.RestoreBackup(path)
+		},
+	}
+
+	cacheRestoreCmd.PersistentFlags().StringVarP(&backupDir, "dir", "d", "", "Directory backups are kept in (default stars-backups next to the cache)")
+
+	cacheCmd.AddCommand(cacheClearCmd, cachePathCmd, cacheBackupCmd, cacheBackupsCmd, cacheRestoreCmd)
+
+	var (
+		exportFormat   string
+		exportGroupBy  string
+		exportOutput   string
+		exportCount    int
+		exportLanguage string
+		exportTopic    string
+		exportLicenses []string
+		exportOwners   []string
+		exportSince    string
+		exportSort     string
+		exportOrder    string
+		feedTitle      string
+		feedLink       string
+	)
+
+	exportCmd := &cobra.Command{
+		Use:   "export [QUERY...]",
+		Short: "Export stars",
+		Long: `Writes cached stars matching an optional query as JSON, CSV, a Markdown list grouped by
+language, topic or owner, or an Atom or RSS feed of the most recently starred projects`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			opts := starmanager.ExportOptions{
+				Format:  starmanager.ExportFormat(exportFormat),
+				GroupBy: starmanager.GroupBy(exportGroupBy),
+				Feed:    starmanager.FeedOptions{Title: feedTitle, Link: feedLink},
+				Filter: starmanager.ProjectOptions{
+					Count:    exportCount,
+					Language: exportLanguage,
+					Topic:    exportTopic,
+					Licenses: exportLicenses,
+					Owners:   exportOwners,
+					Sort:     starmanager.SortKey(exportSort),
+					Order:    starmanager.SortOrder(exportOrder),
+					Query:    strings.Join(args, " "),
+				},
+			}
+
+			if exportSince != "" {
+				cutoff, err := utils.ParseAge(exportSince, time.Now())
+				if err != nil {
+					return err
+				}
+
+				opts.Filter.StarredAfter = cutoff
+			}
+
+			if exportOutput == "" || exportOutput == "-" {
+				return sm.Export(ctx, os.Stdout, opts)
+			}
+
+			f, err := os.Create(exportOutput)
+			if err != nil {
+				return err
+			}
+
+			if err := sm.Export(ctx, f, opts); err != nil {
+				f.Close()
+				return err
+			}
+
+			return f.Close()
+		},
+	}
+
+	exportCmd.PersistentFlags().StringVarP(&exportFormat, "format", "f", string(starmanager.ExportJSON), "Output format: json, csv, markdown, atom or rss")
+	exportCmd.PersistentFlags().StringVarP(&exportGroupBy, "group-by", "g", string(starmanager.GroupByLanguage), "Group Markdown output by language, topic or owner")
+	exportCmd.PersistentFlags().StringVarP(&exportOutput, "output", "o", "", "Write to this file instead of stdout")
+	exportCmd.PersistentFlags().IntVarP(&exportCount, "count", "c", 0, "Maximum number of stars to export (0 for all)")
+	exportCmd.PersistentFlags().StringVarP(&exportLanguage, "language", "l", "", "Limit to projects written only in this language")
+	exportCmd.PersistentFlags().StringVarP(&exportTopic, "topic", "t", "", "Limit to projects with this topic")
+	exportCmd.PersistentFlags().StringSliceVar(&exportLicenses, "license", nil, "Limit to projects under any of these SPDX licenses (e.g. GPL-3.0,MIT)")
+	exportCmd.PersistentFlags().StringSliceVar(&exportOwners, "owner", nil, "Limit to projects of any of these users or organizations")
+	exportCmd.PersistentFlags().StringVarP(&exportSince, "since", "s", "", "Limit to projects starred within this age (e.g. 30d, 6m, 1y)")
+	exportCmd.PersistentFlags().StringVar(&exportSort, "sort", string(starmanager.SortStargazers), "Sort by stars, starred (date), pushed (date), name or url (streams large caches)")
+	exportCmd.PersistentFlags().StringVar(&exportOrder, "order", "", "Sort order, asc or desc (default desc, asc for name)")
+	exportCmd.PersistentFlags().StringVar(&feedTitle, "feed-title", starmanager.DefaultFeedTitle, "Title of Atom and RSS feeds")
+	exportCmd.PersistentFlags().StringVar(&feedLink, "feed-link", "", "URL Atom and RSS feeds are published at")
+
+	var infoOffline bool
+
+	infoCmd := &cobra.Command{
+		Use:   "info URL|OWNER/REPO",
+		Short: "Show the details of a star",
+		Long: `Shows everything known about a single star, combining the cache, your tags and notes and,
+for GitHub repositories, the current open issues, forks, latest release, license and
+homepage`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			detail, err := sm.Show(ctx, args[0], starmanager.ShowOptions{Offline: infoOffline})
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(detail)
+			}
+
+			return printDetail(detail)
+		},
+	}
+
+	infoCmd.Flags().BoolVarP(&infoOffline, "offline", "o", false, "Only show cached data")
+
+	readmeCmd := &cobra.Command{
+		Use:   "readme URL",
+		Short: "Show the README of a star",
+		Long:  `Prints the cached README of a star, downloaded with "stars save --readmes"`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			readme, err := sm.Readme(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(readme)
+			}
+
+			fmt.Println(readme.Content)
+			return nil
+		},
+	}
+
+	var (
+		cloneMode     string
+		cloneUpdate   bool
+		cloneDryRun   bool
+		cloneCount    int
+		cloneLanguage string
+		cloneTopic    string
+	)
+
+	cloneCmd := &cobra.Command{
+		Use:   "clone DIR [QUERY...]",
+		Short: "Clone stars into a directory",
+		Long: `Clones the cached stars matching an optional query into DIR/<host>/<owner>/<repo>, for
+example to keep an offline archive. Running it again resumes an interrupted run, and
+--update fetches the clones that already exist`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			result, err := sm.Clone(ctx, starmanager.CloneOptions{
+				Dir:    args[0],
+				Mode:   starmanager.CloneMode(cloneMode),
+				Update: cloneUpdate,
+				DryRun: cloneDryRun,
+				Filter: starmanager.ProjectOptions{
+					Count:    cloneCount,
+					Language: cloneLanguage,
+					Topic:    cloneTopic,
+					Query:    strings.Join(args[1:], " "),
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				if err := out.write(result); err != nil {
+					return err
+				}
+
+				return result.Err()
+			}
+
+			fmt.Printf("%d cloned, %d updated, %d skipped, %d failed\n", result.Cloned, result.Updated, result.Skipped, result.Failed)
+
+			return result.Err()
+		},
+	}
+
+	cloneCmd.PersistentFlags().StringVarP(&cloneMode, "mode", "m", string(starmanager.CloneFull), "How to clone: full, shallow (latest commit only) or mirror (bare, all refs)")
+	cloneCmd.PersistentFlags().BoolVarP(&cloneUpdate, "update", "u", false, "Fetch repositories that were already cloned")
+	cloneCmd.PersistentFlags().BoolVarP(&cloneDryRun, "dry-run", "n", false, "Only show which repositories would be cloned or updated")
+	cloneCmd.PersistentFlags().IntVarP(&cloneCount, "count", "c", 0, "Maximum number of stars to clone (0 for all)")
+	cloneCmd.PersistentFlags().StringVarP(&cloneLanguage, "language", "l", "", "Limit to projects written only in this language")
+	cloneCmd.PersistentFlags().StringVarP(&cloneTopic, "topic", "t", "", "Limit to projects with this topic")
+
+	var (
+		importFormat string
+		importDryRun bool
+	)
+
+	importCmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Import stars",
+		Long: `Stars every project in a JSON or CSV file written by "stars export" that is not
+starred yet, e.g. to migrate stars to another account`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			format := starmanager.ExportFormat(importFormat)
+			if format == "" {
+				format = starmanager.ExportJSON
+				if strings.HasSuffix(strings.ToLower(args[0]), ".csv") {
+					format = starmanager.ExportCSV
+				}
+			}
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			result, err := sm.Import(ctx, f, starmanager.ImportOptions{Format: format, DryRun: importDryRun})
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				if err := out.write(result); err != nil {
+					return err
+				}
+
+				return result.Err()
+			}
+
+			if importDryRun {
+				fmt.Printf("%d would be starred, %d already starred\n", result.Starred, result.Skipped)
+				return nil
+			}
+
+			fmt.Printf("%d starred, %d already starred, %d failed\n", result.Starred, result.Skipped, result.Failed)
+
+			return result.Err()
+		},
+	}
+
+	importCmd.PersistentFlags().StringVarP(&importFormat, "format", "f", "", "Format of the file: json or csv (default detected from the file extension)")
+	importCmd.PersistentFlags().BoolVarP(&importDryRun, "dry-run", "n", false, "Only show which projects would be starred")
+
+	depsCmd := &cobra.Command{
+		Use:   "deps MANIFEST...",
+		Short: "Cross-reference stars against a project's dependencies",
+		Long: `Reads the dependencies from go.mod, package.json or requirements.txt files, looks up their
+repositories and lists which of them are starred and which are not`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			deps, err := readManifests(args)
+			if err != nil {
+				return err
+			}
+
+			report, err := sm.CrossReference(ctx, deps)
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(report)
+			}
+
+			fmt.Printf("Starred (%d):\n", len(report.Starred))
+			for _, star := range report.Starred {
+				fmt.Printf("  %s\n", star.URL)
+			}
+
+			fmt.Printf("\nNot starred (%d):\n", len(report.Unstarred))
+			for _, dep := range report.Unstarred {
+				fmt.Printf("  %s (%s)\n", dep.URL, dep.Name)
+			}
+
+			if len(report.Unresolved) > 0 {
+				fmt.Printf("\nRepository not found (%d):\n", len(report.Unresolved))
+				for _, dep := range report.Unresolved {
+					fmt.Printf("  %s\n", dep.Name)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	var (
+		recommendCount         int
+		recommendTopics        int
+		recommendLanguages     int
+		recommendMinStars      int
+		recommendWeights       map[string]string
+		recommendExclude       []string
+		recommendExcludeTopics []string
+	)
+
+	recommendCmd := &cobra.Command{
+		Use:   "recommend",
+		Short: "Recommend repositories to star",
+		Long: `Searches GitHub for popular repositories matching the most common topics and languages
+of your stars that you have not starred yet, weighting every topic and language by the
+share of your stars it makes up`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			weights := map[string]float64{}
+			for topic, weight := range recommendWeights {
+				w, err := strconv.ParseFloat(weight, 64)
+				if err != nil {
+					return fmt.Errorf("invalid weight for %s: %v", topic, err)
+				}
+
+				weights[topic] = w
+			}
+
+			recommendations, err := sm.Recommend(ctx, starmanager.RecommendOptions{
+				Count:         recommendCount,
+				Topics:        recommendTopics,
+				Languages:     recommendLanguages,
+				MinStars:      recommendMinStars,
+				Weights:       weights,
+				Exclude:       recommendExclude,
+				ExcludeTopics: recommendExcludeTopics,
+			})
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(recommendations)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+			fmt.Fprintln(w, "URL\tSTARS\tMATCHED\tDESCRIPTION")
+			for _, rec := range recommendations {
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", rec.Star.URL, rec.Star.Stargazers, strings.Join(rec.Matched, ", "), rec.Star.Description)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	recommendCmd.Flags().IntVarP(&recommendCount, "count", "c", starmanager.DefaultRecommendations, "Number of repositories to recommend")
+	recommendCmd.Flags().IntVar(&recommendTopics, "topics", starmanager.DefaultRecommendTopics, "Number of your most common topics to search")
+	recommendCmd.Flags().IntVar(&recommendLanguages, "languages", starmanager.DefaultRecommendLanguages, "Number of your most common languages to search")
+	recommendCmd.Flags().IntVar(&recommendMinStars, "min-stars", 100, "Only recommend repositories with at least this many stars")
+	recommendCmd.Flags().StringToStringVarP(&recommendWeights, "weight", "w", nil, "Multiply the weight of topics, e.g. cli=2,docker=0 (0 leaves a topic out)")
+	recommendCmd.Flags().StringSliceVarP(&recommendExclude, "exclude", "x", nil, "Leave out these repositories (URL or owner/repo) or owners")
+	recommendCmd.Flags().StringSliceVar(&recommendExcludeTopics, "exclude-topic", nil, "Leave out repositories with these topics")
+
+	var similarity float64
+
+	duplicatesCmd := &cobra.Command{
+		Use:   "duplicates",
+		Short: "Find starred forks and near-duplicate stars",
+		Long: `Lists starred forks grouped by the project they were forked from, and stars that look like
+the same project because they have the same name or a similar description. Forks whose
+upstream is starred too can be unstarred with "stars cleanup --include-forks"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			if _, err := sm.FetchParents(ctx); err != nil {
+				return err
+			}
+
+			report, err := sm.Duplicates(ctx, starmanager.DuplicateOptions{Similarity: similarity / 100})
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(report)
+			}
+
+			for _, group := range report.Forks {
+				status := "not starred"
+				if group.Starred != nil {
+					status = "starred"
+				}
+
+				fmt.Printf("%s (%s)\n", group.Upstream, status)
+				for _, fork := range group.Forks {
+					fmt.Printf("  fork %s\n", fork.URL)
+				}
+			}
+
+			for _, group := range report.Duplicates {
+				fmt.Printf("%s:\n", group.Reason)
+				for _, star := range group.Stars {
+					fmt.Printf("  %s\n", star.URL)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	duplicatesCmd.Flags().Float64Var(&similarity, "similarity", starmanager.DefaultSimilarity*100, "Percentage of words descriptions have to share to count as duplicates")
+
+	pinCmd := &cobra.Command{
+		Use:   "pin [URL...]",
+		Short: "Protect stars from cleanup",
+		Long:  "Protects the stars with the given URLs so that cleanup never removes them, or lists protected stars",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				protected, err := sm.Protected()
+				if err != nil {
+					return err
+				}
+
+				if out.structured() {
+					return out.write(protected)
+				}
+
+				for _, url := range protected {
+					fmt.Println(url)
+				}
+
+				return nil
+			}
+
+			for _, url := range args {
+				if err := sm.Pin(url); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	unpinCmd := &cobra.Command{
+		Use:   "unpin URL...",
+		Short: "Stop protecting stars from cleanup",
+		Long:  "Removes the protection of the stars with the given URLs",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, url := range args {
+				if err := sm.Unpin(url); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	tagCmd := &cobra.Command{
+		Use:   "tag URL TAG...",
+		Short: "Tag a star",
+		Long:  "Adds local tags to a star, independent of its topics",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sm.Tag(args[0], args[1:]...)
+		},
+	}
+
+	untagCmd := &cobra.Command{
+		Use:   "untag URL TAG...",
+		Short: "Remove tags from a star",
+		Long:  "Removes local tags from a star",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sm.Untag(args[0], args[1:]...)
+		},
+	}
+
+	tagsCmd := &cobra.Command{
+		Use:   "tags",
+		Short: "List all local tags",
+		Long:  "Displays a list of local tags, sorted by occurrence count",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tags, err := sm.Tags()
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(tags)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
+			for i, pair := range tags {
+				if i == 0 {
+					fmt.Fprintf(w, "TAG\tOCCURRENCES\n")
+				}
+
+				fmt.Fprintf(w, "%s\t%d\n", pair.Key, pair.Value)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	noteCmd := &cobra.Command{
+		Use:   "note URL [TEXT...]",
+		Short: "Show or set the notes on a star",
+		Long:  "Replaces the local notes on a star, or shows them if no text is given. An empty text removes them",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				annotation, err := sm.GetAnnotation(args[0])
+				if err != nil {
+					return err
+				}
+
+				if out.structured() {
+					return out.write(annotation)
+				}
+
+				fmt.Println(annotation.Notes)
+				return nil
+			}
+
+			return sm.Note(args[0], strings.Join(args[1:], " "))
+		},
+	}
+
+	listsCmd := &cobra.Command{
+		Use:   "lists",
+		Short: "List GitHub star lists",
+		Long:  "Displays the GitHub lists stars are organized in, as of the last list sync",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lists, err := sm.Lists()
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(lists)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
+			for i, list := range lists {
+				if i == 0 {
+					fmt.Fprintln(w, "LIST\tSTARS\tDESCRIPTION")
+				}
+
+				fmt.Fprintf(w, "%s\t%d\t%s\n", list.Name, len(list.Items), list.Description)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	listsSyncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Sync GitHub star lists",
+		Long:  "Fetches the GitHub lists stars are organized in",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			count, err := sm.SyncLists(ctx)
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(map[string]int{"synced": count})
+			}
+
+			fmt.Printf("%d lists synced\n", count)
+			return nil
+		},
+	}
+
+	listsAddCmd := &cobra.Command{
+		Use:   "add URL LIST",
+		Short: "Add a star to a list",
+		Long:  "Adds a GitHub star to one of your lists",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sm.AddToList(ctx, args[0], args[1])
+		},
+	}
+
+	listsRemoveCmd := &cobra.Command{
+		Use:   "remove URL LIST",
+		Short: "Remove a star from a list",
+		Long:  "Removes a GitHub star from one of your lists",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sm.RemoveFromList(ctx, args[0], args[1])
+		},
+	}
+
+	listsCmd.AddCommand(listsSyncCmd, listsAddCmd, listsRemoveCmd)
+
+	var (
+		cleanupSelect cleanupFlags
+		cleanupDryRun bool
+		interactive   bool
+		assumeYes     bool
+	)
+
+	cleanupCmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Clean up old stars",
+		Long: `Un-stars projects older than n months, optionally also unstarring archived projects.
+The matching projects are listed and have to be confirmed before anything is unstarred`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Results written as JSON leave no room for prompts
+			if out.structured() && !assumeYes && !cleanupDryRun {
+				return errors.New("--json and --ndjson require --yes or --dry-run")
+			}
+
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			if !cleanupDryRun {
+				if err := safetyBackup(sm); err != nil {
+					return err
+				}
+			}
+
+			opts, err := cleanupSelect.options(ctx, cmd, sm)
+			if err != nil {
+				return err
+			}
+			opts.DryRun = cleanupDryRun
+
+			in := bufio.NewReader(os.Stdin)
+			if !assumeYes {
+				opts.Confirm = func(candidates []*starmanager.CleanupCandidate) []*starmanager.CleanupCandidate {
+					if len(candidates) == 0 {
+						return candidates
+					}
+
+					if interactive {
+						confirmed := []*starmanager.CleanupCandidate{}
+						for _, c := range candidates {
+							if confirm(in, fmt.Sprintf("Unstar %s (%s)?", c.Star.URL, strings.Join(c.Reasons, ", "))) {
+								confirmed = append(confirmed, c)
+							}
+						}
+
+						return confirmed
+					}
+
+					if err := printCandidates(candidates); err != nil {
+						log.Printf("Could not list stars to remove: %v", err.Error())
+					}
+
+					if confirm(in, fmt.Sprintf("Unstar these %d projects?", len(candidates))) {
+						return candidates
+					}
+
+					return nil
+				}
+			}
+
+			result, err := sm.Cleanup(ctx, opts)
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				if err := out.write(result); err != nil {
+					return err
+				}
+
+				return result.Err()
+			}
+
+			if cleanupDryRun {
+				if err := printCandidates(result.Candidates); err != nil {
+					return err
+				}
+
+				fmt.Printf("%d would be removed\n", result.Matched)
+				return nil
+			}
+
+			fmt.Printf("%d removed, %d skipped, %d failed\n", result.Removed, result.Skipped, result.Failed)
+
+			return result.Err()
+		},
+	}
+
+	cleanupSelect.register(cleanupCmd, "")
+	cleanupCmd.PersistentFlags().BoolVarP(&cleanupDryRun, "dry-run", "n", false, "Only list the stars that would be removed and why")
+	cleanupCmd.PersistentFlags().BoolVarP(&interactive, "interactive", "i", false, "Confirm every star separately")
+	cleanupCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Remove matching stars without asking for confirmation")
+
+	watchingCmd := &cobra.Command{
+		Use:   "watching",
+		Short: "List watched repositories",
+		Long:  "Displays the GitHub repositories you watch, as of the last sync of watched repositories",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			subscriptions, err := sm.Subscriptions(ctx)
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(subscriptions)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
+			for i, subscription := range subscriptions {
+				if i == 0 {
+					fmt.Fprintln(w, "URL\tLANGUAGE\tSTARS\tPUSHED")
+				}
+
+				repo := subscription.Repository
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", repo.URL, repo.Language, repo.Stargazers, repo.PushedAt.Format("2006-01-02"))
+			}
+
+			return w.Flush()
+		},
+	}
+
+	watchingSyncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Sync watched repositories",
+		Long:  "Fetches the GitHub repositories you watch",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			count, err := sm.SyncSubscriptions(ctx)
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(map[string]int{"synced": count})
+			}
+
+			fmt.Printf("%d watched repositories synced\n", count)
+			return nil
+		},
+	}
+
+	watchingCompareCmd := &cobra.Command{
+		Use:   "compare",
+		Short: "Compare watched repositories with stars",
+		Long:  "Lists the watched repositories that are not starred and the GitHub stars that are not watched",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			comparison, err := sm.CompareSubscriptions(ctx)
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(comparison)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
+			fmt.Fprintln(w, "URL\tWATCHED\tSTARRED")
+
+			for _, subscription := range comparison.Unstarred {
+				fmt.Fprintf(w, "%s\tyes\tno\n", subscription.URL)
+			}
+
+			for _, star := range comparison.Unwatched {
+				fmt.Fprintf(w, "%s\tno\tyes\n", star.URL)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	watchingUnwatchCmd := &cobra.Command{
+		Use:   "unwatch URL...",
+		Short: "Unwatch repositories",
+		Long:  "Stops watching the given GitHub repositories",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, url := range args {
+				if _, err := sm.Unwatch(ctx, url); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	var (
+		watchMonths      int
+		watchArchived    bool
+		watchForks       bool
+		watchScoreBelow  int
+		watchDryRun      bool
+		watchInteractive bool
+		watchAssumeYes   bool
+		watchRulesFile   string
+	)
+
+	watchingCleanupCmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Unwatch stale repositories",
+		Long: `Unwatches repositories not pushed to in n months, optionally also archived ones, selected
+like the stars cleanup removes. The matching repositories are listed and have to be confirmed
+before anything is unwatched`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Results written as JSON leave no room for prompts
+			if out.structured() && !watchAssumeYes && !watchDryRun {
+				return errors.New("--json and --ndjson require --yes or --dry-run")
+			}
+
+			in := bufio.NewReader(os.Stdin)
+			opts := starmanager.CleanupOptions{
+				Months:     cleanupMonths(cmd, watchMonths, watchForks || watchScoreBelow > 0),
+				Archived:   watchArchived,
+				Forks:      watchForks,
+				ScoreBelow: watchScoreBelow,
+				DryRun:     watchDryRun,
+			}
+
+			if watchRulesFile != "" {
+				policy, err := starmanager.LoadPolicy(watchRulesFile)
+				if err != nil {
+					return err
+				}
+
+				opts.Policy = policy
+			}
+
+			if !watchAssumeYes {
+				opts.Confirm = func(candidates []*starmanager.CleanupCandidate) []*starmanager.CleanupCandidate {
+					if len(candidates) == 0 {
+						return candidates
+					}
+
+					if watchInteractive {
+						confirmed := []*starmanager.CleanupCandidate{}
+						for _, c := range candidates {
+							if confirm(in, fmt.Sprintf("Unwatch %s (%s)?", c.Star.URL, strings.Join(c.Reasons, ", "))) {
+								confirmed = append(confirmed, c)
+							}
+						}
+
+						return confirmed
+					}
+
+					if err := printCandidates(candidates); err != nil {
+						log.Printf("Could not list repositories to unwatch: %v", err.Error())
+					}
+
+					if confirm(in, fmt.Sprintf("Unwatch these %d repositories?", len(candidates))) {
+						return candidates
+					}
+
+					return nil
+				}
+			}
+
+			result, err := sm.CleanupSubscriptions(ctx, opts)
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				if err := out.write(result); err != nil {
+					return err
+				}
+
+				return result.Err()
+			}
+
+			if watchDryRun {
+				if err := printCandidates(result.Candidates); err != nil {
+					return err
+				}
+
+				fmt.Printf("%d would be unwatched\n", result.Matched)
+				return nil
+			}
+
+			fmt.Printf("%d unwatched, %d skipped, %d failed\n", result.Removed, result.Skipped, result.Failed)
+
+			return result.Err()
+		},
+	}
+
+	watchingCleanupCmd.PersistentFlags().IntVarP(&watchMonths, "months", "m", 12, "Number of months to unwatch repositories not pushed to in (0 for any age; not applied with --include-forks or --score-below unless given)")
+	watchingCleanupCmd.PersistentFlags().IntVar(&watchScoreBelow, "score-below", 0, "Include repositories with a health score below this (see health)")
+	watchingCleanupCmd.PersistentFlags().BoolVarP(&watchArchived, "include-archived", "a", false, "Include archived repositories")
+	watchingCleanupCmd.PersistentFlags().BoolVarP(&watchForks, "include-forks", "f", false, "Include forks whose upstream is watched too")
+	watchingCleanupCmd.PersistentFlags().BoolVarP(&watchDryRun, "dry-run", "n", false, "Only list the repositories that would be unwatched and why")
+	watchingCleanupCmd.PersistentFlags().BoolVarP(&watchInteractive, "interactive", "i", false, "Confirm every repository separately")
+	watchingCleanupCmd.PersistentFlags().BoolVarP(&watchAssumeYes, "yes", "y", false, "Unwatch matching repositories without asking for confirmation")
+	watchingCleanupCmd.PersistentFlags().StringVar(&watchRulesFile, "rules", "", "Select repositories to unwatch with the unstar rules in this YAML file instead of --months and --include-archived")
+
+	watchingCmd.AddCommand(watchingSyncCmd, watchingCompareCmd, watchingUnwatchCmd, watchingCleanupCmd)
+
+	var (
+		serveAddr    string
+		serveToken   string
+		serveDebug   bool
+		serveMetrics bool
+	)
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a web dashboard",
+		Long: `Serves a web dashboard for browsing, searching and cleaning up stars, along with the
+JSON API it uses under /api and Atom and RSS feeds of the most recently starred projects
+under /feed.atom and /feed.rss, until interrupted. Cleanups have to be previewed and
+confirmed in the dashboard. Dashboards served on other than loopback addresses require a
+token, which has to be given as ?token= when opening them`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serveToken == "" {
+				serveToken = os.Getenv(server.TokenEnv)
+			}
+
+			if serveToken == "" && !loopbackAddr(serveAddr) {
+				return fmt.Errorf("serving the dashboard on %s requires --token or $%s", serveAddr, server.TokenEnv)
+			}
+
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			mux := http.NewServeMux()
+			server.RegisterDashboard(mux, sm, server.DashboardOptions{
+				Token:   serveToken,
+				Debug:   serveDebug,
+				Metrics: serveMetrics,
+			})
+
+			srv := &http.Server{Addr: serveAddr, Handler: mux}
+			go func() {
+				<-ctx.Done()
+				srv.Shutdown(context.Background())
+			}()
+
+			log.Printf("Serving the dashboard on http://%s", serveAddr)
+			if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	serveCmd.PersistentFlags().StringVarP(&serveAddr, "addr", "a", "localhost:8080", "The address to serve the dashboard on")
+	serveCmd.PersistentFlags().StringVar(&serveToken, "token", "", "Require this token with every request, as ?token= or a bearer token (default $"+server.TokenEnv+")")
+	serveCmd.PersistentFlags().BoolVar(&serveDebug, "debug", false, "Also serve pprof and runtime stats under /debug, behind the same token")
+	serveCmd.PersistentFlags().BoolVar(&serveMetrics, "metrics", false, "Also serve Prometheus metrics under /metrics, behind the same token")
+
+	var (
+		daemonSchedule    string
+		daemonWait        bool
+		daemonPrune       bool
+		daemonIncremental bool
+		daemonReadmes     bool
+		daemonRules       string
+		daemonEnforce     bool
+		daemonReport      string
+		daemonReleases    bool
+		daemonNotify      []string
+	)
+
+	daemonCmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Sync and clean up stars on a schedule",
+		Long: `Syncs stars on a cron-like schedule until interrupted, keeping the cache fresh, e.g. as a
+systemd or launchd service. With --rules the cleanup rules in the file are applied after
+every successful sync, as a dry run unless --enforce is given. What every run changed is
+logged and, with --report, appended to a file as a line of JSON. With --notify, newly
+archived stars, new releases and cleanups are announced on the desktop, in Slack or by email`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			schedule, err := utils.ParseSchedule(daemonSchedule)
+			if err != nil {
+				return err
+			}
+
+			opts := starmanager.DaemonOptions{
+				Schedule:    schedule,
+				Immediately: !daemonWait,
+				Sync: starmanager.SyncOptions{
+					Prune:       daemonPrune,
+					Incremental: daemonIncremental,
+					Readmes:     daemonReadmes,
+					Releases:    daemonReleases,
+				},
+				Enforce: daemonEnforce,
+			}
+
+			if opts.Notifiers, err = notifiers(sm, daemonNotify); err != nil {
+				return err
+			}
+
+			if daemonRules != "" {
+				policy, err := starmanager.LoadPolicy(daemonRules)
+				if err != nil {
+					return err
+				}
+
+				opts.Cleanup = &starmanager.CleanupOptions{Policy: policy}
+			}
+
+			switch {
+			case daemonReport != "":
+				f, err := os.OpenFile(daemonReport, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				opts.Report = f
+			case out.structured():
+				opts.Report = os.Stdout
+			}
+
+			return sm.RunDaemon(ctx, opts)
+		},
+	}
+
+	daemonCmd.PersistentFlags().StringVarP(&daemonSchedule, "schedule", "s", "@every 6h", "When to sync: five cron fields such as \"0 */6 * * *\", @hourly, @daily or @every with a duration")
+	daemonCmd.PersistentFlags().BoolVarP(&daemonWait, "wait", "w", false, "Wait for the first scheduled time instead of syncing right away")
+	daemonCmd.PersistentFlags().BoolVarP(&daemonPrune, "prune", "p", false, "Remove cached stars that are no longer starred")
+	daemonCmd.PersistentFlags().BoolVarP(&daemonIncremental, "incremental", "i", false, "Only fetch pages that changed since the last sync")
+	daemonCmd.PersistentFlags().BoolVar(&daemonReadmes, "readmes", false, "Also download the READMEs of changed stars")
+	daemonCmd.PersistentFlags().BoolVar(&daemonReleases, "releases", false, "Also fetch the latest releases to notify about new ones (slow, one request per star)")
+	daemonCmd.PersistentFlags().StringSliceVar(&daemonNotify, "notify", nil, "Notify about archived stars, new releases and cleanups through these channels: desktop, slack:WEBHOOK or smtp://... (default $"+starmanager.NotifyEnv+")")
+	daemonCmd.PersistentFlags().StringVar(&daemonRules, "rules", "", "Clean up with the rules in this YAML file after every sync (see cleanup)")
+	daemonCmd.PersistentFlags().BoolVar(&daemonEnforce, "enforce", false, "Remove the stars the rules match instead of only reporting them")
+	daemonCmd.PersistentFlags().StringVarP(&daemonReport, "report", "r", "", "Append a JSON report of every run to this file")
+
+	var (
+		reviewLanguages []string
+		reviewTopics    []string
+		reviewTags      []string
+		reviewCleanup   bool
+		reviewSelect    cleanupFlags
+	)
+
+	reviewCmd := &cobra.Command{
+		Use:   "review [QUERY...]",
+		Short: "Review stars interactively",
+		Long: `Lists stars in an interactive terminal interface with fuzzy filtering and a detail pane.
+Keys move through the list (up/down, j/k, g/G), filter it (/), tag (t) or pin (p) the
+selected star, open it in the browser (o) or unstar it (u). With --cleanup only the stars
+cleanup would remove are listed, along with why`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := sm.SaveIfEmpty(ctx); err != nil {
+				return err
+			}
+
+			items := []*tui.Item{}
+
+			if reviewCleanup {
+				opts, err := reviewSelect.options(ctx, cmd, sm)
+				if err != nil {
+					return err
+				}
+
+				candidates, err := sm.CleanupCandidates(ctx, opts)
+				if err != nil {
+					return err
+				}
+
+				for _, c := range candidates {
+					items = append(items, &tui.Item{Star: *c.Star, Reasons: c.Reasons})
+				}
+			} else {
+				stars, err := sm.GetProjects(ctx, starmanager.ProjectOptions{
+					Languages: reviewLanguages,
+					Topics:    reviewTopics,
+					Tags:      reviewTags,
+					Query:     strings.Join(args, " "),
+				})
+				if err != nil {
+					return err
+				}
+
+				for _, star := range stars {
+					items = append(items, &tui.Item{Star: star})
+				}
+			}
+
+			return tui.Run(ctx, os.Stdin, os.Stdout, items, sm, tui.Options{Open: browser.OpenURL})
+		},
+	}
+
+	reviewCmd.PersistentFlags().StringSliceVarP(&reviewLanguages, "language", "l", nil, "Only list stars written in these languages")
+	reviewCmd.PersistentFlags().StringSliceVarP(&reviewTopics, "topic", "t", nil, "Only list stars with any of these topics")
+	reviewCmd.PersistentFlags().StringSliceVar(&reviewTags, "tag", nil, "Only list stars with any of these local tags")
+	reviewCmd.PersistentFlags().BoolVarP(&reviewCleanup, "cleanup", "c", false, "Only list the stars cleanup would remove")
+	reviewSelect.register(reviewCmd, "With --cleanup, ")
+
+	var reconcileDryRun bool
+
+	reconcileCmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Follow renamed repositories and find deleted ones",
+		Long: `Checks every star against its provider. Stars of renamed or transferred repositories are
+moved to their new URL, and stars of deleted repositories are flagged as gone so that
+"cleanup --include-gone" can remove them and "show gone:true" lists them`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := sm.Reconcile(ctx, starmanager.ReconcileOptions{DryRun: reconcileDryRun})
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				if err := out.write(result); err != nil {
+					return err
+				}
+
+				return result.Err()
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+			for _, r := range result.Renamed {
+				fmt.Fprintf(w, "moved\t%s\t-> %s\n", r.From, r.To)
+			}
+
+			for _, url := range result.Gone {
+				fmt.Fprintf(w, "gone\t%s\n", url)
+			}
+
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			fmt.Printf("%d checked, %d moved, %d gone, %d failed\n", result.Checked, len(result.Renamed), len(result.Gone), result.Failed)
+
+			return result.Err()
+		},
+	}
+
+	reconcileCmd.PersistentFlags().BoolVarP(&reconcileDryRun, "dry-run", "n", false, "Only report moved and deleted repositories without updating the cache")
+
+	var starDryRun bool
+
+	starCmd := &cobra.Command{
+		Use:   "star URL|OWNER/REPO...",
+		Short: "Star repositories",
+		Long: `Stars the given GitHub repositories and adds them to the cache right away, without
+waiting for the next sync`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sm.DryRun = starDryRun
+			starred := []*starmanager.Star{}
+
+			for _, ref := range args {
+				owner, repo, err := sm.GitHubRepo(ref)
+				if err != nil {
+					return err
+				}
+
+				star, err := sm.AddStar(ctx, owner, repo)
+				if err != nil {
+					return err
+				}
+
+				starred = append(starred, star)
+				if out.structured() {
+					continue
+				}
+
+				if starDryRun {
+					fmt.Printf("Would star %s\n", star.URL)
+				} else {
+					fmt.Printf("Starred %s\n", star.URL)
+				}
+			}
+
+			if out.structured() {
+				return out.write(starred)
+			}
+
+			return nil
+		},
+	}
+
+	starCmd.PersistentFlags().BoolVarP(&starDryRun, "dry-run", "n", false, "Only check that the repositories exist")
+
+	graveyardCmd := &cobra.Command{
+		Use:   "graveyard",
+		Short: "List removed stars",
+		Long:  "Displays the stars removed by cleanup, most recently removed first, along with why they were removed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			graves, err := sm.Graveyard(ctx)
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(graves)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
+			for i, grave := range graves {
+				if i == 0 {
+					fmt.Fprintln(w, "REMOVED\tURL\tREASON")
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%s\n", grave.RemovedAt.Format(time.RFC3339), grave.URL, grave.Reason)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	restoreCmd := &cobra.Command{
+		Use:   "restore URL...",
+		Short: "Restore removed stars",
+		Long:  "Stars the given projects from the graveyard again",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, url := range args {
+				if err := sm.Restore(ctx, url); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	graveyardCmd.AddCommand(restoreCmd)
+
+	undoCmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Undo the last cleanup",
+		Long:  "Stars all projects removed by the most recent cleanup again",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			restored, err := sm.Undo(ctx)
+			if out.structured() {
+				if werr := out.write(restored); werr != nil {
+					return werr
+				}
+
+				return err
+			}
+
+			fmt.Printf("%d restored\n", len(restored))
+
+			return err
+		},
+	}
+
+	completionCmd := &cobra.Command{
+		Use:         "completion",
+		Annotations: map[string]string{withoutStarManager: ""},
+		Short:       "Generate completion",
+		Long:        "Outputs an autocompletion script to be sourced by a target shell",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Println(`Outputs autocompletion scripts for the CLI. Please refer
+to your shell's documentation on how to configure autocompletion.
+			`)
+			cmd.Help()
+
+			return nil
+		},
+	}
+
+	bashCompletionCmd := &cobra.Command{
+		Use:         "bash",
+		Annotations: map[string]string{withoutStarManager: ""},
+		Short:       "Generate bash completion",
+		Long:        "Outputs Bash autocompletion script",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.GenBashCompletion(os.Stdout)
+		},
+	}
+
+	zshCompletionCmd := &cobra.Command{
+		Use:         "zsh",
+		Annotations: map[string]string{withoutStarManager: ""},
+		Short:       "Generate Zsh completion",
+		Long:        "Outputs Zsh autocompletion script",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.GenZshCompletion(os.Stdout)
+		},
+	}
+
+	completionCmd.AddCommand(bashCompletionCmd, zshCompletionCmd)
+
+	var clientID string
+
+	loginCmd := &cobra.Command{
+		Use:   "login",
+		Short: "Log in to GitHub",
+		Long: `Authorizes stars on GitHub, or the instance given with --host, by entering a code on the
+GitHub website, and stores the token for later runs in the keyring of the system, or in
+~/.config/stars/tokens.yml if there is none. This needs the client ID of a GitHub OAuth
+app with the device flow enabled.
+
+Credentials are looked up in $GITHUB_TOKEN or $GH_TOKEN first, then in the keyring, then
+in the tokens file, then in the credentials of the gh CLI and finally in ~/.netrc`,
+		Annotations: map[string]string{withoutStarManager: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := httpOptions()
+			if host != "" {
+				opts = append(opts, starmanager.WithHost(host))
+			}
+
+			login, err := starmanager.Login(ctx, starmanager.LoginOptions{
+				ClientID: clientID,
+				Prompt: func(code *auth.DeviceCode) {
+					fmt.Fprintf(os.Stderr, "Enter the code %s at %s\n", code.UserCode, code.VerificationURI)
+				},
+			}, opts...)
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(map[string]string{"login": login})
+			}
+
+			fmt.Printf("Logged in as %s\n", login)
+			return nil
+		},
+	}
+
+	loginCmd.PersistentFlags().StringVar(&clientID, "client-id", "", "Client ID of the GitHub OAuth app to authorize (default $"+auth.ClientIDEnv+")")
+
+	logoutCmd := &cobra.Command{
+		Use:         "logout",
+		Short:       "Remove the tokens stored by login or token set",
+		Annotations: map[string]string{withoutStarManager: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := []starmanager.Option{}
+			if host != "" {
+				opts = append(opts, starmanager.WithHost(host))
+			}
+
+			return starmanager.Logout(opts...)
+		},
+	}
+
+	var tokenFile bool
+
+	tokenCmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manage the stored GitHub token",
+		Long:  "Store, rotate and delete the GitHub token kept in the keyring of the system",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.Help()
+			return nil
+		},
+	}
+
+	tokenSetCmd := &cobra.Command{
+		Use:   "set",
+		Short: "Store a token, replacing the one stored before",
+		Long: `Reads a token from stdin, checks it with GitHub, or the instance given with --host, and
+stores it in the keyring of the system for later runs, replacing the token stored before.
+Rotating a token is storing the new one:
+
+  $ pbpaste | stars token set`,
+		Annotations: map[string]string{withoutStarManager: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && err != io.EOF {
+				return err
+			}
+
+			token = strings.TrimSpace(token)
+			if token == "" {
+				return errors.New("no token given on stdin")
+			}
+
+			var store auth.TokenStore
+			if tokenFile {
+				if store, err = auth.NewTokenFile(); err != nil {
+					return err
+				}
+			}
+
+			opts := httpOptions()
+			if host != "" {
+				opts = append(opts, starmanager.WithHost(host))
+			}
+
+			login, err := starmanager.StoreToken(ctx, store, token, opts...)
+			if err != nil {
+				return err
+			}
+
+			if out.structured() {
+				return out.write(map[string]string{"login": login})
+			}
+
+			fmt.Printf("Stored the token of %s\n", login)
+			return nil
+		},
+	}
+
+	tokenSetCmd.PersistentFlags().BoolVar(&tokenFile, "file", false, "Store the token in ~/.config/stars/tokens.yml instead of the keyring")
+
+	tokenDeleteCmd := &cobra.Command{
+		Use:         "delete",
+		Short:       "Delete the stored token",
+		Annotations: map[string]string{withoutStarManager: ""},
+		RunE:        logoutCmd.RunE,
+	}
+
+	tokenCmd.AddCommand(tokenSetCmd, tokenDeleteCmd)
 
 	starsCmd.AddCommand(
 		versionCmd,
 		saveAllStarsCmd,
 		topicsCmd,
+		ownersCmd,
+		statsCmd,
+		trendsCmd,
+		releasesCmd,
+		diffCmd,
+		healthCmd,
 		showStarsCmd,
+		infoCmd,
+		readmeCmd,
+		searchCmd,
 		clearCmd,
+		cacheCmd,
+		exportCmd,
+		importCmd,
+		cloneCmd,
+		depsCmd,
+		recommendCmd,
+		duplicatesCmd,
+		pinCmd,
+		unpinCmd,
+		tagCmd,
+		untagCmd,
+		tagsCmd,
+		noteCmd,
+		listsCmd,
+		reconcileCmd,
+		starCmd,
+		reviewCmd,
+		serveCmd,
+		daemonCmd,
 		cleanupCmd,
+		watchingCmd,
+		graveyardCmd,
+		undoCmd,
+		loginCmd,
+		logoutCmd,
+		tokenCmd,
 		completionCmd,
 	)
 
 	if err := starsCmd.Execute(); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
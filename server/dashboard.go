@@ -0,0 +1,502 @@
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gkze/stars/starmanager"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultFeedItems - the number of stars feeds list unless a count is given
+	DefaultFeedItems int = 50
+
+	// TokenEnv - the environment variable holding the token the dashboard requires
+	TokenEnv string = "STARS_DASHBOARD_TOKEN"
+)
+
+// DashboardOptions - the parameters of RegisterDashboard
+type DashboardOptions struct {
+	// Token has to come with every request if set, as a bearer token in the Authorization
+	// header or as the token query parameter, e.g. for feed readers. Without a token only
+	// requests for localhost or an IP address are served, so that other sites cannot reach
+	// the dashboard through DNS rebinding.
+	Token string
+
+	// Debug also serves the debug endpoints of RegisterDebug, and Metrics the metrics of
+	// RegisterMetrics, both behind the same token or host check as the dashboard
+	Debug   bool
+	Metrics bool
+}
+
+// SyncRequest - the parameters of a sync triggered through the dashboard
+type SyncRequest struct {
+	Prune       bool `json:"prune"`
+	Incremental bool `json:"incremental"`
+}
+
+// SyncResponse summarizes a sync triggered through the dashboard
+type SyncResponse struct {
+	Added     int           `json:"added"`
+	Updated   int           `json:"updated"`
+	Removed   int           `json:"removed"`
+	Failed    int           `json:"failed"`
+	Unchanged int           `json:"unchanged"`
+	Duration  time.Duration `json:"duration"`
+	Errors    []string      `json:"errors"`
+}
+
+// CleanupRequest selects the stars a cleanup triggered through the dashboard removes, like
+// the options of the cleanup command. Only the stars listed in Confirm are removed, so
+// that every removal is confirmed in the dashboard first.
+type CleanupRequest struct {
+	Months     int  `json:"months"`
+	ByStarred  bool `json:"by_starred"`
+	Archived   bool `json:"archived"`
+	Gone       bool `json:"gone"`
+	Forks      bool `json:"forks"`
+	ScoreBelow int  `json:"score_below"`
+
+	// Confirm are the URLs of the matching stars to remove
+	Confirm []string `json:"confirm"`
+}
+
+// CleanupResponse summarizes a cleanup triggered through the dashboard
+type CleanupResponse struct {
+	Matched int      `json:"matched"`
+	Removed int      `json:"removed"`
+	Skipped int      `json:"skipped"`
+	Failed  int      `json:"failed"`
+	Errors  []string `json:"errors"`
+}
+
+// options returns the cleanup options of a request
+func (c *CleanupRequest) options() starmanager.CleanupOptions {
+	return starmanager.CleanupOptions{
+		Months:     c.Months,
+		ByStarred:  c.ByStarred,
+		Archived:   c.Archived,
+		Gone:       c.Gone,
+		Forks:      c.Forks,
+		ScoreBelow: c.ScoreBelow,
+	}
+}
+
+// dashboard serves the web interface and the JSON API over the cache
+type dashboard struct {
+	stars *starmanager.StarManager
+	token string
+
+	// busy keeps syncs and cleanups from running at the same time
+	busy sync.Mutex
+}
+
+// RegisterDashboard mounts a web interface for browsing, searching and cleaning up stars
+// under /, the JSON API it uses under /api and feeds others can subscribe to on the given
+// mux:
+//
+//	GET  /api/stars            stars matching the q, language, topic, tag, list, sort,
+//	                           order and count parameters, like the show command
+//	GET  /api/star?ref=        the details of a star given by URL or as owner/repo,
+//	                           without fetching it unless live is set
+//	GET  /api/stats            breakdowns of the stars
+//	GET  /feed.atom            the most recently starred stars as an Atom feed, filtered
+//	                           with the parameters of /api/stars
+//	GET  /feed.rss             the same as an RSS feed
+//	POST /api/sync             syncs the stars with a SyncRequest
+//	POST /api/cleanup/preview  the stars a CleanupRequest matches and why
+//	POST /api/cleanup          removes the confirmed stars a CleanupRequest matches
+//
+// Requests changing anything have to be JSON POST requests, which browsers do not send
+// across origins without asking the server first. Dashboards served on other addresses than
+// loopback ones should require a token, see DashboardOptions.
+func RegisterDashboard(mux *http.ServeMux, sm *starmanager.StarManager, opts DashboardOptions) {
+	d := &dashboard{stars: sm, token: opts.Token}
+
+	mux.HandleFunc("/", d.guard(d.page))
+	mux.HandleFunc("/api/stars", d.guard(d.listStars))
+	mux.HandleFunc("/api/star", d.guard(d.star))
+	mux.HandleFunc("/api/stats", d.guard(d.stats))
+	mux.HandleFunc("/api/sync", d.guard(d.sync))
+	mux.HandleFunc("/api/cleanup/preview", d.guard(d.previewCleanup))
+	mux.HandleFunc("/api/cleanup", d.guard(d.cleanup))
+	mux.HandleFunc("/feed.atom", d.guard(d.feed(starmanager.ExportAtom)))
+	mux.HandleFunc("/feed.rss", d.guard(d.feed(starmanager.ExportRSS)))
+
+	if opts.Debug {
+		mux.HandleFunc("/debug/", d.guard(DebugHandler().ServeHTTP))
+	}
+	if opts.Metrics {
+		mux.HandleFunc("/metrics", d.guard(MetricsHandler().ServeHTTP))
+	}
+}
+
+// DashboardHandler returns a handler serving only the dashboard
+func DashboardHandler(sm *starmanager.StarManager, opts DashboardOptions) http.Handler {
+	mux := http.NewServeMux()
+	RegisterDashboard(mux, sm, opts)
+
+	return mux
+}
+
+// guard answers requests without the token with an error, or without a token configured,
+// requests for other hosts than localhost and IP addresses
+func (d *dashboard) guard(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if d.token == "" {
+			if !localHost(r.Host) {
+				writeError(w, http.StatusForbidden, fmt.Errorf("unknown host %q, serve the dashboard with a token to allow it", r.Host))
+				return
+			}
+
+			h(w, r)
+			return
+		}
+
+		token := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(d.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("missing or wrong token"))
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// localHost reports whether the host of a request is localhost or an IP address, which
+// other sites cannot make browsers send requests for with their own host name
+func localHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+
+	return host == "localhost" || strings.HasSuffix(host, ".localhost") || net.ParseIP(host) != nil
+}
+
+// writeJSON writes a value as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error as a JSON response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// allow checks the method of a request, and that requests changing anything are JSON. The
+// request is answered with an error if it is not allowed.
+func allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return false
+	}
+
+	if method == http.MethodPost {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, errors.New("requests must be JSON"))
+			return false
+		}
+	}
+
+	return true
+}
+
+// errorStrings returns the messages of errors
+func errorStrings(errs []error) []string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	return messages
+}
+
+func (d *dashboard) page(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(dashboardPage))
+}
+
+func (d *dashboard) listStars(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+
+	opts, err := projectOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Stars are written as they are read, so that large caches are not held in memory
+	written := 0
+	enc := json.NewEncoder(w)
+	err = d.stars.ForEachStar(r.Context(), opts, func(star *starmanager.Star) error {
+		separator := ","
+		if written == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			separator = "["
+		}
+		written++
+
+		if _, err := w.Write([]byte(separator)); err != nil {
+			return err
+		}
+
+		return enc.Encode(star)
+	})
+
+	switch {
+	case err != nil && written == 0:
+		writeError(w, http.StatusBadRequest, err)
+	case err != nil:
+		// The response has been sent in part, so the truncated JSON is all that is left
+		log.Printf("Could not list stars: %v", err)
+	case written == 0:
+		writeError(w, http.StatusBadRequest, starmanager.ErrNoStars)
+	default:
+		w.Write([]byte("]\n"))
+	}
+}
+
+// projectOptions returns the options selecting the stars a request lists
+func projectOptions(query url.Values) (starmanager.ProjectOptions, error) {
+	opts := starmanager.ProjectOptions{
+		Query:     query.Get("q"),
+		Languages: query["language"],
+		Topics:    query["topic"],
+		Owners:    query["owner"],
+		Tags:      query["tag"],
+		List:      query.Get("list"),
+		Sort:      starmanager.SortKey(query.Get("sort")),
+		Order:     starmanager.SortOrder(query.Get("order")),
+	}
+
+	if count := query.Get("count"); count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return opts, err
+		}
+
+		opts.Count = n
+	}
+
+	return opts, nil
+}
+
+// feed returns a handler serving the stars as an Atom or RSS feed
+func (d *dashboard) feed(format starmanager.ExportFormat) http.HandlerFunc {
+	contentType := "application/atom+xml; charset=utf-8"
+	if format == starmanager.ExportRSS {
+		contentType = "application/rss+xml; charset=utf-8"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+
+		opts := starmanager.ExportOptions{Format: format}
+
+		var err error
+		if opts.Filter, err = projectOptions(r.URL.Query()); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if opts.Filter.Count == 0 {
+			opts.Filter.Count = DefaultFeedItems
+		}
+
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		opts.Feed.Link = scheme + "://" + r.Host + r.URL.RequestURI()
+
+		buf := &bytes.Buffer{}
+		if err := d.stars.Export(r.Context(), buf, opts); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Write(buf.Bytes())
+	}
+}
+
+func (d *dashboard) star(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+
+	query := r.URL.Query()
+	live, _ := strconv.ParseBool(query.Get("live"))
+
+	detail, err := d.stars.Show(r.Context(), query.Get("ref"), starmanager.ShowOptions{Offline: !live})
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, detail)
+}
+
+func (d *dashboard) stats(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+
+	stats, err := d.stars.Stats(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (d *dashboard) sync(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+
+	req := SyncRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	d.busy.Lock()
+	defer d.busy.Unlock()
+
+	result, err := d.stars.Sync(r.Context(), starmanager.SyncOptions{Prune: req.Prune, Incremental: req.Incremental})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	errs := make([]error, len(result.Errors))
+	for i, e := range result.Errors {
+		errs[i] = e
+	}
+
+	writeJSON(w, http.StatusOK, &SyncResponse{
+		Added:     result.Added,
+		Updated:   result.Updated,
+		Removed:   result.Removed,
+		Failed:    result.Failed,
+		Unchanged: result.Unchanged,
+		Duration:  result.Duration,
+		Errors:    errorStrings(errs),
+	})
+}
+
+func (d *dashboard) previewCleanup(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+
+	req := CleanupRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	candidates, err := d.stars.CleanupCandidates(r.Context(), req.options())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, candidates)
+}
+
+func (d *dashboard) cleanup(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+
+	req := CleanupRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(req.Confirm) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("no stars confirmed for removal"))
+		return
+	}
+
+	confirmed := make(map[string]bool, len(req.Confirm))
+	for _, url := range req.Confirm {
+		confirmed[url] = true
+	}
+
+	// Only stars that still match are removed, even if more were confirmed
+	opts := req.options()
+	opts.Confirm = func(candidates []*starmanager.CleanupCandidate) []*starmanager.CleanupCandidate {
+		kept := []*starmanager.CleanupCandidate{}
+		for _, c := range candidates {
+			if confirmed[c.Star.URL] {
+				kept = append(kept, c)
+			}
+		}
+
+		return kept
+	}
+
+	d.busy.Lock()
+	defer d.busy.Unlock()
+
+	result, err := d.stars.Cleanup(r.Context(), opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	errs := make([]error, len(result.Errors))
+	for i, e := range result.Errors {
+		errs[i] = e
+	}
+
+	writeJSON(w, http.StatusOK, &CleanupResponse{
+		Matched: result.Matched,
+		Removed: result.Removed,
+		Skipped: result.Skipped,
+		Failed:  result.Failed,
+		Errors:  errorStrings(errs),
+	})
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/gkze/stars/starmanager"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProvider lists a fixed page of stars and records unstarred projects
+type fakeProvider struct {
+	stars     []*starmanager.Star
+	unstarred []string
+}
+
+func (f *fakeProvider) Name() string { return "github.com" }
+
+func (f *fakeProvider) ListStars(ctx context.Context, page int, etag string) (*starmanager.StarPage, error) {
+	return &starmanager.StarPage{Stars: f.stars, LastPage: 1}, nil
+}
+
+func (f *fakeProvider) Star(ctx context.Context, owner, repo string) error { return nil }
+
+func (f *fakeProvider) Unstar(ctx context.Context, owner, repo string) error {
+	f.unstarred = append(f.unstarred, owner+"/"+repo)
+	return nil
+}
+
+func (f *fakeProvider) Resolve(ctx context.Context, owner, repo string) (string, error) {
+	return "https://github.com/" + owner + "/" + repo, nil
+}
+
+func newTestDashboard(t *testing.T) (http.Handler, *starmanager.StarManager, *fakeProvider, func()) {
+	dir, err := ioutil.TempDir("", "stars")
+	assert.NoError(t, err)
+
+	db, err := storm.Open(filepath.Join(dir, starmanager.CacheFile))
+	assert.NoError(t, err)
+
+	provider := &fakeProvider{}
+	sm := &starmanager.StarManager{DB: db, Providers: []starmanager.Provider{provider}}
+
+	return DashboardHandler(sm, DashboardOptions{}), sm, provider, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// do sends a request to the dashboard, decoding a JSON response into out
+func do(t *testing.T, handler http.Handler, method, path, body string, out interface{}) int {
+	req := httptest.NewRequest(method, "http://localhost"+path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if out != nil {
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(out), path)
+	}
+
+	return rec.Code
+}
+
+func TestDashboardPage(t *testing.T) {
+	handler, _, _, cleanup := newTestDashboard(t)
+	defer cleanup()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://localhost/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<title>stars</title>")
+
+	assert.Equal(t, http.StatusNotFound, do(t, handler, "GET", "/nothing", "", nil))
+}
+
+func TestDashboardStars(t *testing.T) {
+	handler, sm, _, cleanup := newTestDashboard(t)
+	defer cleanup()
+
+	assert.NoError(t, sm.DB.Save(&starmanager.Star{URL: "https://github.com/a/cli", Language: "go", Stargazers: 10, Topics: []string{"cli"}}))
+	assert.NoError(t, sm.DB.Save(&starmanager.Star{URL: "https://github.com/a/web", Language: "javascript", Stargazers: 20}))
+
+	stars := []starmanager.Star{}
+	assert.Equal(t, http.StatusOK, do(t, handler, "GET", "/api/stars", "", &stars))
+	assert.Len(t, stars, 2)
+
+	assert.Equal(t, http.StatusOK, do(t, handler, "GET", "/api/stars?language=go", "", &stars))
+	assert.Len(t, stars, 1)
+	assert.Equal(t, "https://github.com/a/cli", stars[0].URL)
+
+	assert.Equal(t, http.StatusOK, do(t, handler, "GET", "/api/stars?count=1&sort=name", "", &stars))
+	assert.Len(t, stars, 1)
+
+	assert.Equal(t, http.StatusOK, do(t, handler, "GET", "/api/stars?sort=url", "", &stars))
+	assert.Equal(t, "https://github.com/a/cli", stars[0].URL)
+	assert.Equal(t, "https://github.com/a/web", stars[1].URL)
+
+	assert.Equal(t, http.StatusBadRequest, do(t, handler, "GET", "/api/stars?language=haskell", "", nil))
+	assert.Equal(t, http.StatusBadRequest, do(t, handler, "GET", "/api/stars?sort=size", "", nil))
+
+	assert.Equal(t, http.StatusBadRequest, do(t, handler, "GET", "/api/stars?count=many", "", nil))
+
+	detail := starmanager.StarDetail{}
+	assert.Equal(t, http.StatusOK, do(t, handler, "GET", "/api/star?ref=https://github.com/a/cli", "", &detail))
+	assert.Equal(t, "go", detail.Star.Language)
+	assert.True(t, detail.Cached)
+
+	assert.Equal(t, http.StatusNotFound, do(t, handler, "GET", "/api/star?ref=a/missing", "", nil))
+
+	stats := starmanager.Stats{}
+	assert.Equal(t, http.StatusOK, do(t, handler, "GET", "/api/stats", "", &stats))
+	assert.Equal(t, 2, stats.Total)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, do(t, handler, "POST", "/api/stars", "{}", nil))
+}
+
+func TestDashboardFeeds(t *testing.T) {
+	handler, sm, _, cleanup := newTestDashboard(t)
+	defer cleanup()
+
+	starred := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, sm.DB.Save(&starmanager.Star{URL: "https://github.com/a/cli", Language: "go", StarredAt: starred}))
+	assert.NoError(t, sm.DB.Save(&starmanager.Star{URL: "https://github.com/a/web", Language: "javascript", StarredAt: starred.AddDate(0, 1, 0)}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://localhost:8080/feed.atom?language=go", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "<id>http://localhost:8080/feed.atom?language=go</id>")
+	assert.Contains(t, rec.Body.String(), "<id>https://github.com/a/cli</id>")
+	assert.NotContains(t, rec.Body.String(), "a/web")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://localhost/feed.rss", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/rss+xml; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.True(t, strings.Index(rec.Body.String(), "a/web") < strings.Index(rec.Body.String(), "a/cli"))
+
+	assert.Equal(t, http.StatusBadRequest, do(t, handler, "GET", "/feed.rss?count=many", "", nil))
+}
+
+func TestDashboardSync(t *testing.T) {
+	handler, sm, provider, cleanup := newTestDashboard(t)
+	defer cleanup()
+
+	provider.stars = []*starmanager.Star{{URL: "https://github.com/a/new", Provider: "github.com"}}
+
+	result := SyncResponse{}
+	assert.Equal(t, http.StatusOK, do(t, handler, "POST", "/api/sync", "{}", &result))
+	assert.Equal(t, 1, result.Added)
+	assert.Empty(t, result.Errors)
+	assert.NoError(t, sm.DB.One("URL", "https://github.com/a/new", &starmanager.Star{}))
+
+	// Changes have to be JSON POST requests
+	assert.Equal(t, http.StatusMethodNotAllowed, do(t, handler, "GET", "/api/sync", "", nil))
+
+	req := httptest.NewRequest("POST", "http://localhost/api/sync", strings.NewReader("prune=true"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
+
+func TestDashboardCleanup(t *testing.T) {
+	handler, sm, provider, cleanup := newTestDashboard(t)
+	defer cleanup()
+
+	old := time.Now().AddDate(-3, 0, 0)
+	assert.NoError(t, sm.DB.Save(&starmanager.Star{URL: "https://github.com/a/old", Provider: "github.com", PushedAt: old}))
+	assert.NoError(t, sm.DB.Save(&starmanager.Star{URL: "https://github.com/a/older", Provider: "github.com", PushedAt: old}))
+	assert.NoError(t, sm.DB.Save(&starmanager.Star{URL: "https://github.com/a/new", Provider: "github.com", PushedAt: time.Now()}))
+
+	candidates := []*starmanager.CleanupCandidate{}
+	assert.Equal(t, http.StatusOK, do(t, handler, "POST", "/api/cleanup/preview", `{"months": 24}`, &candidates))
+	assert.Len(t, candidates, 2)
+	assert.Empty(t, provider.unstarred)
+
+	// Nothing is removed without confirmation
+	assert.Equal(t, http.StatusBadRequest, do(t, handler, "POST", "/api/cleanup", `{"months": 24}`, nil))
+
+	// Only confirmed stars that match are removed
+	result := CleanupResponse{}
+	body := `{"months": 24, "confirm": ["https://github.com/a/old", "https://github.com/a/new"]}`
+	assert.Equal(t, http.StatusOK, do(t, handler, "POST", "/api/cleanup", body, &result))
+	assert.Equal(t, 2, result.Matched)
+	assert.Equal(t, 1, result.Removed)
+	assert.Equal(t, 1, result.Skipped)
+	assert.Equal(t, []string{"a/old"}, provider.unstarred)
+
+	stars := []starmanager.Star{}
+	assert.NoError(t, sm.DB.All(&stars))
+	assert.Len(t, stars, 2)
+}
+
+func TestDashboardGuard(t *testing.T) {
+	handler, sm, _, cleanup := newTestDashboard(t)
+	defer cleanup()
+
+	status := func(handler http.Handler, target string, header http.Header) int {
+		req := httptest.NewRequest("GET", target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	// Without a token only local hosts are served, keeping DNS rebinding out
+	for target, expected := range map[string]int{
+		"http://localhost:8080/api/stats":   http.StatusOK,
+		"http://app.localhost/api/stats":    http.StatusOK,
+		"http://127.0.0.1:8080/api/stats":   http.StatusOK,
+		"http://[::1]:8080/api/stats":       http.StatusOK,
+		"http://192.168.1.2/feed.atom":      http.StatusOK,
+		"http://attacker.example/api/stats": http.StatusForbidden,
+		"http://localhost.example/":         http.StatusForbidden,
+	} {
+		assert.Equal(t, expected, status(handler, target, nil), target)
+	}
+
+	// With a token any host is served, but only with the token
+	handler = DashboardHandler(sm, DashboardOptions{Token: "secret", Debug: true, Metrics: true})
+	bearer := func(token string) http.Header {
+		return http.Header{"Authorization": []string{"Bearer " + token}}
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, status(handler, "http://stars.example.com/api/stats", nil))
+	assert.Equal(t, http.StatusUnauthorized, status(handler, "http://localhost/api/stats", bearer("guess")))
+	assert.Equal(t, http.StatusUnauthorized, status(handler, "http://localhost/feed.rss?token=guess", nil))
+	assert.Equal(t, http.StatusOK, status(handler, "http://stars.example.com/api/stats", bearer("secret")))
+	assert.Equal(t, http.StatusOK, status(handler, "http://stars.example.com/feed.rss?token=secret", nil))
+	assert.Equal(t, http.StatusOK, status(handler, "http://stars.example.com/?token=secret", nil))
+
+	// The debug endpoints and metrics are guarded alike, as pprof reveals the command line
+	for _, target := range []string{"/debug/pprof/cmdline", "/debug/runtime", "/metrics"} {
+		assert.Equal(t, http.StatusUnauthorized, status(handler, "http://stars.example.com"+target, nil), target)
+		assert.Equal(t, http.StatusOK, status(handler, "http://stars.example.com"+target, bearer("secret")), target)
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// started is the time the process began serving, used to report uptime
+var started = time.Now()
+
+// RuntimeStats is a point-in-time snapshot of Go runtime statistics
+type RuntimeStats struct {
+	GoVersion     string  `json:"go_version"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+	Goroutines    int     `json:"goroutines"`
+	GOMAXPROCS    int     `json:"gomaxprocs"`
+	CPUs          int     `json:"cpus"`
+	HeapAlloc     uint64  `json:"heap_alloc"`
+	HeapInuse     uint64  `json:"heap_inuse"`
+	HeapObjects   uint64  `json:"heap_objects"`
+	TotalAlloc    uint64  `json:"total_alloc"`
+	Sys           uint64  `json:"sys"`
+	NumGC         uint32  `json:"num_gc"`
+	PauseTotalNs  uint64  `json:"pause_total_ns"`
+}
+
+// ReadRuntimeStats collects the current runtime statistics
+func ReadRuntimeStats() RuntimeStats {
+	mem := runtime.MemStats{}
+	runtime.ReadMemStats(&mem)
+
+	return RuntimeStats{
+		GoVersion:     runtime.Version(),
+		UptimeSeconds: time.Since(started).Seconds(),
+		Goroutines:    runtime.NumGoroutine(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		CPUs:          runtime.NumCPU(),
+		HeapAlloc:     mem.HeapAlloc,
+		HeapInuse:     mem.HeapInuse,
+		HeapObjects:   mem.HeapObjects,
+		TotalAlloc:    mem.TotalAlloc,
+		Sys:           mem.Sys,
+		NumGC:         mem.NumGC,
+		PauseTotalNs:  mem.PauseTotalNs,
+	}
+}
+
+// RegisterDebug mounts the pprof handlers under /debug/pprof and a JSON runtime
+// statistics endpoint under /debug/runtime on the given mux
+func RegisterDebug(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/runtime", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ReadRuntimeStats())
+	})
+}
+
+// DebugHandler returns a handler serving only the debug endpoints
+func DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	RegisterDebug(mux)
+
+	return mux
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugHandler(t *testing.T) {
+	testCases := []struct {
+		path   string
+		status int
+	}{
+		{path: "/debug/runtime", status: http.StatusOK},
+		{path: "/debug/pprof/", status: http.StatusOK},
+		{path: "/debug/pprof/goroutine?debug=1", status: http.StatusOK},
+		{path: "/notdebug", status: http.StatusNotFound},
+	}
+
+	handler := DebugHandler()
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", tc.path, nil))
+		assert.Equal(t, tc.status, rec.Code, tc.path)
+	}
+}
+
+func TestDebugRuntimeStats(t *testing.T) {
+	rec := httptest.NewRecorder()
+	DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/runtime", nil))
+
+	assert.Contains(t, rec.Body.String(), `"uptime_seconds":`)
+
+	stats := RuntimeStats{}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&stats))
+	assert.NotZero(t, stats.Goroutines)
+	assert.NotEmpty(t, stats.GoVersion)
+	assert.True(t, stats.UptimeSeconds > 0)
+}
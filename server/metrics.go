@@ -0,0 +1,21 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// RegisterMetrics mounts the metrics of syncs and provider requests under /metrics on the
+// given mux, in the Prometheus text exposition format
+func RegisterMetrics(mux *http.ServeMux) {
+	mux.Handle("/metrics", promhttp.Handler())
+}
+
+// MetricsHandler returns a handler serving only the metrics endpoint
+func MetricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	RegisterMetrics(mux)
+
+	return mux
+}
@@ -0,0 +1,272 @@
+package server
+
+// dashboardPage is the web interface served by the dashboard, which only talks to the JSON
+// API
+const dashboardPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>stars</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 0; color: #24292e; }
+  header { display: flex; align-items: center; gap: 1em; padding: 0.75em 1.5em; background: #24292e; color: #fff; }
+  header h1 { font-size: 1.2em; margin: 0; flex: 1; }
+  nav button { background: none; border: none; color: #c8c9cb; font-size: 1em; cursor: pointer; }
+  nav button.active { color: #fff; font-weight: bold; }
+  main { padding: 1em 1.5em; }
+  section { display: none; }
+  section.active { display: block; }
+  form { display: flex; flex-wrap: wrap; gap: 0.5em; align-items: center; margin-bottom: 1em; }
+  input[type=text], input[type=number] { padding: 0.3em; }
+  table { border-collapse: collapse; width: 100%; }
+  th, td { text-align: left; padding: 0.35em 0.5em; border-bottom: 1px solid #e1e4e8; vertical-align: top; }
+  td.num { text-align: right; }
+  .muted { color: #6a737d; }
+  .charts { display: grid; grid-template-columns: repeat(auto-fill, minmax(320px, 1fr)); gap: 1.5em; }
+  .bar { display: flex; align-items: center; gap: 0.5em; margin: 0.2em 0; }
+  .bar span:first-child { width: 8em; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+  .bar div { background: #0366d6; height: 0.9em; }
+  #status { margin-left: auto; font-size: 0.9em; }
+  .danger { background: #cb2431; color: #fff; border: none; padding: 0.4em 0.8em; cursor: pointer; }
+</style>
+</head>
+<body>
+<header>
+  <h1>stars</h1>
+  <nav>
+    <button data-section="browse" class="active">Browse</button>
+    <button data-section="stats">Statistics</button>
+    <button data-section="cleanup">Cleanup</button>
+  </nav>
+  <span id="status"></span>
+  <button id="sync">Sync</button>
+</header>
+<main>
+  <section id="browse" class="active">
+    <form id="search">
+      <input type="text" name="q" placeholder="Search, e.g. cli language:go stars:>100" size="40">
+      <input type="text" name="language" placeholder="Language">
+      <input type="text" name="topic" placeholder="Topic">
+      <select name="sort">
+        <option value="">Most stars</option>
+        <option value="pushed">Last pushed</option>
+        <option value="starred">Last starred</option>
+        <option value="name">Name</option>
+      </select>
+      <button>Search</button>
+    </form>
+    <table>
+      <thead><tr><th>Repository</th><th>Language</th><th>Stars</th><th>Last pushed</th><th>Topics</th></tr></thead>
+      <tbody id="stars"></tbody>
+    </table>
+  </section>
+
+  <section id="stats">
+    <p id="summary"></p>
+    <div class="charts">
+      <div><h3>Languages</h3><div id="languages"></div></div>
+      <div><h3>Topics</h3><div id="topics"></div></div>
+      <div><h3>Licenses</h3><div id="licenses"></div></div>
+      <div><h3>Starred per year</h3><div id="years"></div></div>
+    </div>
+  </section>
+
+  <section id="cleanup">
+    <form id="cleanup-form">
+      <label>Older than <input type="number" name="months" value="24" min="0" style="width: 4em"> months</label>
+      <label><input type="checkbox" name="by_starred"> by starred date</label>
+      <label><input type="checkbox" name="archived"> archived</label>
+      <label><input type="checkbox" name="gone"> gone</label>
+      <label><input type="checkbox" name="forks"> forks of starred</label>
+      <label>Health below <input type="number" name="score_below" value="0" min="0" max="100" style="width: 4em"></label>
+      <button>Preview</button>
+    </form>
+    <table>
+      <thead><tr><th><input type="checkbox" id="select-all" checked></th><th>Repository</th><th>Reasons</th></tr></thead>
+      <tbody id="candidates"></tbody>
+    </table>
+    <p><button class="danger" id="unstar" disabled>Unstar selected</button></p>
+  </section>
+</main>
+<script>
+  function $(id) { return document.getElementById(id); }
+
+  function cell(row, text, className) {
+    var td = row.insertCell();
+    td.textContent = text;
+    if (className) { td.className = className; }
+    return td;
+  }
+
+  function status(text) { $("status").textContent = text; }
+
+  // The token the dashboard was opened with, if it requires one
+  var token = new URLSearchParams(location.search).get("token");
+
+  function api(path, body) {
+    var init = body === undefined ? {headers: {}} : {
+      method: "POST",
+      headers: {"Content-Type": "application/json"},
+      body: JSON.stringify(body)
+    };
+    if (token) { init.headers["Authorization"] = "Bearer " + token; }
+
+    return fetch(path, init).then(function (resp) {
+      return resp.json().then(function (data) {
+        if (!resp.ok) { throw new Error(data.error); }
+        return data;
+      });
+    });
+  }
+
+  function date(value) { return value && value.indexOf("0001") !== 0 ? value.slice(0, 10) : ""; }
+
+  function link(td, url) {
+    var a = document.createElement("a");
+    a.href = url;
+    a.textContent = url.replace(/^https:\/\//, "");
+    td.appendChild(a);
+  }
+
+  function loadStars() {
+    var form = new FormData($("search"));
+    var params = new URLSearchParams();
+    form.forEach(function (value, key) { if (value) { params.append(key, value); } });
+    params.set("count", "500");
+
+    api("/api/stars?" + params).then(function (stars) {
+      var body = $("stars");
+      body.innerHTML = "";
+      stars.forEach(function (star) {
+        var row = body.insertRow();
+        var td = row.insertCell();
+        link(td, star.URL);
+        if (star.Description) {
+          var desc = document.createElement("div");
+          desc.className = "muted";
+          desc.textContent = star.Description;
+          td.appendChild(desc);
+        }
+        cell(row, star.Language);
+        cell(row, star.Stargazers, "num");
+        cell(row, date(star.PushedAt));
+        cell(row, (star.Topics || []).join(", "));
+      });
+      status(stars.length + " stars");
+    }).catch(function (err) { status(err.message); });
+  }
+
+  function chart(id, counts, top) {
+    var el = $(id);
+    el.innerHTML = "";
+    counts = (counts || []).slice(0, top);
+    var max = Math.max.apply(null, counts.map(function (kv) { return kv.value; }).concat([1]));
+    counts.forEach(function (kv) {
+      var bar = document.createElement("div");
+      bar.className = "bar";
+      var name = document.createElement("span");
+      name.textContent = kv.key || "(none)";
+      var fill = document.createElement("div");
+      fill.style.width = (kv.value / max * 12) + "em";
+      var value = document.createElement("span");
+      value.textContent = kv.value;
+      bar.appendChild(name);
+      bar.appendChild(fill);
+      bar.appendChild(value);
+      el.appendChild(bar);
+    });
+  }
+
+  function loadStats() {
+    api("/api/stats").then(function (stats) {
+      $("summary").textContent = stats.total + " stars, " + stats.active + " active, " +
+        stats.archived + " archived, " + stats.gone + " gone, median " +
+        stats.median_days_since_push + " days since push";
+      chart("languages", stats.languages, 15);
+      chart("topics", stats.topics, 15);
+      chart("licenses", stats.licenses, 10);
+      chart("years", stats.years, 20);
+    }).catch(function (err) { status(err.message); });
+  }
+
+  function cleanupRequest() {
+    var form = $("cleanup-form");
+    return {
+      months: parseInt(form.months.value, 10) || 0,
+      by_starred: form.by_starred.checked,
+      archived: form.archived.checked,
+      gone: form.gone.checked,
+      forks: form.forks.checked,
+      score_below: parseInt(form.score_below.value, 10) || 0
+    };
+  }
+
+  function previewCleanup() {
+    api("/api/cleanup/preview", cleanupRequest()).then(function (candidates) {
+      var body = $("candidates");
+      body.innerHTML = "";
+      candidates.forEach(function (c) {
+        var row = body.insertRow();
+        var box = document.createElement("input");
+        box.type = "checkbox";
+        box.checked = true;
+        box.value = c.star.URL;
+        row.insertCell().appendChild(box);
+        link(row.insertCell(), c.star.URL);
+        cell(row, c.reasons.join(", "));
+      });
+      $("unstar").disabled = candidates.length === 0;
+      status(candidates.length + " stars would be removed");
+    }).catch(function (err) { status(err.message); });
+  }
+
+  function cleanup() {
+    var confirmed = Array.prototype.map.call(
+      document.querySelectorAll("#candidates input:checked"),
+      function (box) { return box.value; }
+    );
+
+    if (confirmed.length === 0 || !window.confirm("Unstar " + confirmed.length + " projects?")) {
+      return;
+    }
+
+    var req = cleanupRequest();
+    req.confirm = confirmed;
+    status("Removing...");
+    api("/api/cleanup", req).then(function (result) {
+      status(result.removed + " removed, " + result.failed + " failed. Undo with stars undo");
+      previewCleanup();
+    }).catch(function (err) { status(err.message); });
+  }
+
+  document.querySelectorAll("nav button").forEach(function (button) {
+    button.addEventListener("click", function () {
+      document.querySelectorAll("nav button, section").forEach(function (el) { el.classList.remove("active"); });
+      button.classList.add("active");
+      $(button.dataset.section).classList.add("active");
+      if (button.dataset.section === "stats") { loadStats(); }
+    });
+  });
+
+  $("search").addEventListener("submit", function (e) { e.preventDefault(); loadStars(); });
+  $("cleanup-form").addEventListener("submit", function (e) { e.preventDefault(); previewCleanup(); });
+  $("unstar").addEventListener("click", cleanup);
+  $("select-all").addEventListener("change", function (e) {
+    document.querySelectorAll("#candidates input").forEach(function (box) { box.checked = e.target.checked; });
+  });
+
+  $("sync").addEventListener("click", function () {
+    status("Syncing...");
+    $("sync").disabled = true;
+    api("/api/sync", {incremental: true}).then(function (result) {
+      status(result.added + " added, " + result.updated + " updated, " + result.failed + " failed");
+      loadStars();
+    }).catch(function (err) { status(err.message); }).then(function () { $("sync").disabled = false; });
+  });
+
+  loadStars();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,64 @@
+package starmanager
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/v25/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// FetchActivity fetches the weekly commit activity of the last year for every cached GitHub star
+// and stores it on the star. GitHub computes these statistics lazily, so stars whose
+// statistics are not ready yet are skipped and picked up by a later run. It returns the
+// number of stars whose activity was updated.
+func (s *StarManager) FetchActivity(ctx context.Context) (int, error) {
+	stars, err := s.store().All()
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, star := range stars {
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+
+		// Commit activity is only available from GitHub
+		if star.ProviderName() != webHost(s.Host) {
+			continue
+		}
+
+		owner, repo, err := star.OwnerRepo()
+		if err != nil {
+			log.Printf("Skipping activity for %s: %v", star.URL, err.Error())
+			continue
+		}
+
+		weeks, _, err := s.Client.Repositories.ListCommitActivity(ctx, owner, repo)
+		if err != nil {
+			if _, ok := err.(*github.AcceptedError); ok {
+				log.Printf("Activity for %s is still being computed by GitHub, try again later", star.URL)
+				continue
+			}
+
+			log.Printf("An error occurred while fetching activity for %s: %v", star.URL, err.Error())
+			continue
+		}
+
+		star.Activity = make([]int, len(weeks))
+		for i, week := range weeks {
+			star.Activity[i] = week.GetTotal()
+		}
+		star.ActivityAt = time.Now()
+
+		if err := s.store().Save(star); err != nil {
+			return updated, err
+		}
+
+		updated++
+	}
+
+	log.Printf("Updated commit activity for %d stars", updated)
+	return updated, nil
+}
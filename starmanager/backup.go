@@ -0,0 +1,201 @@
+package starmanager
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// BackupDirName - the directory backups are kept in, next to the cache db file
+	BackupDirName string = "stars-backups"
+
+	// BackupTimeFormat - the format of the time in the names of backup files
+	BackupTimeFormat string = "20060102T150405.000Z"
+
+	// DefaultBackupRetention - the number of backups kept by default
+	DefaultBackupRetention int = 10
+)
+
+// backupPrefix and backupSuffix surround the time in the names of backup files
+const (
+	backupPrefix string = "stars-"
+	backupSuffix string = ".db"
+)
+
+// Backup is a snapshot of the cache db
+type Backup struct {
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+}
+
+// BackupDir returns the directory backups are kept in by default, next to the cache db
+func (s *StarManager) BackupDir() string {
+	return filepath.Join(filepath.Dir(s.DB.Bolt.Path()), BackupDirName)
+}
+
+// Backup writes a consistent snapshot of the cache db to a timestamped file in dir while it
+// stays usable, and returns the path of the file
+func (s *StarManager) Backup(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	name := backupPrefix + time.Now().UTC().Format(BackupTimeFormat) + backupSuffix
+	path := filepath.Join(dir, name)
+
+	// The backup is written under a temporary name so that an interrupted backup is never
+	// mistaken for a complete one
+	tmp := path + ".tmp"
+	if err := s.DB.Bolt.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(tmp, 0600)
+	}); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	log.Printf("Backed up the cache to %s", path)
+	return path, nil
+}
+
+// Backups returns the backups in dir, newest first. A missing dir has no backups.
+func Backups(dir string) ([]Backup, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Backup{}, nil
+		}
+
+		return nil, err
+	}
+
+	backups := []Backup{}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+
+		createdAt, err := time.Parse(BackupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix))
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, Backup{Path: filepath.Join(dir, name), CreatedAt: createdAt, Size: info.Size()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+
+	return backups, nil
+}
+
+// PruneBackups removes all but the newest keep backups in dir, returning the removed ones
+func PruneBackups(dir string, keep int) ([]Backup, error) {
+	backups, err := Backups(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if keep < 0 {
+		keep = 0
+	}
+
+	if len(backups) <= keep {
+		return []Backup{}, nil
+	}
+
+	for _, backup := range backups[keep:] {
+		if err := os.Remove(backup.Path); err != nil {
+			return nil, err
+		}
+
+		log.Printf("Removed old backup %s", backup.Path)
+	}
+
+	return backups[keep:], nil
+}
+
+// RestoreBackup replaces the cache db with a backup and reopens it, upgrading it if it was
+// written by an older version. The current cache is lost, so callers wanting to undo the
+// restore should back it up first.
+func (s *StarManager) RestoreBackup(backup string) error {
+	if err := checkBackup(backup); err != nil {
+		return fmt.Errorf("%s is not a valid backup: %v", backup, err)
+	}
+
+	path := s.DB.Bolt.Path()
+	tmp := path + ".restore"
+	if err := copyFile(backup, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := s.DB.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	renameErr := os.Rename(tmp, path)
+	if renameErr != nil {
+		os.Remove(tmp)
+	}
+
+	db, err := storm.Open(path, storm.Batch())
+	if err != nil {
+		return err
+	}
+	s.DB = db
+
+	if renameErr != nil {
+		return renameErr
+	}
+
+	log.Printf("Restored the cache from %s", backup)
+	return s.Migrate()
+}
+
+// checkBackup opens a backup read-only to make sure it is a bolt db
+func checkBackup(path string) error {
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+
+	return db.Close()
+}
+
+// copyFile copies the file at src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,97 @@
+package starmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	dir := filepath.Join(filepath.Dir(sm.DB.Bolt.Path()), BackupDirName)
+	assert.Equal(t, dir, sm.BackupDir())
+
+	backups, err := Backups(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, backups)
+
+	star := &Star{URL: "https://github.com/gkze/stars", Language: "go"}
+	assert.NoError(t, sm.DB.Save(star))
+	assert.NoError(t, sm.SaveAnnotation(&Annotation{URL: star.URL, Tags: []string{"toolbox"}}))
+
+	path, err := sm.Backup(dir)
+	assert.NoError(t, err)
+
+	backups, err = Backups(dir)
+	assert.NoError(t, err)
+	assert.Len(t, backups, 1)
+	assert.Equal(t, path, backups[0].Path)
+	assert.True(t, backups[0].Size > 0)
+
+	assert.NoError(t, sm.Note(star.URL, "changed"))
+	assert.NoError(t, sm.ClearCache(false))
+
+	assert.NoError(t, sm.RestoreBackup(path))
+	defer sm.DB.Close()
+
+	restored, err := sm.store().Get(star.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "go", restored.Language)
+
+	annotation, err := sm.GetAnnotation(star.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"toolbox"}, annotation.Tags)
+	assert.Empty(t, annotation.Notes)
+}
+
+func TestRestoreInvalidBackup(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/gkze/stars"}))
+
+	path := filepath.Join(filepath.Dir(sm.DB.Bolt.Path()), "garbage.db")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("not a database"), 0600))
+
+	assert.Error(t, sm.RestoreBackup(path))
+
+	// The cache is untouched
+	count, err := sm.store().Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stars-backups")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		name := backupPrefix + start.Add(time.Duration(i)*time.Hour).Format(BackupTimeFormat) + backupSuffix
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0600))
+	}
+
+	// Other files are left alone
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0600))
+
+	removed, err := PruneBackups(dir, 2)
+	assert.NoError(t, err)
+	assert.Len(t, removed, 2)
+	assert.Equal(t, start.Add(time.Hour), removed[0].CreatedAt)
+	assert.Equal(t, start, removed[1].CreatedAt)
+
+	backups, err := Backups(dir)
+	assert.NoError(t, err)
+	assert.Len(t, backups, 2)
+	assert.Equal(t, start.Add(3*time.Hour), backups[0].CreatedAt)
+
+	_, err = os.Stat(filepath.Join(dir, "notes.txt"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,229 @@
+package starmanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CloneMode - how repositories are cloned
+type CloneMode string
+
+const (
+	// CloneFull - regular clones with a working tree and full history
+	CloneFull CloneMode = "full"
+
+	// CloneShallow - clones of only the latest commit
+	CloneShallow CloneMode = "shallow"
+
+	// CloneMirror - bare mirrors of all refs, best suited for archiving
+	CloneMirror CloneMode = "mirror"
+
+	// partialSuffix marks clones that have not finished, which are started over when
+	// cloning is resumed
+	partialSuffix string = ".partial"
+)
+
+var (
+	// gitCommand is the git executable used for cloning
+	gitCommand = "git"
+
+	// cloneURL returns the URL a star is cloned from
+	cloneURL = func(star *Star) string { return star.URL + ".git" }
+)
+
+// CloneOptions - the parameters of Clone
+type CloneOptions struct {
+	// Dir is the directory repositories are cloned into, each under <host>/<owner>/<repo>,
+	// where the owner of GitLab projects in subgroups spans several directories
+	Dir string
+
+	// Mode is how repositories are cloned, CloneFull if unset
+	Mode CloneMode
+
+	// Update fetches clones that already exist instead of skipping them
+	Update bool
+
+	// Filter selects the stars to clone like GetProjects. A zero Count clones all matching
+	// stars.
+	Filter ProjectOptions
+
+	// DryRun only logs which repositories would be cloned or updated
+	DryRun bool
+}
+
+// CloneResult summarizes the outcome of Clone
+type CloneResult struct {
+	Cloned  int `json:"cloned"`
+	Updated int `json:"updated"`
+
+	// Skipped is the number of stars that were already cloned, or are gone
+	Skipped int `json:"skipped"`
+
+	Failed int           `json:"failed"`
+	Errors []*CloneError `json:"errors"`
+}
+
+// clonePath returns the directory a star is cloned into
+func clonePath(dir string, star *Star, mode CloneMode) (string, error) {
+	owner, repo, err := star.OwnerRepo()
+	if err != nil {
+		return "", err
+	}
+
+	if mode == CloneMirror {
+		repo += ".git"
+	}
+
+	return filepath.Join(dir, star.ProviderName(), filepath.FromSlash(owner), repo), nil
+}
+
+// git runs a git command, returning its error output on failure
+func git(ctx context.Context, args ...string) error {
+	stderr := &bytes.Buffer{}
+
+	cmd := exec.CommandContext(ctx, gitCommand, args...)
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// Clone clones the cached stars matching the filter into a directory, using at most
+// Concurrency git processes at a time. Clones are made in a temporary directory that is
+// only renamed once cloning finished, so an interrupted run can be resumed by running it
+// again: finished clones are skipped (or fetched with Update) and unfinished ones are
+// started over. A non-nil error is only returned if cloning could not run at all;
+// repositories that could not be cloned are reported in the result.
+func (s *StarManager) Clone(ctx context.Context, opts CloneOptions) (*CloneResult, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("no directory to clone into")
+	}
+
+	if opts.Mode == "" {
+		opts.Mode = CloneFull
+	}
+
+	if opts.Mode != CloneFull && opts.Mode != CloneShallow && opts.Mode != CloneMirror {
+		return nil, fmt.Errorf("unknown clone mode %q", opts.Mode)
+	}
+
+	stars, err := s.findProjects(ctx, opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Filter.Count > 0 && len(stars) > opts.Filter.Count {
+		stars = stars[0:opts.Filter.Count]
+	}
+
+	result := &CloneResult{}
+	mu := sync.Mutex{}
+
+	runPool(ctx, s.concurrency(), len(stars), func(job int) {
+		star := &stars[job]
+		outcome, err := s.cloneStar(ctx, star, opts)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			log.Printf("An error occurred while attempting to clone %s: %s\n", star.URL, err.Error())
+			result.Failed++
+			result.Errors = append(result.Errors, &CloneError{URL: star.URL, Err: err})
+			return
+		}
+
+		switch outcome {
+		case "cloned":
+			result.Cloned++
+		case "updated":
+			result.Updated++
+		default:
+			result.Skipped++
+		}
+	})
+
+	log.Printf(
+		"%d cloned, %d updated, %d skipped, %d failed",
+		result.Cloned,
+		result.Updated,
+		result.Skipped,
+		result.Failed,
+	)
+
+	return result, ctx.Err()
+}
+
+// cloneStar clones or updates a single star, returning "cloned", "updated" or "skipped"
+func (s *StarManager) cloneStar(ctx context.Context, star *Star, opts CloneOptions) (string, error) {
+	if star.Gone {
+		return "skipped", nil
+	}
+
+	path, err := clonePath(opts.Dir, star, opts.Mode)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if !opts.Update {
+			return "skipped", nil
+		}
+
+		if opts.DryRun || s.DryRun {
+			log.Printf("Would update %s", path)
+			return "updated", nil
+		}
+
+		args := []string{"-C", path, "fetch", "--prune", "--quiet"}
+		if opts.Mode == CloneShallow {
+			args = append(args, "--depth", "1")
+		}
+
+		return "updated", git(ctx, args...)
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if opts.DryRun || s.DryRun {
+		log.Printf("Would clone %s into %s", star.URL, path)
+		return "cloned", nil
+	}
+
+	// Leftovers of an interrupted clone are started over
+	partial := path + partialSuffix
+	if err := os.RemoveAll(partial); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	args := []string{"clone", "--quiet"}
+	switch opts.Mode {
+	case CloneShallow:
+		args = append(args, "--depth", "1")
+	case CloneMirror:
+		args = append(args, "--mirror")
+	}
+
+	if err := git(ctx, append(args, cloneURL(star), partial)...); err != nil {
+		os.RemoveAll(partial)
+		return "", err
+	}
+
+	return "cloned", os.Rename(partial, path)
+}
@@ -0,0 +1,123 @@
+package starmanager
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newUpstream creates a git repository with a single commit to clone from
+func newUpstream(t *testing.T, dir, name string) string {
+	path := filepath.Join(dir, name)
+
+	for _, args := range [][]string{
+		{"init", "--quiet", path},
+		{"-C", path, "-c", "user.name=stars", "-c", "user.email=stars@example.com", "commit", "--quiet", "--allow-empty", "-m", "initial"},
+	} {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+
+	return path
+}
+
+func TestClone(t *testing.T) {
+	if _, err := exec.LookPath(gitCommand); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "clones")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	upstreams := filepath.Join(dir, "upstreams")
+	newUpstream(t, upstreams, "stars")
+	newUpstream(t, upstreams, "tools")
+
+	defer func(u func(*Star) string) { cloneURL = u }(cloneURL)
+	cloneURL = func(star *Star) string {
+		return filepath.Join(upstreams, star.URL[strings.LastIndex(star.URL, "/")+1:])
+	}
+
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/stars", Language: "go"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://gitlab.com/b/tools", Provider: "gitlab.com", Language: "go"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/missing", Language: "go"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/deleted", Language: "go", Gone: true}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/python", Language: "python"}))
+
+	// Leftovers of an interrupted run are started over
+	archive := filepath.Join(dir, "archive")
+	assert.NoError(t, os.MkdirAll(filepath.Join(archive, "github.com", "a", "stars"+partialSuffix), 0755))
+
+	ctx := context.Background()
+	opts := CloneOptions{Dir: archive, Filter: ProjectOptions{Language: "go"}}
+
+	result, err := sm.Clone(ctx, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Cloned)
+	assert.Equal(t, 1, result.Skipped)
+	assert.Equal(t, 1, result.Failed)
+	assert.Equal(t, "https://github.com/a/missing", result.Errors[0].URL)
+
+	assert.DirExists(t, filepath.Join(archive, "github.com", "a", "stars", ".git"))
+	assert.DirExists(t, filepath.Join(archive, "gitlab.com", "b", "tools", ".git"))
+	for _, partial := range []string{"stars", "missing"} {
+		_, err := os.Stat(filepath.Join(archive, "github.com", "a", partial+partialSuffix))
+		assert.True(t, os.IsNotExist(err), partial)
+	}
+
+	// Resuming skips finished clones, updating fetches them
+	assert.NoError(t, sm.DB.DeleteStruct(&Star{URL: "https://github.com/a/missing"}))
+
+	result, err = sm.Clone(ctx, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, &CloneResult{Skipped: 3}, result)
+
+	opts.Update = true
+	result, err = sm.Clone(ctx, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, &CloneResult{Updated: 2, Skipped: 1}, result)
+
+	// Mirrors are bare repositories
+	result, err = sm.Clone(ctx, CloneOptions{Dir: archive, Mode: CloneMirror, Filter: ProjectOptions{Language: "go"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Cloned)
+	assert.FileExists(t, filepath.Join(archive, "github.com", "a", "stars.git", "HEAD"))
+
+	_, err = sm.Clone(ctx, CloneOptions{Dir: archive, Mode: "tarball"})
+	assert.Error(t, err)
+}
+
+func TestClonePath(t *testing.T) {
+	testCases := []struct {
+		star     *Star
+		mode     CloneMode
+		expected string
+	}{
+		{star: &Star{URL: "https://github.com/a/stars"}, mode: CloneFull, expected: "github.com/a/stars"},
+		{star: &Star{URL: "https://github.com/a/stars"}, mode: CloneMirror, expected: "github.com/a/stars.git"},
+		{star: &Star{URL: "https://gitlab.com/b/tools", Provider: "gitlab.com"}, mode: CloneFull, expected: "gitlab.com/b/tools"},
+		{star: &Star{URL: "https://gitlab.com/b/tools/tools", Provider: "gitlab.com"}, mode: CloneFull, expected: "gitlab.com/b/tools/tools"},
+		{star: &Star{URL: "https://gitlab.com/b/sub/group/tools", Provider: "gitlab.com"}, mode: CloneFull, expected: "gitlab.com/b/sub/group/tools"},
+	}
+
+	for _, tc := range testCases {
+		tc.star.setOwnerRepo()
+
+		path, err := clonePath("archive", tc.star, tc.mode)
+		assert.NoError(t, err, tc.star.URL)
+		assert.Equal(t, filepath.Join("archive", filepath.FromSlash(tc.expected)), path, tc.star.URL)
+	}
+
+	_, err := clonePath("archive", &Star{URL: "https://gitlab.com/b"}, CloneFull)
+	assert.Error(t, err)
+}
@@ -0,0 +1,202 @@
+package starmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gkze/stars/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// DaemonOptions configure the scheduled runs of RunDaemon
+type DaemonOptions struct {
+	// Schedule says when to run, see utils.ParseSchedule
+	Schedule utils.Schedule
+
+	// Immediately runs once on start instead of waiting for the first scheduled time
+	Immediately bool
+
+	// Sync configures the sync every run starts with
+	Sync SyncOptions
+
+	// Cleanup, if set, selects the stars to clean up after every successful sync. Stars are
+	// only removed with Enforce; otherwise every cleanup is a dry run reporting what would
+	// be removed. Cleanups are never confirmed interactively.
+	Cleanup *CleanupOptions
+
+	// Enforce removes the stars Cleanup matches
+	Enforce bool
+
+	// Report, if set, receives a DaemonReport of every run as a line of JSON
+	Report io.Writer
+
+	// Notifiers are sent notifications about newly archived stars, stars that published
+	// releases (if syncs fetch releases) and stars cleaned up or about to be
+	Notifiers []Notifier
+}
+
+// DaemonReport is what a single scheduled run did
+type DaemonReport struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+
+	// Sync is the result of the sync, including what changed since the previous one
+	Sync *SyncResult `json:"sync,omitempty"`
+
+	// Releases are the stars that published a release since the previous run, if syncs
+	// fetch releases
+	Releases []Star `json:"releases,omitempty"`
+
+	// Cleanup is the result of the cleanup, nil if there was none
+	Cleanup *CleanupResult `json:"cleanup,omitempty"`
+
+	// Error is why the run failed, if it did
+	Error string `json:"error,omitempty"`
+}
+
+// RunScheduled syncs once and cleans up as configured, returning what was done. Errors are
+// recorded in the report rather than returned, so that a failed run does not stop the daemon.
+// The cleanup is skipped if the sync was incomplete, as it would work on stale data.
+func (s *StarManager) RunScheduled(ctx context.Context, opts DaemonOptions) *DaemonReport {
+	report := &DaemonReport{Started: time.Now()}
+	defer func() { report.Finished = time.Now() }()
+
+	result, err := s.Sync(ctx, opts.Sync)
+	report.Sync = result
+	if err == nil {
+		err = result.Err()
+	}
+
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	if opts.Sync.Releases {
+		if report.Releases, err = s.releasedSinceCheck(ctx, report.Started); err != nil {
+			report.Error = err.Error()
+			return report
+		}
+	}
+
+	if opts.Cleanup == nil {
+		return report
+	}
+
+	cleanup := *opts.Cleanup
+	cleanup.DryRun = !opts.Enforce
+	cleanup.Confirm = nil
+
+	if report.Cleanup, err = s.Cleanup(ctx, cleanup); err == nil {
+		err = report.Cleanup.Err()
+	}
+
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	return report
+}
+
+// releasedSinceCheck returns the stars that published a release since releases were last
+// checked, and records the check. Nothing is returned on the first check, as there is
+// nothing to compare with.
+func (s *StarManager) releasedSinceCheck(ctx context.Context, now time.Time) ([]Star, error) {
+	since, err := s.LastReleaseCheck()
+	if err != nil {
+		return nil, err
+	}
+
+	stars := []Star{}
+	if !since.IsZero() {
+		if stars, err = s.NewReleases(ctx, since); err != nil {
+			return nil, err
+		}
+	}
+
+	return stars, s.MarkReleasesChecked(now)
+}
+
+// Notifications returns the notifications about a run, given whether its cleanup removed
+// stars
+func (r *DaemonReport) Notifications(enforce bool) []*Notification {
+	notifications := []*Notification{}
+
+	if r.Sync != nil {
+		notifications = append(notifications, DiffNotifications(r.Sync.Diff)...)
+	}
+	notifications = append(notifications, ReleaseNotifications(r.Releases)...)
+
+	return append(notifications, CleanupNotifications(r.Cleanup, !enforce)...)
+}
+
+// RunDaemon runs RunScheduled on the given schedule until the context is canceled, logging,
+// reporting and notifying about every run
+func (s *StarManager) RunDaemon(ctx context.Context, opts DaemonOptions) error {
+	if opts.Schedule == nil {
+		return errors.New("no schedule given")
+	}
+
+	run := opts.Immediately
+	for {
+		if run {
+			report := s.RunScheduled(ctx, opts)
+			logReport(report, opts.Enforce)
+
+			if err := Notify(ctx, opts.Notifiers, report.Notifications(opts.Enforce)); err != nil {
+				log.Printf("Could not send notifications: %v", err)
+			}
+
+			if opts.Report != nil {
+				if err := json.NewEncoder(opts.Report).Encode(report); err != nil {
+					log.Printf("Could not write the report: %v", err)
+				}
+			}
+		}
+		run = true
+
+		now := time.Now()
+		next := opts.Schedule.Next(now)
+		if next.IsZero() {
+			return errors.New("the schedule never runs again")
+		}
+
+		log.Printf("Next run at %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// logReport logs a summary of a scheduled run
+func logReport(report *DaemonReport, enforce bool) {
+	if report.Sync != nil {
+		log.Printf(
+			"Synced: %d added, %d updated, %d removed, %d failed",
+			report.Sync.Added,
+			report.Sync.Updated,
+			report.Sync.Removed,
+			report.Sync.Failed,
+		)
+	}
+
+	if report.Cleanup != nil {
+		if enforce {
+			log.Printf("Cleaned up: %d removed, %d failed", report.Cleanup.Removed, report.Cleanup.Failed)
+		} else {
+			log.Printf("Cleanup: %d would be removed", report.Cleanup.Matched)
+		}
+	}
+
+	if report.Error != "" {
+		log.Printf("Run failed: %s", report.Error)
+	}
+}
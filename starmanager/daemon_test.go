@@ -0,0 +1,84 @@
+package starmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gkze/stars/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunScheduled(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	provider := &fakeProvider{name: "github.com"}
+	sm.Providers = []Provider{provider}
+
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/old", PushedAt: time.Now().AddDate(-1, 0, 0)}))
+
+	// Cleanups are dry runs unless enforced
+	opts := DaemonOptions{Cleanup: &CleanupOptions{Months: 6, DryRun: true}}
+	report := sm.RunScheduled(context.Background(), opts)
+	assert.Empty(t, report.Error)
+	assert.NotNil(t, report.Sync)
+	assert.Equal(t, 1, report.Cleanup.Matched)
+	assert.Equal(t, 0, report.Cleanup.Removed)
+	assert.Empty(t, provider.unstarred)
+	assert.False(t, report.Finished.Before(report.Started))
+
+	opts.Enforce = true
+	report = sm.RunScheduled(context.Background(), opts)
+	assert.Equal(t, 1, report.Cleanup.Removed)
+	assert.Equal(t, []string{"a/old"}, provider.unstarred)
+
+	// Nothing is cleaned up after a failed sync
+	provider.err = errors.New("boom")
+	report = sm.RunScheduled(context.Background(), opts)
+	assert.Contains(t, report.Error, "boom")
+	assert.Nil(t, report.Cleanup)
+}
+
+func TestRunDaemon(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	sm.Providers = []Provider{&fakeProvider{name: "github.com"}}
+
+	schedule, err := utils.ParseSchedule("@every 10ms")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+	defer cancel()
+
+	// Dry runs of cleanups notify about the stars they would remove
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/old", Archived: true}))
+	notifier := &recordingNotifier{}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, sm.RunDaemon(ctx, DaemonOptions{
+		Schedule:    schedule,
+		Immediately: true,
+		Report:      buf,
+		Cleanup:     &CleanupOptions{Months: 1200, Archived: true},
+		Notifiers:   []Notifier{notifier},
+	}))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.True(t, len(lines) >= 2, buf.String())
+
+	report := DaemonReport{}
+	assert.NoError(t, json.Unmarshal(lines[0], &report))
+	assert.NotNil(t, report.Sync)
+	assert.Empty(t, report.Error)
+	assert.Equal(t, 1, report.Cleanup.Matched)
+
+	assert.True(t, len(notifier.sent) >= 2)
+	assert.Equal(t, "1 star is about to be unstarred by cleanup", notifier.sent[0].Title)
+
+	assert.Error(t, sm.RunDaemon(context.Background(), DaemonOptions{}))
+}
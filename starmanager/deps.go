@@ -0,0 +1,468 @@
+package starmanager
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Ecosystem - the package ecosystem a dependency comes from
+type Ecosystem string
+
+const (
+	// EcosystemGo - Go modules, read from go.mod
+	EcosystemGo Ecosystem = "go"
+
+	// EcosystemNPM - npm packages, read from package.json
+	EcosystemNPM Ecosystem = "npm"
+
+	// EcosystemPyPI - Python packages, read from requirements.txt
+	EcosystemPyPI Ecosystem = "pypi"
+)
+
+var (
+	// npmRegistry is the base URL of the npm registry used to look up package repositories
+	npmRegistry = "https://registry.npmjs.org/"
+
+	// pypiRegistry is the base URL of the PyPI JSON API used to look up package repositories
+	pypiRegistry = "https://pypi.org/pypi/"
+
+	// goGetBase is prepended to Go import paths to look up their repositories with a go-get
+	// request
+	goGetBase = "https://"
+)
+
+// Dependency is a dependency of a project read from its manifest
+type Dependency struct {
+	Name      string    `json:"name"`
+	Ecosystem Ecosystem `json:"ecosystem"`
+
+	// Indirect is set for dependencies go.mod marks as indirect
+	Indirect bool `json:"indirect,omitempty"`
+
+	// URL is the web URL of the dependency's repository, empty if it could not be resolved
+	URL string `json:"url,omitempty"`
+}
+
+// DependencyReport cross-references the dependencies of a project against the cached stars
+type DependencyReport struct {
+	// Starred are the stars that are dependencies of the project
+	Starred []*Star `json:"starred"`
+
+	// Unstarred are the dependencies that are not starred
+	Unstarred []*Dependency `json:"unstarred"`
+
+	// Unresolved are the dependencies whose repository could not be found
+	Unresolved []*Dependency `json:"unresolved"`
+}
+
+// ParseManifest reads the dependencies from a go.mod, package.json or requirements.txt file,
+// telling them apart by filename. Dependencies whose repository is evident from the manifest
+// alone, such as Go modules hosted on a forge, have their URL set already.
+func ParseManifest(r io.Reader, filename string) ([]*Dependency, error) {
+	switch base := filepath.Base(filename); {
+	case base == "go.mod":
+		return parseGoMod(r)
+	case base == "package.json":
+		return parsePackageJSON(r)
+	case strings.HasPrefix(base, "requirements") && strings.HasSuffix(base, ".txt"):
+		return parseRequirements(r)
+	default:
+		return nil, fmt.Errorf("unsupported manifest %s (expected go.mod, package.json or requirements.txt)", base)
+	}
+}
+
+// parseGoMod reads the required modules of a go.mod file
+func parseGoMod(r io.Reader) ([]*Dependency, error) {
+	deps := []*Dependency{}
+	inRequire := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		indirect := strings.HasSuffix(line, "// indirect")
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+
+		switch {
+		case line == "require (":
+			inRequire = true
+			continue
+		case inRequire && line == ")":
+			inRequire = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+		case !inRequire:
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		deps = append(deps, &Dependency{
+			Name:      fields[0],
+			Ecosystem: EcosystemGo,
+			Indirect:  indirect,
+			URL:       goModuleURL(fields[0]),
+		})
+	}
+
+	return deps, scanner.Err()
+}
+
+// parsePackageJSON reads the dependencies of every kind from a package.json file
+func parsePackageJSON(r io.Reader) ([]*Dependency, error) {
+	manifest := map[string]json.RawMessage{}
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, err
+	}
+
+	specs := map[string]string{}
+	for _, field := range []string{"dependencies", "devDependencies", "peerDependencies", "optionalDependencies"} {
+		raw, ok := manifest[field]
+		if !ok {
+			continue
+		}
+
+		section := map[string]string{}
+		if err := json.Unmarshal(raw, &section); err != nil {
+			return nil, fmt.Errorf("%s: %v", field, err)
+		}
+
+		for name, spec := range section {
+			specs[name] = spec
+		}
+	}
+
+	deps := make([]*Dependency, 0, len(specs))
+	for name, spec := range specs {
+		dep := &Dependency{Name: name, Ecosystem: EcosystemNPM}
+
+		// Dependencies can be installed straight from a repository instead of the registry
+		switch {
+		case strings.HasPrefix(spec, "github:"):
+			dep.URL = repoURL("https://github.com/"+strings.TrimPrefix(spec, "github:"), nil)
+		case strings.Contains(spec, "://") || strings.HasPrefix(spec, "git@"):
+			dep.URL = repoURL(spec, nil)
+		case strings.Count(spec, "/") == 1 && !strings.HasPrefix(spec, ".") && !strings.Contains(spec, ":"):
+			dep.URL = repoURL("https://github.com/"+spec, nil)
+		}
+
+		deps = append(deps, dep)
+	}
+
+	sort.Slice(deps, func(i, j int) bool { return deps[i].Name < deps[j].Name })
+
+	return deps, nil
+}
+
+// requirementName matches the project name at the start of a requirement specifier
+var requirementName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*`)
+
+// parseRequirements reads the requirements of a pip requirements file, skipping options
+// such as references to other requirements files
+func parseRequirements(r io.Reader) ([]*Dependency, error) {
+	deps := []*Dependency{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+
+		if strings.HasPrefix(line, "-e ") || strings.HasPrefix(line, "--editable ") {
+			line = strings.TrimSpace(line[strings.Index(line, " "):])
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		// VCS requirements name the project in their egg fragment
+		if strings.Contains(line, "://") {
+			name := line
+			if i := strings.Index(line, "#egg="); i >= 0 {
+				name = line[i+len("#egg="):]
+			}
+
+			deps = append(deps, &Dependency{Name: name, Ecosystem: EcosystemPyPI, URL: repoURL(line, nil)})
+			continue
+		}
+
+		name := requirementName.FindString(line)
+		if name == "" {
+			continue
+		}
+
+		deps = append(deps, &Dependency{Name: strings.ToLower(name), Ecosystem: EcosystemPyPI})
+	}
+
+	return deps, scanner.Err()
+}
+
+// goModuleURL returns the repository URL of a Go module if it follows from its path alone
+func goModuleURL(module string) string {
+	parts := strings.Split(module, "/")
+
+	switch {
+	case parts[0] == "golang.org" && len(parts) >= 3 && parts[1] == "x":
+		// The golang.org/x repositories are mirrored on GitHub
+		return "https://github.com/golang/" + parts[2]
+	case parts[0] == "gopkg.in" && len(parts) >= 2:
+		// gopkg.in/pkg.v1 is github.com/go-pkg/pkg, gopkg.in/user/pkg.v1 github.com/user/pkg
+		pkg := strings.Split(parts[len(parts)-1], ".v")[0]
+		if len(parts) == 2 {
+			return "https://github.com/go-" + pkg + "/" + pkg
+		}
+
+		return "https://github.com/" + parts[1] + "/" + pkg
+	}
+
+	return repoURL("https://"+module, nil)
+}
+
+// repoURL returns the canonical web URL (https://host/owner/repo) of a repository given any
+// URL pointing into it, including git remotes, or an empty string if it is not hosted on
+// one of the default providers or the given hosts
+func repoURL(raw string, hosts []string) string {
+	raw = strings.TrimPrefix(raw, "git+")
+	if i := strings.Index(raw, "#"); i >= 0 {
+		raw = raw[:i]
+	}
+
+	// SCP-like git remotes (git@github.com:owner/repo.git)
+	if strings.HasPrefix(raw, "git@") {
+		raw = "ssh://" + strings.Replace(strings.TrimPrefix(raw, "git@"), ":", "/", 1)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	host := strings.ToLower(u.Hostname())
+	known := false
+	for _, h := range ProviderHosts {
+		known = known || host == h
+	}
+	for _, h := range hosts {
+		known = known || host == h
+	}
+
+	if !known {
+		return ""
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("https://%s/%s/%s", host, parts[0], strings.TrimSuffix(parts[1], ".git"))
+}
+
+// forgeHosts returns the web hosts of the configured providers
+func (s *StarManager) forgeHosts() []string {
+	hosts := []string{}
+	for _, p := range s.Providers {
+		hosts = append(hosts, p.Name())
+	}
+
+	return hosts
+}
+
+// ResolveDependencies looks up the repositories of the dependencies that do not have a URL
+// yet in their package registries, or with a go-get request for Go modules. Dependencies
+// that could not be resolved are logged and left without a URL.
+func (s *StarManager) ResolveDependencies(ctx context.Context, deps []*Dependency) error {
+	hosts := s.forgeHosts()
+	client := s.httpClient()
+
+	runPool(ctx, s.concurrency(), len(deps), func(job int) {
+		dep := deps[job]
+		if dep.URL != "" {
+			return
+		}
+
+		var err error
+		switch dep.Ecosystem {
+		case EcosystemGo:
+			dep.URL, err = resolveGoModule(ctx, client, dep.Name, hosts)
+		case EcosystemNPM:
+			dep.URL, err = resolveNPMPackage(ctx, client, dep.Name, hosts)
+		case EcosystemPyPI:
+			dep.URL, err = resolvePyPIPackage(ctx, client, dep.Name, hosts)
+		}
+
+		if err != nil {
+			log.Printf("An error occurred while resolving %s: %v", dep.Name, err.Error())
+		}
+	})
+
+	return ctx.Err()
+}
+
+// goImport matches the go-import meta tag served for vanity import paths
+var goImport = regexp.MustCompile(`<meta\s+name="go-import"\s+content="([^"]+)"`)
+
+// resolveGoModule finds the repository of a Go module with a vanity import path by
+// requesting its go-import meta tag
+func resolveGoModule(ctx context.Context, client *http.Client, module string, hosts []string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, goGetBase+module+"?go-get=1", nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	for _, m := range goImport.FindAllStringSubmatch(string(body), -1) {
+		fields := strings.Fields(m[1])
+		if len(fields) == 3 && (module == fields[0] || strings.HasPrefix(module, fields[0]+"/")) {
+			return repoURL(fields[2], hosts), nil
+		}
+	}
+
+	return "", nil
+}
+
+// resolveNPMPackage finds the repository of an npm package from its registry metadata
+func resolveNPMPackage(ctx context.Context, client *http.Client, name string, hosts []string) (string, error) {
+	pkg := struct {
+		Repository json.RawMessage `json:"repository"`
+		Homepage   string          `json:"homepage"`
+	}{}
+
+	registry := &restClient{base: npmRegistry, http: client}
+	if _, err := registry.do(ctx, http.MethodGet, strings.Replace(name, "/", "%2F", 1), "", &pkg); err != nil {
+		return "", err
+	}
+
+	// The repository is either a URL or an object holding one
+	repository := struct {
+		URL string `json:"url"`
+	}{}
+	if err := json.Unmarshal(pkg.Repository, &repository.URL); err != nil {
+		_ = json.Unmarshal(pkg.Repository, &repository)
+	}
+
+	if strings.HasPrefix(repository.URL, "github:") || (strings.Count(repository.URL, "/") == 1 && !strings.Contains(repository.URL, ":")) {
+		repository.URL = "https://github.com/" + strings.TrimPrefix(repository.URL, "github:")
+	}
+
+	if u := repoURL(repository.URL, hosts); u != "" {
+		return u, nil
+	}
+
+	return repoURL(pkg.Homepage, hosts), nil
+}
+
+// resolvePyPIPackage finds the repository of a Python package among the URLs of its PyPI
+// metadata
+func resolvePyPIPackage(ctx context.Context, client *http.Client, name string, hosts []string) (string, error) {
+	pkg := struct {
+		Info struct {
+			HomePage    string            `json:"home_page"`
+			ProjectURLs map[string]string `json:"project_urls"`
+		} `json:"info"`
+	}{}
+
+	registry := &restClient{base: pypiRegistry, http: client}
+	if _, err := registry.do(ctx, http.MethodGet, name+"/json", "", &pkg); err != nil {
+		return "", err
+	}
+
+	// Prefer the URLs most likely to be the repository, in a stable order
+	labels := make([]string, 0, len(pkg.Info.ProjectURLs))
+	for label := range pkg.Info.ProjectURLs {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		si, sj := sourceLabel(labels[i]), sourceLabel(labels[j])
+		if si != sj {
+			return si
+		}
+
+		return labels[i] < labels[j]
+	})
+
+	for _, label := range labels {
+		if u := repoURL(pkg.Info.ProjectURLs[label], hosts); u != "" {
+			return u, nil
+		}
+	}
+
+	return repoURL(pkg.Info.HomePage, hosts), nil
+}
+
+// sourceLabel reports whether a PyPI project URL label names the source repository
+func sourceLabel(label string) bool {
+	label = strings.ToLower(label)
+	return strings.Contains(label, "source") || strings.Contains(label, "repository") || strings.Contains(label, "code")
+}
+
+// CrossReference resolves the repositories of the given dependencies and reports which of
+// them are starred and which are not
+func (s *StarManager) CrossReference(ctx context.Context, deps []*Dependency) (*DependencyReport, error) {
+	if err := s.ResolveDependencies(ctx, deps); err != nil {
+		return nil, err
+	}
+
+	stars, err := s.store().All()
+	if err != nil {
+		return nil, err
+	}
+
+	byURL := map[string]*Star{}
+	for _, star := range stars {
+		byURL[strings.ToLower(star.URL)] = star
+	}
+
+	report := &DependencyReport{Starred: []*Star{}, Unstarred: []*Dependency{}, Unresolved: []*Dependency{}}
+	seen := map[string]bool{}
+	for _, dep := range deps {
+		key := strings.ToLower(dep.URL)
+
+		switch star, ok := byURL[key]; {
+		case dep.URL == "":
+			report.Unresolved = append(report.Unresolved, dep)
+		case seen[key]:
+			// Several modules or packages can live in the same repository
+		case ok:
+			report.Starred = append(report.Starred, star)
+		default:
+			report.Unstarred = append(report.Unstarred, dep)
+		}
+
+		seen[key] = true
+	}
+
+	sort.Slice(report.Starred, func(i, j int) bool { return report.Starred[i].URL < report.Starred[j].URL })
+
+	return report, nil
+}
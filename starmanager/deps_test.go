@@ -0,0 +1,173 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseManifest(t *testing.T) {
+	testCases := []struct {
+		filename string
+		manifest string
+		expected []*Dependency
+		err      bool
+	}{
+		{
+			filename: "go.mod",
+			manifest: `module example.com/app
+
+go 1.13
+
+require github.com/spf13/cobra v0.0.6
+
+require (
+	github.com/google/go-github/v25 v25.1.3
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d // indirect
+	gopkg.in/yaml.v2 v2.2.8
+	example.com/vanity v1.0.0
+)
+
+replace example.com/vanity => ../vanity
+`,
+			expected: []*Dependency{
+				{Name: "github.com/spf13/cobra", Ecosystem: EcosystemGo, URL: "https://github.com/spf13/cobra"},
+				{Name: "github.com/google/go-github/v25", Ecosystem: EcosystemGo, URL: "https://github.com/google/go-github"},
+				{Name: "golang.org/x/oauth2", Ecosystem: EcosystemGo, Indirect: true, URL: "https://github.com/golang/oauth2"},
+				{Name: "gopkg.in/yaml.v2", Ecosystem: EcosystemGo, URL: "https://github.com/go-yaml/yaml"},
+				{Name: "example.com/vanity", Ecosystem: EcosystemGo},
+			},
+		},
+		{
+			filename: "web/package.json",
+			manifest: `{
+				"name": "app",
+				"dependencies": {"react": "^18.0.0", "fork": "github:someone/fork"},
+				"devDependencies": {"@types/node": "^20.0.0", "tool": "git+https://gitlab.com/b/tool.git#v1"}
+			}`,
+			expected: []*Dependency{
+				{Name: "@types/node", Ecosystem: EcosystemNPM},
+				{Name: "fork", Ecosystem: EcosystemNPM, URL: "https://github.com/someone/fork"},
+				{Name: "react", Ecosystem: EcosystemNPM},
+				{Name: "tool", Ecosystem: EcosystemNPM, URL: "https://gitlab.com/b/tool"},
+			},
+		},
+		{
+			filename: "requirements-dev.txt",
+			manifest: `# tools
+-r requirements.txt
+Requests>=2.0  # http
+black[d]==23.1
+-e git+https://github.com/a/lib.git#egg=lib
+`,
+			expected: []*Dependency{
+				{Name: "requests", Ecosystem: EcosystemPyPI},
+				{Name: "black", Ecosystem: EcosystemPyPI},
+				{Name: "lib", Ecosystem: EcosystemPyPI, URL: "https://github.com/a/lib"},
+			},
+		},
+		{
+			filename: "Cargo.toml",
+			err:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		deps, err := ParseManifest(strings.NewReader(tc.manifest), tc.filename)
+		if tc.err {
+			assert.Error(t, err, tc.filename)
+			continue
+		}
+
+		assert.NoError(t, err, tc.filename)
+		assert.Equal(t, tc.expected, deps, tc.filename)
+	}
+}
+
+func TestRepoURL(t *testing.T) {
+	testCases := []struct {
+		raw      string
+		expected string
+	}{
+		{raw: "https://github.com/a/b", expected: "https://github.com/a/b"},
+		{raw: "git+https://github.com/a/b.git", expected: "https://github.com/a/b"},
+		{raw: "git://github.com/a/b.git", expected: "https://github.com/a/b"},
+		{raw: "git@github.com:a/b.git", expected: "https://github.com/a/b"},
+		{raw: "https://github.com/a/b/tree/main/docs#readme", expected: "https://github.com/a/b"},
+		{raw: "https://git.example.com/a/b", expected: "https://git.example.com/a/b"},
+		{raw: "https://example.org/a/b", expected: ""},
+		{raw: "https://github.com/a", expected: ""},
+		{raw: "", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, repoURL(tc.raw, []string{"git.example.com"}), tc.raw)
+	}
+}
+
+func TestCrossReference(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/npm/react":
+			fmt.Fprint(w, `{"repository": {"type": "git", "url": "git+https://github.com/facebook/react.git"}}`)
+		case "/npm/@types%2Fnode":
+			fmt.Fprint(w, `{"repository": "DefinitelyTyped/DefinitelyTyped"}`)
+		case "/pypi/requests/json":
+			fmt.Fprint(w, `{"info": {"home_page": "https://requests.readthedocs.io", "project_urls": {"Documentation": "https://requests.readthedocs.io", "Source": "https://github.com/psf/requests"}}}`)
+		case "/go/example.com/vanity":
+			fmt.Fprint(w, `<html><head><meta name="go-import" content="example.com/vanity git https://codeberg.org/c/vanity"></head></html>`)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	// The registries are only reachable through the configured client's proxy
+	proxy, _ := url.Parse(srv.URL)
+	sm.HTTPClient = &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxy)}}
+
+	defer func(npm, pypi, goGet string) { npmRegistry, pypiRegistry, goGetBase = npm, pypi, goGet }(npmRegistry, pypiRegistry, goGetBase)
+	npmRegistry, pypiRegistry, goGetBase = "http://registry.invalid/npm/", "http://registry.invalid/pypi/", "http://registry.invalid/go/"
+
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/facebook/react"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/psf/requests"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/spf13/Cobra"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/unrelated"}))
+
+	deps := []*Dependency{
+		{Name: "react", Ecosystem: EcosystemNPM},
+		{Name: "@types/node", Ecosystem: EcosystemNPM},
+		{Name: "left-pad", Ecosystem: EcosystemNPM},
+		{Name: "requests", Ecosystem: EcosystemPyPI},
+		{Name: "github.com/spf13/cobra", Ecosystem: EcosystemGo, URL: "https://github.com/spf13/cobra"},
+		{Name: "github.com/spf13/cobra/doc", Ecosystem: EcosystemGo, URL: "https://github.com/spf13/cobra"},
+		{Name: "example.com/vanity", Ecosystem: EcosystemGo},
+	}
+
+	report, err := sm.CrossReference(context.Background(), deps)
+	assert.NoError(t, err)
+
+	starred := []string{}
+	for _, star := range report.Starred {
+		starred = append(starred, star.URL)
+	}
+	assert.Equal(t, []string{"https://github.com/facebook/react", "https://github.com/psf/requests", "https://github.com/spf13/Cobra"}, starred)
+
+	unstarred := []string{}
+	for _, dep := range report.Unstarred {
+		unstarred = append(unstarred, dep.URL)
+	}
+	assert.Equal(t, []string{"https://github.com/DefinitelyTyped/DefinitelyTyped", "https://codeberg.org/c/vanity"}, unstarred)
+
+	assert.Len(t, report.Unresolved, 1)
+	assert.Equal(t, "left-pad", report.Unresolved[0].Name)
+}
@@ -0,0 +1,186 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/google/go-github/v25/github"
+)
+
+// ShowOptions - the parameters of Show
+type ShowOptions struct {
+	// Offline only shows what is cached, without fetching the repository
+	Offline bool
+}
+
+// StarDetail is everything known about a single star
+type StarDetail struct {
+	// Star is the cached star, updated with the live data if it was fetched
+	Star Star `json:"star"`
+
+	// Cached is set if the star is in the cache, i.e. starred as of the last sync
+	Cached bool `json:"cached"`
+
+	Annotation *Annotation    `json:"annotation"`
+	Health     int            `json:"health"`
+	Signals    []HealthSignal `json:"signals"`
+	Readme     bool           `json:"readme"`
+
+	// Forks and Homepage are only known if the repository was fetched
+	Forks    int    `json:"forks"`
+	Homepage string `json:"homepage"`
+
+	// FetchedAt is when the repository was fetched, zero if only cached data is shown.
+	// FetchError says why fetching failed.
+	FetchedAt  time.Time `json:"fetched_at"`
+	FetchError string    `json:"fetch_error,omitempty"`
+}
+
+// starURL returns the URL of a star given by URL or as owner/repo, the latter on GitHub
+func (s *StarManager) starURL(ref string) (string, error) {
+	if strings.Contains(ref, "://") {
+		return strings.TrimSuffix(strings.TrimSuffix(ref, "/"), ".git"), nil
+	}
+
+	parts := strings.Split(strings.Trim(ref, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("%s is neither a URL nor owner/repo", ref)
+	}
+
+	return fmt.Sprintf("https://%s/%s/%s", webHost(s.Host), parts[0], parts[1]), nil
+}
+
+// GitHubRepo returns the owner and name of a repository on the configured GitHub host, given
+// by URL or as owner/repo. URLs of other hosts are rejected.
+func (s *StarManager) GitHubRepo(ref string) (string, string, error) {
+	starURL, err := s.starURL(ref)
+	if err != nil {
+		return "", "", err
+	}
+
+	u, err := url.Parse(starURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	if host := webHost(s.Host); !strings.EqualFold(u.Host, host) {
+		return "", "", fmt.Errorf("%s is not a repository on %s", ref, host)
+	}
+
+	return ownerRepo(starURL)
+}
+
+// cachedStar looks up a star by URL, ignoring case
+func (s *StarManager) cachedStar(url string) (*Star, error) {
+	star, err := s.store().Get(url)
+	if err == nil {
+		return star, nil
+	} else if err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	stars, err := s.store().All()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, star := range stars {
+		if strings.EqualFold(star.URL, url) {
+			return star, nil
+		}
+	}
+
+	return nil, storm.ErrNotFound
+}
+
+// fetchDetail refreshes a star with the current state of its GitHub repository
+func (s *StarManager) fetchDetail(ctx context.Context, detail *StarDetail) error {
+	owner, repo, err := detail.Star.OwnerRepo()
+	if err != nil {
+		return err
+	}
+
+	repository, _, err := s.Client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return githubRateLimitError(err)
+	}
+
+	gh := &GitHubProvider{Host: webHost(s.Host), Client: s.Client}
+	live := gh.star(&github.StarredRepository{Repository: repository})
+
+	// What only the cache knows is kept
+	live.StarredAt = detail.Star.StarredAt
+	live.Activity, live.ActivityAt = detail.Star.Activity, detail.Star.ActivityAt
+	live.Languages, live.LanguagesAt = detail.Star.Languages, detail.Star.LanguagesAt
+	live.Lists = detail.Star.Lists
+
+	if live.LatestRelease, live.ReleasedAt, err = s.latestRelease(ctx, &throttle{}, owner, repo); err != nil {
+		return err
+	}
+
+	detail.Star = *live
+	detail.Forks = repository.GetForksCount()
+	detail.Homepage = repository.GetHomepage()
+	detail.FetchedAt = time.Now()
+
+	return nil
+}
+
+// Show returns the details of a star given by URL or as owner/repo, combining the cache and
+// the local annotations with a live fetch of GitHub repositories. Repositories that are not
+// starred can be shown too, as long as they can be fetched. If fetching fails the cached
+// data is returned along with the reason.
+func (s *StarManager) Show(ctx context.Context, ref string, opts ShowOptions) (*StarDetail, error) {
+	u, err := s.starURL(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &StarDetail{Star: Star{URL: u, Provider: parsed.Host}}
+
+	star, err := s.cachedStar(u)
+	switch {
+	case err == nil:
+		detail.Star = *star
+		detail.Cached = true
+	case err != storm.ErrNotFound:
+		return nil, err
+	case opts.Offline || detail.Star.ProviderName() != webHost(s.Host):
+		return nil, fmt.Errorf("%s is not starred", u)
+	}
+
+	// Annotations and READMEs are kept under the cached URL, even if the repository moved
+	u = detail.Star.URL
+
+	// Details are only fetched from GitHub
+	if !opts.Offline && detail.Star.ProviderName() == webHost(s.Host) {
+		if err := s.fetchDetail(ctx, detail); err != nil {
+			if !detail.Cached {
+				return nil, err
+			}
+
+			detail.FetchError = err.Error()
+		}
+	}
+
+	if detail.Annotation, err = s.GetAnnotation(u); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Readme(ctx, u); err == nil {
+		detail.Readme = true
+	}
+
+	detail.Health, detail.Signals = Score(&detail.Star, time.Now())
+
+	return detail, nil
+}
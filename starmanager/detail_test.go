@@ -0,0 +1,161 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShow(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	failing := false
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			http.Error(w, `{"message": "Server Error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/repos/a/b":
+			fmt.Fprint(w, `{
+				"html_url": "https://github.com/a/b",
+				"description": "live",
+				"pushed_at": "2019-06-01T00:00:00Z",
+				"stargazers_count": 42,
+				"archived": false,
+				"forks_count": 7,
+				"open_issues_count": 3,
+				"homepage": "https://b.example.com",
+				"license": {"spdx_id": "MIT"}
+			}`)
+		case "/repos/a/b/releases/latest":
+			fmt.Fprint(w, `{"tag_name": "v1.2.0", "published_at": "2019-05-01T00:00:00Z"}`)
+		case "/repos/c/d":
+			fmt.Fprint(w, `{
+				"html_url": "https://github.com/c/d",
+				"pushed_at": "2019-06-01T00:00:00Z",
+				"stargazers_count": 1,
+				"archived": false
+			}`)
+		case "/repos/c/d/tags":
+			fmt.Fprint(w, `[]`)
+		default:
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	}))
+	defer closeGitHub()
+
+	sm.Host = GitHub
+	sm.Client = client
+
+	ctx := context.Background()
+	starredAt := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, sm.DB.Save(&Star{
+		URL:         "https://github.com/a/b",
+		Description: "cached",
+		StarredAt:   starredAt,
+		Stargazers:  10,
+		Lists:       []string{"tools"},
+	}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://gitlab.com/e/f", Provider: "gitlab.com", Description: "gitlab"}))
+	assert.NoError(t, sm.Tag("https://github.com/a/b", "cli"))
+	assert.NoError(t, sm.readmes().Save(&Readme{URL: "https://github.com/a/b", Format: ReadmeMarkdown, Content: "# B"}))
+
+	// Cached stars are merged with the live data
+	detail, err := sm.Show(ctx, "a/b", ShowOptions{})
+	assert.NoError(t, err)
+	assert.True(t, detail.Cached)
+	assert.Equal(t, "live", detail.Star.Description)
+	assert.Equal(t, 42, detail.Star.Stargazers)
+	assert.Equal(t, 3, detail.Star.OpenIssues)
+	assert.Equal(t, "MIT", detail.Star.License)
+	assert.Equal(t, "v1.2.0", detail.Star.LatestRelease)
+	assert.Equal(t, starredAt, detail.Star.StarredAt.UTC())
+	assert.Equal(t, []string{"tools"}, detail.Star.Lists)
+	assert.Equal(t, 7, detail.Forks)
+	assert.Equal(t, "https://b.example.com", detail.Homepage)
+	assert.False(t, detail.FetchedAt.IsZero())
+	assert.Equal(t, []string{"cli"}, detail.Annotation.Tags)
+	assert.True(t, detail.Readme)
+	assert.Empty(t, detail.FetchError)
+
+	// Offline only the cache is shown
+	detail, err = sm.Show(ctx, "https://github.com/A/B/", ShowOptions{Offline: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "cached", detail.Star.Description)
+	assert.True(t, detail.FetchedAt.IsZero())
+	assert.Equal(t, []string{"cli"}, detail.Annotation.Tags)
+
+	// Failing fetches fall back to the cache
+	failing = true
+	detail, err = sm.Show(ctx, "https://github.com/a/b", ShowOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "cached", detail.Star.Description)
+	assert.NotEmpty(t, detail.FetchError)
+	failing = false
+
+	// Repositories that are not starred are fetched, unless offline
+	detail, err = sm.Show(ctx, "c/d", ShowOptions{})
+	assert.NoError(t, err)
+	assert.False(t, detail.Cached)
+	assert.Equal(t, 1, detail.Star.Stargazers)
+
+	_, err = sm.Show(ctx, "c/d", ShowOptions{Offline: true})
+	assert.Error(t, err)
+
+	_, err = sm.Show(ctx, "x/missing", ShowOptions{})
+	assert.Error(t, err)
+
+	// Other providers are only shown from the cache
+	detail, err = sm.Show(ctx, "https://gitlab.com/e/f", ShowOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "gitlab", detail.Star.Description)
+	assert.True(t, detail.FetchedAt.IsZero())
+
+	_, err = sm.Show(ctx, "https://gitlab.com/e/missing", ShowOptions{})
+	assert.Error(t, err)
+
+	_, err = sm.Show(ctx, "not-a-repo", ShowOptions{})
+	assert.Error(t, err)
+}
+
+func TestGitHubRepo(t *testing.T) {
+	sm := &StarManager{Host: GitHub}
+
+	testCases := []struct {
+		ref   string
+		owner string
+		repo  string
+		err   bool
+	}{
+		{ref: "gkze/stars", owner: "gkze", repo: "stars"},
+		{ref: "https://github.com/gkze/stars.git", owner: "gkze", repo: "stars"},
+		{ref: "https://GitHub.com/gkze/stars/", owner: "gkze", repo: "stars"},
+		{ref: "https://gitlab.com/gkze/stars", err: true},
+		{ref: "https://github.example.com/gkze/stars", err: true},
+		{ref: "gkze", err: true},
+	}
+
+	for _, tc := range testCases {
+		owner, repo, err := sm.GitHubRepo(tc.ref)
+		if tc.err {
+			assert.Error(t, err, tc.ref)
+			continue
+		}
+
+		assert.NoError(t, err, tc.ref)
+		assert.Equal(t, []string{tc.owner, tc.repo}, []string{owner, repo}, tc.ref)
+	}
+
+	// GitHub Enterprise Server stars are on its own host
+	sm.Host = "github.example.com"
+	owner, repo, err := sm.GitHubRepo("https://github.example.com/gkze/stars")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"gkze", "stars"}, []string{owner, repo})
+}
@@ -0,0 +1,301 @@
+package starmanager
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/asdine/storm/q"
+	"github.com/google/go-github/v25/github"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultSimilarity - the share of words descriptions have to have in common for their
+	// stars to count as duplicates
+	DefaultSimilarity float64 = 0.8
+
+	// minDescriptionWords is the number of distinct words a description needs to be compared,
+	// as short descriptions are too often alike by chance
+	minDescriptionWords int = 4
+
+	// maxForkDepth bounds how many forks of forks are followed to find the upstream project
+	maxForkDepth int = 10
+)
+
+// DuplicateOptions - the parameters of Duplicates
+type DuplicateOptions struct {
+	// Similarity is the share of distinct words (0 to 1) two descriptions have to have in
+	// common, DefaultSimilarity if 0
+	Similarity float64
+}
+
+// ForkGroup is an upstream project along with its starred forks
+type ForkGroup struct {
+	// Upstream is the URL of the project the forks were ultimately forked from
+	Upstream string `json:"upstream"`
+
+	// Starred is the star of the upstream project, nil if it is not starred
+	Starred *Star `json:"starred,omitempty"`
+
+	Forks []*Star `json:"forks"`
+}
+
+// DuplicateGroup is a set of stars that look like the same project
+type DuplicateGroup struct {
+	// Reason is why the stars were grouped, "same name" or "similar description"
+	Reason string  `json:"reason"`
+	Stars  []*Star `json:"stars"`
+}
+
+// DuplicateReport lists starred forks and near-duplicate stars
+type DuplicateReport struct {
+	// Forks are the groups of forks whose upstream is starred too or that share an upstream
+	Forks []*ForkGroup `json:"forks"`
+
+	// Duplicates are the groups of unrelated stars with the same name or a similar
+	// description
+	Duplicates []*DuplicateGroup `json:"duplicates"`
+}
+
+// FetchParents fetches the repository every cached GitHub fork was forked from, which the
+// REST API does not report when listing stars. Only forks without a known parent are
+// fetched. It returns the number of forks updated, and stops early with a RateLimitError if
+// the rate limit outlasts the retries.
+func (s *StarManager) FetchParents(ctx context.Context) (int, error) {
+	all, err := s.store().Query(q.Eq("Fork", true))
+	if err != nil {
+		return 0, err
+	}
+
+	// Parents are only fetched from GitHub, the other providers report them
+	forks := []*Star{}
+	for _, star := range all {
+		if star.Parent == "" && star.ProviderName() == webHost(s.Host) {
+			forks = append(forks, star)
+		}
+	}
+
+	updated, err := s.fetchEach(ctx, "parents", forks, func(ctx context.Context, t *throttle, star *Star) (bool, error) {
+		owner, repo, err := star.OwnerRepo()
+		if err != nil {
+			return false, err
+		}
+
+		var repository *github.Repository
+		err = withGitHubRetry(ctx, t, "parent of "+star.URL, func() (*github.Response, error) {
+			var (
+				resp *github.Response
+				err  error
+			)
+
+			repository, resp, err = s.Client.Repositories.Get(ctx, owner, repo)
+			return resp, err
+		})
+		if err != nil {
+			return false, err
+		}
+
+		star.Parent = repository.GetParent().GetHTMLURL()
+		if star.Parent == "" {
+			return false, nil
+		}
+
+		return true, s.store().Save(star)
+	})
+
+	log.Printf("Updated parents of %d forks", updated)
+	return updated, err
+}
+
+// upstream returns the URL of the project a star was ultimately forked from, following
+// forks of forks through the cache, or the star's own URL if it is not a fork
+func upstream(star *Star, byURL map[string]*Star) string {
+	url := star.URL
+
+	for depth := 0; star != nil && star.Fork && star.Parent != "" && depth < maxForkDepth; depth++ {
+		url = star.Parent
+		star = byURL[strings.ToLower(url)]
+	}
+
+	return url
+}
+
+// similarity returns the Jaccard index of two sets of words
+func similarity(a, b map[string]bool) float64 {
+	common := 0
+	for word := range a {
+		if b[word] {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// wordSet returns the distinct words of a text
+func wordSet(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range tokenize(text) {
+		words[word] = true
+	}
+
+	return words
+}
+
+// Duplicates reports starred forks grouped by their upstream project, and groups of other
+// stars that look like duplicates because they have the same repository name or very
+// similar descriptions
+func (s *StarManager) Duplicates(ctx context.Context, opts DuplicateOptions) (*DuplicateReport, error) {
+	if opts.Similarity <= 0 {
+		opts.Similarity = DefaultSimilarity
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stars, err := s.store().All()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(stars, func(i, j int) bool { return stars[i].URL < stars[j].URL })
+
+	byURL := map[string]*Star{}
+	for _, star := range stars {
+		byURL[strings.ToLower(star.URL)] = star
+	}
+
+	report := &DuplicateReport{Forks: []*ForkGroup{}, Duplicates: []*DuplicateGroup{}}
+
+	// Forks are grouped by their upstream project
+	roots := make([]string, len(stars))
+	groups := map[string]*ForkGroup{}
+	for i, star := range stars {
+		roots[i] = strings.ToLower(upstream(star, byURL))
+		if !star.Fork || star.Parent == "" {
+			continue
+		}
+
+		group, ok := groups[roots[i]]
+		if !ok {
+			group = &ForkGroup{Upstream: upstream(star, byURL), Starred: byURL[roots[i]], Forks: []*Star{}}
+			groups[roots[i]] = group
+		}
+
+		group.Forks = append(group.Forks, star)
+	}
+
+	for _, group := range groups {
+		if group.Starred != nil || len(group.Forks) > 1 {
+			report.Forks = append(report.Forks, group)
+		}
+	}
+
+	sort.Slice(report.Forks, func(i, j int) bool { return report.Forks[i].Upstream < report.Forks[j].Upstream })
+
+	// Other stars are grouped by name, leaving out forks of the same project
+	byName := map[string][]int{}
+	names := []string{}
+	for i, star := range stars {
+		_, repo, err := star.OwnerRepo()
+		if err != nil {
+			continue
+		}
+
+		name := strings.ToLower(repo)
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], i)
+	}
+
+	sort.Strings(names)
+	sameName := map[[2]int]bool{}
+	for _, name := range names {
+		group := &DuplicateGroup{Reason: "same name", Stars: []*Star{}}
+		seen := map[string]bool{}
+
+		for _, i := range byName[name] {
+			if seen[roots[i]] {
+				continue
+			}
+			seen[roots[i]] = true
+
+			for _, j := range byName[name] {
+				sameName[[2]int{i, j}] = true
+			}
+			group.Stars = append(group.Stars, stars[i])
+		}
+
+		if len(group.Stars) > 1 {
+			report.Duplicates = append(report.Duplicates, group)
+		}
+	}
+
+	// The remaining stars are grouped by similar descriptions, merging groups transitively
+	words := make([]map[string]bool, len(stars))
+	for i, star := range stars {
+		words[i] = wordSet(star.Description)
+	}
+
+	parent := make([]int, len(stars))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+
+		return parent[i]
+	}
+
+	for i := range stars {
+		if len(words[i]) < minDescriptionWords {
+			continue
+		}
+
+		for j := i + 1; j < len(stars); j++ {
+			if len(words[j]) < minDescriptionWords || roots[i] == roots[j] || sameName[[2]int{i, j}] {
+				continue
+			}
+
+			if similarity(words[i], words[j]) >= opts.Similarity {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	size := map[int]int{}
+	for i := range stars {
+		size[find(i)]++
+	}
+
+	similar := map[int]*DuplicateGroup{}
+	order := []int{}
+	for i, star := range stars {
+		root := find(i)
+		if size[root] < 2 {
+			continue
+		}
+
+		group, ok := similar[root]
+		if !ok {
+			group = &DuplicateGroup{Reason: "similar description", Stars: []*Star{}}
+			similar[root] = group
+			order = append(order, root)
+		}
+
+		group.Stars = append(group.Stars, star)
+	}
+
+	for _, root := range order {
+		report.Duplicates = append(report.Duplicates, similar[root])
+	}
+
+	return report, nil
+}
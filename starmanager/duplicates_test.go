@@ -0,0 +1,100 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// starURLs returns the URLs of the given stars
+func starURLs(stars []*Star) []string {
+	urls := []string{}
+	for _, star := range stars {
+		urls = append(urls, star.URL)
+	}
+
+	return urls
+}
+
+func TestDuplicates(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	stars := []*Star{
+		{URL: "https://github.com/up/tool", Description: "A fast tool for doing things"},
+		{URL: "https://github.com/a/tool", Description: "A fast tool for doing things", Fork: true, Parent: "https://github.com/up/tool"},
+		{URL: "https://github.com/b/tool", Description: "My fork", Fork: true, Parent: "https://github.com/a/tool"},
+		{URL: "https://github.com/c/lib", Fork: true, Parent: "https://github.com/unstarred/lib"},
+		{URL: "https://github.com/d/lib", Fork: true, Parent: "https://github.com/unstarred/lib"},
+		{URL: "https://github.com/e/single", Fork: true, Parent: "https://github.com/unstarred/single"},
+		{URL: "https://github.com/f/dotfiles"},
+		{URL: "https://github.com/g/dotfiles"},
+		{URL: "https://github.com/h/yaml-parser", Description: "Pure Go YAML parser and emitter"},
+		{URL: "https://github.com/i/goyaml", Description: "A pure Go YAML parser and emitter"},
+		{URL: "https://github.com/j/unrelated", Description: "Pure Go JSON encoder and decoder"},
+	}
+	for _, star := range stars {
+		assert.NoError(t, sm.DB.Save(star))
+	}
+
+	report, err := sm.Duplicates(context.Background(), DuplicateOptions{})
+	assert.NoError(t, err)
+
+	assert.Len(t, report.Forks, 2)
+	assert.Equal(t, "https://github.com/unstarred/lib", report.Forks[0].Upstream)
+	assert.Nil(t, report.Forks[0].Starred)
+	assert.Equal(t, []string{"https://github.com/c/lib", "https://github.com/d/lib"}, starURLs(report.Forks[0].Forks))
+	assert.Equal(t, "https://github.com/up/tool", report.Forks[1].Upstream)
+	assert.Equal(t, "https://github.com/up/tool", report.Forks[1].Starred.URL)
+	assert.Equal(t, []string{"https://github.com/a/tool", "https://github.com/b/tool"}, starURLs(report.Forks[1].Forks))
+
+	// Forks of the same project are not reported again as duplicates
+	assert.Len(t, report.Duplicates, 2)
+	assert.Equal(t, "same name", report.Duplicates[0].Reason)
+	assert.Equal(t, []string{"https://github.com/f/dotfiles", "https://github.com/g/dotfiles"}, starURLs(report.Duplicates[0].Stars))
+	assert.Equal(t, "similar description", report.Duplicates[1].Reason)
+	assert.Equal(t, []string{"https://github.com/h/yaml-parser", "https://github.com/i/goyaml"}, starURLs(report.Duplicates[1].Stars))
+}
+
+func TestFetchParents(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	requests := 0
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/repos/a/fork":
+			fmt.Fprint(w, `{"fork": true, "parent": {"html_url": "https://github.com/up/fork"}}`)
+		default:
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	}))
+	defer closeGitHub()
+
+	sm.Host = GitHub
+	sm.Client = client
+
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/fork", Fork: true}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/known", Fork: true, Parent: "https://github.com/up/known"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/upstream"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://gitlab.com/b/fork", Provider: "gitlab.com", Fork: true}))
+
+	updated, err := sm.FetchParents(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, 1, requests)
+
+	star := Star{}
+	assert.NoError(t, sm.DB.One("URL", "https://github.com/a/fork", &star))
+	assert.Equal(t, "https://github.com/up/fork", star.Parent)
+
+	// The parent survives a re-sync through the REST API, which does not report it
+	_, err = sm.SaveStar(&Star{URL: "https://github.com/a/fork", Fork: true})
+	assert.NoError(t, err)
+	assert.NoError(t, sm.DB.One("URL", "https://github.com/a/fork", &star))
+	assert.Equal(t, "https://github.com/up/fork", star.Parent)
+}
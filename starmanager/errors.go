@@ -0,0 +1,168 @@
+package starmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrGone is returned by providers for projects that no longer exist
+var ErrGone = errors.New("repository no longer exists")
+
+// MultiError aggregates the failures of an operation on many pages or stars
+type MultiError struct {
+	Errors []error
+}
+
+func (m *MultiError) Error() string {
+	if len(m.Errors) == 1 {
+		return m.Errors[0].Error()
+	}
+
+	lines := make([]string, 0, len(m.Errors)+1)
+	lines = append(lines, fmt.Sprintf("%d errors occurred:", len(m.Errors)))
+	for _, err := range m.Errors {
+		lines = append(lines, "\t* "+err.Error())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// CleanupError describes a star that could not be removed
+type CleanupError struct {
+	// URL is the URL of the star
+	URL string
+
+	// Err is the underlying error
+	Err error
+}
+
+func (e *CleanupError) Error() string {
+	return fmt.Sprintf("removing %s: %v", e.URL, e.Err)
+}
+
+// MarshalJSON encodes the URL along with the message of the underlying error
+func (e *CleanupError) MarshalJSON() ([]byte, error) {
+	return urlErrorJSON(e.URL, e.Err)
+}
+
+// ReconcileError describes a star that could not be reconciled
+type ReconcileError struct {
+	// URL is the URL of the star
+	URL string
+
+	// Err is the underlying error
+	Err error
+}
+
+func (e *ReconcileError) Error() string {
+	return fmt.Sprintf("reconciling %s: %v", e.URL, e.Err)
+}
+
+// MarshalJSON encodes the URL along with the message of the underlying error
+func (e *ReconcileError) MarshalJSON() ([]byte, error) {
+	return urlErrorJSON(e.URL, e.Err)
+}
+
+// CloneError describes a star that could not be cloned or updated
+type CloneError struct {
+	// URL is the URL of the star
+	URL string
+
+	// Err is the underlying error
+	Err error
+}
+
+func (e *CloneError) Error() string {
+	return fmt.Sprintf("cloning %s: %v", e.URL, e.Err)
+}
+
+// MarshalJSON encodes the URL along with the message of the underlying error
+func (e *CloneError) MarshalJSON() ([]byte, error) {
+	return urlErrorJSON(e.URL, e.Err)
+}
+
+// urlErrorJSON encodes a failure concerning a star, as errors have no JSON encoding of
+// their own
+func urlErrorJSON(url string, err error) ([]byte, error) {
+	return json.Marshal(struct {
+		URL   string `json:"url"`
+		Error string `json:"error"`
+	}{url, err.Error()})
+}
+
+// CleanupResult summarizes the outcome of a cleanup
+type CleanupResult struct {
+	Matched int `json:"matched"`
+	Removed int `json:"removed"`
+
+	// Skipped is the number of matching stars that were not confirmed for removal
+	Skipped int `json:"skipped"`
+
+	Failed int             `json:"failed"`
+	Errors []*CleanupError `json:"errors"`
+
+	// Candidates are the matching stars, which are not removed in a dry run
+	Candidates []*CleanupCandidate `json:"candidates"`
+}
+
+// Err returns a MultiError listing every star that could not be removed, or nil if all
+// matching stars were removed
+func (r *CleanupResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(r.Errors))
+	for i, e := range r.Errors {
+		errs[i] = e
+	}
+
+	return &MultiError{Errors: errs}
+}
+
+// Err returns a MultiError listing every page and star that failed to sync, or nil if the
+// sync succeeded
+func (r *SyncResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(r.Errors))
+	for i, e := range r.Errors {
+		errs[i] = e
+	}
+
+	return &MultiError{Errors: errs}
+}
+
+// Err returns a MultiError listing every star that could not be reconciled, or nil if all
+// stars were checked
+func (r *ReconcileResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(r.Errors))
+	for i, e := range r.Errors {
+		errs[i] = e
+	}
+
+	return &MultiError{Errors: errs}
+}
+
+// Err returns a MultiError listing every star that could not be cloned or updated, or nil if
+// all stars were archived
+func (r *CloneResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(r.Errors))
+	for i, e := range r.Errors {
+		errs[i] = e
+	}
+
+	return &MultiError{Errors: errs}
+}
@@ -0,0 +1,256 @@
+package starmanager
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormat is a file format stars can be exported to
+type ExportFormat string
+
+const (
+	// ExportJSON exports stars as a JSON array
+	ExportJSON ExportFormat = "json"
+
+	// ExportCSV exports stars as CSV with a header row
+	ExportCSV ExportFormat = "csv"
+
+	// ExportMarkdown exports stars as a grouped Markdown "awesome list"
+	ExportMarkdown ExportFormat = "markdown"
+
+	// ExportAtom exports stars as an Atom feed, most recently starred first
+	ExportAtom ExportFormat = "atom"
+
+	// ExportRSS exports stars as an RSS 2.0 feed, most recently starred first
+	ExportRSS ExportFormat = "rss"
+)
+
+// GroupBy selects how Markdown exports are sectioned
+type GroupBy string
+
+const (
+	// GroupByLanguage creates a section per language
+	GroupByLanguage GroupBy = "language"
+
+	// GroupByTopic creates a section per topic, listing stars under each of their topics
+	GroupByTopic GroupBy = "topic"
+
+	// GroupByOwner creates a section per user or organization owning the repositories
+	GroupByOwner GroupBy = "owner"
+)
+
+// ungrouped is the section stars without a language or topic are listed under
+const ungrouped = "Other"
+
+// csvHeader is the header row of CSV exports
+var csvHeader = []string{
+	"url",
+	"provider",
+	"language",
+	"stargazers",
+	"archived",
+	"license",
+	"topics",
+	"description",
+	"pushed_at",
+	"starred_at",
+}
+
+// ExportOptions selects the stars Export writes and how
+type ExportOptions struct {
+	// Format is the output format, JSON if unset
+	Format ExportFormat
+
+	// GroupBy sections Markdown exports, by language if unset
+	GroupBy GroupBy
+
+	// Filter selects and orders the exported stars like GetProjects. A zero Count exports
+	// all matching stars. Feeds are always ordered by when projects were starred. JSON and
+	// CSV exports sorted by SortURL are streamed, see ForEachStar.
+	Filter ProjectOptions
+
+	// Feed describes the feed itself when exporting to Atom or RSS
+	Feed FeedOptions
+}
+
+// Export writes the cached stars matching the given options to w
+func (s *StarManager) Export(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	if opts.Format == ExportAtom || opts.Format == ExportRSS {
+		opts.Filter.Sort, opts.Filter.Order = SortStarred, OrderDesc
+	}
+
+	each := func(fn func(star *Star) error) error {
+		return s.ForEachStar(ctx, opts.Filter, fn)
+	}
+
+	switch opts.Format {
+	case ExportJSON, "":
+		return exportJSON(w, each)
+	case ExportCSV:
+		return exportCSV(w, each)
+	}
+
+	stars := []Star{}
+	if err := each(func(star *Star) error {
+		stars = append(stars, *star)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	switch opts.Format {
+	case ExportMarkdown:
+		return exportMarkdown(w, stars, opts.GroupBy)
+	case ExportAtom:
+		return exportAtom(w, stars, opts.Feed, time.Now())
+	case ExportRSS:
+		return exportRSS(w, stars, opts.Feed, time.Now())
+	default:
+		return fmt.Errorf("unknown export format %q", opts.Format)
+	}
+}
+
+// exportJSON writes the stars as an indented JSON array one at a time, as they are read
+func exportJSON(w io.Writer, each func(fn func(star *Star) error) error) error {
+	n := 0
+	err := each(func(star *Star) error {
+		data, err := json.MarshalIndent(star, "  ", "  ")
+		if err != nil {
+			return err
+		}
+
+		separator := ",\n  "
+		if n == 0 {
+			separator = "[\n  "
+		}
+		n++
+
+		_, err = io.WriteString(w, separator+string(data))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	end := "\n]\n"
+	if n == 0 {
+		end = "[]\n"
+	}
+
+	_, err = io.WriteString(w, end)
+	return err
+}
+
+func exportCSV(w io.Writer, each func(fn func(star *Star) error) error) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	err := each(func(star *Star) error {
+		record := []string{
+			star.URL,
+			star.ProviderName(),
+			star.Language,
+			strconv.Itoa(star.Stargazers),
+			strconv.FormatBool(star.Archived),
+			star.License,
+			strings.Join(star.Topics, ";"),
+			star.Description,
+			formatTime(star.PushedAt),
+			formatTime(star.StarredAt),
+		}
+
+		return cw.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportMarkdown(w io.Writer, stars []Star, groupBy GroupBy) error {
+	groups := map[string][]Star{}
+
+	for _, star := range stars {
+		keys := []string{star.Language}
+		switch groupBy {
+		case GroupByTopic:
+			keys = star.Topics
+		case GroupByOwner:
+			owner, _, _ := star.OwnerRepo()
+			keys = []string{owner}
+		}
+
+		if len(keys) == 0 || (len(keys) == 1 && keys[0] == "") {
+			keys = []string{ungrouped}
+		}
+
+		for _, key := range keys {
+			groups[key] = append(groups[key], star)
+		}
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		if name != ungrouped {
+			names = append(names, name)
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool { return strings.ToLower(names[i]) < strings.ToLower(names[j]) })
+	if _, ok := groups[ungrouped]; ok {
+		names = append(names, ungrouped)
+	}
+
+	if _, err := fmt.Fprintf(w, "# Stars\n"); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "\n## %s\n\n", name); err != nil {
+			return err
+		}
+
+		for _, star := range groups[name] {
+			if _, err := fmt.Fprintln(w, markdownItem(star)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// markdownItem renders a star as a Markdown list item
+func markdownItem(star Star) string {
+	title := star.URL
+	if owner, repo, err := star.OwnerRepo(); err == nil {
+		title = owner + "/" + repo
+	}
+
+	item := fmt.Sprintf("- [%s](%s)", title, star.URL)
+	if star.Description != "" {
+		item += " - " + strings.TrimSpace(star.Description)
+	}
+
+	return item
+}
+
+// formatTime formats t as RFC 3339, or an empty string if t is zero
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
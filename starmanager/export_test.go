@@ -0,0 +1,92 @@
+package starmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport(t *testing.T) {
+	starred := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	stars := []Star{
+		{
+			URL:         "https://github.com/a/popular",
+			Language:    "go",
+			Stargazers:  100,
+			Topics:      []string{"cli", "git"},
+			Description: "A popular, \"quoted\" project",
+			StarredAt:   starred,
+		},
+		{URL: "https://github.com/a/rusty", Language: "rust", Stargazers: 10, Topics: []string{"cli"}},
+		{URL: "https://gitlab.com/b/docs", Provider: "gitlab.com", Stargazers: 1},
+	}
+
+	testCases := []struct {
+		opts     ExportOptions
+		expected string
+	}{
+		{
+			opts: ExportOptions{Format: ExportCSV, Filter: ProjectOptions{Language: "Go"}},
+			expected: "url,provider,language,stargazers,archived,license,topics,description,pushed_at,starred_at\n" +
+				"https://github.com/a/popular,github.com,go,100,false,,cli;git,\"A popular, \"\"quoted\"\" project\",,2020-01-02T03:04:05Z\n",
+		},
+		{
+			opts: ExportOptions{Format: ExportMarkdown},
+			expected: "# Stars\n" +
+				"\n## go\n\n- [a/popular](https://github.com/a/popular) - A popular, \"quoted\" project\n" +
+				"\n## rust\n\n- [a/rusty](https://github.com/a/rusty)\n" +
+				"\n## Other\n\n- [b/docs](https://gitlab.com/b/docs)\n",
+		},
+		{
+			opts: ExportOptions{Format: ExportMarkdown, GroupBy: GroupByTopic, Filter: ProjectOptions{Count: 2}},
+			expected: "# Stars\n" +
+				"\n## cli\n\n- [a/popular](https://github.com/a/popular) - A popular, \"quoted\" project\n- [a/rusty](https://github.com/a/rusty)\n" +
+				"\n## git\n\n- [a/popular](https://github.com/a/popular) - A popular, \"quoted\" project\n",
+		},
+		{
+			opts: ExportOptions{Format: ExportMarkdown, GroupBy: GroupByOwner},
+			expected: "# Stars\n" +
+				"\n## a\n\n- [a/popular](https://github.com/a/popular) - A popular, \"quoted\" project\n- [a/rusty](https://github.com/a/rusty)\n" +
+				"\n## b\n\n- [b/docs](https://gitlab.com/b/docs)\n",
+		},
+	}
+
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	for _, star := range stars {
+		star := star
+		assert.NoError(t, sm.DB.Save(&star))
+	}
+
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		assert.NoError(t, sm.Export(context.Background(), buf, tc.opts))
+		assert.Equal(t, tc.expected, buf.String())
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, sm.Export(context.Background(), buf, ExportOptions{}))
+
+	exported := []Star{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &exported))
+	assert.Len(t, exported, 3)
+	assert.Equal(t, "https://github.com/a/popular", exported[0].URL)
+
+	// Stars are written one at a time, as an encoder would have written all of them
+	encoded := &bytes.Buffer{}
+	enc := json.NewEncoder(encoded)
+	enc.SetIndent("", "  ")
+	assert.NoError(t, enc.Encode(exported))
+	assert.Equal(t, encoded.String(), buf.String())
+
+	buf = &bytes.Buffer{}
+	assert.NoError(t, sm.Export(context.Background(), buf, ExportOptions{Filter: ProjectOptions{Sort: SortURL, Language: "haskell"}}))
+	assert.Equal(t, "[]\n", buf.String())
+
+	assert.Error(t, sm.Export(context.Background(), &bytes.Buffer{}, ExportOptions{Format: "xml"}))
+}
@@ -0,0 +1,194 @@
+package starmanager
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// DefaultFeedTitle - the title of feeds if none is given
+const DefaultFeedTitle string = "Stars"
+
+// DefaultFeedID - the ID of Atom feeds without a link, which Atom requires to be a URI
+const DefaultFeedID string = "urn:stars:feed"
+
+// FeedOptions describe the feed itself when exporting to Atom or RSS
+type FeedOptions struct {
+	// Title is the title of the feed, DefaultFeedTitle if unset
+	Title string
+
+	// Link is where the feed is published, used as the ID of Atom feeds and the link of
+	// RSS channels
+	Link string
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    *atomLink   `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description,omitempty"`
+	Categories  []string `xml:"category"`
+}
+
+type rssFeed struct {
+	XMLName       xml.Name  `xml:"rss"`
+	Version       string    `xml:"version,attr"`
+	Title         string    `xml:"channel>title"`
+	Link          string    `xml:"channel>link"`
+	Description   string    `xml:"channel>description"`
+	LastBuildDate string    `xml:"channel>lastBuildDate"`
+	Items         []rssItem `xml:"channel>item"`
+}
+
+// feedTime returns when a star appears in a feed: when it was starred, or when it was last
+// pushed to for providers that do not report when projects were starred
+func feedTime(star *Star, now time.Time) time.Time {
+	switch {
+	case !star.StarredAt.IsZero():
+		return star.StarredAt
+	case !star.PushedAt.IsZero():
+		return star.PushedAt
+	default:
+		return now
+	}
+}
+
+// feedUpdated returns when a feed of the given stars last changed, which is when the most
+// recent of them was starred
+func feedUpdated(stars []Star, now time.Time) time.Time {
+	if len(stars) == 0 {
+		return now
+	}
+
+	updated := time.Time{}
+	for i := range stars {
+		if t := feedTime(&stars[i], now); t.After(updated) {
+			updated = t
+		}
+	}
+
+	return updated
+}
+
+// feedTitle returns the title of a star's feed entry, owner/repo where possible
+func feedTitle(star *Star) string {
+	if owner, repo, err := star.OwnerRepo(); err == nil {
+		return owner + "/" + repo
+	}
+
+	return star.URL
+}
+
+func exportAtom(w io.Writer, stars []Star, opts FeedOptions, now time.Time) error {
+	feed := atomFeed{
+		Title:   opts.Title,
+		ID:      opts.Link,
+		Updated: feedUpdated(stars, now).UTC().Format(time.RFC3339),
+		Author:  opts.Title,
+	}
+
+	if feed.Title == "" {
+		feed.Title, feed.Author = DefaultFeedTitle, DefaultFeedTitle
+	}
+
+	if feed.ID == "" {
+		feed.ID = DefaultFeedID
+	} else {
+		feed.Link = &atomLink{Href: opts.Link, Rel: "self"}
+	}
+
+	for i := range stars {
+		entry := atomEntry{
+			Title:   feedTitle(&stars[i]),
+			ID:      stars[i].URL,
+			Link:    atomLink{Href: stars[i].URL},
+			Updated: feedTime(&stars[i], now).UTC().Format(time.RFC3339),
+			Summary: stars[i].Description,
+		}
+
+		for _, topic := range stars[i].Topics {
+			entry.Categories = append(entry.Categories, atomCategory{Term: topic})
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return writeXML(w, feed)
+}
+
+func exportRSS(w io.Writer, stars []Star, opts FeedOptions, now time.Time) error {
+	feed := rssFeed{
+		Version:       "2.0",
+		Title:         opts.Title,
+		Link:          opts.Link,
+		LastBuildDate: feedUpdated(stars, now).UTC().Format(time.RFC1123Z),
+	}
+
+	if feed.Title == "" {
+		feed.Title = DefaultFeedTitle
+	}
+	feed.Description = feed.Title
+
+	for i := range stars {
+		feed.Items = append(feed.Items, rssItem{
+			Title:       feedTitle(&stars[i]),
+			Link:        stars[i].URL,
+			GUID:        rssGUID{Value: stars[i].URL, IsPermaLink: true},
+			PubDate:     feedTime(&stars[i], now).UTC().Format(time.RFC1123Z),
+			Description: stars[i].Description,
+			Categories:  stars[i].Topics,
+		})
+	}
+
+	return writeXML(w, feed)
+}
+
+// writeXML writes v as an indented XML document
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
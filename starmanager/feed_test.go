@@ -0,0 +1,55 @@
+package starmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportFeeds(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	older := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	newer := older.AddDate(0, 1, 0)
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/older", Stargazers: 100, StarredAt: older, Topics: []string{"cli"}}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/newer", Stargazers: 1, StarredAt: newer, Description: "Fresh & new"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/b/rust", Language: "rust", StarredAt: newer.AddDate(0, 1, 0)}))
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, sm.Export(context.Background(), buf, ExportOptions{
+		Format: ExportAtom,
+		Filter: ProjectOptions{Query: "-language:rust", Sort: SortStargazers},
+	}))
+
+	// Feeds list the most recently starred first, whatever the sort
+	atom := atomFeed{}
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &atom))
+	assert.Equal(t, DefaultFeedTitle, atom.Title)
+	assert.Equal(t, DefaultFeedID, atom.ID)
+	assert.Equal(t, "2020-02-02T03:04:05Z", atom.Updated)
+	assert.Len(t, atom.Entries, 2)
+	assert.Equal(t, "a/newer", atom.Entries[0].Title)
+	assert.Equal(t, "Fresh & new", atom.Entries[0].Summary)
+	assert.Equal(t, "https://github.com/a/older", atom.Entries[1].ID)
+	assert.Equal(t, []atomCategory{{Term: "cli"}}, atom.Entries[1].Categories)
+
+	buf.Reset()
+	assert.NoError(t, sm.Export(context.Background(), buf, ExportOptions{
+		Format: ExportRSS,
+		Filter: ProjectOptions{Count: 1},
+		Feed:   FeedOptions{Title: "My stars", Link: "https://example.com/stars.rss"},
+	}))
+
+	rss := rssFeed{}
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &rss))
+	assert.Equal(t, "My stars", rss.Title)
+	assert.Equal(t, "https://example.com/stars.rss", rss.Link)
+	assert.Len(t, rss.Items, 1)
+	assert.Equal(t, "https://github.com/b/rust", rss.Items[0].GUID.Value)
+	assert.Equal(t, "Mon, 02 Mar 2020 03:04:05 +0000", rss.Items[0].PubDate)
+}
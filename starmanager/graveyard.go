@@ -0,0 +1,145 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm"
+	log "github.com/sirupsen/logrus"
+)
+
+// GraveyardNode - the name of the storm node holding removed stars
+const GraveyardNode string = "graveyard"
+
+// Grave is a star that was removed, kept so that the removal can be undone
+type Grave struct {
+	URL  string `storm:"id"`
+	Star Star
+
+	// RemovedAt is when the star was removed
+	RemovedAt time.Time `storm:"index"`
+
+	// Reason says why the star was removed
+	Reason string
+
+	// Batch is when the operation that removed the star started, shared by all stars removed
+	// by the same cleanup
+	Batch time.Time `storm:"index"`
+}
+
+// graveyard returns the storm node holding removed stars
+func (s *StarManager) graveyard() storm.Node {
+	return s.DB.From(GraveyardNode)
+}
+
+// bury records a star about to be removed in the graveyard
+func (s *StarManager) bury(star *Star, reason string, batch time.Time) error {
+	return s.graveyard().Save(&Grave{
+		URL:       star.URL,
+		Star:      *star,
+		RemovedAt: time.Now(),
+		Reason:    reason,
+		Batch:     batch,
+	})
+}
+
+// Graveyard returns all removed stars, most recently removed first
+func (s *StarManager) Graveyard(ctx context.Context) ([]*Grave, error) {
+	graves := []*Grave{}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.graveyard().All(&graves); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(graves, func(i, j int) bool { return graves[i].RemovedAt.After(graves[j].RemovedAt) })
+
+	return graves, nil
+}
+
+// Restore stars a removed project again, returning it to the cache and taking it out of
+// the graveyard
+func (s *StarManager) Restore(ctx context.Context, url string) error {
+	grave := &Grave{}
+	if err := s.graveyard().One("URL", url, grave); err != nil {
+		if err == storm.ErrNotFound {
+			return fmt.Errorf("%s is not in the graveyard", url)
+		}
+
+		return err
+	}
+
+	return s.restore(ctx, grave)
+}
+
+// restore re-stars the star of a grave
+func (s *StarManager) restore(ctx context.Context, grave *Grave) error {
+	owner, repo, err := ownerRepo(grave.URL)
+	if err != nil {
+		return err
+	}
+
+	provider, err := s.Provider(grave.Star.Provider)
+	if err != nil {
+		return err
+	}
+
+	if err := provider.Star(ctx, owner, repo); err != nil {
+		return err
+	}
+
+	// Restored stars are starred anew, so cleanups by starred date do not remove them again
+	grave.Star.StarredAt = time.Now()
+	if _, err := s.SaveStar(&grave.Star); err != nil {
+		return err
+	}
+
+	log.Printf("Restored %s", grave.URL)
+	return s.graveyard().DeleteStruct(grave)
+}
+
+// Undo restores every star removed by the most recent removal, i.e. the last cleanup or
+// RemoveStar call. Stars that could not be restored stay in the graveyard and are reported
+// in the returned MultiError.
+func (s *StarManager) Undo(ctx context.Context) ([]*Grave, error) {
+	latest := &Grave{}
+	if err := s.graveyard().Select().OrderBy("Batch").Reverse().First(latest); err != nil {
+		if err == storm.ErrNotFound {
+			return []*Grave{}, nil
+		}
+
+		return nil, err
+	}
+
+	graves := []*Grave{}
+	if err := s.graveyard().Find("Batch", latest.Batch, &graves); err != nil {
+		return nil, err
+	}
+
+	restored := []*Grave{}
+	errs := []error{}
+
+	for _, grave := range graves {
+		if err := ctx.Err(); err != nil {
+			return restored, err
+		}
+
+		if err := s.restore(ctx, grave); err != nil {
+			errs = append(errs, fmt.Errorf("restoring %s: %v", grave.URL, err))
+			continue
+		}
+
+		restored = append(restored, grave)
+	}
+
+	if len(errs) > 0 {
+		return restored, &MultiError{Errors: errs}
+	}
+
+	return restored, nil
+}
@@ -0,0 +1,61 @@
+package starmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraveyard(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	provider := &fakeProvider{name: "github.com"}
+	sm.Providers = []Provider{provider}
+	ctx := context.Background()
+
+	old := time.Now().AddDate(-1, 0, 0)
+	manual := &Star{URL: "https://github.com/a/manual", PushedAt: time.Now()}
+	assert.NoError(t, sm.DB.Save(manual))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/one", PushedAt: old, StarredAt: old, Topics: []string{"cli"}}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/two", PushedAt: old}))
+
+	removed, err := sm.RemoveStar(ctx, manual)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+
+	result, err := sm.Cleanup(ctx, CleanupOptions{Months: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Removed)
+
+	graves, err := sm.Graveyard(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graves, 3)
+	assert.Equal(t, "https://github.com/a/manual", graves[2].URL)
+	assert.Equal(t, "removed manually", graves[2].Reason)
+	assert.Contains(t, graves[0].Reason, "last pushed")
+
+	// Undo only restores the stars removed by the cleanup
+	restored, err := sm.Undo(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, restored, 2)
+	assert.ElementsMatch(t, []string{"a/one", "a/two"}, provider.starred)
+
+	star := Star{}
+	assert.NoError(t, sm.DB.One("URL", "https://github.com/a/one", &star))
+	assert.Equal(t, []string{"cli"}, star.Topics)
+	assert.WithinDuration(t, time.Now(), star.StarredAt, time.Minute)
+
+	assert.NoError(t, sm.Restore(ctx, "https://github.com/a/manual"))
+	assert.EqualError(t, sm.Restore(ctx, "https://github.com/a/manual"), "https://github.com/a/manual is not in the graveyard")
+
+	graves, err = sm.Graveyard(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, graves)
+
+	restored, err = sm.Undo(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, restored)
+}
@@ -0,0 +1,178 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// MaxHealth - the score of a perfectly healthy star
+	MaxHealth int = 100
+
+	// archivedPenalty is deducted from the score of archived repositories
+	archivedPenalty int = 50
+
+	// maxPushPenalty is deducted from the score of repositories not pushed to for
+	// pushStaleAfter plus pushDeadAfter, growing linearly from pushStaleAfter
+	maxPushPenalty int = 35
+
+	// maxIssuePenalty is deducted from the score of repositories with at least one open
+	// issue per ten stargazers
+	maxIssuePenalty int = 15
+
+	// maxReleasePenalty is deducted from the score of repositories whose latest release is
+	// older than releaseStaleAfter plus releaseDeadAfter, growing linearly from
+	// releaseStaleAfter
+	maxReleasePenalty int = 15
+
+	// pushStaleAfter and pushDeadAfter bound the penalty for the push age, releaseStaleAfter
+	// and releaseDeadAfter the one for the release age
+	pushStaleAfter    time.Duration = 180 * 24 * time.Hour
+	pushDeadAfter     time.Duration = 900 * 24 * time.Hour
+	releaseStaleAfter time.Duration = 365 * 24 * time.Hour
+	releaseDeadAfter  time.Duration = 3 * 365 * 24 * time.Hour
+)
+
+// HealthSignal is a single reason a star's health score was lowered
+type HealthSignal struct {
+	Name    string `json:"name"`
+	Penalty int    `json:"penalty"`
+	Detail  string `json:"detail"`
+}
+
+// Health is the health score of a star along with the signals that lowered it
+type Health struct {
+	Star Star `json:"star"`
+
+	// Score is between 0 (dead) and MaxHealth
+	Score   int            `json:"score"`
+	Signals []HealthSignal `json:"signals"`
+}
+
+// HealthOptions - the parameters of a health report
+type HealthOptions struct {
+	// Count limits the report to this many stars, 0 reports all
+	Count int
+
+	// Below limits the report to stars scoring below this, 0 reports all
+	Below int
+}
+
+// stalePenalty scales penalty linearly with how far age exceeds staleAfter, reaching the
+// full penalty deadAfter later
+func stalePenalty(age, staleAfter, deadAfter time.Duration, penalty int) int {
+	if age <= staleAfter {
+		return 0
+	}
+
+	if age >= staleAfter+deadAfter {
+		return penalty
+	}
+
+	return int(float64(penalty) * float64(age-staleAfter) / float64(deadAfter))
+}
+
+// Score rates the health of a star from whether it is archived, how long ago it was pushed
+// to, its open issues per stargazer and the age of its latest release. Signals that are
+// unknown, such as the release of a project that never published one, do not lower the
+// score.
+func Score(star *Star, now time.Time) (int, []HealthSignal) {
+	signals := []HealthSignal{}
+
+	if star.Gone {
+		return 0, append(signals, HealthSignal{Name: "gone", Penalty: MaxHealth, Detail: "repository no longer exists"})
+	}
+
+	if star.Archived {
+		signals = append(signals, HealthSignal{Name: "archived", Penalty: archivedPenalty, Detail: "archived"})
+	}
+
+	if !star.PushedAt.IsZero() {
+		age := now.Sub(star.PushedAt)
+		if penalty := stalePenalty(age, pushStaleAfter, pushDeadAfter, maxPushPenalty); penalty > 0 {
+			signals = append(signals, HealthSignal{
+				Name:    "pushed",
+				Penalty: penalty,
+				Detail:  fmt.Sprintf("last pushed %s", star.PushedAt.Format("2006-01-02")),
+			})
+		}
+	}
+
+	if star.OpenIssues > 0 && star.Stargazers > 0 {
+		ratio := float64(star.OpenIssues) / float64(star.Stargazers)
+
+		penalty := int(ratio * 10 * float64(maxIssuePenalty))
+		if penalty > maxIssuePenalty {
+			penalty = maxIssuePenalty
+		}
+
+		if penalty > 0 {
+			signals = append(signals, HealthSignal{
+				Name:    "issues",
+				Penalty: penalty,
+				Detail:  fmt.Sprintf("%d open issues for %d stargazers", star.OpenIssues, star.Stargazers),
+			})
+		}
+	}
+
+	if !star.ReleasedAt.IsZero() {
+		age := now.Sub(star.ReleasedAt)
+		if penalty := stalePenalty(age, releaseStaleAfter, releaseDeadAfter, maxReleasePenalty); penalty > 0 {
+			signals = append(signals, HealthSignal{
+				Name:    "release",
+				Penalty: penalty,
+				Detail:  fmt.Sprintf("last released %s", star.ReleasedAt.Format("2006-01-02")),
+			})
+		}
+	}
+
+	score := MaxHealth
+	for _, signal := range signals {
+		score -= signal.Penalty
+	}
+
+	if score < 0 {
+		score = 0
+	}
+
+	return score, signals
+}
+
+// HealthReport scores every cached star, worst first
+func (s *StarManager) HealthReport(ctx context.Context, opts HealthOptions) ([]*Health, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stars, err := s.store().All()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := []*Health{}
+	for _, star := range stars {
+		score, signals := Score(star, now)
+		if opts.Below > 0 && score >= opts.Below {
+			continue
+		}
+
+		report = append(report, &Health{Star: *star, Score: score, Signals: signals})
+	}
+
+	sort.SliceStable(report, func(i, j int) bool {
+		if report[i].Score != report[j].Score {
+			return report[i].Score < report[j].Score
+		}
+
+		return report[i].Star.PushedAt.Before(report[j].Star.PushedAt)
+	})
+
+	if opts.Count > 0 && len(report) > opts.Count {
+		report = report[:opts.Count]
+	}
+
+	return report, nil
+}
@@ -0,0 +1,75 @@
+package starmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScore(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		star    Star
+		score   int
+		signals []string
+	}{
+		{star: Star{PushedAt: now, Stargazers: 100}, score: 100, signals: []string{}},
+		{star: Star{PushedAt: now, Archived: true}, score: 50, signals: []string{"archived"}},
+		{star: Star{PushedAt: now.AddDate(-4, 0, 0)}, score: 65, signals: []string{"pushed"}},
+		{star: Star{PushedAt: now, OpenIssues: 5, Stargazers: 100}, score: 93, signals: []string{"issues"}},
+		{star: Star{PushedAt: now, OpenIssues: 500, Stargazers: 100}, score: 85, signals: []string{"issues"}},
+		{star: Star{PushedAt: now, ReleasedAt: now.AddDate(-5, 0, 0)}, score: 85, signals: []string{"release"}},
+		{star: Star{PushedAt: now, ReleasedAt: now.AddDate(0, -6, 0)}, score: 100, signals: []string{}},
+		{
+			star:    Star{Archived: true, PushedAt: now.AddDate(-4, 0, 0), ReleasedAt: now.AddDate(-5, 0, 0), OpenIssues: 10, Stargazers: 1},
+			score:   0,
+			signals: []string{"archived", "pushed", "issues", "release"},
+		},
+		{star: Star{PushedAt: now, Gone: true}, score: 0, signals: []string{"gone"}},
+	}
+
+	for _, tc := range testCases {
+		score, signals := Score(&tc.star, now)
+		assert.Equal(t, tc.score, score)
+
+		names := []string{}
+		for _, s := range signals {
+			names = append(names, s.Name)
+		}
+		assert.Equal(t, tc.signals, names)
+	}
+}
+
+func TestHealthReport(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	now := time.Now()
+	stars := []Star{
+		{URL: "https://github.com/a/healthy", PushedAt: now},
+		{URL: "https://github.com/a/archived", PushedAt: now, Archived: true},
+		{URL: "https://github.com/a/dead", PushedAt: now.AddDate(-5, 0, 0), Archived: true},
+	}
+	for i := range stars {
+		assert.NoError(t, sm.DB.Save(&stars[i]))
+	}
+
+	report, err := sm.HealthReport(context.Background(), HealthOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, report, 3)
+	assert.Equal(t, "https://github.com/a/dead", report[0].Star.URL)
+	assert.Equal(t, 15, report[0].Score)
+	assert.Equal(t, "https://github.com/a/healthy", report[2].Star.URL)
+
+	report, err = sm.HealthReport(context.Background(), HealthOptions{Below: 100, Count: 1})
+	assert.NoError(t, err)
+	assert.Len(t, report, 1)
+
+	result, err := sm.Cleanup(context.Background(), CleanupOptions{Months: 120, ScoreBelow: 20, DryRun: true})
+	assert.NoError(t, err)
+	assert.Len(t, result.Candidates, 1)
+	assert.Equal(t, []string{"health score 15"}, result.Candidates[0].Reasons)
+}
@@ -0,0 +1,239 @@
+package starmanager
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	log "github.com/sirupsen/logrus"
+)
+
+// ImportOptions controls how Import restores stars
+type ImportOptions struct {
+	// Format is the format of the export being imported, JSON if unset. Markdown exports
+	// cannot be imported.
+	Format ExportFormat
+
+	// DryRun only reports which projects would be starred without starring them
+	DryRun bool
+}
+
+// ImportError describes a project that could not be starred
+type ImportError struct {
+	// URL is the URL of the project
+	URL string
+
+	// Err is the underlying error
+	Err error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("starring %s: %v", e.URL, e.Err)
+}
+
+// MarshalJSON encodes the URL along with the message of the underlying error
+func (e *ImportError) MarshalJSON() ([]byte, error) {
+	return urlErrorJSON(e.URL, e.Err)
+}
+
+// ImportResult summarizes the outcome of an import
+type ImportResult struct {
+	// Total is the number of stars in the export
+	Total int `json:"total"`
+
+	// Starred is the number of projects starred, or that would be starred in a dry run
+	Starred int `json:"starred"`
+
+	// Skipped is the number of projects that are already starred according to the cache
+	Skipped int `json:"skipped"`
+
+	Failed int            `json:"failed"`
+	Errors []*ImportError `json:"errors"`
+}
+
+// Err returns a MultiError listing every project that could not be starred, or nil if all
+// projects were starred
+func (r *ImportResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(r.Errors))
+	for i, e := range r.Errors {
+		errs[i] = e
+	}
+
+	return &MultiError{Errors: errs}
+}
+
+// ReadExport reads the stars from a JSON or CSV file written by Export
+func ReadExport(r io.Reader, format ExportFormat) ([]*Star, error) {
+	switch format {
+	case ExportJSON, "":
+		stars := []*Star{}
+		if err := json.NewDecoder(r).Decode(&stars); err != nil {
+			return nil, err
+		}
+
+		return stars, nil
+	case ExportCSV:
+		return readCSV(r)
+	default:
+		return nil, fmt.Errorf("cannot import %q exports", format)
+	}
+}
+
+// readCSV reads stars from a CSV export, locating the columns by the header row
+func readCSV(r io.Reader) ([]*Star, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return []*Star{}, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+
+	if _, ok := columns["url"]; !ok {
+		return nil, fmt.Errorf("CSV export has no url column")
+	}
+
+	stars := make([]*Star, 0, len(records)-1)
+	for line, record := range records[1:] {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+
+			return ""
+		}
+
+		star := &Star{
+			URL:         field("url"),
+			Provider:    field("provider"),
+			Language:    field("language"),
+			License:     field("license"),
+			Description: field("description"),
+		}
+
+		if topics := field("topics"); topics != "" {
+			star.Topics = strings.Split(topics, ";")
+		}
+
+		if stargazers := field("stargazers"); stargazers != "" {
+			if star.Stargazers, err = strconv.Atoi(stargazers); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line+2, err)
+			}
+		}
+
+		star.Archived = field("archived") == "true"
+
+		for name, t := range map[string]*time.Time{"pushed_at": &star.PushedAt, "starred_at": &star.StarredAt} {
+			if value := field(name); value != "" {
+				if *t, err = time.Parse(time.RFC3339, value); err != nil {
+					return nil, fmt.Errorf("line %d: %v", line+2, err)
+				}
+			}
+		}
+
+		stars = append(stars, star)
+	}
+
+	return stars, nil
+}
+
+// Import reads an export and stars every project in it that is not starred yet, throttling
+// and retrying requests when rate limited. Starred projects are added to the cache. A
+// non-nil error is only returned if the import could not run at all; projects that could
+// not be starred are reported in the result.
+func (s *StarManager) Import(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	stars, err := ReadExport(r, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{Total: len(stars)}
+	toStar := []*Star{}
+
+	for _, star := range stars {
+		if _, err := s.store().Get(star.URL); err == nil {
+			result.Skipped++
+			continue
+		} else if err != storm.ErrNotFound {
+			return nil, err
+		}
+
+		toStar = append(toStar, star)
+	}
+
+	if opts.DryRun {
+		for _, star := range toStar {
+			log.Printf("Would star %s", star.URL)
+		}
+
+		result.Starred = len(toStar)
+		return result, nil
+	}
+
+	mu := sync.Mutex{}
+	throttles := map[string]*throttle{}
+	for _, p := range s.Providers {
+		throttles[p.Name()] = &throttle{}
+	}
+
+	runPool(ctx, s.concurrency(), len(toStar), func(job int) {
+		star := toStar[job]
+		err := s.importStar(ctx, star, throttles)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			log.Printf("An error occurred while attempting to star %s: %s\n", star.URL, err.Error())
+			result.Failed++
+			result.Errors = append(result.Errors, &ImportError{URL: star.URL, Err: err})
+			return
+		}
+
+		result.Starred++
+	})
+
+	log.Printf("Starred %d of %d imported projects, %d skipped, %d failed", result.Starred, result.Total, result.Skipped, result.Failed)
+	return result, ctx.Err()
+}
+
+// importStar stars a single imported project on its provider and caches it
+func (s *StarManager) importStar(ctx context.Context, star *Star, throttles map[string]*throttle) error {
+	owner, repo, err := star.OwnerRepo()
+	if err != nil {
+		return err
+	}
+
+	provider, err := s.Provider(star.Provider)
+	if err != nil {
+		return err
+	}
+
+	err = withRetry(ctx, throttles[provider.Name()], star.URL, func() error {
+		return provider.Star(ctx, owner, repo)
+	})
+	if err != nil {
+		return err
+	}
+
+	star.StarredAt = time.Now()
+	_, err = s.SaveStar(star)
+	return err
+}
@@ -0,0 +1,67 @@
+package starmanager
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImport(t *testing.T) {
+	pushed := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	stars := []Star{
+		{URL: "https://github.com/a/one", Language: "go", Topics: []string{"cli", "git"}, Stargazers: 5, PushedAt: pushed},
+		{URL: "https://github.com/a/two", Archived: true},
+		{URL: "https://gitlab.com/b/three", Provider: "gitlab.com"},
+	}
+
+	for _, format := range []ExportFormat{ExportJSON, ExportCSV} {
+		source, cleanupSource := newTestStarManager(t)
+		for i := range stars {
+			assert.NoError(t, source.DB.Save(&stars[i]))
+		}
+
+		export := &bytes.Buffer{}
+		assert.NoError(t, source.Export(context.Background(), export, ExportOptions{Format: format}))
+		cleanupSource()
+
+		sm, cleanup := newTestStarManager(t)
+		github := &fakeProvider{name: "github.com"}
+		gitlab := &fakeProvider{name: "gitlab.com"}
+		sm.Providers = []Provider{github, gitlab}
+		assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/two"}))
+
+		result, err := sm.Import(context.Background(), bytes.NewReader(export.Bytes()), ImportOptions{Format: format, DryRun: true})
+		assert.NoError(t, err)
+		assert.Equal(t, &ImportResult{Total: 3, Starred: 2, Skipped: 1}, result)
+		assert.Empty(t, github.starred)
+
+		result, err = sm.Import(context.Background(), bytes.NewReader(export.Bytes()), ImportOptions{Format: format})
+		assert.NoError(t, err)
+		assert.NoError(t, result.Err())
+		assert.Equal(t, 2, result.Starred)
+		assert.Equal(t, []string{"a/one"}, github.starred)
+		assert.Equal(t, []string{"b/three"}, gitlab.starred)
+
+		imported := Star{}
+		assert.NoError(t, sm.DB.One("URL", "https://github.com/a/one", &imported))
+		assert.Equal(t, []string{"cli", "git"}, imported.Topics)
+		assert.Equal(t, 5, imported.Stargazers)
+		assert.True(t, pushed.Equal(imported.PushedAt))
+
+		cleanup()
+	}
+}
+
+func TestReadExportErrors(t *testing.T) {
+	_, err := ReadExport(bytes.NewBufferString("# Stars\n"), ExportMarkdown)
+	assert.Error(t, err)
+
+	_, err = ReadExport(bytes.NewBufferString("name\nfoo\n"), ExportCSV)
+	assert.EqualError(t, err, "CSV export has no url column")
+
+	_, err = ReadExport(bytes.NewBufferString("url,stargazers\nhttps://github.com/a/b,many\n"), ExportCSV)
+	assert.Error(t, err)
+}
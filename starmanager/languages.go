@@ -0,0 +1,86 @@
+package starmanager
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v25/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// FetchLanguages fetches the full language breakdown of every cached GitHub star and stores
+// it on the star, so that polyglot projects can be found by languages other than their
+// primary one. It returns the number of stars whose languages were updated, and stops early
+// with a RateLimitError if the rate limit outlasts the retries.
+func (s *StarManager) FetchLanguages(ctx context.Context) (int, error) {
+	all, err := s.store().All()
+	if err != nil {
+		return 0, err
+	}
+
+	// Language breakdowns are only available from GitHub
+	stars := []*Star{}
+	for _, star := range all {
+		if star.ProviderName() == webHost(s.Host) {
+			stars = append(stars, star)
+		}
+	}
+
+	updated, err := s.fetchEach(ctx, "languages", stars, func(ctx context.Context, t *throttle, star *Star) (bool, error) {
+		owner, repo, err := star.OwnerRepo()
+		if err != nil {
+			return false, err
+		}
+
+		var languages map[string]int
+		err = withGitHubRetry(ctx, t, "languages of "+star.URL, func() (*github.Response, error) {
+			var (
+				resp *github.Response
+				err  error
+			)
+
+			languages, resp, err = s.Client.Repositories.ListLanguages(ctx, owner, repo)
+			return resp, err
+		})
+		if err != nil {
+			return false, err
+		}
+
+		// Languages are stored in lower case like the primary language
+		star.Languages = make(map[string]int, len(languages))
+		for language, bytes := range languages {
+			star.Languages[strings.ToLower(language)] += bytes
+		}
+		star.LanguagesAt = time.Now()
+
+		return true, s.store().Save(star)
+	})
+
+	log.Printf("Updated languages for %d stars", updated)
+	return updated, err
+}
+
+// speaks reports whether a star is written in any of the given languages, case
+// insensitively. Besides the primary language, any language making up at least threshold
+// (between 0 and 1) of the code matches if the language breakdown was fetched and the
+// threshold is positive.
+func speaks(star *Star, languages []string, threshold float64) bool {
+	total := 0
+	for _, bytes := range star.Languages {
+		total += bytes
+	}
+
+	for _, language := range languages {
+		language = strings.ToLower(language)
+		if language == star.Language {
+			return true
+		}
+
+		if threshold > 0 && total > 0 && float64(star.Languages[language])/float64(total) >= threshold {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,111 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchLanguages(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/a/polyglot/languages":
+			fmt.Fprint(w, `{"Go": 700, "Python": 200, "Shell": 100}`)
+		default:
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	}))
+	defer closeGitHub()
+
+	sm.Host = GitHub
+	sm.Client = client
+
+	ctx := context.Background()
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/polyglot", Language: "go"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/python", Language: "python"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://gitlab.com/b/rust", Provider: "gitlab.com", Language: "rust"}))
+
+	updated, err := sm.FetchLanguages(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, updated)
+
+	star := Star{}
+	assert.NoError(t, sm.DB.One("URL", "https://github.com/a/polyglot", &star))
+	assert.Equal(t, map[string]int{"go": 700, "python": 200, "shell": 100}, star.Languages)
+
+	// The breakdown survives a re-sync of the star
+	_, err = sm.SaveStar(&Star{URL: "https://github.com/a/polyglot", Language: "go"})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		opts     ProjectOptions
+		expected []string
+	}{
+		{
+			opts:     ProjectOptions{Languages: []string{"python"}},
+			expected: []string{"https://github.com/a/python"},
+		},
+		{
+			opts:     ProjectOptions{Languages: []string{"Python"}, LanguageThreshold: 0.1},
+			expected: []string{"https://github.com/a/polyglot", "https://github.com/a/python"},
+		},
+		{
+			opts:     ProjectOptions{Languages: []string{"python"}, LanguageThreshold: 0.25},
+			expected: []string{"https://github.com/a/python"},
+		},
+		{
+			opts:     ProjectOptions{ExcludeLanguages: []string{"shell"}, LanguageThreshold: 0.1},
+			expected: []string{"https://github.com/a/python", "https://gitlab.com/b/rust"},
+		},
+	}
+
+	for _, tc := range testCases {
+		projects, err := sm.findProjects(ctx, tc.opts)
+		assert.NoError(t, err)
+
+		urls := []string{}
+		for _, p := range projects {
+			urls = append(urls, p.URL)
+		}
+		assert.ElementsMatch(t, tc.expected, urls)
+	}
+}
+
+func TestFetchLanguagesRateLimited(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	requests := int32(0)
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message": "API rate limit exceeded for user ID 1."}`)
+	}))
+	defer closeGitHub()
+
+	sm.Host = GitHub
+	sm.Concurrency = 1
+	sm.Client = client
+
+	for _, name := range []string{"one", "two", "three"} {
+		assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/" + name}))
+	}
+
+	// The remaining stars are not fetched once the rate limit outlasted the retries
+	updated, err := sm.FetchLanguages(context.Background())
+	assert.IsType(t, &RateLimitError{}, err)
+	assert.Equal(t, 0, updated)
+	assert.Equal(t, int32(MaxRetries+1), atomic.LoadInt32(&requests))
+}
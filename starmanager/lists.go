@@ -0,0 +1,302 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/asdine/storm"
+	"github.com/gkze/stars/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListNode - the name of the storm node holding the user's GitHub star lists
+const ListNode string = "lists"
+
+// listsQuery fetches a page of the viewer's star lists
+const listsQuery = `query($first: Int!, $after: String) {
+  viewer {
+    lists(first: $first, after: $after) {
+      pageInfo { endCursor hasNextPage }
+      nodes { id name slug description }
+    }
+  }
+}`
+
+// listItemsQuery fetches a page of the repositories on a star list
+const listItemsQuery = `query($id: ID!, $first: Int!, $after: String) {
+  node(id: $id) {
+    ... on UserList {
+      items(first: $first, after: $after) {
+        pageInfo { endCursor hasNextPage }
+        nodes { ... on Repository { url } }
+      }
+    }
+  }
+}`
+
+// repositoryIDQuery looks up the node ID of a repository
+const repositoryIDQuery = `query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) { id }
+}`
+
+// updateListsMutation sets the lists a repository is on
+const updateListsMutation = `mutation($itemId: ID!, $listIds: [ID!]!) {
+  updateUserListsForItem(input: {itemId: $itemId, listIds: $listIds}) { clientMutationId }
+}`
+
+// graphqlPageInfo is the cursor pagination state of a GraphQL connection
+type graphqlPageInfo struct {
+	EndCursor   string `json:"endCursor"`
+	HasNextPage bool   `json:"hasNextPage"`
+}
+
+// StarList is a GitHub list the user organizes their stars in
+type StarList struct {
+	ID          string `storm:"id"`
+	Name        string
+	Slug        string
+	Description string
+
+	// Items are the URLs of the repositories on the list
+	Items []string
+}
+
+// lists returns the storm node holding star lists
+func (s *StarManager) lists() storm.Node {
+	return s.DB.From(ListNode)
+}
+
+// Lists returns the star lists as of the last SyncLists
+func (s *StarManager) Lists() ([]*StarList, error) {
+	lists := []*StarList{}
+	if err := s.lists().All(&lists); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+// fetchLists fetches the user's star lists along with their items from GitHub
+func (s *StarManager) fetchLists(ctx context.Context) ([]*StarList, error) {
+	lists := []*StarList{}
+	variables := map[string]interface{}{"first": PageSize}
+
+	for {
+		resp := struct {
+			Viewer struct {
+				Lists struct {
+					PageInfo graphqlPageInfo `json:"pageInfo"`
+					Nodes    []*StarList     `json:"nodes"`
+				} `json:"lists"`
+			} `json:"viewer"`
+		}{}
+
+		if _, err := githubGraphQL(ctx, s.Client, listsQuery, variables, &resp); err != nil {
+			return nil, err
+		}
+
+		lists = append(lists, resp.Viewer.Lists.Nodes...)
+		if !resp.Viewer.Lists.PageInfo.HasNextPage {
+			break
+		}
+
+		variables["after"] = resp.Viewer.Lists.PageInfo.EndCursor
+	}
+
+	for _, list := range lists {
+		list.Items = []string{}
+		variables := map[string]interface{}{"id": list.ID, "first": PageSize}
+
+		for {
+			resp := struct {
+				Node struct {
+					Items struct {
+						PageInfo graphqlPageInfo `json:"pageInfo"`
+						Nodes    []struct {
+							URL string `json:"url"`
+						} `json:"nodes"`
+					} `json:"items"`
+				} `json:"node"`
+			}{}
+
+			if _, err := githubGraphQL(ctx, s.Client, listItemsQuery, variables, &resp); err != nil {
+				return nil, err
+			}
+
+			for _, item := range resp.Node.Items.Nodes {
+				// Lists can also hold other kinds of items, which have no URL here
+				if item.URL != "" {
+					list.Items = append(list.Items, item.URL)
+				}
+			}
+
+			if !resp.Node.Items.PageInfo.HasNextPage {
+				break
+			}
+
+			variables["after"] = resp.Node.Items.PageInfo.EndCursor
+		}
+	}
+
+	return lists, nil
+}
+
+// SyncLists fetches the user's GitHub star lists, stores them and records the lists every
+// cached star is on. It returns the number of lists.
+func (s *StarManager) SyncLists(ctx context.Context) (int, error) {
+	lists, err := s.fetchLists(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	membership := map[string][]string{}
+	for _, list := range lists {
+		for _, url := range list.Items {
+			membership[url] = append(membership[url], list.Name)
+		}
+	}
+
+	tx, err := s.DB.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	listsTx := tx.From(ListNode)
+	if err := dropBucket(listsTx, &StarList{}); err != nil {
+		return 0, err
+	}
+
+	for _, list := range lists {
+		if err := listsTx.Save(list); err != nil {
+			return 0, err
+		}
+	}
+
+	store := s.storeIn(tx)
+	stars, err := store.All()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, star := range stars {
+		names := membership[star.URL]
+		if len(names) == 0 && len(star.Lists) == 0 {
+			continue
+		}
+
+		star.Lists = names
+		if err := store.Save(star); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	log.Printf("Synced %d lists", len(lists))
+	return len(lists), nil
+}
+
+// findList returns the stored list with the given name or slug, case insensitively
+func (s *StarManager) findList(name string) (*StarList, []*StarList, error) {
+	lists, err := s.Lists()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, list := range lists {
+		if strings.EqualFold(list.Name, name) || strings.EqualFold(list.Slug, name) {
+			return list, lists, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("unknown list %q, lists may need to be synced", name)
+}
+
+// AddToList adds the cached GitHub star with the given URL to a list
+func (s *StarManager) AddToList(ctx context.Context, url, list string) error {
+	return s.updateLists(ctx, url, list, true)
+}
+
+// RemoveFromList removes the cached GitHub star with the given URL from a list
+func (s *StarManager) RemoveFromList(ctx context.Context, url, list string) error {
+	return s.updateLists(ctx, url, list, false)
+}
+
+// updateLists adds a star to or removes it from a list on GitHub and in the cache
+func (s *StarManager) updateLists(ctx context.Context, url, name string, add bool) error {
+	star, err := s.store().Get(url)
+	if err != nil {
+		if err == storm.ErrNotFound {
+			return fmt.Errorf("%s is not a cached star", url)
+		}
+
+		return err
+	}
+
+	if star.ProviderName() != webHost(s.Host) {
+		return fmt.Errorf("%s is not a GitHub star, only GitHub supports lists", url)
+	}
+
+	target, lists, err := s.findList(name)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for _, n := range star.Lists {
+		if n != target.Name {
+			names = append(names, n)
+		}
+	}
+	if add {
+		names = append(names, target.Name)
+	}
+
+	ids := []string{}
+	for _, list := range lists {
+		if utils.StringInSlice(list.Name, names) {
+			ids = append(ids, list.ID)
+		}
+	}
+
+	owner, repo, err := ownerRepo(url)
+	if err != nil {
+		return err
+	}
+
+	repository := struct {
+		Repository struct {
+			ID string `json:"id"`
+		} `json:"repository"`
+	}{}
+	if _, err := githubGraphQL(ctx, s.Client, repositoryIDQuery, map[string]interface{}{"owner": owner, "name": repo}, &repository); err != nil {
+		return err
+	}
+
+	variables := map[string]interface{}{"itemId": repository.Repository.ID, "listIds": ids}
+	if _, err := githubGraphQL(ctx, s.Client, updateListsMutation, variables, &struct{}{}); err != nil {
+		return err
+	}
+
+	items := []string{}
+	for _, item := range target.Items {
+		if item != url {
+			items = append(items, item)
+		}
+	}
+	if add {
+		items = append(items, url)
+	}
+	target.Items = items
+
+	if err := s.lists().Save(target); err != nil {
+		return err
+	}
+
+	star.Lists = names
+	return s.store().Save(star)
+}
@@ -0,0 +1,100 @@
+package starmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLists(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	var updated map[string]interface{}
+
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		switch {
+		case strings.Contains(body.Query, "updateUserListsForItem"):
+			updated = body.Variables
+			fmt.Fprint(w, `{"data": {"updateUserListsForItem": {"clientMutationId": null}}}`)
+		case strings.Contains(body.Query, "repository("):
+			fmt.Fprint(w, `{"data": {"repository": {"id": "R_1"}}}`)
+		case strings.Contains(body.Query, "node(id:") && body.Variables["after"] == nil && body.Variables["id"] == "L_1":
+			fmt.Fprint(w, `{"data": {"node": {"items": {
+				"pageInfo": {"endCursor": "c1", "hasNextPage": true},
+				"nodes": [{"url": "https://github.com/a/one"}]}}}}`)
+		case strings.Contains(body.Query, "node(id:") && body.Variables["id"] == "L_1":
+			fmt.Fprint(w, `{"data": {"node": {"items": {
+				"pageInfo": {"hasNextPage": false},
+				"nodes": [{"url": "https://github.com/a/two"}, {}]}}}}`)
+		case strings.Contains(body.Query, "node(id:"):
+			fmt.Fprint(w, `{"data": {"node": {"items": {"pageInfo": {"hasNextPage": false}, "nodes": []}}}}`)
+		default:
+			fmt.Fprint(w, `{"data": {"viewer": {"lists": {
+				"pageInfo": {"hasNextPage": false},
+				"nodes": [
+					{"id": "L_1", "name": "Tools", "slug": "tools"},
+					{"id": "L_2", "name": "Reading List", "slug": "reading-list"}
+				]}}}}`)
+		}
+	}))
+	defer closeGitHub()
+
+	sm.Host = GitHub
+	sm.Client = client
+
+	ctx := context.Background()
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/one"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/two"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://gitlab.com/b/three", Provider: "gitlab.com"}))
+
+	count, err := sm.SyncLists(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	projects, err := sm.findProjects(ctx, ProjectOptions{List: "tools"})
+	assert.NoError(t, err)
+	assert.Len(t, projects, 2)
+
+	// List memberships survive a re-sync of the star
+	_, err = sm.SaveStar(&Star{URL: "https://github.com/a/one", Description: "updated"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.AddToList(ctx, "https://github.com/a/one", "reading-list"))
+	assert.Equal(t, "R_1", updated["itemId"])
+	assert.ElementsMatch(t, []interface{}{"L_1", "L_2"}, updated["listIds"])
+
+	star := Star{}
+	assert.NoError(t, sm.DB.One("URL", "https://github.com/a/one", &star))
+	assert.Equal(t, []string{"Tools", "Reading List"}, star.Lists)
+
+	assert.NoError(t, sm.RemoveFromList(ctx, "https://github.com/a/one", "Tools"))
+	assert.Equal(t, []interface{}{"L_2"}, updated["listIds"])
+
+	projects, err = sm.findProjects(ctx, ProjectOptions{Query: "list:tools"})
+	assert.NoError(t, err)
+	assert.Len(t, projects, 1)
+	assert.Equal(t, "https://github.com/a/two", projects[0].URL)
+
+	lists, err := sm.Lists()
+	assert.NoError(t, err)
+	for _, list := range lists {
+		if list.Name == "Reading List" {
+			assert.Equal(t, []string{"https://github.com/a/one"}, list.Items)
+		}
+	}
+
+	assert.Error(t, sm.AddToList(ctx, "https://github.com/a/one", "nope"))
+	assert.Error(t, sm.AddToList(ctx, "https://gitlab.com/b/three", "tools"))
+}
@@ -0,0 +1,186 @@
+package starmanager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/gkze/stars/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+// LocalNode - the name of the storm node holding local-only metadata
+const LocalNode string = "local"
+
+// Annotation holds the local-only metadata of a star, i.e. everything that is not derived
+// from the provider. Annotations live in their own bucket keyed by the star's URL so that
+// clearing the cache or resyncing does not destroy them.
+type Annotation struct {
+	URL       string   `storm:"id"`
+	Tags      []string `storm:"index"`
+	Notes     string
+	Protected bool `storm:"index"`
+	Surfaced  []time.Time
+}
+
+// local returns the storm node holding annotations
+func (s *StarManager) local() storm.Node {
+	return s.DB.From(LocalNode)
+}
+
+// GetAnnotation returns the annotation for the star with the given URL. If the star has no
+// annotation yet, an empty one is returned.
+func (s *StarManager) GetAnnotation(url string) (*Annotation, error) {
+	annotation := &Annotation{}
+
+	if err := s.local().One("URL", url, annotation); err != nil {
+		if err == storm.ErrNotFound {
+			return &Annotation{URL: url}, nil
+		}
+
+		return nil, err
+	}
+
+	return annotation, nil
+}
+
+// SaveAnnotation persists an annotation to the local metadata bucket
+func (s *StarManager) SaveAnnotation(annotation *Annotation) error {
+	return s.local().Save(annotation)
+}
+
+// GetAnnotations returns all stored annotations keyed by star URL
+func (s *StarManager) GetAnnotations() (map[string]*Annotation, error) {
+	annotations := []*Annotation{}
+	if err := s.local().All(&annotations); err != nil {
+		return nil, err
+	}
+
+	byURL := make(map[string]*Annotation, len(annotations))
+	for _, a := range annotations {
+		byURL[a.URL] = a
+	}
+
+	return byURL, nil
+}
+
+// Pin protects the cached star with the given URL, so that Cleanup never removes it
+func (s *StarManager) Pin(url string) error {
+	return s.annotate(url, func(a *Annotation) { a.Protected = true })
+}
+
+// Unpin removes the protection of the star with the given URL
+func (s *StarManager) Unpin(url string) error {
+	return s.annotate(url, func(a *Annotation) { a.Protected = false })
+}
+
+// Tag adds local tags to the cached star with the given URL
+func (s *StarManager) Tag(url string, tags ...string) error {
+	return s.annotate(url, func(a *Annotation) {
+		for _, tag := range tags {
+			if tag != "" && !utils.StringInSlice(tag, a.Tags) {
+				a.Tags = append(a.Tags, tag)
+			}
+		}
+	})
+}
+
+// Untag removes local tags from the cached star with the given URL
+func (s *StarManager) Untag(url string, tags ...string) error {
+	return s.annotate(url, func(a *Annotation) {
+		kept := []string{}
+		for _, tag := range a.Tags {
+			if !utils.StringInSlice(tag, tags) {
+				kept = append(kept, tag)
+			}
+		}
+
+		a.Tags = kept
+	})
+}
+
+// Note replaces the notes on the cached star with the given URL. An empty note removes it.
+func (s *StarManager) Note(url, note string) error {
+	return s.annotate(url, func(a *Annotation) { a.Notes = note })
+}
+
+// annotate applies a change to the annotation of a cached star
+func (s *StarManager) annotate(url string, change func(a *Annotation)) error {
+	if _, err := s.store().Get(url); err != nil {
+		if err == storm.ErrNotFound {
+			return fmt.Errorf("%s is not a cached star", url)
+		}
+
+		return err
+	}
+
+	annotation, err := s.GetAnnotation(url)
+	if err != nil {
+		return err
+	}
+
+	change(annotation)
+	return s.SaveAnnotation(annotation)
+}
+
+// Tags returns all local tags along with how many stars have them, most common first
+func (s *StarManager) Tags() ([]KV, error) {
+	annotations, err := s.GetAnnotations()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, a := range annotations {
+		for _, tag := range a.Tags {
+			counts[tag]++
+		}
+	}
+
+	return sortedCounts(counts), nil
+}
+
+// Protected returns the URLs of all protected stars
+func (s *StarManager) Protected() ([]string, error) {
+	annotations := []*Annotation{}
+	if err := s.local().Find("Protected", true, &annotations); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	urls := make([]string, 0, len(annotations))
+	for _, a := range annotations {
+		urls = append(urls, a.URL)
+	}
+
+	return urls, nil
+}
+
+// MarkSurfaced records that the given stars were surfaced (displayed or opened) now, building
+// up a history that can be used to avoid showing the same stars over and over
+func (s *StarManager) MarkSurfaced(stars []Star) error {
+	now := time.Now()
+
+	for _, star := range stars {
+		annotation, err := s.GetAnnotation(star.URL)
+		if err != nil {
+			return err
+		}
+
+		annotation.Surfaced = append(annotation.Surfaced, now)
+		if err := s.SaveAnnotation(annotation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dropBucket drops the bucket backing the given struct type, treating a missing bucket as
+// already dropped
+func dropBucket(n storm.Node, data interface{}) error {
+	if err := n.Drop(data); err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+
+	return nil
+}
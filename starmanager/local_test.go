@@ -0,0 +1,156 @@
+package starmanager
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/google/go-github/v25/github"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestStarManager returns a StarManager backed by a temporary db, and a function that
+// removes it
+func newTestStarManager(t *testing.T) (*StarManager, func()) {
+	dir, err := ioutil.TempDir("", "stars")
+	assert.NoError(t, err)
+
+	db, err := storm.Open(filepath.Join(dir, CacheFile))
+	assert.NoError(t, err)
+
+	return &StarManager{DB: db}, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// newTestGitHub starts a fake GitHub API serving requests with the given handler, and returns
+// a client of it and a function that stops it
+func newTestGitHub(t *testing.T, handler http.Handler) (*github.Client, func()) {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	return client, srv.Close
+}
+
+func TestAnnotationsSurviveClearCache(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	star := Star{URL: "https://github.com/gkze/stars", Language: "go"}
+	assert.NoError(t, sm.DB.Save(&star))
+	assert.NoError(t, sm.SaveAnnotation(&Annotation{
+		URL:       star.URL,
+		Tags:      []string{"toolbox"},
+		Protected: true,
+	}))
+	assert.NoError(t, sm.MarkSurfaced([]Star{star}))
+
+	assert.NoError(t, sm.ClearCache(false))
+
+	count, err := sm.DB.Count(&Star{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	annotation, err := sm.GetAnnotation(star.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"toolbox"}, annotation.Tags)
+	assert.True(t, annotation.Protected)
+	assert.Len(t, annotation.Surfaced, 1)
+
+	// Clearing an already empty cache is not an error
+	assert.NoError(t, sm.ClearCache(false))
+}
+
+func TestGetAnnotationMissing(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	annotation, err := sm.GetAnnotation("https://github.com/gkze/nothere")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/gkze/nothere", annotation.URL)
+	assert.False(t, annotation.Protected)
+}
+
+func TestPinnedStarsSurviveCleanup(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	provider := &fakeProvider{name: "github.com"}
+	sm.Providers = []Provider{provider}
+
+	old := time.Now().AddDate(-5, 0, 0)
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/precious", PushedAt: old}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/junk", PushedAt: old}))
+
+	assert.NoError(t, sm.Pin("https://github.com/a/precious"))
+	assert.EqualError(t, sm.Pin("https://github.com/a/unknown"), "https://github.com/a/unknown is not a cached star")
+
+	protected, err := sm.Protected()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://github.com/a/precious"}, protected)
+
+	result, err := sm.Cleanup(context.Background(), CleanupOptions{Months: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Removed)
+	assert.Equal(t, []string{"a/junk"}, provider.unstarred)
+
+	assert.NoError(t, sm.Unpin("https://github.com/a/precious"))
+
+	protected, err = sm.Protected()
+	assert.NoError(t, err)
+	assert.Empty(t, protected)
+}
+
+func TestTagsAndNotes(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/tool", Description: "A rate limiter", Stargazers: 2}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/lib", Description: "A rate limiter too", Stargazers: 1}))
+
+	assert.NoError(t, sm.Tag("https://github.com/a/tool", "work", "toolbox", "work"))
+	assert.NoError(t, sm.Tag("https://github.com/a/lib", "work", "read-later"))
+	assert.NoError(t, sm.Untag("https://github.com/a/lib", "read-later"))
+	assert.NoError(t, sm.Note("https://github.com/a/lib", "Used by the billing service"))
+	assert.Error(t, sm.Tag("https://github.com/a/unknown", "work"))
+
+	annotation, err := sm.GetAnnotation("https://github.com/a/tool")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"work", "toolbox"}, annotation.Tags)
+
+	tags, err := sm.Tags()
+	assert.NoError(t, err)
+	assert.Equal(t, []KV{{"work", 2}, {"toolbox", 1}}, tags)
+
+	projects, err := sm.findProjects(ctx, ProjectOptions{Tags: []string{"toolbox"}})
+	assert.NoError(t, err)
+	assert.Len(t, projects, 1)
+	assert.Equal(t, "https://github.com/a/tool", projects[0].URL)
+
+	projects, err = sm.findProjects(ctx, ProjectOptions{Query: "tag:work -tag:toolbox billing"})
+	assert.NoError(t, err)
+	assert.Len(t, projects, 1)
+	assert.Equal(t, "https://github.com/a/lib", projects[0].URL)
+
+	results, err := sm.Search(ctx, "limiter tag:toolbox")
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "https://github.com/a/tool", results[0].URL)
+
+	results, err = sm.Search(ctx, "tag:work")
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+}
@@ -0,0 +1,113 @@
+package starmanager
+
+import (
+	"context"
+	"os"
+
+	"github.com/gkze/stars/auth"
+)
+
+// LoginOptions configure Login
+type LoginOptions struct {
+	// ClientID is the client ID of the GitHub OAuth app to authorize, $STARS_OAUTH_CLIENT_ID
+	// if empty
+	ClientID string
+
+	// Scopes are the OAuth scopes to ask for, auth.DefaultScopes if empty
+	Scopes []string
+
+	// Prompt shows the user the code to enter and where
+	Prompt func(code *auth.DeviceCode)
+
+	// Store keeps the token, auth.NewTokenStore if nil
+	Store auth.TokenStore
+}
+
+// Login authorizes stars on GitHub, or the GitHub Enterprise Server instance given with
+// WithHost, through the OAuth device flow and stores the token for New to find, in the
+// keyring if there is one. It returns the login of the authorized user.
+func Login(ctx context.Context, lo LoginOptions, opts ...Option) (string, error) {
+	o := newOptions(opts...)
+
+	if lo.ClientID == "" {
+		lo.ClientID = os.Getenv(auth.ClientIDEnv)
+	}
+	if len(lo.Scopes) == 0 {
+		lo.Scopes = auth.DefaultScopes
+	}
+
+	httpClient, err := o.httpClient()
+	if err != nil {
+		return "", err
+	}
+
+	flow := &auth.DeviceFlow{ClientID: lo.ClientID, BaseURL: "https://" + webHost(o.host), Client: httpClient}
+	code, err := flow.Start(ctx, lo.Scopes)
+	if err != nil {
+		return "", err
+	}
+
+	if lo.Prompt != nil {
+		lo.Prompt(code)
+	}
+
+	token, err := flow.Wait(ctx, code)
+	if err != nil {
+		return "", err
+	}
+
+	return storeToken(ctx, o, lo.Store, token)
+}
+
+// StoreToken checks a token with GitHub, or the GitHub Enterprise Server instance given with
+// WithHost, and stores it for New to find, replacing the token stored before. Tokens are
+// stored in the given store, or in the keyring if there is one if nil. It returns the login
+// of the user the token belongs to.
+func StoreToken(ctx context.Context, store auth.TokenStore, token string, opts ...Option) (string, error) {
+	return storeToken(ctx, newOptions(opts...), store, token)
+}
+
+// storeToken checks and stores a token, see StoreToken
+func storeToken(ctx context.Context, o *options, store auth.TokenStore, token string) (string, error) {
+	httpClient, err := o.httpClient()
+	if err != nil {
+		return "", err
+	}
+
+	client, err := newGitHubClient(ctx, o.host, token, httpClient)
+	if err != nil {
+		return "", err
+	}
+
+	user, _, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	if store == nil {
+		if store, err = auth.NewTokenStore(); err != nil {
+			return "", err
+		}
+	}
+
+	return user.GetLogin(), store.SetToken(o.host, user.GetLogin(), token)
+}
+
+// Logout removes the tokens stored by Login or StoreToken for GitHub, or the GitHub
+// Enterprise Server instance given with WithHost, from both the keyring and the tokens file
+func Logout(opts ...Option) error {
+	o := newOptions(opts...)
+
+	if keyring := auth.NewKeyring(); keyring.Available() {
+		if err := keyring.DeleteToken(o.host); err != nil {
+			return err
+		}
+	}
+
+	tokens, err := auth.NewTokenFile()
+	if err != nil {
+		return err
+	}
+
+	return tokens.DeleteToken(o.host)
+}
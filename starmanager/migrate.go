@@ -0,0 +1,229 @@
+package starmanager
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	log "github.com/sirupsen/logrus"
+)
+
+// SchemaNode - the name of the storm node holding the schema version of the cache
+const SchemaNode string = "schema"
+
+// schemaVersionID is the ID of the schema version record
+const schemaVersionID string = "version"
+
+// SchemaVersion records which migrations the cache has been upgraded with
+type SchemaVersion struct {
+	ID         string `storm:"id"`
+	Version    int
+	MigratedAt time.Time
+}
+
+// Migration upgrades caches written by older versions in place
+type Migration struct {
+	// Version is the schema version the migration upgrades to, one more than the previous one
+	Version int
+
+	// Description says what the migration does, for the logs
+	Description string
+
+	// Migrate upgrades the cache within a transaction. Stars kept outside the storm database
+	// are passed in their own store, which is not part of the transaction, so migrations
+	// must be safe to run again. host is the API host of the GitHub instance the cache is
+	// synced with, empty for github.com.
+	Migrate func(tx storm.Node, stars StarStore, host string) error
+}
+
+// migrations upgrade the cache one version at a time, oldest first. New migrations are
+// appended with the next version; released ones are never changed.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "index the fields of stars added after they were cached",
+		Migrate: func(tx storm.Node, stars StarStore, host string) error {
+			if b, ok := stars.(*BoltStore); ok {
+				return b.node.ReIndex(&Star{})
+			}
+
+			return nil
+		},
+	},
+	{
+		Version:     2,
+		Description: "record the provider of stars cached before other forges were supported",
+		Migrate: func(tx storm.Node, stars StarStore, host string) error {
+			return updateStars(stars, func(star *Star) bool {
+				if star.Provider != "" {
+					return false
+				}
+
+				star.Provider = webHost(host)
+				return true
+			})
+		},
+	},
+	{
+		Version:     3,
+		Description: "lower-case the languages of stars cached before they were normalized",
+		Migrate: func(tx storm.Node, stars StarStore, host string) error {
+			return updateStars(stars, func(star *Star) bool {
+				language := strings.ToLower(star.Language)
+				if language == star.Language {
+					return false
+				}
+
+				star.Language = language
+				return true
+			})
+		},
+	},
+	{
+		Version:     4,
+		Description: "record the owners and names of the repositories of cached stars",
+		Migrate: func(tx storm.Node, stars StarStore, host string) error {
+			return updateStars(stars, func(star *Star) bool {
+				owner, repo := star.Owner, star.RepoName
+				star.setOwnerRepo()
+
+				return star.Owner != owner || star.RepoName != repo
+			})
+		},
+	},
+	{
+		Version:     5,
+		Description: "record the full namespaces of projects in GitLab subgroups as their owners",
+		Migrate: func(tx storm.Node, stars StarStore, host string) error {
+			return updateStars(stars, func(star *Star) bool {
+				owner := star.Owner
+				star.setOwnerRepo()
+
+				return star.Owner != owner
+			})
+		},
+	},
+}
+
+// LatestSchemaVersion is the schema version of caches written by this version
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// updateStars saves the stars a change applies to
+func updateStars(stars StarStore, change func(star *Star) bool) error {
+	all, err := stars.All()
+	if err != nil {
+		return err
+	}
+
+	for _, star := range all {
+		if !change(star) {
+			continue
+		}
+
+		if err := stars.Save(star); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// schema returns the storm node holding the schema version
+func (s *StarManager) schema() storm.Node {
+	return s.DB.From(SchemaNode)
+}
+
+// SchemaVersion returns the schema version of the cache, 0 for caches written before
+// versions were recorded
+func (s *StarManager) SchemaVersion() (int, error) {
+	version := &SchemaVersion{}
+	if err := s.schema().One("ID", schemaVersionID, version); err != nil {
+		if err == storm.ErrNotFound {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	return version.Version, nil
+}
+
+// Migrate upgrades the cache to the latest schema version. New caches are only stamped with
+// it. Should a migration fail, the provider-derived data is cleared so that the next sync
+// fetches every star again, and local metadata such as tags and notes is kept. Caches
+// written by a newer version are refused.
+func (s *StarManager) Migrate() error {
+	return s.migrate(migrations)
+}
+
+// migrate applies the given migrations, see Migrate
+func (s *StarManager) migrate(migrations []Migration) error {
+	latest := migrations[len(migrations)-1].Version
+
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if version > latest {
+		return fmt.Errorf("the cache has schema version %d, but this version of stars only supports up to %d; upgrade stars or clear the cache", version, latest)
+	}
+
+	if version == 0 {
+		count, err := s.store().Count()
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return s.setSchemaVersion(s.DB, latest)
+		}
+	}
+
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+
+		log.Printf("Migrating the cache to version %d: %s", migration.Version, migration.Description)
+
+		if err := s.applyMigration(migration); err != nil {
+			log.Printf("Could not migrate the cache (%v), clearing it so that the next sync fetches all stars again", err)
+
+			if err := s.ClearCache(false); err != nil {
+				return err
+			}
+
+			return s.setSchemaVersion(s.DB, latest)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration runs a migration and records the new version in the same transaction
+func (s *StarManager) applyMigration(migration Migration) error {
+	tx, err := s.DB.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := migration.Migrate(tx, s.storeIn(tx), s.Host); err != nil {
+		return err
+	}
+
+	if err := s.setSchemaVersion(tx, migration.Version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setSchemaVersion records the schema version of the cache
+func (s *StarManager) setSchemaVersion(n storm.Node, version int) error {
+	return n.From(SchemaNode).Save(&SchemaVersion{ID: schemaVersionID, Version: version, MigratedAt: time.Now()})
+}
@@ -0,0 +1,120 @@
+package starmanager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/asdine/storm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateNewCache(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	assert.NoError(t, sm.Migrate())
+
+	version, err := sm.SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, LatestSchemaVersion(), version)
+}
+
+func TestMigrateOldCache(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/gkze/stars", Language: "Go"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://gitlab.com/acme/tool", Provider: "gitlab.com", Language: "rust"}))
+
+	assert.NoError(t, sm.Migrate())
+
+	version, err := sm.SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, LatestSchemaVersion(), version)
+
+	stars := []Star{}
+	assert.NoError(t, sm.DB.Find("Provider", "github.com", &stars))
+	assert.Len(t, stars, 1)
+	assert.Equal(t, "go", stars[0].Language)
+	assert.Equal(t, "gkze", stars[0].Owner)
+	assert.Equal(t, "stars", stars[0].RepoName)
+
+	assert.NoError(t, sm.DB.Find("Owner", "acme", &stars))
+	assert.Len(t, stars, 1)
+	assert.Equal(t, "tool", stars[0].RepoName)
+
+	// Migrating again changes nothing
+	assert.NoError(t, sm.Migrate())
+
+	count, err := sm.DB.Count(&Star{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestMigrateEnterpriseCache(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	sm.Host = "github.example.com"
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.example.com/acme/tool"}))
+
+	assert.NoError(t, sm.Migrate())
+
+	star := Star{}
+	assert.NoError(t, sm.DB.One("URL", "https://github.example.com/acme/tool", &star))
+	assert.Equal(t, "github.example.com", star.Provider)
+}
+
+func TestMigrateNewerCache(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	assert.NoError(t, sm.setSchemaVersion(sm.DB, LatestSchemaVersion()+1))
+	assert.Error(t, sm.Migrate())
+}
+
+func TestMigrateFailureResyncs(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	star := &Star{URL: "https://github.com/gkze/stars", Language: "go"}
+	assert.NoError(t, sm.DB.Save(star))
+	assert.NoError(t, sm.SaveAnnotation(&Annotation{URL: star.URL, Tags: []string{"toolbox"}}))
+	assert.NoError(t, sm.setSchemaVersion(sm.DB, 1))
+
+	applied := []int{}
+	err := sm.migrate([]Migration{
+		{Version: 1, Migrate: func(tx storm.Node, stars StarStore, host string) error {
+			applied = append(applied, 1)
+			return nil
+		}},
+		{Version: 2, Migrate: func(tx storm.Node, stars StarStore, host string) error {
+			applied = append(applied, 2)
+			star.Language = "changed"
+			if err := stars.Save(star); err != nil {
+				return err
+			}
+
+			return errors.New("unsupported cache")
+		}},
+		{Version: 3, Migrate: func(tx storm.Node, stars StarStore, host string) error {
+			applied = append(applied, 3)
+			return nil
+		}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2}, applied)
+
+	version, err := sm.SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, version)
+
+	// The stars are cleared for a full sync, local metadata is kept
+	count, err := sm.DB.Count(&Star{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	annotation, err := sm.GetAnnotation(star.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"toolbox"}, annotation.Tags)
+}
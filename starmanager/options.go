@@ -0,0 +1,355 @@
+package starmanager
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gkze/stars/auth"
+	"github.com/gkze/stars/utils"
+	"github.com/google/go-github/v25/github"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// HostEnv - the environment variable that overrides the GitHub API host
+	HostEnv string = "STARS_GITHUB_HOST"
+
+	// CacheEnv - the environment variable that overrides the path of the cache db file
+	CacheEnv string = "STARS_CACHE"
+
+	// TokensEnv - the environment variable holding additional GitHub tokens to rotate
+	// through, separated by commas or whitespace
+	TokensEnv string = "STARS_GITHUB_TOKENS"
+
+	// TopicAliasesEnv - the environment variable holding additional topic aliases as
+	// alias=topic pairs, separated by commas or whitespace
+	TopicAliasesEnv string = "STARS_TOPIC_ALIASES"
+
+	// XDGCacheEnv - the environment variable holding the base directory for user-specific
+	// cache files, see the XDG Base Directory Specification
+	XDGCacheEnv string = "XDG_CACHE_HOME"
+)
+
+// options holds the settings New is configured with
+type options struct {
+	host         string
+	cachePath    string
+	gitlabHosts  []string
+	giteaHosts   []string
+	graphql      bool
+	concurrency  int
+	dryRun       bool
+	client       *http.Client
+	proxy        string
+	timeout      time.Duration
+	retries      int
+	logRequests  bool
+	tokens       []string
+	rotateBelow  int
+	topicAliases map[string]string
+
+	snapshotRetention time.Duration
+}
+
+// Option configures a StarManager created by New
+type Option func(*options)
+
+// WithHost points the StarManager at a GitHub Enterprise Server instance instead of
+// github.com. The host is also used to look up credentials in the netrc file.
+func WithHost(host string) Option {
+	return func(o *options) {
+		o.host = host
+	}
+}
+
+// WithCachePath keeps the cache db at the given path instead of the default location, e.g.
+// on a local disk when the home directory is on a network share. The directory is created
+// if needed.
+func WithCachePath(path string) Option {
+	return func(o *options) {
+		o.cachePath = path
+	}
+}
+
+// WithGitLab additionally syncs stars from the GitLab instance on the given web host.
+// Credentials are looked up in the netrc file under the same host.
+func WithGitLab(host string) Option {
+	return func(o *options) {
+		o.gitlabHosts = append(o.gitlabHosts, normalizeHost(host))
+	}
+}
+
+// WithGitea additionally syncs stars from the Gitea or Forgejo instance on the given web
+// host. Credentials are looked up in the netrc file under the same host.
+func WithGitea(host string) Option {
+	return func(o *options) {
+		o.giteaHosts = append(o.giteaHosts, normalizeHost(host))
+	}
+}
+
+// WithGraphQL fetches GitHub stars through the GraphQL API, which needs far fewer requests
+// and returns topics, licenses and starred dates consistently
+func WithGraphQL() Option {
+	return func(o *options) {
+		o.graphql = true
+	}
+}
+
+// WithConcurrency limits the number of concurrent requests made while syncing and cleaning
+// up, e.g. for slow connections or strict proxies
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		o.concurrency = n
+	}
+}
+
+// WithDryRun makes AddStar, RemoveStar, Cleanup and Reconcile only log the changes they
+// would make instead of making them
+func WithDryRun() Option {
+	return func(o *options) {
+		o.dryRun = true
+	}
+}
+
+// WithHTTPClient makes requests to the providers with the given client, e.g. one with a
+// custom transport. Retries, request logging and timeouts configured with the other options
+// still apply; WithProxy does not.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithProxy sends requests through the given proxy URL. By default the proxy is taken from
+// the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+func WithProxy(proxy string) Option {
+	return func(o *options) {
+		o.proxy = proxy
+	}
+}
+
+// WithTimeout gives up on requests that take longer than the given duration, including
+// retries and reading the response
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithRetries retries requests hitting a secondary rate limit up to n times,
+// DefaultHTTPRetries by default. Zero disables retries.
+func WithRetries(n int) Option {
+	return func(o *options) {
+		o.retries = n
+	}
+}
+
+// WithRequestLogging logs every request with its status and duration, for debugging
+func WithRequestLogging() Option {
+	return func(o *options) {
+		o.logRequests = true
+	}
+}
+
+// WithTokens additionally makes requests with the given GitHub tokens, switching to the next
+// one whenever fewer than the rotation threshold requests remain for the one in use. The
+// token found in the configured credentials stays the main one: starring and listing stars
+// are always done with it, the others only read repositories. By default the tokens are
+// taken from the STARS_GITHUB_TOKENS environment variable.
+func WithTokens(tokens ...string) Option {
+	return func(o *options) {
+		o.tokens = append(o.tokens, tokens...)
+	}
+}
+
+// WithTokenRotationThreshold switches tokens once fewer than n requests remain for the one
+// in use, DefaultTokenRotationThreshold by default
+func WithTokenRotationThreshold(n int) Option {
+	return func(o *options) {
+		o.rotateBelow = n
+	}
+}
+
+// WithTopicAliases treats the topics given as keys as the topics they map to, e.g. golang as
+// go, in addition to DefaultTopicAliases. By default more aliases are taken from the
+// STARS_TOPIC_ALIASES environment variable.
+func WithTopicAliases(aliases map[string]string) Option {
+	return func(o *options) {
+		for alias, topic := range aliases {
+			o.topicAliases[alias] = topic
+		}
+	}
+}
+
+// WithSnapshotRetention prunes snapshots older than the given duration whenever a snapshot
+// is taken, DefaultSnapshotRetention by default. Zero keeps all snapshots.
+func WithSnapshotRetention(d time.Duration) Option {
+	return func(o *options) {
+		o.snapshotRetention = d
+	}
+}
+
+// newOptions applies the given options on top of the defaults, which are taken from the
+// environment where applicable
+func newOptions(opts ...Option) *options {
+	o := &options{
+		host:        os.Getenv(HostEnv),
+		cachePath:   os.Getenv(CacheEnv),
+		retries:     DefaultHTTPRetries,
+		tokens:      splitTokens(os.Getenv(TokensEnv)),
+		rotateBelow: DefaultTokenRotationThreshold,
+
+		snapshotRetention: DefaultSnapshotRetention,
+	}
+
+	aliases, err := ParseTopicAliases(os.Getenv(TopicAliasesEnv))
+	if err != nil {
+		log.Printf("Ignoring $%s: %v", TopicAliasesEnv, err.Error())
+		aliases = map[string]string{}
+	}
+	o.topicAliases = aliases
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	o.host = normalizeHost(o.host)
+	if o.host == "" {
+		o.host = GitHub
+	}
+
+	// gitlab.com and codeberg.org are synced whenever the netrc file has credentials for them
+	if !utils.StringInSlice(ProviderHosts["gitlab"], o.gitlabHosts) {
+		o.gitlabHosts = append(o.gitlabHosts, ProviderHosts["gitlab"])
+	}
+	if !utils.StringInSlice(ProviderHosts["codeberg"], o.giteaHosts) {
+		o.giteaHosts = append(o.giteaHosts, ProviderHosts["codeberg"])
+	}
+
+	return o
+}
+
+// defaultCachePath returns where the cache db is kept unless configured otherwise:
+// $XDG_CACHE_HOME/stars.db if XDG_CACHE_HOME is set to an absolute path, as the XDG Base
+// Directory Specification requires, and ~/.cache/stars.db otherwise. A cache already kept
+// in ~/.cache/stars.db keeps being used as long as there is none in XDG_CACHE_HOME.
+func defaultCachePath(home string) string {
+	legacy := filepath.Join(home, CachePath, CacheFile)
+
+	dir := os.Getenv(XDGCacheEnv)
+	if !filepath.IsAbs(dir) {
+		return legacy
+	}
+
+	path := filepath.Join(dir, CacheFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err := os.Stat(legacy); err == nil {
+			log.Printf("Using the cache in %s, move it to %s to use $%s", legacy, path, XDGCacheEnv)
+			return legacy
+		}
+	}
+
+	return path
+}
+
+// newForgeProviders returns the GitLab and Gitea providers for every configured host there
+// are credentials for
+func newForgeProviders(ctx context.Context, credentials auth.Interface, o *options, httpClient *http.Client) []Provider {
+	providers := []Provider{}
+
+	for _, host := range o.gitlabHosts {
+		if _, token, err := credentials.GetAuth(host); err == nil {
+			providers = append(providers, NewGitLabProvider(host, token, httpClient))
+		}
+	}
+
+	for _, host := range o.giteaHosts {
+		if _, token, err := credentials.GetAuth(host); err == nil {
+			providers = append(providers, NewGiteaProvider(host, token, httpClient))
+		}
+	}
+
+	return providers
+}
+
+// splitTokens splits a list of tokens separated by commas or whitespace
+func splitTokens(tokens string) []string {
+	return strings.FieldsFunc(tokens, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// githubTokens returns the tokens to make GitHub requests with, the main one first and
+// without duplicates
+func (o *options) githubTokens(main string) []string {
+	tokens := []string{main}
+	for _, token := range o.tokens {
+		if !utils.StringInSlice(token, tokens) {
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens
+}
+
+// normalizeHost strips any scheme and path from a host given as a URL
+func normalizeHost(host string) string {
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+
+	return host
+}
+
+// webHost returns the web host stars are served from for the given GitHub API host, an
+// empty one being GitHub's
+func webHost(apiHost string) string {
+	if apiHost == GitHub || apiHost == "" {
+		return ProviderHosts["github"]
+	}
+
+	return apiHost
+}
+
+// newGitHubClient returns a client for the given API host authenticating with token on top
+// of base. Hosts other than api.github.com are treated as GitHub Enterprise Server instances.
+func newGitHubClient(ctx context.Context, host, token string, base *http.Client) (*github.Client, error) {
+	httpClient := oauth2.NewClient(
+		context.WithValue(ctx, oauth2.HTTPClient, base),
+		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
+	)
+	httpClient.Timeout = base.Timeout
+
+	return newGitHubClientFor(host, httpClient)
+}
+
+// newRotatingGitHubClient returns a client for the given API host authenticating with the
+// tokens of source on top of base
+func newRotatingGitHubClient(host string, source *RotatingTokenSource, base *http.Client) (*github.Client, error) {
+	transport := base.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return newGitHubClientFor(host, &http.Client{Transport: source.Transport(transport), Timeout: base.Timeout})
+}
+
+// newGitHubClientFor returns a client for the given API host using httpClient
+func newGitHubClientFor(host string, httpClient *http.Client) (*github.Client, error) {
+	if host == GitHub {
+		return github.NewClient(httpClient), nil
+	}
+
+	return github.NewEnterpriseClient(
+		"https://"+host+"/api/v3/",
+		"https://"+host+"/api/uploads/",
+		httpClient,
+	)
+}
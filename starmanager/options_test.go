@@ -0,0 +1,90 @@
+package starmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOptionsHost(t *testing.T) {
+	defer os.Unsetenv(HostEnv)
+
+	testCases := []struct {
+		env      string
+		opts     []Option
+		expected string
+	}{
+		{expected: GitHub},
+		{env: "ghe.example.com", expected: "ghe.example.com"},
+		{env: "ghe.example.com", opts: []Option{WithHost("other.example.com")}, expected: "other.example.com"},
+		{opts: []Option{WithHost("https://ghe.example.com/api/v3/")}, expected: "ghe.example.com"},
+	}
+
+	for _, tc := range testCases {
+		os.Setenv(HostEnv, tc.env)
+		assert.Equal(t, tc.expected, newOptions(tc.opts...).host)
+	}
+}
+
+func TestNewOptionsCachePath(t *testing.T) {
+	defer os.Unsetenv(CacheEnv)
+
+	os.Setenv(CacheEnv, "")
+	assert.Equal(t, "", newOptions().cachePath)
+
+	os.Setenv(CacheEnv, "/tmp/stars.db")
+	assert.Equal(t, "/tmp/stars.db", newOptions().cachePath)
+	assert.Equal(t, "/srv/stars.db", newOptions(WithCachePath("/srv/stars.db")).cachePath)
+}
+
+func TestDefaultCachePath(t *testing.T) {
+	defer os.Setenv(XDGCacheEnv, os.Getenv(XDGCacheEnv))
+
+	testCases := []struct {
+		xdg      string
+		expected string
+	}{
+		{expected: filepath.Join("/home/user", ".cache", "stars.db")},
+		{xdg: "/var/cache/user", expected: filepath.Join("/var/cache/user", "stars.db")},
+		// Relative paths are invalid according to the specification and ignored
+		{xdg: "cache", expected: filepath.Join("/home/user", ".cache", "stars.db")},
+	}
+
+	for _, tc := range testCases {
+		os.Setenv(XDGCacheEnv, tc.xdg)
+		assert.Equal(t, tc.expected, defaultCachePath("/home/user"))
+	}
+
+	// Caches kept in the old location are used until there is one in XDG_CACHE_HOME
+	home, err := ioutil.TempDir("", "home")
+	assert.NoError(t, err)
+	defer os.RemoveAll(home)
+
+	xdg := filepath.Join(home, "xdg")
+	legacy := filepath.Join(home, CachePath, CacheFile)
+	os.Setenv(XDGCacheEnv, xdg)
+
+	assert.Equal(t, filepath.Join(xdg, CacheFile), defaultCachePath(home))
+
+	assert.NoError(t, os.MkdirAll(filepath.Dir(legacy), 0755))
+	assert.NoError(t, ioutil.WriteFile(legacy, nil, 0600))
+	assert.Equal(t, legacy, defaultCachePath(home))
+
+	assert.NoError(t, os.MkdirAll(xdg, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(xdg, CacheFile), nil, 0600))
+	assert.Equal(t, filepath.Join(xdg, CacheFile), defaultCachePath(home))
+}
+
+func TestNewGitHubClientEnterprise(t *testing.T) {
+	client, err := newGitHubClientFor(GitHub, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.github.com/", client.BaseURL.String())
+
+	client, err = newGitHubClientFor("ghe.example.com", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ghe.example.com/api/v3/", client.BaseURL.String())
+	assert.Equal(t, "https://ghe.example.com/api/uploads/", client.UploadURL.String())
+}
@@ -0,0 +1,112 @@
+package starmanager
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultConcurrency - the number of concurrent requests made when none is configured
+const DefaultConcurrency int = 4
+
+// concurrency returns the configured number of workers, or the default
+func (s *StarManager) concurrency() int {
+	if s.Concurrency > 0 {
+		return s.Concurrency
+	}
+
+	return DefaultConcurrency
+}
+
+// runPool calls fn for every job in [0, jobs) on at most workers goroutines and returns
+// once all started jobs are done. Jobs that have not started when the context is done are
+// skipped.
+func runPool(ctx context.Context, workers, jobs int, fn func(job int)) {
+	if workers > jobs {
+		workers = jobs
+	}
+
+	queue := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range queue {
+				fn(job)
+			}
+		}()
+	}
+
+dispatch:
+	for job := 0; job < jobs; job++ {
+		if ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case queue <- job:
+		}
+	}
+	close(queue)
+
+	wg.Wait()
+}
+
+// fetchEach calls fetch for every star on at most s.concurrency() workers sharing a throttle,
+// and returns the number of stars fetch reported as updated. Stars fetch fails for are logged
+// and skipped, except when it fails with a RateLimitError, i.e. the rate limit outlasted the
+// retries: the stars that have not started yet are skipped and the error is returned. what
+// describes what is fetched in log messages.
+func (s *StarManager) fetchEach(ctx context.Context, what string, stars []*Star, fetch func(ctx context.Context, t *throttle, star *Star) (bool, error)) (int, error) {
+	poolCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	t := &throttle{}
+	mu := sync.Mutex{}
+	updated := 0
+
+	var limited error
+
+	runPool(poolCtx, s.concurrency(), len(stars), func(job int) {
+		star := stars[job]
+		ok, err := fetch(poolCtx, t, star)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err == nil {
+			if ok {
+				updated++
+			}
+
+			return
+		}
+
+		if _, ok := err.(*RateLimitError); ok {
+			if limited == nil {
+				log.Printf("Stopped fetching %s: %v", what, err.Error())
+				limited = err
+				cancel()
+			}
+
+			return
+		}
+
+		if poolCtx.Err() == nil {
+			log.Printf("An error occurred while fetching %s for %s: %v", what, star.URL, err.Error())
+		}
+	})
+
+	if limited != nil {
+		return updated, limited
+	}
+
+	return updated, ctx.Err()
+}
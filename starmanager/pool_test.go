@@ -0,0 +1,55 @@
+package starmanager
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunPool(t *testing.T) {
+	testCases := []struct {
+		workers int
+		jobs    int
+	}{
+		{workers: 1, jobs: 10},
+		{workers: 4, jobs: 100},
+		{workers: 8, jobs: 3},
+		{workers: 2, jobs: 0},
+	}
+
+	for _, tc := range testCases {
+		mu := sync.Mutex{}
+		running, peak := 0, 0
+		done := make([]bool, tc.jobs)
+
+		runPool(context.Background(), tc.workers, tc.jobs, func(job int) {
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			done[job] = true
+			mu.Unlock()
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+		})
+
+		assert.True(t, peak <= tc.workers)
+		for _, d := range done {
+			assert.True(t, d)
+		}
+	}
+}
+
+func TestRunPoolCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ran := 0
+	runPool(ctx, 1, 10, func(job int) { ran++ })
+	assert.Equal(t, 0, ran)
+}
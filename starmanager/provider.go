@@ -0,0 +1,196 @@
+package starmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// Provider is a forge on which projects can be starred, such as GitHub, GitLab or a Gitea
+// instance like Codeberg
+type Provider interface {
+	// Name returns the web host of the provider (e.g. "github.com"), which is also stored
+	// on every star fetched from it
+	Name() string
+
+	// ListStars returns a page of the user's stars, numbered from 1. If etag is set the
+	// request is conditional and the returned page is marked NotModified if it has not
+	// changed.
+	ListStars(ctx context.Context, page int, etag string) (*StarPage, error)
+
+	// Star stars the given project
+	Star(ctx context.Context, owner, repo string) error
+
+	// Unstar unstars the given project
+	Unstar(ctx context.Context, owner, repo string) error
+
+	// Resolve returns the current web URL of the given project, following renames and
+	// transfers. ErrGone is returned if the project no longer exists.
+	Resolve(ctx context.Context, owner, repo string) (string, error)
+}
+
+// SequentialProvider is implemented by providers whose pages can only be fetched in order,
+// such as those paginating with cursors. If Sequential returns true, syncs fetch their pages
+// one after another and start over instead of resuming, as the pages saved before an
+// interruption cannot be skipped.
+type SequentialProvider interface {
+	Sequential() bool
+}
+
+// StarPage is a single page of stars returned by a Provider
+type StarPage struct {
+	Stars []*Star
+
+	// LastPage is the number of the last page, 0 if unknown
+	LastPage int
+
+	// NextPage is the number of the next page, 0 if this is the last page
+	NextPage int
+
+	// ETag identifies the contents of the page for conditional requests
+	ETag string
+
+	// NotModified is set if the page has not changed since the requested ETag, in which
+	// case Stars is empty
+	NotModified bool
+
+	// Rate is the rate limit status after fetching the page
+	Rate Rate
+}
+
+// HTTPError is returned by the REST providers for unsuccessful responses
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+// goneError returns ErrGone if err is a REST response saying the project does not exist
+func goneError(err error) error {
+	if e, ok := err.(*HTTPError); ok && (e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone) {
+		return ErrGone
+	}
+
+	return err
+}
+
+// Provider returns the configured provider with the given name, treating an empty name as
+// GitHub for stars cached before providers were introduced
+func (s *StarManager) Provider(name string) (Provider, error) {
+	if name == "" {
+		name = ProviderHosts["github"]
+	}
+
+	for _, p := range s.Providers {
+		if p.Name() == name {
+			return p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no provider configured for %s", name)
+}
+
+// restClient is a minimal JSON REST client shared by the non-GitHub providers
+type restClient struct {
+	base   string
+	header http.Header
+	http   *http.Client
+}
+
+// do performs a request against the API, decoding a JSON response body into out if it is
+// not nil. Non-2xx responses other than 304 are returned as errors, as a RateLimitError if
+// the request was rejected because of a rate limit.
+func (c *restClient) do(ctx context.Context, method, path string, etag string, out interface{}) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	client := c.http
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		err := &HTTPError{
+			Method:     method,
+			URL:        req.URL.String(),
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "" {
+			return resp, &RateLimitError{RetryAfter: retryAfter(resp), Err: err}
+		}
+
+		return resp, err
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp, err
+		}
+	}
+
+	return resp, nil
+}
+
+// linkPage matches a single entry of an RFC 5988 "Link" header
+var linkPage = regexp.MustCompile(`<([^>]+)>;\s*rel="(\w+)"`)
+
+// parseLinkPages returns the page numbers of the "next" and "last" relations of a "Link"
+// header, 0 where absent
+func parseLinkPages(link string) (int, int) {
+	next, last := 0, 0
+
+	for _, m := range linkPage.FindAllStringSubmatch(link, -1) {
+		u, err := url.Parse(m[1])
+		if err != nil {
+			continue
+		}
+
+		page, err := strconv.Atoi(u.Query().Get("page"))
+		if err != nil {
+			continue
+		}
+
+		switch m[2] {
+		case "next":
+			next = page
+		case "last":
+			last = page
+		}
+	}
+
+	return next, last
+}
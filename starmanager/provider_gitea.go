@@ -0,0 +1,132 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GiteaProvider provides stars from a Gitea or Forgejo instance such as codeberg.org
+type GiteaProvider struct {
+	Host   string
+	client *restClient
+}
+
+// NewGiteaProvider returns a provider for the Gitea instance on the given web host,
+// authenticating with an access token
+func NewGiteaProvider(host, token string, httpClient *http.Client) *GiteaProvider {
+	return &GiteaProvider{
+		Host: host,
+		client: &restClient{
+			base:   "https://" + host + "/api/v1/",
+			header: http.Header{"Authorization": []string{"token " + token}},
+			http:   httpClient,
+		},
+	}
+}
+
+// giteaRepo is the subset of a Gitea repository we cache
+type giteaRepo struct {
+	ID          int64     `json:"id"`
+	HTMLURL     string    `json:"html_url"`
+	Description string    `json:"description"`
+	Language    string    `json:"language"`
+	StarsCount  int       `json:"stars_count"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Archived    bool      `json:"archived"`
+	OpenIssues  int       `json:"open_issues_count"`
+	Topics      []string  `json:"topics"`
+	Fork        bool      `json:"fork"`
+
+	// Parent is the repository a fork was forked from
+	Parent *struct {
+		HTMLURL string `json:"html_url"`
+	} `json:"parent"`
+
+	// Licenses are the SPDX identifiers of the licenses Gitea detected, only reported by
+	// Gitea 1.22 and later
+	Licenses []string `json:"licenses"`
+}
+
+// Name returns the web host of the Gitea instance
+func (g *GiteaProvider) Name() string {
+	return g.Host
+}
+
+// ListStars fetches a single page of the user's starred repositories
+func (g *GiteaProvider) ListStars(ctx context.Context, page int, etag string) (*StarPage, error) {
+	repos := []giteaRepo{}
+	resp, err := g.client.do(
+		ctx,
+		"GET",
+		fmt.Sprintf("user/starred?limit=%d&page=%d", PageSize, page),
+		etag,
+		&repos,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		return &StarPage{NotModified: true, ETag: etag}, nil
+	}
+
+	starPage := &StarPage{ETag: resp.Header.Get("ETag"), Rate: rateFromHeaders(resp.Header)}
+	starPage.NextPage, starPage.LastPage = parseLinkPages(resp.Header.Get("Link"))
+
+	for _, r := range repos {
+		license := ""
+		if len(r.Licenses) > 0 {
+			license = r.Licenses[0]
+		}
+
+		parent := ""
+		if r.Parent != nil {
+			parent = r.Parent.HTMLURL
+		}
+
+		starPage.Stars = append(starPage.Stars, &Star{
+			Provider:    g.Name(),
+			URL:         r.HTMLURL,
+			Description: r.Description,
+			Language:    strings.ToLower(r.Language),
+			Stargazers:  r.StarsCount,
+			PushedAt:    r.UpdatedAt,
+			Archived:    r.Archived,
+			Topics:      r.Topics,
+			RepoID:      strconv.FormatInt(r.ID, 10),
+			License:     license,
+			OpenIssues:  r.OpenIssues,
+			Fork:        r.Fork,
+			Parent:      parent,
+		})
+	}
+
+	return starPage, nil
+}
+
+// Star stars the given repository
+func (g *GiteaProvider) Star(ctx context.Context, owner, repo string) error {
+	_, err := g.client.do(ctx, "PUT", "user/starred/"+owner+"/"+repo, "", nil)
+	return err
+}
+
+// Unstar unstars the given repository
+func (g *GiteaProvider) Unstar(ctx context.Context, owner, repo string) error {
+	_, err := g.client.do(ctx, "DELETE", "user/starred/"+owner+"/"+repo, "", nil)
+	return err
+}
+
+// Resolve returns the current URL of the given repository. Gitea redirects requests for
+// renamed and transferred repositories to their new location.
+func (g *GiteaProvider) Resolve(ctx context.Context, owner, repo string) (string, error) {
+	r := giteaRepo{}
+	if _, err := g.client.do(ctx, "GET", "repos/"+owner+"/"+repo, "", &r); err != nil {
+		return "", goneError(err)
+	}
+
+	return r.HTMLURL, nil
+}
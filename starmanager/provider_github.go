@@ -0,0 +1,131 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v25/github"
+)
+
+// GitHubProvider provides stars from github.com or a GitHub Enterprise Server instance
+type GitHubProvider struct {
+	// Host is the web host of the GitHub instance, e.g. github.com
+	Host     string
+	Client   *github.Client
+	Username string
+}
+
+// Name returns the web host of the GitHub instance
+func (g *GitHubProvider) Name() string {
+	return g.Host
+}
+
+// ListStars fetches a single page of the user's starred repositories, oldest first so that
+// pages stay stable as new stars are added.
+func (g *GitHubProvider) ListStars(ctx context.Context, page int, etag string) (*StarPage, error) {
+	u := "user/starred"
+	if g.Username != "" {
+		u = fmt.Sprintf("users/%v/starred", g.Username)
+	}
+	u = fmt.Sprintf("%s?sort=created&direction=asc&per_page=%d&page=%d", u, PageSize, page)
+
+	req, err := g.Client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", starredAccept)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	repos := []*github.StarredRepository{}
+	resp, err := g.Client.Do(ctx, req, &repos)
+	if resp != nil && resp.StatusCode == http.StatusNotModified {
+		return &StarPage{NotModified: true, ETag: etag, Rate: githubRate(resp)}, nil
+	}
+	if err != nil {
+		return nil, githubRateLimitError(err)
+	}
+
+	starPage := &StarPage{
+		LastPage: resp.LastPage,
+		NextPage: resp.NextPage,
+		ETag:     resp.Header.Get("ETag"),
+		Rate:     githubRate(resp),
+	}
+
+	for _, r := range repos {
+		starPage.Stars = append(starPage.Stars, g.star(r))
+	}
+
+	return starPage, nil
+}
+
+// githubRate returns the rate limit status of a GitHub response
+func githubRate(resp *github.Response) Rate {
+	return Rate{Remaining: resp.Rate.Remaining, Reset: resp.Rate.Reset.Time}
+}
+
+// star converts a starred repository returned by the API into a Star
+func (g *GitHubProvider) star(starred *github.StarredRepository) *Star {
+	repo := starred.Repository
+	lang, desc := "", ""
+
+	// We have to perform the below two checks because some repos don't have languages or
+	// desciptions, and the client does not create those struct fields, resulting in a SIGSEGV
+	// (segmentation fault).
+	if repo.Language != nil {
+		lang = *repo.Language
+	}
+
+	if repo.Description != nil {
+		desc = *repo.Description
+	}
+
+	return &Star{
+		Provider:    g.Name(),
+		PushedAt:    repo.PushedAt.Time,
+		StarredAt:   starred.GetStarredAt().Time,
+		URL:         *repo.HTMLURL,
+		Language:    strings.ToLower(lang),
+		Stargazers:  *repo.StargazersCount,
+		Description: desc,
+		Topics:      repo.Topics,
+		Archived:    *repo.Archived,
+		License:     repo.GetLicense().GetSPDXID(),
+		RepoID:      repo.GetNodeID(),
+		OpenIssues:  repo.GetOpenIssuesCount(),
+		Fork:        repo.GetFork(),
+		Parent:      repo.GetParent().GetHTMLURL(),
+	}
+}
+
+// Star stars the given repository
+func (g *GitHubProvider) Star(ctx context.Context, owner, repo string) error {
+	_, err := g.Client.Activity.Star(ctx, owner, repo)
+	return githubRateLimitError(err)
+}
+
+// Unstar unstars the given repository
+func (g *GitHubProvider) Unstar(ctx context.Context, owner, repo string) error {
+	_, err := g.Client.Activity.Unstar(ctx, owner, repo)
+	return githubRateLimitError(err)
+}
+
+// Resolve returns the current URL of the given repository. GitHub redirects requests for
+// renamed and transferred repositories to their new location.
+func (g *GitHubProvider) Resolve(ctx context.Context, owner, repo string) (string, error) {
+	repository, resp, err := g.Client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnavailableForLegalReasons) {
+			return "", ErrGone
+		}
+
+		return "", githubRateLimitError(err)
+	}
+
+	return repository.GetHTMLURL(), nil
+}
@@ -0,0 +1,228 @@
+package starmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v25/github"
+)
+
+// starredQuery fetches a page of the stars of owner along with everything we cache about
+// them, so that a single request replaces a REST page plus per-repository lookups. The
+// variables and owner are filled in by starredQueryFor.
+const starredQuery = `query($first: Int!, $after: String%s) {
+  owner: %s {
+    starredRepositories(first: $first, after: $after, orderBy: {field: STARRED_AT, direction: ASC}) {
+      pageInfo { endCursor hasNextPage }
+      edges {
+        starredAt
+        node {
+          id
+          url
+          description
+          pushedAt
+          isArchived
+          isFork
+          parent { url }
+          stargazerCount
+          primaryLanguage { name }
+          licenseInfo { spdxId }
+          issues(states: OPEN) { totalCount }
+          pullRequests(states: OPEN) { totalCount }
+          latestRelease { tagName publishedAt }
+          repositoryTopics(first: 20) { nodes { topic { name } } }
+        }
+      }
+    }
+  }
+}`
+
+// starredQueryFor returns starredQuery listing the stars of the given user, or of the
+// authenticated user if login is empty
+func starredQueryFor(login string) string {
+	if login == "" {
+		return fmt.Sprintf(starredQuery, "", "viewer")
+	}
+
+	return fmt.Sprintf(starredQuery, ", $login: String!", "user(login: $login)")
+}
+
+// graphqlStarred is the response to starredQuery
+type graphqlStarred struct {
+	Owner struct {
+		StarredRepositories struct {
+			PageInfo struct {
+				EndCursor   string `json:"endCursor"`
+				HasNextPage bool   `json:"hasNextPage"`
+			} `json:"pageInfo"`
+			Edges []struct {
+				StarredAt time.Time `json:"starredAt"`
+				Node      struct {
+					ID              string    `json:"id"`
+					URL             string    `json:"url"`
+					Description     string    `json:"description"`
+					PushedAt        time.Time `json:"pushedAt"`
+					IsArchived      bool      `json:"isArchived"`
+					IsFork          bool      `json:"isFork"`
+					StargazerCount  int       `json:"stargazerCount"`
+					PrimaryLanguage struct {
+						Name string `json:"name"`
+					} `json:"primaryLanguage"`
+					Parent struct {
+						URL string `json:"url"`
+					} `json:"parent"`
+					LicenseInfo struct {
+						SpdxID string `json:"spdxId"`
+					} `json:"licenseInfo"`
+					Issues struct {
+						TotalCount int `json:"totalCount"`
+					} `json:"issues"`
+					PullRequests struct {
+						TotalCount int `json:"totalCount"`
+					} `json:"pullRequests"`
+					LatestRelease struct {
+						TagName     string    `json:"tagName"`
+						PublishedAt time.Time `json:"publishedAt"`
+					} `json:"latestRelease"`
+					RepositoryTopics struct {
+						Nodes []struct {
+							Topic struct {
+								Name string `json:"name"`
+							} `json:"topic"`
+						} `json:"nodes"`
+					} `json:"repositoryTopics"`
+				} `json:"node"`
+			} `json:"edges"`
+		} `json:"starredRepositories"`
+	} `json:"owner"`
+}
+
+// graphqlResponse is the envelope of every GraphQL response
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// githubGraphQL runs a GraphQL query or mutation against the GitHub instance of the client
+// and decodes the data of the response into out
+func githubGraphQL(ctx context.Context, client *github.Client, query string, variables map[string]interface{}, out interface{}) (*github.Response, error) {
+	// The GraphQL endpoint lives next to the REST API root, both on github.com
+	// (/graphql) and on GitHub Enterprise Server (/api/graphql)
+	req, err := client.NewRequest("POST", "../graphql", map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &graphqlResponse{}
+	response, err := client.Do(ctx, req, resp)
+	if err != nil {
+		return response, githubRateLimitError(err)
+	}
+
+	if len(resp.Errors) > 0 {
+		messages := []string{}
+		for _, e := range resp.Errors {
+			messages = append(messages, e.Message)
+		}
+
+		return response, fmt.Errorf("graphql: %s", strings.Join(messages, "; "))
+	}
+
+	return response, json.Unmarshal(resp.Data, out)
+}
+
+// GitHubGraphQLProvider provides stars from GitHub using the GraphQL API, which needs a
+// single request per page of stars. GraphQL pagination is cursor based, so pages have to
+// be requested in order, see Sequential; starring and unstarring go through the REST API.
+type GitHubGraphQLProvider struct {
+	*GitHubProvider
+
+	mu      sync.Mutex
+	cursors map[int]string
+}
+
+// NewGitHubGraphQLProvider wraps a REST GitHub provider to list stars through GraphQL
+func NewGitHubGraphQLProvider(rest *GitHubProvider) *GitHubGraphQLProvider {
+	return &GitHubGraphQLProvider{GitHubProvider: rest, cursors: map[int]string{1: ""}}
+}
+
+// Sequential reports that pages have to be fetched in order, as the cursor of a page is
+// only known once the page before it has been fetched
+func (g *GitHubGraphQLProvider) Sequential() bool {
+	return true
+}
+
+// ListStars fetches a single page of the stars of Username, or of the authenticated user if
+// it is empty, like the REST provider. GraphQL has no conditional requests, so etag is
+// ignored and no page is ever reported unchanged.
+func (g *GitHubGraphQLProvider) ListStars(ctx context.Context, page int, etag string) (*StarPage, error) {
+	g.mu.Lock()
+	cursor, ok := g.cursors[page]
+	g.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("page %d requested before page %d", page, page-1)
+	}
+
+	variables := map[string]interface{}{"first": PageSize}
+	if cursor != "" {
+		variables["after"] = cursor
+	}
+	if g.Username != "" {
+		variables["login"] = g.Username
+	}
+
+	resp := &graphqlStarred{}
+	response, err := githubGraphQL(ctx, g.Client, starredQueryFor(g.Username), variables, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	starred := resp.Owner.StarredRepositories
+	starPage := &StarPage{Rate: githubRate(response)}
+	if starred.PageInfo.HasNextPage {
+		starPage.NextPage = page + 1
+
+		g.mu.Lock()
+		g.cursors[page+1] = starred.PageInfo.EndCursor
+		g.mu.Unlock()
+	}
+
+	for _, edge := range starred.Edges {
+		topics := []string{}
+		for _, t := range edge.Node.RepositoryTopics.Nodes {
+			topics = append(topics, t.Topic.Name)
+		}
+
+		starPage.Stars = append(starPage.Stars, &Star{
+			Provider:    g.Name(),
+			URL:         edge.Node.URL,
+			Description: edge.Node.Description,
+			PushedAt:    edge.Node.PushedAt,
+			StarredAt:   edge.StarredAt,
+			Archived:    edge.Node.IsArchived,
+			Stargazers:  edge.Node.StargazerCount,
+			Language:    strings.ToLower(edge.Node.PrimaryLanguage.Name),
+			License:     edge.Node.LicenseInfo.SpdxID,
+			Topics:      topics,
+			RepoID:      edge.Node.ID,
+
+			// Counted like the REST API, which includes pull requests in open issues
+			OpenIssues:    edge.Node.Issues.TotalCount + edge.Node.PullRequests.TotalCount,
+			LatestRelease: edge.Node.LatestRelease.TagName,
+			ReleasedAt:    edge.Node.LatestRelease.PublishedAt,
+			Fork:          edge.Node.IsFork,
+			Parent:        edge.Node.Parent.URL,
+		})
+	}
+
+	return starPage, nil
+}
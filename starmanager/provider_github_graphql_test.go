@@ -0,0 +1,143 @@
+package starmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// graphqlPage renders a page of the starred repositories GraphQL response
+func graphqlPage(name string, next string) string {
+	return fmt.Sprintf(`{"data": {"owner": {"starredRepositories": {
+		"pageInfo": {"endCursor": %q, "hasNextPage": %t},
+		"edges": [{
+			"starredAt": "2020-02-01T00:00:00Z",
+			"node": {
+				"url": "https://github.com/%s",
+				"description": "A project",
+				"pushedAt": "2020-03-01T00:00:00Z",
+				"isArchived": false,
+				"stargazerCount": 7,
+				"primaryLanguage": {"name": "Rust"},
+				"licenseInfo": {"spdxId": "MIT"},
+				"repositoryTopics": {"nodes": [{"topic": {"name": "cli"}}]}
+			}
+		}]
+	}}}}`, next, next != "", name)
+}
+
+func TestGitHubGraphQLProviderSync(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/graphql", r.URL.Path)
+
+		body := struct {
+			Variables map[string]interface{} `json:"variables"`
+		}{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		switch body.Variables["after"] {
+		case nil:
+			fmt.Fprint(w, graphqlPage("a/one", "cursor1"))
+		case "cursor1":
+			fmt.Fprint(w, graphqlPage("a/two", ""))
+		default:
+			fmt.Fprint(w, `{"errors": [{"message": "bad cursor"}]}`)
+		}
+	}))
+	defer closeGitHub()
+
+	sm.Providers = []Provider{NewGitHubGraphQLProvider(&GitHubProvider{Host: "github.com", Client: client})}
+
+	result, err := sm.Sync(context.Background(), SyncOptions{})
+	assert.NoError(t, err)
+	assert.True(t, result.Succeeded())
+	assert.Equal(t, 2, result.Added)
+
+	star := Star{}
+	assert.NoError(t, sm.DB.One("URL", "https://github.com/a/two", &star))
+	assert.Equal(t, "rust", star.Language)
+	assert.Equal(t, "MIT", star.License)
+	assert.Equal(t, []string{"cli"}, star.Topics)
+	assert.Equal(t, 2020, star.StarredAt.Year())
+
+	// Pages can only be requested in order
+	_, err = sm.Providers[0].ListStars(context.Background(), 5, "")
+	assert.Error(t, err)
+}
+
+func TestGitHubGraphQLProviderResume(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	failing := true
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Variables map[string]interface{} `json:"variables"`
+		}{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		switch body.Variables["after"] {
+		case nil:
+			fmt.Fprint(w, graphqlPage("a/one", "cursor1"))
+		case "cursor1":
+			if failing {
+				fmt.Fprint(w, `{"errors": [{"message": "something went wrong"}]}`)
+				return
+			}
+			fmt.Fprint(w, graphqlPage("a/two", "cursor2"))
+		case "cursor2":
+			fmt.Fprint(w, graphqlPage("a/three", ""))
+		}
+	}))
+	defer closeGitHub()
+
+	sm.Providers = []Provider{NewGitHubGraphQLProvider(&GitHubProvider{Host: "github.com", Client: client})}
+
+	result, err := sm.Sync(context.Background(), SyncOptions{})
+	assert.NoError(t, err)
+	assert.False(t, result.Succeeded())
+	assert.Equal(t, 1, result.Added)
+
+	meta, err := sm.LastSync("github.com")
+	assert.NoError(t, err)
+	assert.True(t, meta.Interrupted())
+
+	// Resuming starts over, following the cursors from the first page
+	failing = false
+	result, err = sm.Sync(context.Background(), SyncOptions{Resume: true})
+	assert.NoError(t, err)
+	assert.True(t, result.Succeeded())
+	assert.Equal(t, 0, result.Resumed)
+	assert.Equal(t, 2, result.Added)
+
+	meta, err = sm.LastSync("github.com")
+	assert.NoError(t, err)
+	assert.False(t, meta.Interrupted())
+}
+
+func TestGitHubGraphQLProviderUsername(t *testing.T) {
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Contains(t, body.Query, "user(login: $login)")
+		assert.Equal(t, "someone", body.Variables["login"])
+
+		fmt.Fprint(w, graphqlPage("a/one", ""))
+	}))
+	defer closeGitHub()
+
+	provider := NewGitHubGraphQLProvider(&GitHubProvider{Host: "github.com", Username: "someone", Client: client})
+	page, err := provider.ListStars(context.Background(), 1, "")
+	assert.NoError(t, err)
+	assert.Len(t, page.Stars, 1)
+}
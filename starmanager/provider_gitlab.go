@@ -0,0 +1,168 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitLabProvider provides stars from gitlab.com or a self-hosted GitLab instance
+type GitLabProvider struct {
+	Host   string
+	client *restClient
+
+	userOnce sync.Once
+	userID   int
+	userErr  error
+}
+
+// NewGitLabProvider returns a provider for the GitLab instance on the given web host,
+// authenticating with a personal access token
+func NewGitLabProvider(host, token string, httpClient *http.Client) *GitLabProvider {
+	return &GitLabProvider{
+		Host: host,
+		client: &restClient{
+			base:   "https://" + host + "/api/v4/",
+			header: http.Header{"Private-Token": []string{token}},
+			http:   httpClient,
+		},
+	}
+}
+
+// gitlabProject is the subset of a GitLab project we cache
+type gitlabProject struct {
+	ID             int       `json:"id"`
+	WebURL         string    `json:"web_url"`
+	Description    string    `json:"description"`
+	StarCount      int       `json:"star_count"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+	Archived       bool      `json:"archived"`
+	OpenIssues     int       `json:"open_issues_count"`
+	Topics         []string  `json:"topics"`
+	TagList        []string  `json:"tag_list"`
+
+	// ForkedFromProject is only set for forks
+	ForkedFromProject *struct {
+		WebURL string `json:"web_url"`
+	} `json:"forked_from_project"`
+}
+
+// Name returns the web host of the GitLab instance
+func (g *GitLabProvider) Name() string {
+	return g.Host
+}
+
+// user returns the id of the authenticated user, which the starred projects API requires
+func (g *GitLabProvider) user(ctx context.Context) (int, error) {
+	g.userOnce.Do(func() {
+		user := struct {
+			ID int `json:"id"`
+		}{}
+
+		_, g.userErr = g.client.do(ctx, "GET", "user", "", &user)
+		g.userID = user.ID
+	})
+
+	return g.userID, g.userErr
+}
+
+// ListStars fetches a single page of the user's starred projects
+func (g *GitLabProvider) ListStars(ctx context.Context, page int, etag string) (*StarPage, error) {
+	id, err := g.user(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []gitlabProject{}
+	resp, err := g.client.do(
+		ctx,
+		"GET",
+		fmt.Sprintf("users/%d/starred_projects?order_by=created_at&sort=asc&per_page=%d&page=%d", id, PageSize, page),
+		etag,
+		&projects,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		return &StarPage{NotModified: true, ETag: etag}, nil
+	}
+
+	starPage := &StarPage{ETag: resp.Header.Get("ETag"), Rate: rateFromHeaders(resp.Header)}
+	starPage.NextPage, _ = strconv.Atoi(resp.Header.Get("X-Next-Page"))
+	starPage.LastPage, _ = strconv.Atoi(resp.Header.Get("X-Total-Pages"))
+
+	for _, p := range projects {
+		topics := p.Topics
+		if len(topics) == 0 {
+			topics = p.TagList
+		}
+
+		parent := ""
+		if p.ForkedFromProject != nil {
+			parent = p.ForkedFromProject.WebURL
+		}
+
+		starPage.Stars = append(starPage.Stars, &Star{
+			Provider:    g.Name(),
+			URL:         p.WebURL,
+			Description: p.Description,
+			Stargazers:  p.StarCount,
+			PushedAt:    p.LastActivityAt,
+			Archived:    p.Archived,
+			Topics:      topics,
+			RepoID:      strconv.Itoa(p.ID),
+			OpenIssues:  p.OpenIssues,
+			Fork:        p.ForkedFromProject != nil,
+			Parent:      parent,
+		})
+	}
+
+	return starPage, nil
+}
+
+// projectPath returns the URL-encoded project path used to address a project in the API
+func (g *GitLabProvider) projectPath(owner, repo string) string {
+	return url.PathEscape(strings.Join([]string{owner, repo}, "/"))
+}
+
+// Star stars the given project
+func (g *GitLabProvider) Star(ctx context.Context, owner, repo string) error {
+	resp, err := g.client.do(ctx, "POST", "projects/"+g.projectPath(owner, repo)+"/star", "", nil)
+
+	// GitLab answers 304 if the project is already starred
+	if resp != nil && resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	return err
+}
+
+// Unstar unstars the given project
+func (g *GitLabProvider) Unstar(ctx context.Context, owner, repo string) error {
+	resp, err := g.client.do(ctx, "POST", "projects/"+g.projectPath(owner, repo)+"/unstar", "", nil)
+
+	// GitLab answers 304 if the project is not starred
+	if resp != nil && resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	return err
+}
+
+// Resolve returns the current URL of the given project. GitLab redirects requests for
+// renamed and transferred projects to their new location.
+func (g *GitLabProvider) Resolve(ctx context.Context, owner, repo string) (string, error) {
+	project := gitlabProject{}
+	if _, err := g.client.do(ctx, "GET", "projects/"+g.projectPath(owner, repo), "", &project); err != nil {
+		return "", goneError(err)
+	}
+
+	return project.WebURL, nil
+}
@@ -0,0 +1,179 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLinkPages(t *testing.T) {
+	testCases := []struct {
+		link string
+		next int
+		last int
+	}{
+		{link: "", next: 0, last: 0},
+		{
+			link: `<https://codeberg.org/api/v1/user/starred?page=2>; rel="next", <https://codeberg.org/api/v1/user/starred?page=5>; rel="last"`,
+			next: 2,
+			last: 5,
+		},
+		{link: `<https://codeberg.org/api/v1/user/starred?page=1>; rel="first"`, next: 0, last: 0},
+	}
+
+	for _, tc := range testCases {
+		next, last := parseLinkPages(tc.link)
+		assert.Equal(t, tc.next, next, tc.link)
+		assert.Equal(t, tc.last, last, tc.link)
+	}
+}
+
+func TestGitLabProvider(t *testing.T) {
+	requests := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		assert.Equal(t, "secret", r.Header.Get("Private-Token"))
+
+		switch r.URL.Path {
+		case "/user":
+			fmt.Fprint(w, `{"id": 42}`)
+		case "/users/42/starred_projects":
+			w.Header().Set("X-Next-Page", "2")
+			w.Header().Set("X-Total-Pages", "3")
+			fmt.Fprint(w, `[{
+				"web_url": "https://gitlab.com/gitlab-org/cli",
+				"description": "GitLab CLI",
+				"star_count": 500,
+				"last_activity_at": "2020-01-01T00:00:00Z",
+				"archived": false,
+				"tag_list": ["cli"]
+			}]`)
+		default:
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+	defer srv.Close()
+
+	provider := NewGitLabProvider("gitlab.com", "secret", nil)
+	provider.client.base = srv.URL + "/"
+
+	page, err := provider.ListStars(context.Background(), 1, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, page.NextPage)
+	assert.Equal(t, 3, page.LastPage)
+	assert.Len(t, page.Stars, 1)
+	assert.Equal(t, "gitlab.com", page.Stars[0].Provider)
+	assert.Equal(t, "https://gitlab.com/gitlab-org/cli", page.Stars[0].URL)
+	assert.Equal(t, 500, page.Stars[0].Stargazers)
+	assert.Equal(t, []string{"cli"}, page.Stars[0].Topics)
+
+	// Already unstarred projects are answered with 304, which is not an error
+	assert.NoError(t, provider.Unstar(context.Background(), "gitlab-org", "cli"))
+	assert.Contains(t, requests, "POST /projects/gitlab-org%2Fcli/unstar")
+}
+
+func TestGiteaProvider(t *testing.T) {
+	requests := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		assert.Equal(t, "token secret", r.Header.Get("Authorization"))
+
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/user/starred":
+			w.Header().Set("ETag", `"abc"`)
+			fmt.Fprint(w, `[{
+				"html_url": "https://codeberg.org/forgejo/forgejo",
+				"description": "Beyond coding",
+				"language": "Go",
+				"stars_count": 1000,
+				"updated_at": "2020-01-01T00:00:00Z",
+				"archived": false,
+				"topics": ["forge"],
+				"licenses": ["GPL-3.0-or-later"]
+			}]`)
+		case "/repos/old/name":
+			fmt.Fprint(w, `{"html_url": "https://codeberg.org/new/name"}`)
+		case "/repos/deleted/repo":
+			http.Error(w, `{"message": "not found"}`, http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	provider := NewGiteaProvider("codeberg.org", "secret", nil)
+	provider.client.base = srv.URL + "/"
+
+	page, err := provider.ListStars(context.Background(), 1, "")
+	assert.NoError(t, err)
+	assert.Equal(t, `"abc"`, page.ETag)
+	assert.Equal(t, 0, page.NextPage)
+	assert.Len(t, page.Stars, 1)
+	assert.Equal(t, "codeberg.org", page.Stars[0].Provider)
+	assert.Equal(t, "go", page.Stars[0].Language)
+	assert.Equal(t, "GPL-3.0-or-later", page.Stars[0].License)
+
+	page, err = provider.ListStars(context.Background(), 1, `"abc"`)
+	assert.NoError(t, err)
+	assert.True(t, page.NotModified)
+
+	assert.NoError(t, provider.Star(context.Background(), "forgejo", "forgejo"))
+	assert.NoError(t, provider.Unstar(context.Background(), "forgejo", "forgejo"))
+	assert.Contains(t, requests, "PUT /user/starred/forgejo/forgejo")
+	assert.Contains(t, requests, "DELETE /user/starred/forgejo/forgejo")
+
+	url, err := provider.Resolve(context.Background(), "old", "name")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://codeberg.org/new/name", url)
+
+	_, err = provider.Resolve(context.Background(), "deleted", "repo")
+	assert.Equal(t, ErrGone, err)
+}
+
+func TestSyncMultipleProviders(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	defer serveTestStars(t, sm, 1, map[string]string{"1": starredPage("a/one")})()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"html_url": "https://codeberg.org/b/two", "stars_count": 3}]`)
+	}))
+	defer srv.Close()
+
+	gitea := NewGiteaProvider("codeberg.org", "secret", nil)
+	gitea.client.base = srv.URL + "/"
+	sm.Providers = append(sm.Providers, gitea)
+
+	// Stars of providers that are not configured are never pruned
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://gitlab.com/c/three", Provider: "gitlab.com"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://codeberg.org/b/gone", Provider: "codeberg.org"}))
+
+	result, err := sm.Sync(context.Background(), SyncOptions{Prune: true})
+	assert.NoError(t, err)
+	assert.True(t, result.Succeeded())
+	assert.Equal(t, 2, result.Added)
+	assert.Equal(t, 1, result.Removed)
+
+	stars := []Star{}
+	assert.NoError(t, sm.DB.All(&stars))
+
+	urls := []string{}
+	for _, star := range stars {
+		urls = append(urls, star.URL)
+	}
+	assert.ElementsMatch(t, []string{
+		"https://github.com/a/one",
+		"https://codeberg.org/b/two",
+		"https://gitlab.com/c/three",
+	}, urls)
+}
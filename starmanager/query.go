@@ -0,0 +1,311 @@
+package starmanager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/q"
+	"github.com/gkze/stars/utils"
+)
+
+// queryDateLayout is the layout of dates in queries
+const queryDateLayout string = "2006-01-02"
+
+// Query is a parsed star query in a syntax similar to GitHub's search, for example
+//
+//	language:go,rust topic:cli -topic:deprecated stars:>500 pushed:>2023-01-01
+//
+// The qualifiers are listed in the README. Comma separated values match any of them,
+// repeated qualifiers all have to match and a leading "-" excludes matches. Topics match
+// through their aliases, see TopicAliases. Words without a qualifier match the
+// description, URL and local notes.
+type Query struct {
+	// matchers are evaluated by storm
+	matchers []q.Matcher
+
+	// filters are evaluated in memory for what storm cannot match, such as topics and
+	// local tags
+	filters []func(star *Star, annotation *Annotation) bool
+}
+
+// ParseQuery parses a star query, matching topics through DefaultTopicAliases
+func ParseQuery(query string) (*Query, error) {
+	return parseQuery(query, DefaultTopicAliases)
+}
+
+// parseQuery parses a star query, matching topics through the given aliases
+func parseQuery(query string, aliases TopicAliases) (*Query, error) {
+	parsed := &Query{}
+
+	for _, part := range strings.Fields(query) {
+		negate := false
+		if strings.HasPrefix(part, "-") && strings.IndexByte(part, ':') > 1 {
+			negate, part = true, part[1:]
+		}
+
+		qualifier, value := "", part
+		if i := strings.IndexByte(part, ':'); i > 0 {
+			qualifier, value = strings.ToLower(part[:i]), part[i+1:]
+		}
+
+		if qualifier != "" && value == "" {
+			return nil, fmt.Errorf("missing value for %s", qualifier)
+		}
+
+		matcher, filter, err := parseQualifier(qualifier, value, aliases)
+		if err != nil {
+			return nil, err
+		}
+
+		if matcher != nil {
+			if negate {
+				matcher = q.Not(matcher)
+			}
+
+			parsed.matchers = append(parsed.matchers, matcher)
+		}
+
+		if filter != nil {
+			if negate {
+				include := filter
+				filter = func(star *Star, annotation *Annotation) bool { return !include(star, annotation) }
+			}
+
+			parsed.filters = append(parsed.filters, filter)
+		}
+	}
+
+	return parsed, nil
+}
+
+// parseQualifier returns the matcher or in-memory filter for a single qualifier
+func parseQualifier(qualifier, value string, aliases TopicAliases) (q.Matcher, func(star *Star, annotation *Annotation) bool, error) {
+	values := strings.Split(value, ",")
+
+	switch qualifier {
+	case "":
+		text := strings.ToLower(value)
+		return nil, func(star *Star, annotation *Annotation) bool {
+			return strings.Contains(strings.ToLower(star.Description), text) ||
+				strings.Contains(strings.ToLower(star.URL), text) ||
+				(annotation != nil && strings.Contains(strings.ToLower(annotation.Notes), text))
+		}, nil
+	case "language", "lang":
+		return languageMatcher(values), nil, nil
+	case "topic":
+		topics := aliases.CanonicalTopics(values)
+		return nil, func(star *Star, annotation *Annotation) bool { return hasTopics(star, topics, MatchAny, aliases) }, nil
+	case "list":
+		return nil, func(star *Star, annotation *Annotation) bool { return onList(star, values) }, nil
+	case "tag":
+		return nil, func(star *Star, annotation *Annotation) bool { return hasTags(annotation, values) }, nil
+	case "license":
+		return nil, func(star *Star, annotation *Annotation) bool { return hasLicense(star, values) }, nil
+	case "owner", "user", "org":
+		return nil, func(star *Star, annotation *Annotation) bool { return ownedBy(star, values) }, nil
+	case "provider":
+		matchers := make([]q.Matcher, 0, len(values))
+		for _, v := range values {
+			matchers = append(matchers, providerMatcher(v))
+		}
+
+		return q.Or(matchers...), nil, nil
+	case "archived", "gone":
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %s value %q", qualifier, value)
+		}
+
+		field := "Archived"
+		if qualifier == "gone" {
+			field = "Gone"
+		}
+
+		return q.Eq(field, flag), nil, nil
+	case "stars":
+		m, err := rangeMatcher("Stargazers", value, func(v string) (interface{}, error) {
+			return strconv.Atoi(v)
+		})
+
+		return m, nil, err
+	case "pushed", "starred":
+		field := "PushedAt"
+		if qualifier == "starred" {
+			field = "StarredAt"
+		}
+
+		// A plain date matches the whole day
+		if day, err := time.Parse(queryDateLayout, value); err == nil {
+			return q.And(q.Gte(field, day), q.Lt(field, day.AddDate(0, 0, 1))), nil, nil
+		}
+
+		m, err := rangeMatcher(field, value, func(v string) (interface{}, error) {
+			return time.Parse(queryDateLayout, v)
+		})
+
+		return m, nil, err
+	}
+
+	return nil, nil, fmt.Errorf("unknown qualifier %q", qualifier)
+}
+
+// languageMatcher matches stars written in any of the given languages
+func languageMatcher(languages []string) q.Matcher {
+	lower := make([]string, len(languages))
+	for i, l := range languages {
+		lower[i] = strings.ToLower(l)
+	}
+
+	return q.In("Language", lower)
+}
+
+// hasLicense reports whether a star is under any of the given SPDX license identifiers,
+// case insensitively
+func hasLicense(star *Star, licenses []string) bool {
+	for _, license := range licenses {
+		if strings.EqualFold(license, star.License) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ownedBy reports whether a star's repository belongs to any of the given users or
+// organizations, case insensitively
+func ownedBy(star *Star, owners []string) bool {
+	owner, _, err := star.OwnerRepo()
+	if err != nil {
+		return false
+	}
+
+	for _, o := range owners {
+		if strings.EqualFold(o, owner) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// onList reports whether a star is on any of the given lists, case insensitively
+func onList(star *Star, lists []string) bool {
+	for _, list := range lists {
+		for _, name := range star.Lists {
+			if strings.EqualFold(list, name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// hasTags reports whether an annotation, which may be nil, has any of the given tags
+func hasTags(annotation *Annotation, tags []string) bool {
+	if annotation == nil {
+		return false
+	}
+
+	for _, tag := range tags {
+		if utils.StringInSlice(tag, annotation.Tags) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasTopics reports whether a star has any or all of the given canonical topics, comparing
+// them with the canonical topics of the star
+func hasTopics(star *Star, topics []string, mode MatchMode, aliases TopicAliases) bool {
+	starTopics := aliases.CanonicalTopics(star.Topics)
+
+	for _, topic := range topics {
+		found := utils.StringInSlice(topic, starTopics)
+
+		if found && mode != MatchAll {
+			return true
+		}
+
+		if !found && mode == MatchAll {
+			return false
+		}
+	}
+
+	return mode == MatchAll
+}
+
+// rangeMatcher builds a matcher for a comparison (>500, <=2023-01-01), range (10..100) or
+// exact value, parsing the operands with the given function
+func rangeMatcher(field, value string, parse func(string) (interface{}, error)) (q.Matcher, error) {
+	operand := func(v string) (interface{}, error) {
+		parsed, err := parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s", v, field)
+		}
+
+		return parsed, nil
+	}
+
+	if parts := strings.SplitN(value, "..", 2); len(parts) == 2 {
+		from, err := operand(parts[0])
+		if err != nil {
+			return nil, err
+		}
+
+		to, err := operand(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		return q.And(q.Gte(field, from), q.Lte(field, to)), nil
+	}
+
+	comparisons := []struct {
+		op      string
+		matcher func(string, interface{}) q.Matcher
+	}{
+		{">=", q.Gte},
+		{"<=", q.Lte},
+		{">", q.Gt},
+		{"<", q.Lt},
+	}
+
+	for _, c := range comparisons {
+		if strings.HasPrefix(value, c.op) {
+			v, err := operand(value[len(c.op):])
+			if err != nil {
+				return nil, err
+			}
+
+			return c.matcher(field, v), nil
+		}
+	}
+
+	v, err := operand(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.Eq(field, v), nil
+}
+
+// Matchers returns the part of the query storm can evaluate
+func (qy *Query) Matchers() []q.Matcher {
+	return qy.matchers
+}
+
+// Match reports whether a star with the given annotation, which may be nil, satisfies the
+// part of the query evaluated in memory
+func (qy *Query) Match(star *Star, annotation *Annotation) bool {
+	for _, filter := range qy.filters {
+		if !filter(star, annotation) {
+			return false
+		}
+	}
+
+	return true
+}
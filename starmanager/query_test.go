@@ -0,0 +1,133 @@
+package starmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProjectsQuery(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	day := func(s string) time.Time {
+		d, err := time.Parse(queryDateLayout, s)
+		assert.NoError(t, err)
+		return d.Add(12 * time.Hour)
+	}
+
+	stars := []Star{
+		{URL: "https://github.com/a/cli", Language: "go", Topics: []string{"cli"}, Stargazers: 1000, PushedAt: day("2023-06-01"), License: "MIT"},
+		{URL: "https://github.com/a/small-cli", Language: "go", Topics: []string{"cli"}, Stargazers: 10, PushedAt: day("2022-06-01")},
+		{URL: "https://github.com/a/web", Language: "go", Stargazers: 600, PushedAt: day("2023-02-01"), Archived: true, Description: "Web framework"},
+		{URL: "https://gitlab.com/b/tool", Provider: "gitlab.com", Language: "rust", Topics: []string{"cli"}, Stargazers: 700, PushedAt: day("2021-01-01")},
+	}
+	for i := range stars {
+		assert.NoError(t, sm.DB.Save(&stars[i]))
+	}
+
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{query: "language:go topic:cli stars:>500 pushed:>2023-01-01 archived:false", expected: []string{"https://github.com/a/cli"}},
+		{query: "topic:cli", expected: []string{"https://github.com/a/cli", "https://gitlab.com/b/tool", "https://github.com/a/small-cli"}},
+		{query: "stars:10..650", expected: []string{"https://github.com/a/web", "https://github.com/a/small-cli"}},
+		{query: "stars:<=10", expected: []string{"https://github.com/a/small-cli"}},
+		{query: "pushed:2023-02-01", expected: []string{"https://github.com/a/web"}},
+		{query: "provider:gitlab", expected: []string{"https://gitlab.com/b/tool"}},
+		{query: "license:mit", expected: []string{"https://github.com/a/cli"}},
+		{query: "owner:B", expected: []string{"https://gitlab.com/b/tool"}},
+		{query: "org:a,b stars:>650", expected: []string{"https://github.com/a/cli", "https://gitlab.com/b/tool"}},
+		{query: "-user:a", expected: []string{"https://gitlab.com/b/tool"}},
+		{query: "Language:Go framework", expected: []string{"https://github.com/a/web"}},
+		{query: "archived:true topic:cli", expected: []string{}},
+		{query: "language:go,rust topic:cli -stars:<100", expected: []string{"https://github.com/a/cli", "https://gitlab.com/b/tool"}},
+		{query: "-language:go", expected: []string{"https://gitlab.com/b/tool"}},
+		{query: "topic:cli -provider:gitlab -archived:true", expected: []string{"https://github.com/a/cli", "https://github.com/a/small-cli"}},
+	}
+
+	for _, tc := range testCases {
+		projects, err := sm.findProjects(context.Background(), ProjectOptions{Query: tc.query})
+		assert.NoError(t, err, tc.query)
+
+		urls := []string{}
+		for _, p := range projects {
+			urls = append(urls, p.URL)
+		}
+		assert.Equal(t, tc.expected, urls, tc.query)
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	testCases := map[string]string{
+		"colour:red":        `unknown qualifier "colour"`,
+		"language:":         "missing value for language",
+		"archived:maybe":    `invalid archived value "maybe"`,
+		"stars:>many":       `invalid value "many" for Stargazers`,
+		"pushed:>yesterday": `invalid value "yesterday" for PushedAt`,
+	}
+
+	for query, expected := range testCases {
+		_, err := ParseQuery(query)
+		assert.EqualError(t, err, expected)
+	}
+}
+
+func TestGetProjectsMultipleFilters(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	stars := []Star{
+		{URL: "https://github.com/a/go-cli", Language: "go", Topics: []string{"cli", "tui"}, Stargazers: 4, License: "GPL-3.0"},
+		{URL: "https://github.com/a/go-old", Language: "go", Topics: []string{"cli", "deprecated"}, Stargazers: 3, License: "MIT"},
+		{URL: "https://github.com/a/rust-cli", Language: "rust", Topics: []string{"cli"}, Stargazers: 2},
+		{URL: "https://github.com/a/js-cli", Language: "javascript", Topics: []string{"tui"}, Stargazers: 1},
+	}
+	for i := range stars {
+		assert.NoError(t, sm.DB.Save(&stars[i]))
+	}
+
+	testCases := []struct {
+		opts     ProjectOptions
+		expected []string
+	}{
+		{
+			opts:     ProjectOptions{Languages: []string{"Go", "rust"}, Topics: []string{"cli"}, ExcludeTopics: []string{"deprecated"}},
+			expected: []string{"https://github.com/a/go-cli", "https://github.com/a/rust-cli"},
+		},
+		{
+			opts:     ProjectOptions{Topics: []string{"cli", "tui"}},
+			expected: []string{"https://github.com/a/go-cli", "https://github.com/a/go-old", "https://github.com/a/rust-cli", "https://github.com/a/js-cli"},
+		},
+		{
+			opts:     ProjectOptions{Topics: []string{"cli", "tui"}, TopicMatch: MatchAll},
+			expected: []string{"https://github.com/a/go-cli"},
+		},
+		{
+			opts:     ProjectOptions{Language: "go", ExcludeLanguages: []string{"go"}},
+			expected: []string{},
+		},
+		{
+			opts:     ProjectOptions{ExcludeLanguages: []string{"go", "javascript"}},
+			expected: []string{"https://github.com/a/rust-cli"},
+		},
+		{
+			opts:     ProjectOptions{Licenses: []string{"gpl-3.0", "Apache-2.0"}},
+			expected: []string{"https://github.com/a/go-cli"},
+		},
+	}
+
+	for _, tc := range testCases {
+		projects, err := sm.findProjects(context.Background(), tc.opts)
+		assert.NoError(t, err)
+
+		urls := []string{}
+		for _, p := range projects {
+			urls = append(urls, p.URL)
+		}
+		assert.Equal(t, tc.expected, urls)
+	}
+}
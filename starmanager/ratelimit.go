@@ -0,0 +1,252 @@
+package starmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v25/github"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxRetries - how many times a failed page request is retried
+	MaxRetries int = 5
+
+	// MinRateRemaining - requests are paused until the rate limit resets once fewer than
+	// this many requests remain
+	MinRateRemaining int = 10
+
+	// defaultRetryAfter - how long to wait after a rate limit response that does not say
+	defaultRetryAfter time.Duration = time.Minute
+)
+
+var (
+	// retryBaseDelay is the delay before the first retry, doubling with every retry
+	retryBaseDelay = time.Second
+
+	// retryMaxDelay caps the exponential backoff delay
+	retryMaxDelay = time.Minute
+)
+
+// RateLimitError is returned by providers when a request was rejected because of a rate
+// limit, and says how long to wait before trying again
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %s: %v", e.RetryAfter, e.Err)
+}
+
+// Rate is the rate limit status reported by a provider along with a page of stars
+type Rate struct {
+	// Remaining is the number of requests left, only meaningful if Reset is set
+	Remaining int
+
+	// Reset is when the rate limit resets, zero if the provider did not report it
+	Reset time.Time
+}
+
+// rateFromHeaders reads the rate limit status from the response headers used by GitHub
+// (X-RateLimit-*) and GitLab (RateLimit-*)
+func rateFromHeaders(h http.Header) Rate {
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		remaining, err := strconv.Atoi(h.Get(prefix + "Remaining"))
+		if err != nil {
+			continue
+		}
+
+		reset, err := strconv.ParseInt(h.Get(prefix+"Reset"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		return Rate{Remaining: remaining, Reset: time.Unix(reset, 0)}
+	}
+
+	return Rate{}
+}
+
+// retryable reports whether a failed request may succeed if retried, which is only the case
+// for rate limits, server errors and network errors. Other errors, such as not found or
+// other client errors, are permanent.
+func retryable(err error) bool {
+	var (
+		rateLimit *RateLimitError
+		response  *github.ErrorResponse
+		httpErr   *HTTPError
+		netErr    net.Error
+	)
+
+	switch {
+	case errors.As(err, &rateLimit):
+		return true
+	case errors.As(err, &response):
+		return response.Response.StatusCode >= 500
+	case errors.As(err, &httpErr):
+		return httpErr.StatusCode >= 500
+	case errors.As(err, &netErr):
+		return true
+	}
+
+	// A connection closed while the response was read
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// githubRateLimitError converts the rate limit errors of the GitHub client into a
+// RateLimitError, returning other errors unchanged
+func githubRateLimitError(err error) error {
+	switch e := err.(type) {
+	case *github.RateLimitError:
+		return &RateLimitError{RetryAfter: time.Until(e.Rate.Reset.Time), Err: err}
+	case *github.AbuseRateLimitError:
+		retryAfter := defaultRetryAfter
+		if e.RetryAfter != nil {
+			retryAfter = *e.RetryAfter
+		}
+
+		return &RateLimitError{RetryAfter: retryAfter, Err: err}
+	}
+
+	return err
+}
+
+// retryAfter parses the "Retry-After" header of a response, given in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	return defaultRetryAfter
+}
+
+// throttle pauses all requests to a provider until a point in time, shared by the workers
+// fetching its pages
+type throttle struct {
+	sync.Mutex
+	until time.Time
+}
+
+// pause holds back requests until the given time, unless they are already held back longer
+func (t *throttle) pause(until time.Time) {
+	t.Lock()
+	defer t.Unlock()
+
+	if until.After(t.until) {
+		log.Printf("Pausing requests until %s", until.Format(time.RFC3339))
+		t.until = until
+	}
+}
+
+// wait blocks until requests may be made again or the context is done
+func (t *throttle) wait(ctx context.Context) error {
+	t.Lock()
+	delay := time.Until(t.until)
+	t.Unlock()
+
+	return sleep(ctx, delay)
+}
+
+// observe pauses requests until the rate limit resets if it is nearly exhausted
+func (t *throttle) observe(rate Rate) {
+	if rate.Remaining < MinRateRemaining && rate.Reset.After(time.Now()) {
+		t.pause(rate.Reset)
+	}
+}
+
+// sleep waits for the given duration or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// listStarsWithRetry fetches a page of stars, honoring rate limits and retrying failures
+// with exponential backoff
+func listStarsWithRetry(ctx context.Context, p Provider, t *throttle, page int, etag string) (*StarPage, error) {
+	var starPage *StarPage
+
+	err := withRetry(ctx, t, fmt.Sprintf("page %d from %s", page, p.Name()), func() error {
+		var err error
+		if starPage, err = p.ListStars(ctx, page, etag); err != nil {
+			return err
+		}
+
+		t.observe(starPage.Rate)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return starPage, nil
+}
+
+// withGitHubRetry is withRetry for a request made with the GitHub client, observing the rate
+// limit status of its response
+func withGitHubRetry(ctx context.Context, t *throttle, what string, fn func() (*github.Response, error)) error {
+	return withRetry(ctx, t, what, func() error {
+		resp, err := fn()
+		if err != nil {
+			return githubRateLimitError(err)
+		}
+
+		t.observe(githubRate(resp))
+		return nil
+	})
+}
+
+// withRetry calls fn until it succeeds, honoring rate limits and retrying failures with
+// exponential backoff. what describes the request in log messages.
+func withRetry(ctx context.Context, t *throttle, what string, fn func() error) error {
+	delay := retryBaseDelay
+
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(ctx); err != nil {
+			return err
+		}
+
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil || attempt >= MaxRetries || !retryable(err) {
+			return err
+		}
+
+		if rle, ok := err.(*RateLimitError); ok {
+			log.Printf("Rate limited requesting %s: %v", what, err.Error())
+			t.pause(time.Now().Add(rle.RetryAfter))
+			continue
+		}
+
+		log.Printf("Retrying %s in %s: %v", what, delay, err.Error())
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+
+		delay *= 2
+		if delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
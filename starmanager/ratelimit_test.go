@@ -0,0 +1,114 @@
+package starmanager
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v25/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	// Keep retries fast in tests
+	retryBaseDelay = time.Millisecond
+	retryMaxDelay = 10 * time.Millisecond
+}
+
+// flakyProvider fails with the queued errors before returning an empty page
+type flakyProvider struct {
+	errs  []error
+	calls int
+}
+
+func (f *flakyProvider) Name() string { return "flaky.example.com" }
+
+func (f *flakyProvider) ListStars(ctx context.Context, page int, etag string) (*StarPage, error) {
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+
+	return &StarPage{}, nil
+}
+
+func (f *flakyProvider) Star(ctx context.Context, owner, repo string) error   { return nil }
+func (f *flakyProvider) Unstar(ctx context.Context, owner, repo string) error { return nil }
+func (f *flakyProvider) Resolve(ctx context.Context, owner, repo string) (string, error) {
+	return "https://" + f.Name() + "/" + owner + "/" + repo, nil
+}
+
+func TestListStarsWithRetry(t *testing.T) {
+	testCases := []struct {
+		errs  []error
+		calls int
+		err   bool
+	}{
+		{errs: nil, calls: 1},
+		{errs: []error{&url.Error{Op: "Get", Err: errors.New("connection reset")}, &HTTPError{StatusCode: 502}}, calls: 3},
+		{errs: []error{io.ErrUnexpectedEOF}, calls: 2},
+		{errs: []error{&RateLimitError{RetryAfter: time.Millisecond}}, calls: 2},
+		{errs: []error{&HTTPError{StatusCode: 404}}, calls: 1, err: true},
+		{errs: []error{&HTTPError{StatusCode: 403}}, calls: 1, err: true},
+		{errs: []error{&github.ErrorResponse{Response: &http.Response{StatusCode: 422}}}, calls: 1, err: true},
+		{errs: []error{ErrGone}, calls: 1, err: true},
+		{errs: []error{errors.New("graphql: Could not resolve to a Repository")}, calls: 1, err: true},
+		{
+			errs: []error{
+				&HTTPError{StatusCode: 500},
+				&HTTPError{StatusCode: 500},
+				&HTTPError{StatusCode: 500},
+				&HTTPError{StatusCode: 500},
+				&HTTPError{StatusCode: 500},
+				&HTTPError{StatusCode: 500},
+			},
+			calls: MaxRetries + 1,
+			err:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		p := &flakyProvider{errs: tc.errs}
+
+		_, err := listStarsWithRetry(context.Background(), p, &throttle{}, 1, "")
+		if tc.err {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, tc.calls, p.calls)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	th := &throttle{}
+
+	// Plenty of requests left, or no reset reported
+	th.observe(Rate{Remaining: 100, Reset: time.Now().Add(time.Hour)})
+	th.observe(Rate{Remaining: 0})
+	assert.True(t, th.until.IsZero())
+
+	reset := time.Now().Add(time.Hour)
+	th.observe(Rate{Remaining: 1, Reset: reset})
+	assert.Equal(t, reset, th.until)
+
+	// Waiting on a throttled provider gives up when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.Error(t, th.wait(ctx))
+}
+
+func TestRateFromHeaders(t *testing.T) {
+	h := http.Header{}
+	assert.Equal(t, Rate{}, rateFromHeaders(h))
+
+	h.Set("RateLimit-Remaining", "5")
+	h.Set("RateLimit-Reset", "1600000000")
+	assert.Equal(t, Rate{Remaining: 5, Reset: time.Unix(1600000000, 0)}, rateFromHeaders(h))
+}
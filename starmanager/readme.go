@@ -0,0 +1,149 @@
+package starmanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/google/go-github/v25/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReadmeNode - the name of the storm node holding the cached READMEs
+const ReadmeNode string = "readmes"
+
+// ReadmeFormat - the format READMEs are cached in
+type ReadmeFormat string
+
+const (
+	// ReadmeMarkdown - the README as written, usually Markdown
+	ReadmeMarkdown ReadmeFormat = "markdown"
+
+	// ReadmeHTML - the README rendered to HTML by GitHub
+	ReadmeHTML ReadmeFormat = "html"
+)
+
+// readmeMediaTypes are the media types GitHub returns READMEs in for every format
+var readmeMediaTypes = map[ReadmeFormat]string{
+	ReadmeMarkdown: "application/vnd.github.v3.raw",
+	ReadmeHTML:     "application/vnd.github.v3.html",
+}
+
+// Readme is the cached README of a star. READMEs are kept apart from the stars, as they
+// are large and only needed one at a time.
+type Readme struct {
+	URL       string `storm:"id"`
+	Format    ReadmeFormat
+	Content   string
+	FetchedAt time.Time
+}
+
+// readmes returns the storm node holding the cached READMEs
+func (s *StarManager) readmes() storm.Node {
+	return s.DB.From(ReadmeNode)
+}
+
+// fetchReadme downloads the README of a GitHub repository in the given format. An empty
+// content is returned if the repository has no README.
+func (s *StarManager) fetchReadme(ctx context.Context, t *throttle, owner, repo string, format ReadmeFormat) (string, error) {
+	req, err := s.Client.NewRequest("GET", fmt.Sprintf("repos/%s/%s/readme", owner, repo), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", readmeMediaTypes[format])
+
+	content := &bytes.Buffer{}
+	err = withGitHubRetry(ctx, t, "README of "+owner+"/"+repo, func() (*github.Response, error) {
+		content.Reset()
+		return s.Client.Do(ctx, req, content)
+	})
+	if e, ok := err.(*github.ErrorResponse); ok && e.Response.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+
+	return content.String(), err
+}
+
+// FetchReadmes downloads the README of every cached GitHub star into the cache, in Markdown
+// unless the format says otherwise. READMEs already cached in the same format, or the lack
+// of one, are only downloaded again if the repository was pushed to since. It returns the
+// number of READMEs downloaded, and stops early with a RateLimitError if the rate limit
+// outlasts the retries.
+func (s *StarManager) FetchReadmes(ctx context.Context, format ReadmeFormat) (int, error) {
+	if format == "" {
+		format = ReadmeMarkdown
+	}
+
+	if _, ok := readmeMediaTypes[format]; !ok {
+		return 0, fmt.Errorf("unknown README format %q", format)
+	}
+
+	all, err := s.store().All()
+	if err != nil {
+		return 0, err
+	}
+
+	// READMEs are only fetched from GitHub
+	stars := []*Star{}
+	for _, star := range all {
+		if star.ProviderName() != webHost(s.Host) {
+			continue
+		}
+
+		cached := &Readme{}
+		if err := s.readmes().One("URL", star.URL, cached); err == nil {
+			if cached.Format == format && cached.FetchedAt.After(star.PushedAt) {
+				continue
+			}
+		} else if err != storm.ErrNotFound {
+			return 0, err
+		}
+
+		stars = append(stars, star)
+	}
+
+	updated, err := s.fetchEach(ctx, "READMEs", stars, func(ctx context.Context, t *throttle, star *Star) (bool, error) {
+		owner, repo, err := star.OwnerRepo()
+		if err != nil {
+			return false, err
+		}
+
+		content, err := s.fetchReadme(ctx, t, owner, repo, format)
+		if err != nil {
+			return false, err
+		}
+
+		// Repositories without a README are cached with an empty one, so that they are not
+		// asked for again until they are pushed to
+		readme := &Readme{URL: star.URL, Format: format, Content: content, FetchedAt: time.Now()}
+		return content != "", s.readmes().Save(readme)
+	})
+
+	log.Printf("Downloaded %d READMEs", updated)
+	return updated, err
+}
+
+// Readme returns the cached README of a star
+func (s *StarManager) Readme(ctx context.Context, url string) (*Readme, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	readme := &Readme{}
+	if err := s.readmes().One("URL", url, readme); err != nil {
+		if err == storm.ErrNotFound {
+			return nil, fmt.Errorf("no README cached for %s", url)
+		}
+
+		return nil, err
+	}
+
+	if readme.Content == "" {
+		return nil, fmt.Errorf("%s has no README", url)
+	}
+
+	return readme, nil
+}
@@ -0,0 +1,80 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchReadmes(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	mu := sync.Mutex{}
+	requests := map[string]int{}
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/repos/a/documented/readme" && r.Header.Get("Accept") == readmeMediaTypes[ReadmeHTML]:
+			fmt.Fprint(w, "<h1>Documented</h1>")
+		case r.URL.Path == "/repos/a/documented/readme":
+			fmt.Fprint(w, "# Documented")
+		default:
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	}))
+	defer closeGitHub()
+
+	sm.Host = GitHub
+	sm.Client = client
+
+	ctx := context.Background()
+	pushedAt := time.Now().Add(-time.Hour)
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/documented", PushedAt: pushedAt}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/undocumented", PushedAt: pushedAt}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://gitlab.com/b/other", Provider: "gitlab.com"}))
+
+	updated, err := sm.FetchReadmes(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, updated)
+
+	readme, err := sm.Readme(ctx, "https://github.com/a/documented")
+	assert.NoError(t, err)
+	assert.Equal(t, ReadmeMarkdown, readme.Format)
+	assert.Equal(t, "# Documented", readme.Content)
+
+	_, err = sm.Readme(ctx, "https://github.com/a/undocumented")
+	assert.Error(t, err)
+
+	// Unchanged repositories are not downloaded again, nor asked for a missing README, unless
+	// the format changes
+	updated, err = sm.FetchReadmes(ctx, ReadmeMarkdown)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, updated)
+	assert.Equal(t, 1, requests["/repos/a/documented/readme"])
+	assert.Equal(t, 1, requests["/repos/a/undocumented/readme"])
+
+	updated, err = sm.FetchReadmes(ctx, ReadmeHTML)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, updated)
+
+	readme, err = sm.Readme(ctx, "https://github.com/a/documented")
+	assert.NoError(t, err)
+	assert.Equal(t, "<h1>Documented</h1>", readme.Content)
+
+	_, err = sm.FetchReadmes(ctx, "pdf")
+	assert.Error(t, err)
+
+	// READMEs are dropped along with the stars they belong to
+	assert.NoError(t, sm.ClearCache(false))
+	_, err = sm.Readme(ctx, "https://github.com/a/documented")
+	assert.Error(t, err)
+}
@@ -0,0 +1,279 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v25/github"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultRecommendations - how many repositories Recommend suggests by default
+	DefaultRecommendations int = 10
+
+	// DefaultRecommendTopics - how many of the most common topics Recommend searches by default
+	DefaultRecommendTopics int = 5
+
+	// DefaultRecommendLanguages - how many of the most common languages Recommend searches by
+	// default
+	DefaultRecommendLanguages int = 3
+
+	// recommendPerQuery is the number of search results requested per topic or language
+	recommendPerQuery int = 30
+)
+
+// RecommendOptions - the parameters of Recommend
+type RecommendOptions struct {
+	// Count is the number of recommendations, DefaultRecommendations if 0
+	Count int
+
+	// Topics is the number of most common topics to search, DefaultRecommendTopics if 0
+	Topics int
+
+	// Languages is the number of most common languages to search, DefaultRecommendLanguages
+	// if 0
+	Languages int
+
+	// MinStars only recommends repositories with at least this many stargazers
+	MinStars int
+
+	// Weights multiply the weight of topics in the profile, keyed by topic. A weight of 0
+	// leaves the topic out.
+	Weights map[string]float64
+
+	// Exclude leaves out repositories given by URL or owner/repo, and all repositories of
+	// owners given by name
+	Exclude []string
+
+	// ExcludeTopics leaves out repositories with any of these topics
+	ExcludeTopics []string
+}
+
+// Recommendation is a repository matching the profile of the cached stars that is not
+// starred yet
+type Recommendation struct {
+	Star Star `json:"star"`
+
+	// Score ranks recommendations, growing with how well the repository matches the profile
+	// and how popular it is
+	Score float64 `json:"score"`
+
+	// Matched are the topics and languages of the profile the repository matched
+	Matched []string `json:"matched"`
+}
+
+// profileWeight is a topic or language of the profile along with its share of the stars
+type profileWeight struct {
+	qualifier string
+	value     string
+	weight    float64
+}
+
+// profile weighs the most common topics and languages of the cached stars by the share of
+// stars they make up
+func (s *StarManager) profile(ctx context.Context, opts RecommendOptions) ([]profileWeight, error) {
+	stats, err := s.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := []profileWeight{}
+	if stats.Total == 0 {
+		return profile, nil
+	}
+
+	topics := 0
+	for _, kv := range stats.Topics {
+		if topics >= opts.Topics {
+			break
+		}
+
+		multiplier, ok := opts.Weights[kv.Key]
+		if !ok {
+			multiplier = 1
+		}
+
+		if multiplier <= 0 || containsFold(opts.ExcludeTopics, kv.Key) {
+			continue
+		}
+
+		profile = append(profile, profileWeight{
+			qualifier: "topic",
+			value:     kv.Key,
+			weight:    multiplier * float64(kv.Value) / float64(stats.Total),
+		})
+		topics++
+	}
+
+	languages := 0
+	for _, kv := range stats.Languages {
+		if languages >= opts.Languages {
+			break
+		}
+
+		if kv.Key == unknown {
+			continue
+		}
+
+		profile = append(profile, profileWeight{
+			qualifier: "language",
+			value:     kv.Key,
+			weight:    float64(kv.Value) / float64(stats.Total),
+		})
+		languages++
+	}
+
+	return profile, nil
+}
+
+// containsFold reports whether values contains value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// excluded reports whether a recommendation is left out by the exclusion list
+func excluded(star *Star, opts RecommendOptions) bool {
+	owner, repo, err := star.OwnerRepo()
+	if err != nil {
+		return true
+	}
+
+	for _, e := range opts.Exclude {
+		e = strings.TrimSuffix(e, "/")
+		if strings.EqualFold(e, star.URL) || strings.EqualFold(e, owner+"/"+repo) || strings.EqualFold(e, owner) {
+			return true
+		}
+	}
+
+	for _, topic := range star.Topics {
+		if containsFold(opts.ExcludeTopics, topic) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Recommend searches GitHub for popular repositories matching the most common topics and
+// languages of the cached stars, leaving out repositories that are starred, were removed
+// before, are archived or are excluded. Each topic and language is weighted by the share of
+// stars it makes up, optionally adjusted per topic.
+func (s *StarManager) Recommend(ctx context.Context, opts RecommendOptions) ([]*Recommendation, error) {
+	if opts.Count <= 0 {
+		opts.Count = DefaultRecommendations
+	}
+	if opts.Topics <= 0 {
+		opts.Topics = DefaultRecommendTopics
+	}
+	if opts.Languages <= 0 {
+		opts.Languages = DefaultRecommendLanguages
+	}
+
+	profile, err := s.profile(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Starred and previously removed repositories are never recommended
+	known := map[string]bool{}
+
+	stars, err := s.store().All()
+	if err != nil {
+		return nil, err
+	}
+	for _, star := range stars {
+		known[strings.ToLower(star.URL)] = true
+	}
+
+	graves, err := s.Graveyard(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, grave := range graves {
+		known[strings.ToLower(grave.URL)] = true
+	}
+
+	gh := &GitHubProvider{Host: webHost(s.Host), Client: s.Client}
+	t := &throttle{}
+	candidates := map[string]*Star{}
+
+	for _, p := range profile {
+		query := fmt.Sprintf("%s:%s stars:>=%d archived:false", p.qualifier, p.value, opts.MinStars)
+
+		var result *github.RepositoriesSearchResult
+		err := withRetry(ctx, t, query, func() error {
+			var err error
+			result, _, err = s.Client.Search.Repositories(ctx, query, &github.SearchOptions{
+				Sort:        "stars",
+				Order:       "desc",
+				ListOptions: github.ListOptions{PerPage: recommendPerQuery},
+			})
+
+			return githubRateLimitError(err)
+		})
+		if err != nil {
+			log.Printf("An error occurred while searching for %s: %v", query, err.Error())
+			continue
+		}
+
+		for i := range result.Repositories {
+			repo := &result.Repositories[i]
+			if repo.HTMLURL == nil || repo.PushedAt == nil || repo.StargazersCount == nil || repo.Archived == nil {
+				continue
+			}
+
+			star := gh.star(&github.StarredRepository{Repository: repo})
+			key := strings.ToLower(star.URL)
+			if known[key] || star.Archived || star.Stargazers < opts.MinStars || excluded(star, opts) {
+				continue
+			}
+
+			candidates[key] = star
+		}
+	}
+
+	recommendations := []*Recommendation{}
+	for _, star := range candidates {
+		rec := &Recommendation{Star: *star, Matched: []string{}}
+
+		relevance := 0.0
+		for _, p := range profile {
+			matches := p.qualifier == "language" && strings.EqualFold(star.Language, p.value)
+			if p.qualifier == "topic" {
+				matches = containsFold(star.Topics, p.value)
+			}
+
+			if matches {
+				relevance += p.weight
+				rec.Matched = append(rec.Matched, p.qualifier+":"+p.value)
+			}
+		}
+
+		rec.Score = relevance * math.Log10(float64(star.Stargazers)+10)
+		recommendations = append(recommendations, rec)
+	}
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+
+		return recommendations[i].Star.URL < recommendations[j].Star.URL
+	})
+
+	if len(recommendations) > opts.Count {
+		recommendations = recommendations[:opts.Count]
+	}
+
+	return recommendations, nil
+}
@@ -0,0 +1,93 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// searchRepo renders a repository as returned by the GitHub search API
+func searchRepo(name, language string, stargazers int, topics ...string) string {
+	return fmt.Sprintf(
+		`{"html_url": "https://github.com/%s", "language": %q, "stargazers_count": %d, "topics": ["%s"], "archived": false, "pushed_at": "2024-01-01T00:00:00Z"}`,
+		name, language, stargazers, strings.Join(topics, `", "`),
+	)
+}
+
+func TestRecommend(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	queries := []string{}
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		queries = append(queries, query)
+
+		repos := []string{}
+		switch {
+		case strings.HasPrefix(query, "topic:cli "):
+			repos = []string{
+				searchRepo("a/starred", "Go", 5000, "cli"),
+				searchRepo("b/removed", "Go", 4000, "cli"),
+				searchRepo("c/both", "Go", 3000, "cli", "tui"),
+				searchRepo("d/cli-only", "Rust", 900000, "cli"),
+				searchRepo("spam/tool", "Go", 2000, "cli"),
+			}
+		case strings.HasPrefix(query, "topic:tui "):
+			repos = []string{
+				searchRepo("c/both", "Go", 3000, "cli", "tui"),
+				searchRepo("e/deprecated", "Go", 8000, "tui", "deprecated"),
+			}
+		case strings.HasPrefix(query, "language:go "):
+			repos = []string{searchRepo("f/go-only", "Go", 100000, "web")}
+		}
+
+		fmt.Fprintf(w, `{"total_count": %d, "items": [%s]}`, len(repos), strings.Join(repos, ","))
+	}))
+	defer closeGitHub()
+
+	sm.Host = GitHub
+	sm.Client = client
+
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/starred", Language: "go", Topics: []string{"cli", "tui"}}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/other", Language: "go", Topics: []string{"cli", "tui"}}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/legacy", Topics: []string{"legacy"}}))
+	assert.NoError(t, sm.graveyard().Save(&Grave{URL: "https://github.com/b/removed"}))
+
+	recommendations, err := sm.Recommend(context.Background(), RecommendOptions{
+		Count:         3,
+		Topics:        2,
+		Languages:     1,
+		MinStars:      100,
+		Exclude:       []string{"spam"},
+		ExcludeTopics: []string{"deprecated"},
+	})
+	assert.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		"topic:cli stars:>=100 archived:false",
+		"topic:tui stars:>=100 archived:false",
+		"language:go stars:>=100 archived:false",
+	}, queries)
+
+	urls := []string{}
+	for _, rec := range recommendations {
+		urls = append(urls, rec.Star.URL)
+	}
+	assert.Equal(t, []string{"https://github.com/c/both", "https://github.com/d/cli-only", "https://github.com/f/go-only"}, urls)
+	assert.Equal(t, []string{"topic:cli", "topic:tui", "language:go"}, recommendations[0].Matched)
+
+	// A weight of 0 leaves a topic out of the profile
+	queries = []string{}
+	_, err = sm.Recommend(context.Background(), RecommendOptions{Topics: 2, Languages: 1, Weights: map[string]float64{"tui": 0}})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"topic:cli stars:>=0 archived:false",
+		"topic:legacy stars:>=0 archived:false",
+		"language:go stars:>=0 archived:false",
+	}, queries)
+}
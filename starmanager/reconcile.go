@@ -0,0 +1,189 @@
+package starmanager
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/asdine/storm"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReconcileOptions - the parameters of a reconcile pass
+type ReconcileOptions struct {
+	// DryRun only reports moved and deleted repositories without updating the cache
+	DryRun bool
+}
+
+// ReconcileResult summarizes the outcome of a reconcile pass
+type ReconcileResult struct {
+	Checked int `json:"checked"`
+
+	// Renamed are the stars whose repositories were renamed or transferred, and which are
+	// now cached under their new URL
+	Renamed []Rename `json:"renamed"`
+
+	// Gone are the URLs of the stars whose repositories no longer exist
+	Gone []string `json:"gone"`
+
+	Failed int               `json:"failed"`
+	Errors []*ReconcileError `json:"errors"`
+}
+
+// Reconcile checks every cached star against its provider. Stars of renamed or transferred
+// repositories are moved to their new URL along with their annotations, and stars of
+// repositories that no longer exist are flagged as gone so that Cleanup can remove them. A
+// non-nil error is only returned if the pass could not run at all; stars that could not be
+// checked are reported in the result.
+func (s *StarManager) Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileResult, error) {
+	dryRun := opts.DryRun || s.DryRun
+
+	stars, err := s.store().All()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReconcileResult{Renamed: []Rename{}, Gone: []string{}}
+	mu := sync.Mutex{}
+	throttles := map[string]*throttle{}
+	for _, p := range s.Providers {
+		throttles[p.Name()] = &throttle{}
+	}
+
+	runPool(ctx, s.concurrency(), len(stars), func(job int) {
+		star := stars[job]
+		rename, err := s.reconcileStar(ctx, star, throttles, dryRun)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			log.Printf("An error occurred while attempting to reconcile %s: %s\n", star.URL, err.Error())
+			result.Failed++
+			result.Errors = append(result.Errors, &ReconcileError{URL: star.URL, Err: err})
+			return
+		}
+
+		result.Checked++
+		if rename != nil {
+			result.Renamed = append(result.Renamed, *rename)
+		} else if star.Gone {
+			result.Gone = append(result.Gone, star.URL)
+		}
+	})
+
+	sort.Slice(result.Renamed, func(i, j int) bool { return result.Renamed[i].From < result.Renamed[j].From })
+	sort.Strings(result.Gone)
+
+	log.Printf(
+		"Reconciled %d stars: %d renamed, %d gone, %d failed",
+		result.Checked,
+		len(result.Renamed),
+		len(result.Gone),
+		result.Failed,
+	)
+
+	return result, ctx.Err()
+}
+
+// reconcileStar resolves the current URL of a single star, moving or flagging it as needed
+func (s *StarManager) reconcileStar(ctx context.Context, star *Star, throttles map[string]*throttle, dryRun bool) (*Rename, error) {
+	owner, repo, err := star.OwnerRepo()
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := s.Provider(star.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	url := ""
+	err = withRetry(ctx, throttles[provider.Name()], star.URL, func() error {
+		var err error
+		url, err = provider.Resolve(ctx, owner, repo)
+		return err
+	})
+
+	switch {
+	case err == ErrGone:
+		if !star.Gone {
+			log.Printf("%s no longer exists", star.URL)
+			star.Gone = true
+
+			if !dryRun {
+				return nil, s.store().Save(star)
+			}
+		}
+	case err != nil:
+		return nil, err
+	case url != "" && url != star.URL:
+		log.Printf("%s moved to %s", star.URL, url)
+		rename := &Rename{From: star.URL, To: url}
+
+		if dryRun {
+			return rename, nil
+		}
+
+		return rename, s.moveStar(star, url)
+	case star.Gone:
+		star.Gone = false
+
+		if !dryRun {
+			return nil, s.store().Save(star)
+		}
+	}
+
+	return nil, nil
+}
+
+// moveStar moves a cached star and its annotation to the new URL of its repository. If the
+// new URL is already cached, that star and its annotation are kept instead.
+func (s *StarManager) moveStar(star *Star, url string) error {
+	tx, err := s.DB.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The moved star is saved first, so that a store outside the transaction keeps the star
+	// under one URL or the other should the move fail halfway
+	store := s.storeIn(tx)
+	moved := *star
+	moved.URL = url
+	moved.Gone = false
+	moved.setOwnerRepo()
+
+	if _, err := store.Get(url); err == storm.ErrNotFound {
+		if err := store.Save(&moved); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if err := store.Delete(star.URL); err != nil {
+		return err
+	}
+
+	local := tx.From(LocalNode)
+	annotation := &Annotation{}
+	if err := local.One("URL", star.URL, annotation); err == nil {
+		if err := local.DeleteStruct(annotation); err != nil {
+			return err
+		}
+
+		annotation.URL = url
+		if err := local.One("URL", url, &Annotation{}); err == storm.ErrNotFound {
+			if err := local.Save(annotation); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+	} else if err != storm.ErrNotFound {
+		return err
+	}
+
+	return tx.Commit()
+}
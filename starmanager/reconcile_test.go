@@ -0,0 +1,60 @@
+package starmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcile(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	provider := &fakeProvider{
+		name:  "github.com",
+		moved: map[string]string{"a/old-name": "https://github.com/b/new-name"},
+		gone:  []string{"a/deleted"},
+	}
+	sm.Providers = []Provider{provider}
+
+	ctx := context.Background()
+	now := time.Now()
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/old-name", Stargazers: 5, PushedAt: now}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/deleted", PushedAt: now}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/back", Gone: true, PushedAt: now}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/fine", PushedAt: now}))
+	assert.NoError(t, sm.Tag("https://github.com/a/old-name", "keep"))
+
+	result, err := sm.Reconcile(ctx, ReconcileOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, result.Err())
+	assert.Equal(t, 4, result.Checked)
+	assert.Equal(t, []Rename{{From: "https://github.com/a/old-name", To: "https://github.com/b/new-name"}}, result.Renamed)
+	assert.Equal(t, []string{"https://github.com/a/deleted"}, result.Gone)
+
+	moved := Star{}
+	assert.NoError(t, sm.DB.One("URL", "https://github.com/b/new-name", &moved))
+	assert.Equal(t, 5, moved.Stargazers)
+	assert.Error(t, sm.DB.One("URL", "https://github.com/a/old-name", &Star{}))
+
+	annotation, err := sm.GetAnnotation("https://github.com/b/new-name")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"keep"}, annotation.Tags)
+
+	back := Star{}
+	assert.NoError(t, sm.DB.One("URL", "https://github.com/a/back", &back))
+	assert.False(t, back.Gone)
+
+	projects, err := sm.findProjects(ctx, ProjectOptions{Query: "gone:true"})
+	assert.NoError(t, err)
+	assert.Len(t, projects, 1)
+
+	// Gone stars are removed without unstarring them
+	cleanupResult, err := sm.Cleanup(ctx, CleanupOptions{Months: 1, Gone: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cleanupResult.Removed)
+	assert.Empty(t, provider.unstarred)
+	assert.Error(t, sm.DB.One("URL", "https://github.com/a/deleted", &Star{}))
+}
@@ -0,0 +1,169 @@
+package starmanager
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/google/go-github/v25/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReleaseNode - the name of the storm node holding the state of release checks
+const ReleaseNode string = "releases"
+
+// releaseCheckID is the ID of the record of the last release check
+const releaseCheckID string = "check"
+
+// ReleaseCheck records when new releases were last looked at
+type ReleaseCheck struct {
+	ID        string `storm:"id"`
+	CheckedAt time.Time
+}
+
+// releases returns the storm node holding the state of release checks
+func (s *StarManager) releases() storm.Node {
+	return s.DB.From(ReleaseNode)
+}
+
+// latestRelease returns the tag and publish date of the latest release of a GitHub
+// repository, falling back to its latest tag for repositories that only publish tags. An
+// empty tag is returned if there are neither.
+func (s *StarManager) latestRelease(ctx context.Context, t *throttle, owner, repo string) (string, time.Time, error) {
+	var release *github.RepositoryRelease
+	err := withGitHubRetry(ctx, t, "latest release of "+owner+"/"+repo, func() (*github.Response, error) {
+		var (
+			resp *github.Response
+			err  error
+		)
+
+		release, resp, err = s.Client.Repositories.GetLatestRelease(ctx, owner, repo)
+		return resp, err
+	})
+	if err == nil {
+		return release.GetTagName(), release.GetPublishedAt().Time, nil
+	}
+
+	if e, ok := err.(*github.ErrorResponse); !ok || e.Response.StatusCode != http.StatusNotFound {
+		return "", time.Time{}, err
+	}
+
+	var tags []*github.RepositoryTag
+	err = withGitHubRetry(ctx, t, "tags of "+owner+"/"+repo, func() (*github.Response, error) {
+		var (
+			resp *github.Response
+			err  error
+		)
+
+		tags, resp, err = s.Client.Repositories.ListTags(ctx, owner, repo, &github.ListOptions{PerPage: 1})
+		return resp, err
+	})
+	if err != nil || len(tags) == 0 {
+		return "", time.Time{}, err
+	}
+
+	// Tags carry no date of their own, so the date of the tagged commit is used
+	var commit *github.RepositoryCommit
+	err = withGitHubRetry(ctx, t, "latest tag of "+owner+"/"+repo, func() (*github.Response, error) {
+		var (
+			resp *github.Response
+			err  error
+		)
+
+		commit, resp, err = s.Client.Repositories.GetCommit(ctx, owner, repo, tags[0].GetCommit().GetSHA())
+		return resp, err
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tags[0].GetName(), commit.GetCommit().GetCommitter().GetDate(), nil
+}
+
+// FetchReleases fetches the latest release, or tag if there are no releases, of every cached
+// GitHub star and stores its version and publish date on the star. It returns the number
+// of stars whose release was updated, and stops early with a RateLimitError if the rate
+// limit outlasts the retries.
+func (s *StarManager) FetchReleases(ctx context.Context) (int, error) {
+	all, err := s.store().All()
+	if err != nil {
+		return 0, err
+	}
+
+	// Releases are only fetched from GitHub
+	stars := []*Star{}
+	for _, star := range all {
+		if star.ProviderName() == webHost(s.Host) {
+			stars = append(stars, star)
+		}
+	}
+
+	updated, err := s.fetchEach(ctx, "releases", stars, func(ctx context.Context, t *throttle, star *Star) (bool, error) {
+		owner, repo, err := star.OwnerRepo()
+		if err != nil {
+			return false, err
+		}
+
+		tag, publishedAt, err := s.latestRelease(ctx, t, owner, repo)
+		if err != nil {
+			return false, err
+		}
+
+		if tag == star.LatestRelease && publishedAt.Equal(star.ReleasedAt) {
+			return false, nil
+		}
+
+		star.LatestRelease = tag
+		star.ReleasedAt = publishedAt
+
+		return true, s.store().Save(star)
+	})
+
+	log.Printf("Updated releases for %d stars", updated)
+	return updated, err
+}
+
+// NewReleases returns the stars that published a release after the given time, most recent
+// release first
+func (s *StarManager) NewReleases(ctx context.Context, since time.Time) ([]Star, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	released, err := s.store().Query(q.Gt("ReleasedAt", since))
+	if err != nil {
+		return nil, err
+	}
+
+	stars := make([]Star, 0, len(released))
+	for _, star := range released {
+		stars = append(stars, *star)
+	}
+
+	sort.SliceStable(stars, func(i, j int) bool { return stars[i].ReleasedAt.After(stars[j].ReleasedAt) })
+
+	return stars, nil
+}
+
+// LastReleaseCheck returns when new releases were last checked with MarkReleasesChecked,
+// the zero time if never
+func (s *StarManager) LastReleaseCheck() (time.Time, error) {
+	check := &ReleaseCheck{}
+	if err := s.releases().One("ID", releaseCheckID, check); err != nil {
+		if err == storm.ErrNotFound {
+			return time.Time{}, nil
+		}
+
+		return time.Time{}, err
+	}
+
+	return check.CheckedAt, nil
+}
+
+// MarkReleasesChecked records that new releases were checked at the given time
+func (s *StarManager) MarkReleasesChecked(at time.Time) error {
+	return s.releases().Save(&ReleaseCheck{ID: releaseCheckID, CheckedAt: at})
+}
@@ -0,0 +1,118 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchReleases(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	flaky := int32(0)
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/a/flaky/releases/latest":
+			// Server errors are retried
+			if atomic.AddInt32(&flaky, 1) == 1 {
+				http.Error(w, `{"message": "Bad Gateway"}`, http.StatusBadGateway)
+				return
+			}
+
+			fmt.Fprint(w, `{"tag_name": "v2.0.0", "published_at": "2023-12-01T00:00:00Z"}`)
+		case "/repos/a/released/releases/latest":
+			fmt.Fprint(w, `{"tag_name": "v1.2.0", "published_at": "2024-03-01T00:00:00Z"}`)
+		case "/repos/a/tagged/tags":
+			fmt.Fprint(w, `[{"name": "0.9", "commit": {"sha": "abc"}}]`)
+		case "/repos/a/tagged/commits/abc":
+			fmt.Fprint(w, `{"sha": "abc", "commit": {"committer": {"date": "2024-01-15T00:00:00Z"}}}`)
+		case "/repos/a/untagged/tags":
+			fmt.Fprint(w, `[]`)
+		default:
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	}))
+	defer closeGitHub()
+
+	sm.Host = GitHub
+	sm.Client = client
+
+	ctx := context.Background()
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/released"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/flaky"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/tagged"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/untagged"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://gitlab.com/b/other", Provider: "gitlab.com"}))
+
+	updated, err := sm.FetchReleases(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, updated)
+
+	star := Star{}
+	assert.NoError(t, sm.DB.One("URL", "https://github.com/a/flaky", &star))
+	assert.Equal(t, "v2.0.0", star.LatestRelease)
+
+	assert.NoError(t, sm.DB.One("URL", "https://github.com/a/released", &star))
+	assert.Equal(t, "v1.2.0", star.LatestRelease)
+	assert.True(t, star.ReleasedAt.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)))
+
+	// The release survives a re-sync through a provider that does not report releases
+	_, err = sm.SaveStar(&Star{URL: "https://github.com/a/released"})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		since    time.Time
+		expected []string
+	}{
+		{
+			since:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{"https://github.com/a/released", "https://github.com/a/tagged"},
+		},
+		{
+			since:    time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{"https://github.com/a/released"},
+		},
+		{
+			since:    time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		stars, err := sm.NewReleases(ctx, tc.since)
+		assert.NoError(t, err)
+
+		urls := []string{}
+		for _, s := range stars {
+			urls = append(urls, s.URL)
+		}
+		assert.Equal(t, tc.expected, urls)
+	}
+}
+
+func TestReleaseCheck(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	checkedAt, err := sm.LastReleaseCheck()
+	assert.NoError(t, err)
+	assert.True(t, checkedAt.IsZero())
+
+	now := time.Now().Round(time.Second)
+	assert.NoError(t, sm.MarkReleasesChecked(now))
+
+	checkedAt, err = sm.LastReleaseCheck()
+	assert.NoError(t, err)
+	assert.True(t, checkedAt.Equal(now))
+
+	// The check is not a star
+	stars := []Star{}
+	assert.NoError(t, sm.DB.All(&stars))
+	assert.Empty(t, stars)
+}
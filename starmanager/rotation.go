@@ -0,0 +1,185 @@
+package starmanager
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// DefaultTokenRotationThreshold - requests switch to another token once fewer than this many
+// requests remain for the one in use
+const DefaultTokenRotationThreshold int = 100
+
+// RotatingTokenSource spreads requests over several tokens, e.g. of machine users, for
+// accounts too large for the rate limit of one. A token is used until fewer than the
+// threshold requests remain for it, then the next one with requests left is switched to.
+// Rate limits are tracked per resource (core, search, graphql), as GitHub counts them
+// separately.
+//
+// The first token is the main one, of the user whose stars are managed. Requests whose
+// outcome depends on whose token is used, such as starring or listing the viewer's stars,
+// are always made with it; the others only read repositories.
+type RotatingTokenSource struct {
+	sync.Mutex
+	tokens    []string
+	threshold int
+
+	// current is the index of the token in use per resource
+	current map[string]int
+
+	// rates is the last rate limit status seen per token and resource
+	rates []map[string]Rate
+}
+
+// NewRotatingTokenSource returns a token source rotating the given tokens, the first being
+// the main one, switching away from a token once fewer than threshold requests remain for it
+func NewRotatingTokenSource(tokens []string, threshold int) *RotatingTokenSource {
+	rates := make([]map[string]Rate, len(tokens))
+	for i := range rates {
+		rates[i] = map[string]Rate{}
+	}
+
+	return &RotatingTokenSource{tokens: tokens, threshold: threshold, current: map[string]int{}, rates: rates}
+}
+
+// Token returns the token to read from the core API with
+func (r *RotatingTokenSource) Token() (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: r.tokens[r.pick("core", time.Now())]}, nil
+}
+
+// Observe records the rate limit status of the token at index i for a resource, as reported
+// with a response
+func (r *RotatingTokenSource) Observe(i int, resource string, rate Rate) {
+	if rate.Reset.IsZero() {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.rates[i][resource] = rate
+}
+
+// Transport returns a transport authenticating requests made over base with the rotated
+// tokens and observing the rate limits reported with the responses
+func (r *RotatingTokenSource) Transport(base http.RoundTripper) http.RoundTripper {
+	return &rotatingTransport{base: base, source: r}
+}
+
+// pick returns the index of the token to use for a resource, switching tokens if the one in
+// use is running out of requests. If all are, the one whose rate limit resets first is used.
+func (r *RotatingTokenSource) pick(resource string, now time.Time) int {
+	r.Lock()
+	defer r.Unlock()
+
+	current := r.current[resource]
+	if r.usable(current, resource, now) {
+		return current
+	}
+
+	next := current
+	for i := 1; i < len(r.tokens); i++ {
+		candidate := (current + i) % len(r.tokens)
+		if r.usable(candidate, resource, now) {
+			next = candidate
+			break
+		}
+
+		if r.rates[candidate][resource].Reset.Before(r.rates[next][resource].Reset) {
+			next = candidate
+		}
+	}
+
+	if next != current {
+		log.Printf("Switching to token %d of %d for the %s API", next+1, len(r.tokens), resource)
+		r.current[resource] = next
+	}
+
+	return next
+}
+
+// usable reports whether a token has enough requests left for a resource, which is assumed
+// until a response says otherwise
+func (r *RotatingTokenSource) usable(i int, resource string, now time.Time) bool {
+	rate, ok := r.rates[i][resource]
+
+	return !ok || rate.Remaining >= r.threshold || !rate.Reset.After(now)
+}
+
+// rotatingTransport authenticates requests with the tokens of a RotatingTokenSource
+type rotatingTransport struct {
+	base   http.RoundTripper
+	source *RotatingTokenSource
+}
+
+func (t *rotatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := rateResource(req)
+
+	i := 0
+	if shareable(req) {
+		i = t.source.pick(resource, time.Now())
+	}
+
+	resp, err := t.roundTrip(req, i, resource)
+
+	// Private repositories may only be visible to the main token
+	if err == nil && i != 0 && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnauthorized) {
+		resp.Body.Close()
+		return t.roundTrip(req, 0, resource)
+	}
+
+	return resp, err
+}
+
+// roundTrip makes a request with the token at index i and records the reported rate limit
+func (t *rotatingTransport) roundTrip(req *http.Request, i int, resource string) (*http.Response, error) {
+	// Round trippers must not modify the request they are given
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.source.tokens[i])
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if reported := resp.Header.Get("X-RateLimit-Resource"); reported != "" {
+		resource = reported
+	}
+	t.source.Observe(i, resource, rateFromHeaders(resp.Header))
+
+	return resp, nil
+}
+
+// shareable reports whether a request may be made with any of the tokens: reads that do not
+// depend on the authenticated user
+func shareable(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/api/v3")
+	switch {
+	case path == "/user", strings.HasPrefix(path, "/user/"), strings.HasPrefix(path, "/notifications"):
+		return false
+	case strings.HasSuffix(path, "/subscription"):
+		return false
+	}
+
+	return true
+}
+
+// rateResource returns the rate limit resource a GitHub API request counts against
+func rateResource(req *http.Request) string {
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/graphql"):
+		return "graphql"
+	case strings.Contains(req.URL.Path, "/search/"):
+		return "search"
+	}
+
+	return "core"
+}
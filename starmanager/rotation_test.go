@@ -0,0 +1,120 @@
+package starmanager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingTokenSourcePick(t *testing.T) {
+	now := time.Now()
+	source := NewRotatingTokenSource([]string{"main", "second", "third"}, 100)
+
+	// Tokens are assumed to have requests left until told otherwise
+	assert.Equal(t, 0, source.pick("core", now))
+
+	source.Observe(0, "core", Rate{Remaining: 500, Reset: now.Add(time.Hour)})
+	assert.Equal(t, 0, source.pick("core", now))
+
+	source.Observe(0, "core", Rate{Remaining: 99, Reset: now.Add(time.Hour)})
+	assert.Equal(t, 1, source.pick("core", now))
+	assert.Equal(t, 1, source.pick("core", now))
+
+	// Other resources are counted separately
+	assert.Equal(t, 0, source.pick("search", now))
+
+	// Once all tokens run low, the one resetting first is used
+	source.Observe(1, "core", Rate{Remaining: 5, Reset: now.Add(30 * time.Minute)})
+	source.Observe(2, "core", Rate{Remaining: 5, Reset: now.Add(10 * time.Minute)})
+	assert.Equal(t, 2, source.pick("core", now))
+
+	// and kept once its rate limit has reset
+	assert.Equal(t, 2, source.pick("core", now.Add(20*time.Minute)))
+
+	token, err := source.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "third", token.AccessToken)
+}
+
+func TestRotatingTransport(t *testing.T) {
+	remaining := map[string]int{"main": 100, "second": 5000}
+	private := "/repos/gkze/private"
+	seen := []string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Authorization")[len("Bearer "):]
+		seen = append(seen, token)
+
+		remaining[token]--
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining[token]))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+
+		if r.URL.Path == private && token != "main" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source := NewRotatingTokenSource([]string{"main", "second"}, 100)
+	client := &http.Client{Transport: source.Transport(http.DefaultTransport)}
+
+	request := func(method, path string) int {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		assert.NoError(t, err)
+
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	// The main token drops below the threshold with the first response
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/repos/gkze/stars"))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/repos/gkze/stars/readme"))
+
+	// Requests depending on the user are always made with the main token
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/user/starred"))
+	assert.Equal(t, http.StatusOK, request(http.MethodPut, "/user/starred/gkze/stars"))
+
+	// Private repositories fall back to the main token
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, private))
+
+	assert.Equal(t, []string{"main", "second", "main", "main", "second", "main"}, seen)
+}
+
+func TestShareable(t *testing.T) {
+	testCases := []struct {
+		method    string
+		url       string
+		shareable bool
+	}{
+		{http.MethodGet, "https://api.github.com/repos/gkze/stars/releases", true},
+		{http.MethodGet, "https://api.github.com/users/gkze/starred", true},
+		{http.MethodGet, "https://ghe.example.com/api/v3/repos/gkze/user/readme", true},
+		{http.MethodGet, "https://api.github.com/user", false},
+		{http.MethodGet, "https://ghe.example.com/api/v3/user/starred", false},
+		{http.MethodGet, "https://api.github.com/notifications", false},
+		{http.MethodGet, "https://api.github.com/repos/gkze/stars/subscription", false},
+		{http.MethodPost, "https://api.github.com/graphql", false},
+		{http.MethodDelete, "https://api.github.com/user/starred/gkze/stars", false},
+	}
+
+	for _, tc := range testCases {
+		req, err := http.NewRequest(tc.method, tc.url, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.shareable, shareable(req), tc.url)
+	}
+}
+
+func TestGitHubTokens(t *testing.T) {
+	assert.Equal(t, []string{"a", "b", "c"}, splitTokens("a, b\nc,,"))
+
+	o := newOptions(WithTokens("main", "second", "second", "third"))
+	assert.Equal(t, []string{"main", "second", "third"}, o.githubTokens("main"))
+	assert.Equal(t, DefaultTokenRotationThreshold, o.rotateBelow)
+}
@@ -0,0 +1,226 @@
+package starmanager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/gkze/stars/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// RuleAction is what happens to a star matched by a cleanup rule
+type RuleAction string
+
+const (
+	// ActionUnstar removes matching stars
+	ActionUnstar RuleAction = "unstar"
+
+	// ActionKeep protects matching stars from every unstar rule
+	ActionKeep RuleAction = "keep"
+)
+
+// Condition describes the stars a rule matches. All set fields have to match; a condition
+// without any set fields is invalid.
+type Condition struct {
+	// Archived matches stars by their archive status
+	Archived *bool `yaml:"archived"`
+
+	// Gone matches stars by whether their repositories no longer exist
+	Gone *bool `yaml:"gone"`
+
+	// PushedOlderThan matches stars last pushed to longer ago than this age (e.g. 3y)
+	PushedOlderThan string `yaml:"pushed_older_than"`
+
+	// StarredOlderThan matches stars starred longer ago than this age (e.g. 6m)
+	StarredOlderThan string `yaml:"starred_older_than"`
+
+	// StargazersBelow matches stars with fewer stargazers than this
+	StargazersBelow int `yaml:"stargazers_below"`
+
+	// ScoreBelow matches stars with a health score below this, see Score
+	ScoreBelow int `yaml:"score_below"`
+
+	// Language matches stars written in this language, case insensitively
+	Language string `yaml:"language"`
+
+	// Topic matches stars with this topic. Cleanup also matches its aliases, see
+	// TopicAliases.
+	Topic string `yaml:"topic"`
+
+	// License matches stars with this SPDX license identifier, case insensitively
+	License string `yaml:"license"`
+}
+
+// Rule is a single cleanup rule of a Policy
+type Rule struct {
+	Name   string     `yaml:"name"`
+	Action RuleAction `yaml:"action"`
+	When   Condition  `yaml:"when"`
+}
+
+// Policy is a set of cleanup rules, usually loaded from a YAML file such as:
+//
+//	rules:
+//	  - name: never touch work projects
+//	    action: keep
+//	    when:
+//	      topic: work
+//	  - name: archived
+//	    action: unstar
+//	    when:
+//	      archived: true
+//	  - name: dead coffeescript
+//	    action: unstar
+//	    when:
+//	      pushed_older_than: 3y
+//	      stargazers_below: 50
+//	      language: coffeescript
+//
+// Keep rules take precedence over unstar rules regardless of their order.
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadPolicy reads and validates a YAML policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePolicy(data)
+}
+
+// ParsePolicy parses and validates a YAML policy
+func ParsePolicy(data []byte) (*Policy, error) {
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, err
+	}
+
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		if rule.Action != ActionUnstar && rule.Action != ActionKeep {
+			return nil, fmt.Errorf("%s: unknown action %q (expected %s or %s)", rule.Name, rule.Action, ActionUnstar, ActionKeep)
+		}
+
+		if rule.When == (Condition{}) {
+			return nil, fmt.Errorf("%s: a rule needs at least one condition", rule.Name)
+		}
+
+		for _, age := range []string{rule.When.PushedOlderThan, rule.When.StarredOlderThan} {
+			if age == "" {
+				continue
+			}
+
+			if _, err := utils.ParseAge(age, time.Now()); err != nil {
+				return nil, fmt.Errorf("%s: %v", rule.Name, err)
+			}
+		}
+	}
+
+	return policy, nil
+}
+
+// Evaluate returns the rule deciding the fate of the given star and whether the star should
+// be unstarred. If no rule matches, the returned rule is nil.
+func (p *Policy) Evaluate(star *Star, now time.Time) (*Rule, bool) {
+	var unstar *Rule
+
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !rule.When.Matches(star, now) {
+			continue
+		}
+
+		if rule.Action == ActionKeep {
+			return rule, false
+		}
+
+		if unstar == nil {
+			unstar = rule
+		}
+	}
+
+	return unstar, unstar != nil
+}
+
+// withTopicAliases returns a copy of the policy matching the canonical topics of its
+// conditions, to be evaluated against stars with canonical topics
+func (p *Policy) withTopicAliases(aliases TopicAliases) *Policy {
+	canonical := &Policy{Rules: make([]Rule, len(p.Rules))}
+	copy(canonical.Rules, p.Rules)
+
+	for i := range canonical.Rules {
+		if topic := canonical.Rules[i].When.Topic; topic != "" {
+			canonical.Rules[i].When.Topic = aliases.Canonical(topic)
+		}
+	}
+
+	return canonical
+}
+
+// Matches reports whether the star satisfies every set field of the condition
+func (c *Condition) Matches(star *Star, now time.Time) bool {
+	if c.Archived != nil && *c.Archived != star.Archived {
+		return false
+	}
+
+	if c.Gone != nil && *c.Gone != star.Gone {
+		return false
+	}
+
+	if !olderThan(star.PushedAt, c.PushedOlderThan, now) || !olderThan(star.StarredAt, c.StarredOlderThan, now) {
+		return false
+	}
+
+	if c.StargazersBelow > 0 && star.Stargazers >= c.StargazersBelow {
+		return false
+	}
+
+	if c.ScoreBelow > 0 {
+		if score, _ := Score(star, now); score >= c.ScoreBelow {
+			return false
+		}
+	}
+
+	if c.Language != "" && !strings.EqualFold(c.Language, star.Language) {
+		return false
+	}
+
+	if c.Topic != "" && !utils.StringInSlice(c.Topic, star.Topics) {
+		return false
+	}
+
+	if c.License != "" && !strings.EqualFold(c.License, star.License) {
+		return false
+	}
+
+	return true
+}
+
+// olderThan reports whether t lies further back than the given age, which is always the
+// case if no age is set and never if t is unknown (zero), as for the starred dates of
+// providers that do not report them. Ages are validated when the policy is parsed.
+func olderThan(t time.Time, age string, now time.Time) bool {
+	if age == "" {
+		return true
+	}
+
+	if t.IsZero() {
+		return false
+	}
+
+	cutoff, err := utils.ParseAge(age, now)
+	if err != nil {
+		return false
+	}
+
+	return t.Before(cutoff)
+}
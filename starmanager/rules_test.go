@@ -0,0 +1,123 @@
+package starmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPolicy = `
+rules:
+  - name: archived
+    action: unstar
+    when:
+      archived: true
+  - name: dead coffeescript
+    action: unstar
+    when:
+      pushed_older_than: 3y
+      stargazers_below: 50
+      language: CoffeeScript
+  - name: unhealthy rust
+    action: unstar
+    when:
+      language: rust
+      score_below: 40
+  - name: starred long ago
+    action: unstar
+    when:
+      starred_older_than: 3y
+  - name: work
+    action: keep
+    when:
+      topic: work
+`
+
+func TestPolicyEvaluate(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	assert.NoError(t, err)
+
+	now := time.Now()
+	old := now.AddDate(-4, 0, 0)
+
+	testCases := []struct {
+		star   Star
+		rule   string
+		unstar bool
+	}{
+		{star: Star{Archived: true}, rule: "archived", unstar: true},
+		{star: Star{Archived: true, Topics: []string{"work"}}, rule: "work", unstar: false},
+		{star: Star{Language: "coffeescript", PushedAt: old, Stargazers: 10}, rule: "dead coffeescript", unstar: true},
+		{star: Star{Language: "coffeescript", PushedAt: old, Stargazers: 100}, rule: "", unstar: false},
+		{star: Star{Language: "coffeescript", PushedAt: now, Stargazers: 10}, rule: "", unstar: false},
+		{star: Star{Language: "go", PushedAt: old}, rule: "", unstar: false},
+		{star: Star{Language: "go", StarredAt: old}, rule: "starred long ago", unstar: true},
+		{
+			star:   Star{Language: "rust", PushedAt: old, ReleasedAt: old, OpenIssues: 50, Stargazers: 100},
+			rule:   "unhealthy rust",
+			unstar: true,
+		},
+		{star: Star{Language: "rust", PushedAt: now, OpenIssues: 50, Stargazers: 100}, rule: "", unstar: false},
+	}
+
+	for _, tc := range testCases {
+		rule, unstar := policy.Evaluate(&tc.star, now)
+		assert.Equal(t, tc.unstar, unstar)
+
+		if tc.rule == "" {
+			assert.Nil(t, rule)
+		} else {
+			assert.Equal(t, tc.rule, rule.Name)
+		}
+	}
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	testCases := []struct {
+		policy string
+		err    string
+	}{
+		{
+			policy: "rules:\n  - action: delete\n    when:\n      archived: true\n",
+			err:    `rule 1: unknown action "delete" (expected unstar or keep)`,
+		},
+		{
+			policy: "rules:\n  - name: everything\n    action: unstar\n",
+			err:    "everything: a rule needs at least one condition",
+		},
+		{
+			policy: "rules:\n  - action: unstar\n    when:\n      pushed_older_than: 3x\n",
+			err:    `rule 1: invalid age unit in "3x" (expected one of d, w, m, y)`,
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := ParsePolicy([]byte(tc.policy))
+		assert.EqualError(t, err, tc.err)
+	}
+
+	_, err := ParsePolicy([]byte("rules:\n  - action: unstar\n    when:\n      colour: red\n"))
+	assert.Error(t, err)
+}
+
+func TestCleanupPolicy(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	sm.Providers = []Provider{&fakeProvider{name: "github.com"}}
+
+	policy, err := ParsePolicy([]byte(testPolicy))
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/archived", Archived: true, PushedAt: time.Now()}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/work", Archived: true, Topics: []string{"work"}}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/old", PushedAt: time.Now().AddDate(-5, 0, 0)}))
+
+	result, err := sm.Cleanup(context.Background(), CleanupOptions{Policy: policy, DryRun: true})
+	assert.NoError(t, err)
+	assert.Len(t, result.Candidates, 1)
+	assert.Equal(t, "https://github.com/a/archived", result.Candidates[0].Star.URL)
+	assert.Equal(t, "archived", result.Candidates[0].Rule)
+}
@@ -0,0 +1,371 @@
+package starmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/asdine/storm"
+	log "github.com/sirupsen/logrus"
+)
+
+// SearchNode - the name of the storm node holding the full-text search index
+const SearchNode string = "search"
+
+// searchMetaID is the ID of the record describing the search index
+const searchMetaID string = "index"
+
+// Posting lists the stars a term of the search index occurs in
+type Posting struct {
+	Term string `storm:"id"`
+	URLs []string
+}
+
+// SearchMeta describes the state of the search index
+type SearchMeta struct {
+	ID      string `storm:"id"`
+	Stars   int
+	BuiltAt time.Time
+}
+
+// searchClause is a single part of a search query: a term, a quoted phrase, a prefix (term
+// ending in "*") or a local tag ("tag:work")
+type searchClause struct {
+	terms  []string
+	prefix bool
+	tag    string
+}
+
+// search returns the storm node holding the search index
+func (s *StarManager) search() storm.Node {
+	return s.DB.From(SearchNode)
+}
+
+// tokenize splits text into lower case words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// searchFields returns the tokens of every searchable field of a star. Phrases only match
+// within a single field.
+func searchFields(star *Star) [][]string {
+	fields := [][]string{tokenize(star.Description), tokenize(star.Language)}
+
+	if owner, repo, err := star.OwnerRepo(); err == nil {
+		fields = append(fields, tokenize(owner+" "+repo))
+	} else {
+		fields = append(fields, tokenize(star.URL))
+	}
+
+	for _, topic := range star.Topics {
+		fields = append(fields, tokenize(topic))
+	}
+
+	return fields
+}
+
+// parseSearchQuery parses a query of space separated terms, quoted phrases and prefixes,
+// all of which have to match
+func parseSearchQuery(query string) ([]searchClause, error) {
+	clauses := []searchClause{}
+
+	for rest := strings.TrimSpace(query); rest != ""; rest = strings.TrimSpace(rest) {
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated phrase in %q", query)
+			}
+
+			if terms := tokenize(rest[1 : end+1]); len(terms) > 0 {
+				clauses = append(clauses, searchClause{terms: terms})
+			}
+
+			rest = rest[end+2:]
+			continue
+		}
+
+		word := rest
+		if end := strings.IndexAny(rest, " \t\""); end >= 0 {
+			word = rest[:end]
+		}
+		rest = rest[len(word):]
+
+		if strings.HasPrefix(strings.ToLower(word), "tag:") && len(word) > len("tag:") {
+			clauses = append(clauses, searchClause{tag: word[len("tag:"):]})
+			continue
+		}
+
+		prefix := strings.HasSuffix(word, "*")
+		terms := tokenize(word)
+		if len(terms) == 0 {
+			continue
+		}
+
+		// Words such as "rate-limiter" are searched as phrases since they are indexed as
+		// separate terms
+		clauses = append(clauses, searchClause{terms: terms, prefix: prefix})
+	}
+
+	if len(clauses) == 0 {
+		return nil, errors.New("empty search query")
+	}
+
+	return clauses, nil
+}
+
+// matches returns how often the clause occurs in the fields of a star with the given
+// annotation, which may be nil
+func (c searchClause) matches(fields [][]string, annotation *Annotation) int {
+	if c.tag != "" {
+		if hasTags(annotation, []string{c.tag}) {
+			return 1
+		}
+
+		return 0
+	}
+
+	count := 0
+
+	for _, tokens := range fields {
+		for i := 0; i+len(c.terms) <= len(tokens); i++ {
+			matched := true
+
+			for j, term := range c.terms {
+				token := tokens[i+j]
+				last := j == len(c.terms)-1
+
+				if token != term && !(last && c.prefix && strings.HasPrefix(token, term)) {
+					matched = false
+					break
+				}
+			}
+
+			if matched {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// Reindex rebuilds the search index from the cached stars
+func (s *StarManager) Reindex(ctx context.Context) error {
+	stars, err := s.store().All()
+	if err != nil {
+		return err
+	}
+
+	postings := map[string]map[string]bool{}
+	for _, star := range stars {
+		for _, tokens := range searchFields(star) {
+			for _, token := range tokens {
+				if postings[token] == nil {
+					postings[token] = map[string]bool{}
+				}
+
+				postings[token][star.URL] = true
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tx, err := s.search().Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := dropBucket(tx, &Posting{}); err != nil {
+		return err
+	}
+
+	for term, urls := range postings {
+		posting := &Posting{Term: term, URLs: make([]string, 0, len(urls))}
+		for url := range urls {
+			posting.URLs = append(posting.URLs, url)
+		}
+
+		if err := tx.Save(posting); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Save(&SearchMeta{ID: searchMetaID, Stars: len(stars), BuiltAt: time.Now()}); err != nil {
+		return err
+	}
+
+	log.Printf("Indexed %d terms of %d stars", len(postings), len(stars))
+	return tx.Commit()
+}
+
+// searchIndexStale reports whether the search index is missing or was built from a
+// different set of stars
+func (s *StarManager) searchIndexStale() (bool, error) {
+	meta := &SearchMeta{}
+	if err := s.search().One("ID", searchMetaID, meta); err != nil {
+		if err == storm.ErrNotFound {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	count, err := s.store().Count()
+	if err != nil {
+		return false, err
+	}
+
+	return count != meta.Stars, nil
+}
+
+// candidates returns the URLs of the stars the index says contain the clause
+func (s *StarManager) candidates(clause searchClause, annotations map[string]*Annotation) (map[string]bool, error) {
+	urls := map[string]bool{}
+
+	if clause.tag != "" {
+		for url, annotation := range annotations {
+			if hasTags(annotation, []string{clause.tag}) {
+				urls[url] = true
+			}
+		}
+
+		return urls, nil
+	}
+
+	for i, term := range clause.terms {
+		postings := []*Posting{}
+
+		var err error
+		if clause.prefix && i == len(clause.terms)-1 {
+			err = s.search().Prefix("Term", term, &postings)
+		} else {
+			posting := &Posting{}
+			err = s.search().One("Term", term, posting)
+			postings = append(postings, posting)
+		}
+
+		if err == storm.ErrNotFound {
+			return map[string]bool{}, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		termURLs := map[string]bool{}
+		for _, p := range postings {
+			for _, url := range p.URLs {
+				if i == 0 || urls[url] {
+					termURLs[url] = true
+				}
+			}
+		}
+
+		urls = termURLs
+	}
+
+	return urls, nil
+}
+
+// Search returns the cached stars matching a full-text query over their descriptions,
+// topics, languages and names, best matches first. All space separated terms have to
+// match; "quoted phrases" match consecutive words, terms ending in "*" match prefixes and
+// "tag:name" matches stars with a local tag.
+func (s *StarManager) Search(ctx context.Context, query string) ([]Star, error) {
+	clauses, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	stale, err := s.searchIndexStale()
+	if err != nil {
+		return nil, err
+	}
+
+	if stale {
+		if err := s.Reindex(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	annotations, err := s.GetAnnotations()
+	if err != nil {
+		return nil, err
+	}
+
+	var urls map[string]bool
+	for _, clause := range clauses {
+		clauseURLs, err := s.candidates(clause, annotations)
+		if err != nil {
+			return nil, err
+		}
+
+		if urls == nil {
+			urls = clauseURLs
+			continue
+		}
+
+		for url := range urls {
+			if !clauseURLs[url] {
+				delete(urls, url)
+			}
+		}
+	}
+
+	stars := []Star{}
+	scores := map[string]int{}
+
+	// The index only narrows down the candidates; stars are matched against their current
+	// contents since they may have changed after the index was built
+	for url := range urls {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		star, err := s.store().Get(url)
+		if err != nil {
+			if err == storm.ErrNotFound {
+				continue
+			}
+
+			return nil, err
+		}
+
+		fields := searchFields(star)
+		score := 0
+		for _, clause := range clauses {
+			n := clause.matches(fields, annotations[url])
+			if n == 0 {
+				score = 0
+				break
+			}
+
+			score += n
+		}
+
+		if score > 0 {
+			scores[url] = score
+			stars = append(stars, *star)
+		}
+	}
+
+	sort.Slice(stars, func(i, j int) bool {
+		if scores[stars[i].URL] != scores[stars[j].URL] {
+			return scores[stars[i].URL] > scores[stars[j].URL]
+		}
+
+		return stars[i].Stargazers > stars[j].Stargazers
+	})
+
+	return stars, nil
+}
@@ -0,0 +1,61 @@
+package starmanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	stars := []Star{
+		{URL: "https://github.com/a/throttled", Description: "A rate limiter library for Go", Language: "go", Stargazers: 10},
+		{URL: "https://github.com/a/governor", Description: "Limits the rate of requests", Topics: []string{"rate-limiting"}, Stargazers: 100},
+		{URL: "https://github.com/b/ratatui", Description: "Terminal user interfaces", Language: "rust", Stargazers: 50},
+	}
+
+	for i := range stars {
+		assert.NoError(t, sm.DB.Save(&stars[i]))
+	}
+
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{query: "rate", expected: []string{"https://github.com/a/governor", "https://github.com/a/throttled"}},
+		{query: "RATE go", expected: []string{"https://github.com/a/throttled"}},
+		{query: `"rate limiter"`, expected: []string{"https://github.com/a/throttled"}},
+		{query: `"limiter rate"`, expected: []string{}},
+		{query: "rat*", expected: []string{"https://github.com/a/governor", "https://github.com/b/ratatui", "https://github.com/a/throttled"}},
+		{query: "rate-limiting", expected: []string{"https://github.com/a/governor"}},
+		{query: "ratatui", expected: []string{"https://github.com/b/ratatui"}},
+		{query: "nothing", expected: []string{}},
+	}
+
+	for _, tc := range testCases {
+		results, err := sm.Search(ctx, tc.query)
+		assert.NoError(t, err)
+
+		urls := []string{}
+		for _, star := range results {
+			urls = append(urls, star.URL)
+		}
+		assert.Equal(t, tc.expected, urls, tc.query)
+	}
+
+	// Adding a star makes the index stale, so it is rebuilt on the next search
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/c/leaky", Description: "Leaky bucket rate limiter"}))
+	results, err := sm.Search(ctx, "leaky")
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	_, err = sm.Search(ctx, `"unterminated`)
+	assert.Error(t, err)
+
+	_, err = sm.Search(ctx, "  ")
+	assert.Error(t, err)
+}
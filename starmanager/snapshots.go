@@ -0,0 +1,403 @@
+package starmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// SnapshotNode - the name of the storm node holding the snapshots taken on every sync
+	SnapshotNode string = "snapshots"
+
+	// DefaultSnapshotRetention - how long snapshots are kept unless configured otherwise
+	DefaultSnapshotRetention time.Duration = 2 * 365 * 24 * time.Hour
+
+	// minSnapshots is the number of latest snapshots that are never pruned, so that Diff
+	// still works after a long break between syncs
+	minSnapshots int = 2
+)
+
+// SnapshotEntry is the state of a single star at the time of a snapshot
+type SnapshotEntry struct {
+	RepoID     string
+	Stargazers int
+	PushedAt   time.Time
+	Archived   bool
+}
+
+// Snapshot records the stargazer counts and push dates of all cached stars at a point in
+// time, so that they can be compared over time
+type Snapshot struct {
+	ID      int       `storm:"id,increment"`
+	TakenAt time.Time `storm:"index"`
+
+	// Stars maps star URLs to their state
+	Stars map[string]SnapshotEntry
+}
+
+// TrendKind - what a trend query looks for
+type TrendKind string
+
+const (
+	// TrendGained - stars that gained the most stargazers
+	TrendGained TrendKind = "gained"
+
+	// TrendQuiet - stars that have not been pushed to
+	TrendQuiet TrendKind = "quiet"
+)
+
+// TrendOptions - the parameters of a trend query
+type TrendOptions struct {
+	Kind TrendKind
+
+	// Since is the start of the period to compare. The latest snapshot taken at or before
+	// it is used as the baseline, or the oldest snapshot if there is none.
+	Since time.Time
+
+	// Count limits the number of trends returned, 0 returns all
+	Count int
+}
+
+// Trend is the change of a single star between the baseline snapshot and now
+type Trend struct {
+	Star Star `json:"star"`
+
+	// Baseline is when the snapshot the star is compared against was taken
+	Baseline time.Time `json:"baseline"`
+
+	// Before and After are the stargazer counts at the baseline and now
+	Before int `json:"before"`
+	After  int `json:"after"`
+}
+
+// Gained returns the number of stargazers gained since the baseline, negative if some
+// were lost
+func (t *Trend) Gained() int {
+	return t.After - t.Before
+}
+
+// Rename is a star whose repository was renamed or transferred
+type Rename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// SyncDiff is what changed between two snapshots
+type SyncDiff struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+
+	// Starred are the URLs of the stars that were added
+	Starred []string `json:"starred"`
+
+	// Removed are the URLs of the stars that disappeared, because they were unstarred or
+	// their repositories deleted. Unstarred stars only disappear from the cache when
+	// syncing with pruning.
+	Removed []string `json:"removed"`
+
+	// Archived are the URLs of the stars whose repositories were archived
+	Archived []string `json:"archived"`
+
+	// Renamed are the stars whose repositories were renamed or transferred
+	Renamed []Rename `json:"renamed"`
+}
+
+// Empty reports whether nothing changed
+func (d *SyncDiff) Empty() bool {
+	return len(d.Starred)+len(d.Removed)+len(d.Archived)+len(d.Renamed) == 0
+}
+
+// snapshots returns the storm node holding snapshots
+func (s *StarManager) snapshots() storm.Node {
+	return s.DB.From(SnapshotNode)
+}
+
+// TakeSnapshot records the current stargazer counts and push dates of all cached stars, and
+// prunes the snapshots that are older than SnapshotRetention along with it. The latest
+// snapshots Diff compares are always kept.
+func (s *StarManager) TakeSnapshot(ctx context.Context) (*Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stars, err := s.store().All()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{TakenAt: time.Now(), Stars: make(map[string]SnapshotEntry, len(stars))}
+	for _, star := range stars {
+		snapshot.Stars[star.URL] = SnapshotEntry{
+			RepoID:     star.RepoID,
+			Stargazers: star.Stargazers,
+			PushedAt:   star.PushedAt,
+			Archived:   star.Archived,
+		}
+	}
+
+	tx, err := s.snapshots().Begin(true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.Save(snapshot); err != nil {
+		return nil, err
+	}
+
+	pruned, err := s.pruneSnapshots(tx, snapshot.TakenAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Took snapshot of %d stars, pruned %d old snapshots", len(stars), pruned)
+	return snapshot, nil
+}
+
+// pruneSnapshots deletes the snapshots older than SnapshotRetention as of now, except for
+// the latest minSnapshots ones, and returns how many were deleted
+func (s *StarManager) pruneSnapshots(tx storm.Node, now time.Time) (int, error) {
+	if s.SnapshotRetention <= 0 {
+		return 0, nil
+	}
+
+	snapshots := []*Snapshot{}
+	err := tx.Select(q.Lt("TakenAt", now.Add(-s.SnapshotRetention))).OrderBy("TakenAt").Find(&snapshots)
+	if err == storm.ErrNotFound {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	total, err := tx.Count(&Snapshot{})
+	if err != nil {
+		return 0, err
+	}
+
+	if keep := total - minSnapshots; len(snapshots) > keep {
+		if keep < 0 {
+			keep = 0
+		}
+
+		snapshots = snapshots[:keep]
+	}
+
+	for _, snapshot := range snapshots {
+		if err := tx.DeleteStruct(snapshot); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(snapshots), nil
+}
+
+// Snapshots returns all snapshots, oldest first
+func (s *StarManager) Snapshots(ctx context.Context) ([]*Snapshot, error) {
+	snapshots := []*Snapshot{}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	err := s.snapshots().Select().OrderBy("TakenAt").Find(&snapshots)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+// latestSnapshot returns the most recent snapshot, or nil if none was taken yet
+func (s *StarManager) latestSnapshot() (*Snapshot, error) {
+	snapshots := []*Snapshot{}
+
+	err := s.snapshots().Select().OrderBy("TakenAt").Reverse().Limit(1).Find(&snapshots)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshots[0], nil
+}
+
+// baseline returns the latest snapshot taken at or before the given time, falling back to
+// the oldest snapshot
+func (s *StarManager) baseline(since time.Time) (*Snapshot, error) {
+	snapshots := []*Snapshot{}
+
+	err := s.snapshots().
+		Select(q.Lte("TakenAt", since)).
+		OrderBy("TakenAt").
+		Reverse().
+		Limit(1).
+		Find(&snapshots)
+	if err == storm.ErrNotFound {
+		err = s.snapshots().Select().OrderBy("TakenAt").Limit(1).Find(&snapshots)
+	}
+
+	if err == storm.ErrNotFound {
+		return nil, errors.New("no snapshots, sync stars to take one")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshots[0], nil
+}
+
+// Trends compares the cached stars against the snapshot taken at the start of a period.
+// TrendGained returns the stars that gained the most stargazers, TrendQuiet returns the
+// stars that have not been pushed to since the start of the period, longest quiet first.
+// Stars starred after the baseline snapshot are left out.
+func (s *StarManager) Trends(ctx context.Context, opts TrendOptions) ([]*Trend, error) {
+	if opts.Kind == "" {
+		opts.Kind = TrendGained
+	}
+
+	if opts.Kind != TrendGained && opts.Kind != TrendQuiet {
+		return nil, fmt.Errorf("unknown trend %q", opts.Kind)
+	}
+
+	baseline, err := s.baseline(opts.Since)
+	if err != nil {
+		return nil, err
+	}
+
+	quietSince := opts.Since
+	if quietSince.IsZero() {
+		quietSince = baseline.TakenAt
+	}
+
+	stars, err := s.store().All()
+	if err != nil {
+		return nil, err
+	}
+
+	trends := []*Trend{}
+	for _, star := range stars {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		entry, ok := baseline.Stars[star.URL]
+		if !ok {
+			continue
+		}
+
+		if opts.Kind == TrendQuiet && star.PushedAt.After(quietSince) {
+			continue
+		}
+
+		trends = append(trends, &Trend{
+			Star:     *star,
+			Baseline: baseline.TakenAt,
+			Before:   entry.Stargazers,
+			After:    star.Stargazers,
+		})
+	}
+
+	sort.SliceStable(trends, func(i, j int) bool {
+		if opts.Kind == TrendQuiet {
+			return trends[i].Star.PushedAt.Before(trends[j].Star.PushedAt)
+		}
+
+		return trends[i].Gained() > trends[j].Gained()
+	})
+
+	if opts.Count > 0 && len(trends) > opts.Count {
+		trends = trends[:opts.Count]
+	}
+
+	return trends, nil
+}
+
+// diffSnapshots compares two snapshots. Stars are matched by repository ID where both
+// snapshots have one, so that renamed repositories are not reported as removed and added.
+func diffSnapshots(before, after *Snapshot) *SyncDiff {
+	diff := &SyncDiff{
+		Since:    before.TakenAt,
+		Until:    after.TakenAt,
+		Starred:  []string{},
+		Removed:  []string{},
+		Archived: []string{},
+		Renamed:  []Rename{},
+	}
+
+	beforeIDs := map[string]string{}
+	for url, entry := range before.Stars {
+		if entry.RepoID != "" {
+			beforeIDs[entry.RepoID] = url
+		}
+	}
+
+	renamed := map[string]bool{}
+	for url, entry := range after.Stars {
+		previous, ok := before.Stars[url]
+
+		if !ok {
+			from, found := beforeIDs[entry.RepoID]
+			if entry.RepoID == "" || !found {
+				diff.Starred = append(diff.Starred, url)
+				continue
+			}
+
+			renamed[from] = true
+			diff.Renamed = append(diff.Renamed, Rename{From: from, To: url})
+			previous = before.Stars[from]
+		}
+
+		if entry.Archived && !previous.Archived {
+			diff.Archived = append(diff.Archived, url)
+		}
+	}
+
+	for url := range before.Stars {
+		if _, ok := after.Stars[url]; !ok && !renamed[url] {
+			diff.Removed = append(diff.Removed, url)
+		}
+	}
+
+	sort.Strings(diff.Starred)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Archived)
+	sort.Slice(diff.Renamed, func(i, j int) bool { return diff.Renamed[i].From < diff.Renamed[j].From })
+
+	return diff
+}
+
+// Diff reports what changed between the last two syncs
+func (s *StarManager) Diff(ctx context.Context) (*SyncDiff, error) {
+	snapshots := []*Snapshot{}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	err := s.snapshots().Select().OrderBy("TakenAt").Reverse().Limit(2).Find(&snapshots)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	if len(snapshots) < 2 {
+		return nil, errors.New("stars have to be synced twice to see what changed")
+	}
+
+	return diffSnapshots(snapshots[1], snapshots[0]), nil
+}
@@ -0,0 +1,159 @@
+package starmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrends(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := sm.Trends(ctx, TrendOptions{})
+	assert.Error(t, err)
+
+	old := &Snapshot{
+		TakenAt: now.AddDate(0, -7, 0),
+		Stars: map[string]SnapshotEntry{
+			"https://github.com/a/rising":  {Stargazers: 10},
+			"https://github.com/a/steady":  {Stargazers: 50},
+			"https://github.com/a/falling": {Stargazers: 20},
+		},
+	}
+	recent := &Snapshot{
+		TakenAt: now.AddDate(0, -1, 0),
+		Stars: map[string]SnapshotEntry{
+			"https://github.com/a/rising":  {Stargazers: 90},
+			"https://github.com/a/steady":  {Stargazers: 50},
+			"https://github.com/a/falling": {Stargazers: 20},
+		},
+	}
+	assert.NoError(t, sm.snapshots().Save(recent))
+	assert.NoError(t, sm.snapshots().Save(old))
+
+	stars := []Star{
+		{URL: "https://github.com/a/rising", Stargazers: 100, PushedAt: now.AddDate(0, 0, -1)},
+		{URL: "https://github.com/a/steady", Stargazers: 55, PushedAt: now.AddDate(-1, 0, 0)},
+		{URL: "https://github.com/a/falling", Stargazers: 15, PushedAt: now.AddDate(0, -8, 0)},
+		{URL: "https://github.com/a/new", Stargazers: 1000},
+	}
+	for i := range stars {
+		assert.NoError(t, sm.DB.Save(&stars[i]))
+	}
+
+	snapshots, err := sm.Snapshots(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 2)
+	assert.True(t, snapshots[0].TakenAt.Before(snapshots[1].TakenAt))
+
+	trends, err := sm.Trends(ctx, TrendOptions{Since: now.AddDate(0, -6, 0)})
+	assert.NoError(t, err)
+	assert.Len(t, trends, 3)
+	assert.Equal(t, "https://github.com/a/rising", trends[0].Star.URL)
+	assert.Equal(t, 90, trends[0].Gained())
+	assert.Equal(t, -5, trends[2].Gained())
+
+	// The most recent snapshot at or before Since is the baseline
+	trends, err = sm.Trends(ctx, TrendOptions{Since: now.AddDate(0, 0, -7), Count: 1})
+	assert.NoError(t, err)
+	assert.Len(t, trends, 1)
+	assert.Equal(t, 10, trends[0].Gained())
+
+	trends, err = sm.Trends(ctx, TrendOptions{Kind: TrendQuiet, Since: now.AddDate(0, -6, 0)})
+	assert.NoError(t, err)
+	assert.Len(t, trends, 2)
+	assert.Equal(t, "https://github.com/a/steady", trends[0].Star.URL)
+	assert.Equal(t, "https://github.com/a/falling", trends[1].Star.URL)
+
+	_, err = sm.Trends(ctx, TrendOptions{Kind: "hot"})
+	assert.Error(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	before := &Snapshot{
+		TakenAt: now.Add(-time.Hour),
+		Stars: map[string]SnapshotEntry{
+			"https://github.com/a/kept":     {RepoID: "R_1"},
+			"https://github.com/a/old-name": {RepoID: "R_2"},
+			"https://github.com/a/gone":     {RepoID: "R_3"},
+			"https://github.com/a/retired":  {RepoID: "R_4"},
+		},
+	}
+	after := &Snapshot{
+		TakenAt: now,
+		Stars: map[string]SnapshotEntry{
+			"https://github.com/a/kept":     {RepoID: "R_1"},
+			"https://github.com/b/new-name": {RepoID: "R_2", Archived: true},
+			"https://github.com/a/retired":  {RepoID: "R_4", Archived: true},
+			"https://github.com/a/added":    {RepoID: "R_5"},
+			"https://gitlab.com/c/no-id":    {},
+		},
+	}
+
+	_, err := sm.Diff(ctx)
+	assert.Error(t, err)
+
+	assert.NoError(t, sm.snapshots().Save(after))
+	assert.NoError(t, sm.snapshots().Save(before))
+
+	diff, err := sm.Diff(ctx)
+	assert.NoError(t, err)
+	assert.False(t, diff.Empty())
+	assert.Equal(t, before.TakenAt.Unix(), diff.Since.Unix())
+	assert.Equal(t, []string{"https://github.com/a/added", "https://gitlab.com/c/no-id"}, diff.Starred)
+	assert.Equal(t, []string{"https://github.com/a/gone"}, diff.Removed)
+	assert.Equal(t, []string{"https://github.com/a/retired", "https://github.com/b/new-name"}, diff.Archived)
+	assert.Equal(t, []Rename{{From: "https://github.com/a/old-name", To: "https://github.com/b/new-name"}}, diff.Renamed)
+
+	assert.True(t, diffSnapshots(after, after).Empty())
+}
+
+func TestTakeSnapshotPrunes(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, months := range []int{-30, -25, -13, -1} {
+		assert.NoError(t, sm.snapshots().Save(&Snapshot{TakenAt: now.AddDate(0, months, 0)}))
+	}
+
+	// All snapshots are kept without retention
+	_, err := sm.TakeSnapshot(ctx)
+	assert.NoError(t, err)
+
+	snapshots, err := sm.Snapshots(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 5)
+
+	sm.SnapshotRetention = DefaultSnapshotRetention
+	_, err = sm.TakeSnapshot(ctx)
+	assert.NoError(t, err)
+
+	snapshots, err = sm.Snapshots(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 4)
+	assert.True(t, snapshots[0].TakenAt.After(now.AddDate(-2, 0, 0)))
+
+	// The latest snapshots are kept however old they are
+	sm.SnapshotRetention = time.Nanosecond
+	_, err = sm.TakeSnapshot(ctx)
+	assert.NoError(t, err)
+
+	snapshots, err = sm.Snapshots(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, minSnapshots)
+}
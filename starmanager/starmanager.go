@@ -3,6 +3,7 @@ package starmanager
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/asdine/storm"
 	"github.com/asdine/storm/q"
 	"github.com/gkze/stars/auth"
@@ -10,12 +11,13 @@ import (
 	"github.com/google/go-github/v25/github"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
-	"golang.org/x/oauth2"
 	"math/rand"
+	"net/http"
 	"net/url"
 	"os"
 	"os/user"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -26,7 +28,8 @@ const (
 	// GitHub - the GitHub API host
 	GitHub string = "api.github.com"
 
-	// CachePath - the path to the cache db file
+	// CachePath - the directory of the cache db file within the home directory, used unless
+	// XDG_CACHE_HOME is set
 	CachePath string = ".cache"
 
 	// CacheFile - the filename of the db cache
@@ -38,50 +41,170 @@ const (
 
 // Star represents the starred project that is saved locally
 type Star struct {
-	PushedAt    time.Time `storm:"index"`
-	URL         string    `storm:"id,index,unique"`
-	Language    string    `storm:"index"`
+	Provider  string    `storm:"index"`
+	PushedAt  time.Time `storm:"index"`
+	StarredAt time.Time `storm:"index"`
+	URL       string    `storm:"id,index,unique"`
+	Language  string    `storm:"index"`
+
+	// Owner is the user or organization the repository belongs to and RepoName its name,
+	// both as in the URL. They are parsed from the URL when the star is saved.
+	Owner    string `storm:"index"`
+	RepoName string `storm:"index"`
+
 	Stargazers  int
 	Archived    bool     `storm:"index"`
 	Description string   `storm:"index"`
 	Topics      []string `storm:"index"`
+
+	// License is the SPDX identifier of the repository's license (e.g. "GPL-3.0"), empty if
+	// the provider did not detect one
+	License string `storm:"index"`
+
+	// RepoID is the provider's ID of the repository, which unlike the URL stays the same
+	// when it is renamed or transferred
+	RepoID string `storm:"index"`
+
+	// Gone is set by Reconcile if the repository no longer exists
+	Gone bool `storm:"index"`
+
+	// Fork is set if the repository is a fork, and Parent is the URL of the repository it
+	// was forked from. GitHub's REST API does not report the parent when listing stars, see
+	// FetchParents.
+	Fork   bool `storm:"index"`
+	Parent string
+
+	// OpenIssues is the number of open issues, including pull requests on GitHub
+	OpenIssues int
+
+	// LatestRelease is the tag of the latest release and ReleasedAt when it was published,
+	// both empty if there is none or neither the provider nor FetchReleases reported it
+	LatestRelease string
+	ReleasedAt    time.Time `storm:"index"`
+
+	// Activity is the weekly commit count over the last year, oldest first. It is only
+	// populated by the opt-in activity enrichment.
+	Activity   []int
+	ActivityAt time.Time
+
+	// Languages maps every language of the repository to the number of bytes written in
+	// it. It is only populated by the opt-in language enrichment.
+	Languages   map[string]int
+	LanguagesAt time.Time
+
+	// Lists are the names of the GitHub lists the star is on, as of the last list sync
+	Lists []string
+}
+
+// OwnerRepo returns the owner and name of the repository, parsing them from the URL for
+// stars that were not saved with them
+func (s *Star) OwnerRepo() (string, string, error) {
+	if s.Owner != "" && s.RepoName != "" {
+		return s.Owner, s.RepoName, nil
+	}
+
+	return ownerRepo(s.URL)
+}
+
+// setOwnerRepo records the owner and name of the repository parsed from the URL, clearing
+// them if it is not a repository URL
+func (s *Star) setOwnerRepo() {
+	s.Owner, s.RepoName, _ = ownerRepo(s.URL)
+}
+
+// ProviderName returns the name of the provider the star was fetched from. Stars cached
+// before providers were introduced all come from GitHub or GitHub Enterprise, whose web host
+// is the host of their URL.
+func (s *Star) ProviderName() string {
+	if s.Provider != "" {
+		return s.Provider
+	}
+
+	if u, err := url.Parse(s.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+
+	return ProviderHosts["github"]
 }
 
 // StarManager is the central object used to manage stars for a GitHub account
 type StarManager struct {
+	Host     string
 	Username string
 	Password string
-	Context  context.Context
 	Client   *github.Client
 	DB       *storm.DB
+
+	// HTTPClient makes the requests that do not go to a provider, such as package registry
+	// lookups, with the configured proxy, timeout and retries. http.DefaultClient if nil.
+	HTTPClient *http.Client
+
+	// Store keeps the cached stars, in DB if nil
+	Store StarStore
+
+	// Providers are the forges stars are synced from, the first being GitHub
+	Providers []Provider
+
+	// Concurrency is the maximum number of concurrent requests, DefaultConcurrency if unset
+	Concurrency int
+
+	// DryRun makes AddStar, RemoveStar, Cleanup and Reconcile only log the changes they would
+	// make
+	DryRun bool
+
+	// TopicAliases are applied to the topics of stars when they are saved, counted and
+	// matched, DefaultTopicAliases if nil
+	TopicAliases TopicAliases
+
+	// SnapshotRetention is how long snapshots are kept, pruning older ones whenever a snapshot
+	// is taken. Zero keeps all snapshots.
+	SnapshotRetention time.Duration
 }
 
 // New - initialize a new starmanager
-func New() (*StarManager, error) {
-	cfg, err := auth.NewConfig()
+func New(opts ...Option) (*StarManager, error) {
+	o := newOptions(opts...)
+
+	credentials, err := auth.NewDefault(o.host)
 	if err != nil {
 		return nil, err
 	}
 
-	netrcAuth, err := auth.NewNetrc(cfg)
-	username, password, err := netrcAuth.GetAuth(GitHub)
+	username, password, err := credentials.GetAuth(o.host)
 	if err != nil {
 		return nil, err
 	}
-	ctx := context.Background()
-	client := github.NewClient(oauth2.NewClient(
-		ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: password}),
-	))
 
-	currentUser, err := user.Current()
+	httpClient, err := o.httpClient()
 	if err != nil {
-		log.Printf("Could not determine the current user! %v", err.Error())
+		return nil, err
+	}
 
+	ctx := context.Background()
+	var client *github.Client
+	if tokens := o.githubTokens(password); len(tokens) > 1 {
+		log.Printf("Rotating %d GitHub tokens", len(tokens))
+		client, err = newRotatingGitHubClient(o.host, NewRotatingTokenSource(tokens, o.rotateBelow), httpClient)
+	} else {
+		client, err = newGitHubClient(ctx, o.host, password, httpClient)
+	}
+	if err != nil {
 		return nil, err
 	}
 
-	cacheDir := filepath.Join(currentUser.HomeDir, CachePath)
-	cacheFullPath := filepath.Join(cacheDir, CacheFile)
+	cacheFullPath := o.cachePath
+	if cacheFullPath == "" {
+		currentUser, err := user.Current()
+		if err != nil {
+			log.Printf("Could not determine the current user! %v", err.Error())
+
+			return nil, err
+		}
+
+		cacheFullPath = defaultCachePath(currentUser.HomeDir)
+	}
+
+	cacheDir := filepath.Dir(cacheFullPath)
 	toCreate := []struct {
 		path string
 		mode os.FileMode
@@ -104,122 +227,163 @@ func New() (*StarManager, error) {
 		return nil, err
 	}
 
+	if err := (&StarManager{DB: db, Host: o.host}).Migrate(); err != nil {
+		db.Close()
+		log.Printf("An error occurred migrating the db! %v", err.Error())
+
+		return nil, err
+	}
+
+	var gh Provider = &GitHubProvider{Host: webHost(o.host), Client: client, Username: username}
+	if o.graphql {
+		gh = NewGitHubGraphQLProvider(gh.(*GitHubProvider))
+	}
+
+	providers := []Provider{gh}
+	providers = append(providers, newForgeProviders(ctx, credentials, o, httpClient)...)
+
 	return &StarManager{
-		Username: username,
-		Password: password,
-		Context:  ctx,
-		Client:   client,
-		DB:       db,
+		Host:              o.host,
+		Username:          username,
+		Password:          password,
+		Client:            client,
+		DB:                db,
+		HTTPClient:        httpClient,
+		Providers:         providers,
+		Concurrency:       o.concurrency,
+		DryRun:            o.dryRun,
+		TopicAliases:      NewTopicAliases(o.topicAliases),
+		SnapshotRetention: o.snapshotRetention,
 	}, nil
 }
 
-// ClearCache resets the local db.
-func (s *StarManager) ClearCache() error {
-	if err := os.Remove(s.DB.Bolt.Path()); err != nil {
-		return err
+// httpClient returns the client for requests that do not go to a provider
+func (s *StarManager) httpClient() *http.Client {
+	if s.HTTPClient != nil {
+		return s.HTTPClient
 	}
 
-	log.Printf("Cleared cache")
-	return nil
+	return http.DefaultClient
 }
 
-// SaveStarredRepository saves a single starred project to the local cache.
-func (s *StarManager) SaveStarredRepository(repo *github.Repository, wg *sync.WaitGroup) error {
-	wg.Add(1)
-	defer wg.Done()
-	lang, desc := "", ""
+// ClearCache resets the provider-derived data in the local db. Local-only metadata (tags,
+// notes, protected flags and surfaced history) is preserved unless all is set, in which case
+// the whole db file is removed.
+func (s *StarManager) ClearCache(all bool) error {
+	if all {
+		// Stars kept outside the db file are not removed with it
+		if s.Store != nil {
+			if err := s.clearStars(); err != nil {
+				return err
+			}
+		}
+
+		if err := os.Remove(s.DB.Bolt.Path()); err != nil {
+			return err
+		}
 
-	// We have to perform the below two checks because some repos don't have languages or
-	// desciptions, and the client does not create those struct fields, resulting in a SIGSEGV
-	// (segmentation fault).
-	if repo.Language != nil {
-		lang = *repo.Language
+		log.Printf("Cleared cache including local metadata")
+		return nil
 	}
 
-	if repo.Description != nil {
-		desc = *repo.Description
+	if err := s.clearStars(); err != nil {
+		return err
 	}
 
-	err := s.DB.Save(&Star{
-		PushedAt:    repo.PushedAt.Time,
-		URL:         *repo.HTMLURL,
-		Language:    strings.ToLower(lang),
-		Stargazers:  *repo.StargazersCount,
-		Description: desc,
-		Topics:      repo.Topics,
-		Archived:    *repo.Archived,
-	})
-	if err != nil {
+	if err := dropBucket(s.readmes(), &Readme{}); err != nil {
+		return err
+	}
+
+	if err := s.resetSyncState(); err != nil {
 		return err
 	}
 
-	log.Printf("Saved %s (with topics %s)\n", *repo.HTMLURL, repo.Topics)
+	log.Printf("Cleared cache")
 	return nil
 }
 
-// SaveStarredPage saves an entire page of starred repositories concurrently, optionally sending
-// server responses to a channel if it is provided.
-func (s *StarManager) SaveStarredPage(pageno int, responses chan *github.Response, wg *sync.WaitGroup) chan error {
-	wg.Add(1)
-	defer wg.Done()
-	errors := make(chan error)
-
-	firstPage, response, err := s.Client.Activity.ListStarred(
-		s.Context,
-		s.Username,
-		&github.ActivityListStarredOptions{
-			ListOptions: github.ListOptions{
-				PerPage: PageSize,
-				Page:    pageno,
-			},
-		},
+// ProviderHosts maps provider names to the web host their star URLs live on
+var ProviderHosts = map[string]string{
+	"github":   "github.com",
+	"gitlab":   "gitlab.com",
+	"codeberg": "codeberg.org",
+}
+
+// providerMatcher matches stars from the given provider (e.g. "github") or web host,
+// including stars cached before providers were recorded on them
+func providerMatcher(provider string) q.Matcher {
+	host, ok := ProviderHosts[strings.ToLower(provider)]
+	if !ok {
+		host = provider
+	}
+
+	return q.Or(
+		q.Eq("Provider", host),
+		q.Re("URL", "^https?://"+regexp.QuoteMeta(host)+"/"),
 	)
-	if err != nil {
-		log.Printf(
-			"An error occurred while attempting to fetch page %d of %s's GitHub stars!",
-			pageno,
-			s.Username,
-		)
+}
+
+// ClearOptions selects which cached stars ClearStars deletes. Empty fields match everything.
+type ClearOptions struct {
+	// Provider limits deletion to stars from this provider (e.g. "github") or web host
+	Provider string
 
-		errors <- err
+	// Language limits deletion to stars written in this language
+	Language string
+
+	// OlderThan limits deletion to stars last pushed to before this time
+	OlderThan time.Time
+}
+
+// ClearStars deletes the cached stars matching the given options, leaving everything else in
+// the db intact, and returns how many stars were deleted.
+func (s *StarManager) ClearStars(ctx context.Context, opts ClearOptions) (int, error) {
+	matchers := []q.Matcher{}
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
 	}
 
-	if responses != nil {
-		responses <- response
+	if opts.Provider != "" {
+		matchers = append(matchers, providerMatcher(opts.Provider))
 	}
 
-	log.Printf("Attempting to save starred projects on page %d...\n", pageno)
-	for _, r := range firstPage {
-		go s.SaveStarredRepository(r.Repository, wg)
+	if opts.Language != "" {
+		matchers = append(matchers, q.Eq("Language", strings.ToLower(opts.Language)))
 	}
 
-	return errors
-}
+	if !opts.OlderThan.IsZero() {
+		matchers = append(matchers, q.Lt("PushedAt", opts.OlderThan))
+	}
 
-// SaveAllStars saves all stars.
-func (s *StarManager) SaveAllStars() (bool, error) {
-	wg := sync.WaitGroup{}
-	responses := make(chan *github.Response, 1)
+	stars, err := s.store().Query(matchers...)
+	if err != nil {
+		return 0, err
+	}
 
-	// Fetch the first page to determine the last page number from the response "Link" header
-	log.Printf("Attempting to save first page...")
-	go s.SaveStarredPage(1, responses, &wg)
-	firstPageResponse := <-responses
+	count := len(stars)
+	if count == 0 {
+		return 0, nil
+	}
 
-	log.Printf("Attempting to save the rest of the pages...")
-	for i := 2; i <= firstPageResponse.LastPage; i++ {
-		go s.SaveStarredPage(i, nil, &wg)
+	for _, star := range stars {
+		if err := s.store().Delete(star.URL); err != nil {
+			return 0, err
+		}
 	}
-	wg.Wait()
 
-	log.Printf("Successfully saved all starred projects")
-	return true, nil
+	if err := s.resetSyncState(); err != nil {
+		return 0, err
+	}
+
+	log.Printf("Cleared %d stars from the cache", count)
+	return count, nil
 }
 
 // SaveIfEmpty saves all stars if the local cache is empty
-func (s *StarManager) SaveIfEmpty() error {
-	if count, _ := s.DB.Count(&Star{}); count == 0 {
-		if _, err := s.SaveAllStars(); err != nil {
+func (s *StarManager) SaveIfEmpty(ctx context.Context) error {
+	if count, _ := s.store().Count(); count == 0 {
+		if _, err := s.Sync(ctx, SyncOptions{}); err != nil {
 			return err
 		}
 	}
@@ -229,103 +393,585 @@ func (s *StarManager) SaveIfEmpty() error {
 
 // KV is a generic struct that maintains a string key - int value pair ( :( ).
 type KV struct {
-	Key   string
-	Value int
+	Key   string `json:"key"`
+	Value int    `json:"value"`
 }
 
-// GetTopics returns topics for a repository, otherwise if no repository is passed, returns
-// a list of all topics
-func (s *StarManager) GetTopics() []KV {
-	stars := []Star{}
+// sortedCounts turns counts into key - value pairs, most common first and alphabetically
+// among equally common keys
+func sortedCounts(counts map[string]int) []KV {
+	results := make([]KV, 0, len(counts))
+	for key, count := range counts {
+		results = append(results, KV{key, count})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Value != results[j].Value {
+			return results[i].Value > results[j].Value
+		}
+
+		return results[i].Key < results[j].Key
+	})
+
+	return results
+}
+
+// GetTopics returns a list of all topics of all stars along with how many stars have them,
+// most common first. Topics are counted by their canonical topic, see TopicAliases.
+func (s *StarManager) GetTopics(ctx context.Context) ([]KV, error) {
 	topicCounts := map[string]int{}
 
-	s.DB.All(&stars)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stars, err := s.store().All()
+	if err != nil {
+		return nil, err
+	}
 
+	aliases := s.topicAliases()
 	for _, star := range stars {
-		for _, topic := range star.Topics {
+		for _, topic := range aliases.CanonicalTopics(star.Topics) {
 			topicCounts[topic]++
 		}
 	}
 
-	results := []KV{}
+	return sortedCounts(topicCounts), nil
+}
+
+// GetOwners returns the users and organizations owning the repositories of all stars along
+// with how many of them they own, most starred first
+func (s *StarManager) GetOwners(ctx context.Context) ([]KV, error) {
+	ownerCounts := map[string]int{}
 
-	for topic, count := range topicCounts {
-		results = append(results, KV{topic, count})
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Value > results[j].Value
+	err := eachStar(s.store(), func(star *Star) error {
+		if owner, _, err := star.OwnerRepo(); err == nil {
+			ownerCounts[owner]++
+		}
+
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return results
+	return sortedCounts(ownerCounts), nil
 }
 
-// GetProjects returns random projects given a project count to return, and an optional
-// language and topic to filter by.
-func (s *StarManager) GetProjects(count int, language, topic string, random bool) ([]Star, error) {
-	stars := []Star{}
+// SortKey selects the order in which GetProjects returns projects
+type SortKey string
 
-	if language != "" {
-		if err := s.DB.Select(q.Eq("Language", language)).Find(&stars); err != nil {
-			return nil, err
+const (
+	// SortStargazers sorts by stargazer count, most popular first
+	SortStargazers SortKey = "stars"
+
+	// SortStarred sorts by when the project was starred, most recent first
+	SortStarred SortKey = "starred"
+
+	// SortPushed sorts by when the project was last pushed to, most recent first
+	SortPushed SortKey = "pushed"
+
+	// SortName sorts by owner and repository name, alphabetically
+	SortName SortKey = "name"
+
+	// SortURL sorts by URL, the order stars are cached in, which lets ForEachStar stream
+	// them
+	SortURL SortKey = "url"
+)
+
+// SortOrder is the direction projects are sorted in
+type SortOrder string
+
+const (
+	// OrderAsc sorts smallest, oldest or alphabetically first
+	OrderAsc SortOrder = "asc"
+
+	// OrderDesc sorts largest, newest or alphabetically last first
+	OrderDesc SortOrder = "desc"
+)
+
+// sortProjects sorts stars by the given key and order. An empty order uses the natural
+// order of the key, which is ascending for names and descending otherwise.
+func sortProjects(stars []Star, key SortKey, order SortOrder) error {
+	var less func(a, b *Star) bool
+
+	switch key {
+	case SortStargazers, "":
+		less = func(a, b *Star) bool { return a.Stargazers < b.Stargazers }
+	case SortStarred:
+		less = func(a, b *Star) bool { return a.StarredAt.Before(b.StarredAt) }
+	case SortPushed:
+		less = func(a, b *Star) bool { return a.PushedAt.Before(b.PushedAt) }
+	case SortName:
+		less = func(a, b *Star) bool { return projectName(a) < projectName(b) }
+	case SortURL:
+		less = func(a, b *Star) bool { return a.URL < b.URL }
+	default:
+		return fmt.Errorf("unknown sort key %q", key)
+	}
+
+	if order == "" {
+		order = OrderDesc
+		if key == SortName || key == SortURL {
+			order = OrderAsc
 		}
-	} else {
-		if err := s.DB.All(&stars); err != nil {
-			return nil, err
+	}
+
+	switch order {
+	case OrderAsc:
+		sort.SliceStable(stars, func(i, j int) bool { return less(&stars[i], &stars[j]) })
+	case OrderDesc:
+		sort.SliceStable(stars, func(i, j int) bool { return less(&stars[j], &stars[i]) })
+	default:
+		return fmt.Errorf("unknown sort order %q", order)
+	}
+
+	return nil
+}
+
+// projectName returns the lower case "owner/repo" name of a star, or its URL if it has none
+func projectName(star *Star) string {
+	owner, repo, err := star.OwnerRepo()
+	if err != nil {
+		return strings.ToLower(star.URL)
+	}
+
+	return strings.ToLower(owner + "/" + repo)
+}
+
+// MatchMode selects whether any or all of several values have to match
+type MatchMode string
+
+const (
+	// MatchAny matches if any of the values match
+	MatchAny MatchMode = "any"
+
+	// MatchAll matches if all of the values match
+	MatchAll MatchMode = "all"
+)
+
+// ProjectOptions selects and orders the projects returned by GetProjects. Empty fields
+// match everything.
+type ProjectOptions struct {
+	// Count is the maximum number of projects to return, all matching projects if zero
+	Count int
+
+	// Language limits results to projects written in this language
+	Language string
+
+	// Topic limits results to projects with this topic
+	Topic string
+
+	// Languages limits results to projects written in any of these languages
+	Languages []string
+
+	// Topics limits results to projects with any or, depending on TopicMatch, all of these
+	// topics
+	Topics []string
+
+	// TopicMatch selects whether projects need any (the default) or all of Topics
+	TopicMatch MatchMode
+
+	// ExcludeLanguages removes projects written in any of these languages
+	ExcludeLanguages []string
+
+	// LanguageThreshold makes Language, Languages and ExcludeLanguages also match projects
+	// whose fetched language breakdown has at least this share (between 0 and 1) of code in
+	// a language. Only the primary language matches if it is 0.
+	LanguageThreshold float64
+
+	// ExcludeTopics removes projects with any of these topics
+	ExcludeTopics []string
+
+	// Licenses limits results to projects under any of these SPDX license identifiers,
+	// case insensitively
+	Licenses []string
+
+	// Owners limits results to projects of any of these users or organizations, case
+	// insensitively
+	Owners []string
+
+	// Tags limits results to projects with any of these local tags
+	Tags []string
+
+	// List limits results to projects on this GitHub list
+	List string
+
+	// StarredAfter limits results to projects starred after this time
+	StarredAfter time.Time
+
+	// Random shuffles the results, taking precedence over Sort
+	Random bool
+
+	// Sort orders the results, by stargazers if unset
+	Sort SortKey
+
+	// Order is the direction of Sort, the natural order of the sort key if unset
+	Order SortOrder
+
+	// Query further narrows down the results with a query such as "topic:cli stars:>500",
+	// see ParseQuery
+	Query string
+}
+
+// ErrNoStars is returned by GetProjects if no cached stars match
+var ErrNoStars = errors.New("No stars matching criteria found")
+
+// ErrStopIteration can be returned by the functions passed to ForEachStar to stop early
+// without an error
+var ErrStopIteration = errors.New("stop iteration")
+
+// GetProjects returns projects matching the given options.
+func (s *StarManager) GetProjects(ctx context.Context, opts ProjectOptions) ([]Star, error) {
+	stars, err := s.findProjects(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stars) > 0 {
+		if opts.Count > 0 && len(stars) > opts.Count {
+			return stars[0:opts.Count], nil
 		}
+
+		return stars, nil
 	}
 
-	if topic != "" {
-		topicStars := []Star{}
+	return []Star{}, ErrNoStars
+}
+
+// ForEachStar calls fn with every project matching the given options, up to Count, without
+// loading all of them into memory when sorted by SortURL, the order stars are cached in.
+// Other orders and Random need all matching projects to order them, so they are loaded
+// first, like GetProjects does. Iteration stops at the first error returned by fn, which is
+// returned unless it is ErrStopIteration. fn must not save or remove stars, as the cache may
+// be read while it runs.
+func (s *StarManager) ForEachStar(ctx context.Context, opts ProjectOptions, fn func(star *Star) error) error {
+	err := s.forEachStar(ctx, opts, fn)
+	if err == ErrStopIteration {
+		return nil
+	}
+
+	return err
+}
+
+// forEachStar calls fn with every matching project, see ForEachStar
+func (s *StarManager) forEachStar(ctx context.Context, opts ProjectOptions, fn func(star *Star) error) error {
+	if opts.Random || opts.Sort != SortURL || opts.Order == OrderDesc {
+		stars, err := s.findProjects(ctx, opts)
+		if err != nil {
+			return err
+		}
 
-		for _, star := range stars {
-			if utils.StringInSlice(topic, star.Topics) {
-				topicStars = append(topicStars, star)
+		for i := range stars {
+			if opts.Count > 0 && i >= opts.Count {
+				break
+			}
+
+			if err := fn(&stars[i]); err != nil {
+				return err
 			}
 		}
 
-		stars = topicStars
+		return nil
+	}
+
+	filter, err := s.newProjectFilter(ctx, opts)
+	if err != nil {
+		return err
 	}
 
-	if random == true {
+	n := 0
+	return eachStar(s.store(), func(star *Star) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !filter.match(star) {
+			return nil
+		}
+
+		if err := fn(star); err != nil {
+			return err
+		}
+
+		if n++; opts.Count > 0 && n >= opts.Count {
+			return ErrStopIteration
+		}
+
+		return nil
+	}, filter.matchers...)
+}
+
+// projectFilter selects the projects matching ProjectOptions: the storm matchers narrow
+// down the stars read from the store, and match checks what they cannot express
+type projectFilter struct {
+	opts        ProjectOptions
+	matchers    []q.Matcher
+	query       *Query
+	languages   []string
+	annotations map[string]*Annotation
+
+	// topics and excludeTopics are the canonical topics of the options
+	topics        []string
+	excludeTopics []string
+	aliases       TopicAliases
+}
+
+// newProjectFilter returns the filter for the given options
+func (s *StarManager) newProjectFilter(ctx context.Context, opts ProjectOptions) (*projectFilter, error) {
+	matchers := []q.Matcher{}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	languages := opts.Languages
+	if opts.Language != "" {
+		languages = append([]string{opts.Language}, languages...)
+	}
+
+	// Language breakdowns cannot be matched by storm
+	if opts.LanguageThreshold <= 0 {
+		if len(languages) > 0 {
+			matchers = append(matchers, languageMatcher(languages))
+		}
+
+		if len(opts.ExcludeLanguages) > 0 {
+			matchers = append(matchers, q.Not(languageMatcher(opts.ExcludeLanguages)))
+		}
+	}
+
+	if !opts.StarredAfter.IsZero() {
+		matchers = append(matchers, q.Gt("StarredAt", opts.StarredAfter))
+	}
+
+	query, err := parseQuery(opts.Query, s.topicAliases())
+	if err != nil {
+		return nil, err
+	}
+	matchers = append(matchers, query.Matchers()...)
+
+	annotations, err := s.GetAnnotations()
+	if err != nil {
+		return nil, err
+	}
+
+	topics := opts.Topics
+	if opts.Topic != "" {
+		topics = append([]string{opts.Topic}, topics...)
+	}
+
+	aliases := s.topicAliases()
+
+	return &projectFilter{
+		opts:          opts,
+		matchers:      matchers,
+		query:         query,
+		languages:     languages,
+		topics:        aliases.CanonicalTopics(topics),
+		excludeTopics: aliases.CanonicalTopics(opts.ExcludeTopics),
+		aliases:       aliases,
+		annotations:   annotations,
+	}, nil
+}
+
+// match reports whether a star read with the matchers of the filter matches the rest of it
+func (f *projectFilter) match(star *Star) bool {
+	opts := f.opts
+	annotation := f.annotations[star.URL]
+
+	if len(opts.Tags) > 0 && !hasTags(annotation, opts.Tags) {
+		return false
+	}
+
+	if opts.List != "" && !onList(star, []string{opts.List}) {
+		return false
+	}
+
+	if len(opts.Licenses) > 0 && !hasLicense(star, opts.Licenses) {
+		return false
+	}
+
+	if len(opts.Owners) > 0 && !ownedBy(star, opts.Owners) {
+		return false
+	}
+
+	if opts.LanguageThreshold > 0 {
+		if len(f.languages) > 0 && !speaks(star, f.languages, opts.LanguageThreshold) {
+			return false
+		}
+
+		if speaks(star, opts.ExcludeLanguages, opts.LanguageThreshold) {
+			return false
+		}
+	}
+
+	if !f.query.Match(star, annotation) {
+		return false
+	}
+
+	if len(f.topics) > 0 && !hasTopics(star, f.topics, opts.TopicMatch, f.aliases) {
+		return false
+	}
+
+	return !hasTopics(star, f.excludeTopics, MatchAny, f.aliases)
+}
+
+// findProjects returns all projects matching the given options in the requested order,
+// ignoring Count
+func (s *StarManager) findProjects(ctx context.Context, opts ProjectOptions) ([]Star, error) {
+	filter, err := s.newProjectFilter(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	matched, err := s.store().Query(filter.matchers...)
+	if err != nil {
+		return nil, err
+	}
+
+	stars := []Star{}
+	for _, star := range matched {
+		if filter.match(star) {
+			stars = append(stars, *star)
+		}
+	}
+
+	if opts.Random == true {
 		rand.Seed(time.Now().UTC().UnixNano())
 		rand.Shuffle(len(stars), func(i, j int) {
 			stars[i], stars[j] = stars[j], stars[i]
 		})
-	} else {
-		sort.Slice(stars, func(i, j int) bool { return stars[i].Stargazers > stars[j].Stargazers })
+	} else if err := sortProjects(stars, opts.Sort, opts.Order); err != nil {
+		return nil, err
 	}
 
-	if len(stars) > 0 {
-		if len(stars) > count {
-			return stars[0:count], nil
-		}
+	return stars, nil
+}
 
-		return stars, nil
+// ownerRepo extracts the owner and repository name from a star's URL. The owner of a GitLab
+// project in a subgroup is its full namespace, e.g. group/subgroup.
+func ownerRepo(starURL string) (string, string, error) {
+	u, err := url.Parse(starURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	// GitLab separates the pages of a project from its path with /-/
+	path := strings.Trim(u.Path, "/")
+	if i := strings.Index(path, "/-/"); i >= 0 {
+		path = path[:i]
+	}
+
+	splitPath := strings.Split(path, "/")
+	if len(splitPath) < 2 || splitPath[0] == "" || splitPath[1] == "" {
+		return "", "", fmt.Errorf("%s is not a repository URL", starURL)
 	}
 
-	return []Star{}, errors.New("No stars matching criteria found")
+	// GitHub has no nested namespaces, anything after the repository is one of its pages
+	if strings.EqualFold(u.Host, ProviderHosts["github"]) {
+		return splitPath[0], splitPath[1], nil
+	}
+
+	for _, part := range splitPath {
+		if part == "" {
+			return "", "", fmt.Errorf("%s is not a repository URL", starURL)
+		}
+	}
+
+	last := len(splitPath) - 1
+	return strings.Join(splitPath[:last], "/"), splitPath[last], nil
+}
+
+// RemoveStar unstars the project on its provider and removes the star from the local cache,
+// keeping it in the graveyard so that it can be restored. In dry run mode nothing is changed
+// and false is returned.
+func (s *StarManager) RemoveStar(ctx context.Context, star *Star) (bool, error) {
+	return s.removeStar(ctx, star, "removed manually", time.Now())
 }
 
-// RemoveStar unstars the project on Github and removes the star from the local cache.
-func (s *StarManager) RemoveStar(star *Star, wg *sync.WaitGroup) (bool, error) {
-	wg.Add(1)
-	defer wg.Done()
+// AddStar stars a GitHub repository and stores it in the local cache right away, so that it
+// does not have to wait for the next sync. A star of the repository that was removed earlier
+// is taken out of the graveyard. In dry run mode the repository is only fetched.
+func (s *StarManager) AddStar(ctx context.Context, owner, repo string) (*Star, error) {
+	repository, resp, err := s.Client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s/%s does not exist", owner, repo)
+		}
+
+		return nil, githubRateLimitError(err)
+	}
+
+	gh := &GitHubProvider{Host: webHost(s.Host), Client: s.Client}
+	star := gh.star(&github.StarredRepository{Repository: repository})
+	star.StarredAt = time.Now()
 
-	starURL, parseErr := url.Parse(star.URL)
-	if parseErr != nil {
-		return false, parseErr
+	if s.DryRun {
+		log.Printf("Would star %s", star.URL)
+		return star, nil
 	}
 
-	splitPath := strings.Split(starURL.Path, "/")
+	provider, err := s.Provider(gh.Name())
+	if err != nil {
+		return nil, err
+	}
 
-	_, unstarErr := s.Client.Activity.Unstar(s.Context, splitPath[1], splitPath[2])
-	if unstarErr != nil {
-		log.Printf("An error occurred while attempting to unstar %s: %s\n", star.URL, unstarErr.Error())
-		return false, unstarErr
+	if err := provider.Star(ctx, owner, repo); err != nil {
+		log.Printf("An error occurred while attempting to star %s: %s\n", star.URL, err.Error())
+		return nil, err
 	}
 
-	deleteErr := s.DB.DeleteStruct(star)
+	if _, err := s.SaveStar(star); err != nil {
+		return nil, err
+	}
+
+	if err := s.graveyard().DeleteStruct(&Grave{URL: star.URL}); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	log.Printf("Starred %s", star.URL)
+
+	return star, nil
+}
+
+// removeStar removes a star, burying it with the given reason as part of the removal batch
+// started at the given time
+func (s *StarManager) removeStar(ctx context.Context, star *Star, reason string, batch time.Time) (bool, error) {
+	if s.DryRun {
+		log.Printf("Would remove %s", star.URL)
+		return false, nil
+	}
+
+	// Stars of repositories that no longer exist cannot be unstarred, only forgotten
+	if !star.Gone {
+		owner, repo, parseErr := star.OwnerRepo()
+		if parseErr != nil {
+			return false, parseErr
+		}
+
+		provider, providerErr := s.Provider(star.Provider)
+		if providerErr != nil {
+			return false, providerErr
+		}
+
+		unstarErr := provider.Unstar(ctx, owner, repo)
+		if unstarErr != nil {
+			log.Printf("An error occurred while attempting to unstar %s: %s\n", star.URL, unstarErr.Error())
+			return false, unstarErr
+		}
+	}
+
+	if buryErr := s.bury(star, reason, batch); buryErr != nil {
+		return false, buryErr
+	}
+
+	deleteErr := s.store().Delete(star.URL)
 	if deleteErr != nil {
 		return false, deleteErr
 	}
@@ -335,46 +981,221 @@ func (s *StarManager) RemoveStar(star *Star, wg *sync.WaitGroup) (bool, error) {
 	return true, nil
 }
 
-// Cleanup removes stars older than a specified time in months and optionally archived stars.
-func (s *StarManager) Cleanup(age int, archived bool) error {
-	allStars := []*Star{}
-	toDelete := make(chan *Star)
-	wg := sync.WaitGroup{}
-	then := time.Now().AddDate(0, -age, 0)
+// CleanupOptions selects the stars Cleanup removes
+type CleanupOptions struct {
+	// Months removes stars older than this many months, regardless of age if 0. The other
+	// criteria select stars on their own.
+	Months int
 
-	if err := s.DB.All(&allStars); err != nil {
-		return err
+	// ByStarred measures age by when the project was starred instead of when it was last
+	// pushed to
+	ByStarred bool
+
+	// Archived additionally removes archived stars regardless of age
+	Archived bool
+
+	// Gone additionally removes stars whose repositories no longer exist, as flagged by
+	// Reconcile
+	Gone bool
+
+	// ScoreBelow additionally removes stars with a health score below this, see Score
+	ScoreBelow int
+
+	// Forks additionally removes forks whose parent is starred too, keeping the parent
+	Forks bool
+
+	// Keep are the URLs of stars that must not be removed even if they match, such as the
+	// dependencies of a project found by CrossReference
+	Keep []string
+
+	// Policy, if set, selects the stars to remove by its rules instead of by Months and
+	// Archived
+	Policy *Policy
+
+	// DryRun only reports the matching stars without removing them
+	DryRun bool
+
+	// Confirm, if set, is called with the matching stars before any of them are removed and
+	// returns the ones that should actually be removed
+	Confirm func(candidates []*CleanupCandidate) []*CleanupCandidate
+}
+
+// CleanupCandidate is a star matched by Cleanup along with why it matched
+type CleanupCandidate struct {
+	Star    *Star    `json:"star"`
+	Reasons []string `json:"reasons"`
+
+	// Rule is the name of the policy rule that matched, if any
+	Rule string `json:"rule,omitempty"`
+}
+
+// CleanupCandidates returns the stars Cleanup would remove with the given options. Protected
+// stars never match.
+func (s *StarManager) CleanupCandidates(ctx context.Context, opts CleanupOptions) ([]*CleanupCandidate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	allStars, err := s.store().All()
+	if err != nil {
+		return nil, err
 	}
 
 	log.Printf("Filtering stars to delete (from %d)...", len(allStars))
+	return s.cleanupCandidates(allStars, opts)
+}
+
+// cleanupCandidates returns the given stars that match the cleanup options, looking for the
+// parents of forks among them
+func (s *StarManager) cleanupCandidates(allStars []*Star, opts CleanupOptions) ([]*CleanupCandidate, error) {
+	candidates := []*CleanupCandidate{}
+	now := time.Now()
+	then := now.AddDate(0, -opts.Months, 0)
+
+	protected, err := s.Protected()
+	if err != nil {
+		return nil, err
+	}
+
+	keep := map[string]bool{}
+	for _, url := range opts.Keep {
+		keep[strings.ToLower(url)] = true
+	}
+
+	starred := map[string]bool{}
 	for _, star := range allStars {
-		if star.PushedAt.Before(then) || star.Archived == archived {
-			log.Printf(
-				"Queueing %s for deletion (last pushed at %+v, archive status: %t)",
-				star.URL,
-				star.PushedAt,
-				star.Archived,
-			)
+		starred[strings.ToLower(star.URL)] = true
+	}
 
-			go func(ch chan *Star, s *Star, wg *sync.WaitGroup) {
-				wg.Add(1)
-				defer wg.Done()
+	aliases := s.topicAliases()
+	policy := opts.Policy
+	if policy != nil {
+		policy = policy.withTopicAliases(aliases)
+	}
 
-				ch <- s
-			}(toDelete, star, &wg)
+	for _, star := range allStars {
+		reasons := []string{}
+
+		if utils.StringInSlice(star.URL, protected) {
+			log.Printf("Skipping protected star %s", star.URL)
+			continue
+		}
+
+		if keep[strings.ToLower(star.URL)] {
+			log.Printf("Keeping %s", star.URL)
+			continue
+		}
+
+		if policy != nil {
+			canonical := *star
+			canonical.Topics = aliases.CanonicalTopics(star.Topics)
+
+			rule, unstar := policy.Evaluate(&canonical, now)
+			if !unstar {
+				if rule != nil {
+					log.Printf("Keeping %s (rule %q)", star.URL, rule.Name)
+				}
+
+				continue
+			}
+
+			log.Printf("Queueing %s for deletion (rule %q)", star.URL, rule.Name)
+			candidates = append(candidates, &CleanupCandidate{
+				Star:    star,
+				Reasons: []string{"rule " + rule.Name},
+				Rule:    rule.Name,
+			})
+
+			continue
+		}
+
+		if opts.Months > 0 {
+			// Not every provider reports when projects were starred
+			if opts.ByStarred && !star.StarredAt.IsZero() && star.StarredAt.Before(then) {
+				reasons = append(reasons, fmt.Sprintf("starred %s", star.StarredAt.Format("2006-01-02")))
+			} else if !opts.ByStarred && star.PushedAt.Before(then) {
+				reasons = append(reasons, fmt.Sprintf("last pushed %s", star.PushedAt.Format("2006-01-02")))
+			}
+		}
+
+		if opts.Archived && star.Archived {
+			reasons = append(reasons, "archived")
+		}
+
+		if opts.Gone && star.Gone {
+			reasons = append(reasons, "gone")
+		}
+
+		if opts.Forks && star.Fork && starred[strings.ToLower(star.Parent)] {
+			reasons = append(reasons, "fork of "+star.Parent)
+		}
+
+		if opts.ScoreBelow > 0 {
+			if score, _ := Score(star, now); score < opts.ScoreBelow {
+				reasons = append(reasons, fmt.Sprintf("health score %d", score))
+			}
+		}
+
+		if len(reasons) == 0 {
+			continue
 		}
+
+		log.Printf("Queueing %s for deletion (%s)", star.URL, strings.Join(reasons, ", "))
+		candidates = append(candidates, &CleanupCandidate{Star: star, Reasons: reasons})
 	}
 
-	// Cannot close channel in main goroutine as it will block
-	go func() {
-		wg.Wait()
-		close(toDelete)
-	}()
+	return candidates, nil
+}
 
-	for star := range toDelete {
-		go s.RemoveStar(star, &wg)
+// Cleanup removes stars older than a specified time in months and optionally archived stars.
+// A non-nil error is only returned if the cleanup could not run at all; stars that could not
+// be removed are reported in the result.
+func (s *StarManager) Cleanup(ctx context.Context, opts CleanupOptions) (*CleanupResult, error) {
+	candidates, err := s.CleanupCandidates(ctx, opts)
+	if err != nil {
+		return nil, err
 	}
-	wg.Wait()
 
-	return nil
+	batch := time.Now()
+	result := s.removeCandidates(ctx, "stars", candidates, opts, func(candidate *CleanupCandidate) error {
+		_, err := s.removeStar(ctx, candidate.Star, strings.Join(candidate.Reasons, ", "), batch)
+		return err
+	})
+
+	return result, ctx.Err()
+}
+
+// removeCandidates calls remove concurrently for every candidate confirmed by opts.Confirm,
+// unless it is a dry run, and reports the outcome. what names the candidates in log messages.
+func (s *StarManager) removeCandidates(ctx context.Context, what string, candidates []*CleanupCandidate, opts CleanupOptions, remove func(*CleanupCandidate) error) *CleanupResult {
+	result := &CleanupResult{Matched: len(candidates), Candidates: candidates}
+	if opts.DryRun || s.DryRun {
+		return result
+	}
+
+	toDelete := candidates
+	if opts.Confirm != nil {
+		toDelete = opts.Confirm(candidates)
+	}
+	result.Skipped = len(candidates) - len(toDelete)
+
+	mu := sync.Mutex{}
+
+	runPool(ctx, s.concurrency(), len(toDelete), func(job int) {
+		err := remove(toDelete[job])
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, &CleanupError{URL: toDelete[job].Star.URL, Err: err})
+			return
+		}
+
+		result.Removed++
+	})
+
+	log.Printf("Removed %d of %d matching %s, %d skipped, %d failed", result.Removed, result.Matched, what, result.Skipped, result.Failed)
+	return result
 }
@@ -0,0 +1,507 @@
+package starmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gkze/stars/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClearStars(t *testing.T) {
+	now := time.Now()
+	stars := []Star{
+		{URL: "https://github.com/a/old-go", Language: "go", PushedAt: now.AddDate(-2, 0, 0)},
+		{URL: "https://github.com/a/new-go", Language: "go", PushedAt: now},
+		{URL: "https://github.com/a/old-rust", Language: "rust", PushedAt: now.AddDate(-2, 0, 0)},
+		{URL: "https://gitlab.com/a/old-go", Language: "go", PushedAt: now.AddDate(-2, 0, 0)},
+	}
+
+	testCases := []struct {
+		opts      ClearOptions
+		remaining []string
+	}{
+		{
+			opts: ClearOptions{Language: "Go", OlderThan: now.AddDate(-1, 0, 0)},
+			remaining: []string{
+				"https://github.com/a/new-go",
+				"https://github.com/a/old-rust",
+			},
+		},
+		{
+			opts: ClearOptions{Provider: "github", Language: "go"},
+			remaining: []string{
+				"https://github.com/a/old-rust",
+				"https://gitlab.com/a/old-go",
+			},
+		},
+		{
+			opts: ClearOptions{Provider: "gitlab.com"},
+			remaining: []string{
+				"https://github.com/a/new-go",
+				"https://github.com/a/old-go",
+				"https://github.com/a/old-rust",
+			},
+		},
+		{
+			opts: ClearOptions{Language: "cobol"},
+			remaining: []string{
+				"https://github.com/a/new-go",
+				"https://github.com/a/old-go",
+				"https://github.com/a/old-rust",
+				"https://gitlab.com/a/old-go",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		sm, cleanup := newTestStarManager(t)
+
+		for i := range stars {
+			assert.NoError(t, sm.DB.Save(&stars[i]))
+		}
+
+		deleted, err := sm.ClearStars(context.Background(), tc.opts)
+		assert.NoError(t, err)
+		assert.Equal(t, len(stars)-len(tc.remaining), deleted)
+
+		left := []Star{}
+		assert.NoError(t, sm.DB.All(&left))
+
+		urls := []string{}
+		for _, star := range left {
+			urls = append(urls, star.URL)
+		}
+		assert.ElementsMatch(t, tc.remaining, urls)
+
+		cleanup()
+	}
+}
+
+func TestOwnerRepo(t *testing.T) {
+	testCases := []struct {
+		url   string
+		owner string
+		repo  string
+		err   bool
+	}{
+		{url: "https://github.com/gkze/stars", owner: "gkze", repo: "stars"},
+		{url: "https://github.com/gkze/stars/", owner: "gkze", repo: "stars"},
+		{url: "https://github.com/gkze/stars/tree/master", owner: "gkze", repo: "stars"},
+		{url: "https://gitlab.com/group/sub/project", owner: "group/sub", repo: "project"},
+		{url: "https://gitlab.com/group/sub/project/-/tree/main", owner: "group/sub", repo: "project"},
+		{url: "https://gitlab.com/group//project", err: true},
+		{url: "https://github.com/gkze", err: true},
+		{url: "://", err: true},
+	}
+
+	for _, tc := range testCases {
+		owner, repo, err := ownerRepo(tc.url)
+		if tc.err {
+			assert.Error(t, err, tc.url)
+			continue
+		}
+
+		assert.NoError(t, err, tc.url)
+		assert.Equal(t, tc.owner, owner)
+		assert.Equal(t, tc.repo, repo)
+	}
+}
+
+func TestProviderName(t *testing.T) {
+	assert.Equal(t, "gitlab.com", (&Star{URL: "https://gitlab.com/a/b", Provider: "gitlab.com"}).ProviderName())
+	assert.Equal(t, "github.example.com", (&Star{URL: "https://github.example.com/a/b"}).ProviderName())
+	assert.Equal(t, "github.com", (&Star{URL: "https://github.com/a/b"}).ProviderName())
+	assert.Equal(t, "github.com", (&Star{}).ProviderName())
+}
+
+func TestSaveStarOwnerRepo(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	_, err := sm.SaveStar(&Star{URL: "https://github.com/hashicorp/terraform"})
+	assert.NoError(t, err)
+	_, err = sm.SaveStar(&Star{URL: "https://github.com/hashicorp/vault"})
+	assert.NoError(t, err)
+	_, err = sm.SaveStar(&Star{URL: "https://github.com/gkze/stars"})
+	assert.NoError(t, err)
+
+	stars := []Star{}
+	assert.NoError(t, sm.DB.Find("Owner", "hashicorp", &stars))
+	assert.Len(t, stars, 2)
+
+	owner, repo, err := stars[0].OwnerRepo()
+	assert.NoError(t, err)
+	assert.Equal(t, "hashicorp", owner)
+	assert.Equal(t, stars[0].RepoName, repo)
+
+	projects, err := sm.findProjects(context.Background(), ProjectOptions{Owners: []string{"HashiCorp"}})
+	assert.NoError(t, err)
+	assert.Len(t, projects, 2)
+
+	owners, err := sm.GetOwners(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []KV{{"hashicorp", 2}, {"gkze", 1}}, owners)
+
+	// Stars cached before the fields existed fall back to their URL
+	owner, repo, err = (&Star{URL: "https://github.com/a/b"}).OwnerRepo()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, []string{owner, repo})
+}
+
+func TestGetProjectsStarredAfter(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	now := time.Now()
+	stars := []Star{
+		{URL: "https://github.com/a/old", Stargazers: 100, StarredAt: now.AddDate(-1, 0, 0)},
+		{URL: "https://github.com/a/recent", Stargazers: 1, StarredAt: now.AddDate(0, 0, -2)},
+		{URL: "https://github.com/a/newest", Stargazers: 10, StarredAt: now.AddDate(0, 0, -1)},
+	}
+	for i := range stars {
+		assert.NoError(t, sm.DB.Save(&stars[i]))
+	}
+
+	projects, err := sm.GetProjects(context.Background(), ProjectOptions{
+		Count:        10,
+		StarredAfter: now.AddDate(0, 0, -30),
+		Sort:         SortStarred,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, projects, 2)
+	assert.Equal(t, "https://github.com/a/newest", projects[0].URL)
+	assert.Equal(t, "https://github.com/a/recent", projects[1].URL)
+
+	projects, err = sm.GetProjects(context.Background(), ProjectOptions{Count: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://github.com/a/old"}, []string{projects[0].URL})
+
+	projects, err = sm.GetProjects(context.Background(), ProjectOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, projects, len(stars))
+
+	_, err = sm.GetProjects(context.Background(), ProjectOptions{Count: 1, Language: "cobol"})
+	assert.Error(t, err)
+}
+
+// fakeProvider records the projects it is asked to star and unstar. Projects resolve to
+// their URL on the provider unless they are listed in moved or gone.
+type fakeProvider struct {
+	sync.Mutex
+	name      string
+	starred   []string
+	unstarred []string
+	moved     map[string]string
+	gone      []string
+	err       error
+}
+
+func (f *fakeProvider) Name() string { return f.name }
+
+func (f *fakeProvider) ListStars(ctx context.Context, page int, etag string) (*StarPage, error) {
+	return &StarPage{}, f.err
+}
+
+func (f *fakeProvider) Star(ctx context.Context, owner, repo string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	f.starred = append(f.starred, owner+"/"+repo)
+	return f.err
+}
+
+func (f *fakeProvider) Unstar(ctx context.Context, owner, repo string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	f.unstarred = append(f.unstarred, owner+"/"+repo)
+	return f.err
+}
+
+func (f *fakeProvider) Resolve(ctx context.Context, owner, repo string) (string, error) {
+	name := owner + "/" + repo
+	if utils.StringInSlice(name, f.gone) {
+		return "", ErrGone
+	}
+
+	if url, ok := f.moved[name]; ok {
+		return url, nil
+	}
+
+	return "https://" + f.name + "/" + name, f.err
+}
+
+func TestAddStar(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/a/b" {
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+			return
+		}
+
+		fmt.Fprint(w, `{
+			"html_url": "https://github.com/a/b",
+			"description": "a tool",
+			"language": "Go",
+			"pushed_at": "2019-06-01T00:00:00Z",
+			"stargazers_count": 42,
+			"archived": false,
+			"topics": ["cli"]
+		}`)
+	}))
+	defer closeGitHub()
+
+	sm.Host = GitHub
+	sm.Client = client
+
+	provider := &fakeProvider{name: "github.com"}
+	sm.Providers = []Provider{provider}
+	ctx := context.Background()
+
+	// Dry runs neither star nor cache anything
+	sm.DryRun = true
+	star, err := sm.AddStar(ctx, "a", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/a/b", star.URL)
+	assert.Empty(t, provider.starred)
+	assert.Error(t, sm.DB.One("URL", "https://github.com/a/b", &Star{}))
+	sm.DryRun = false
+
+	// Stars removed earlier leave the graveyard
+	assert.NoError(t, sm.bury(&Star{URL: "https://github.com/a/b"}, "removed manually", time.Now()))
+
+	star, err = sm.AddStar(ctx, "a", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a/b"}, provider.starred)
+	assert.False(t, star.StarredAt.IsZero())
+
+	cached := Star{}
+	assert.NoError(t, sm.DB.One("URL", "https://github.com/a/b", &cached))
+	assert.Equal(t, "go", cached.Language)
+	assert.Equal(t, 42, cached.Stargazers)
+	assert.Equal(t, []string{"cli"}, cached.Topics)
+
+	graves, err := sm.Graveyard(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, graves)
+
+	// Starring twice is harmless
+	_, err = sm.AddStar(ctx, "a", "b")
+	assert.NoError(t, err)
+
+	_, err = sm.AddStar(ctx, "a", "missing")
+	assert.EqualError(t, err, "a/missing does not exist")
+
+	provider.err = errors.New("forbidden")
+	_, err = sm.AddStar(ctx, "a", "b")
+	assert.Error(t, err)
+}
+
+func TestCleanup(t *testing.T) {
+	now := time.Now()
+	stars := []Star{
+		{URL: "https://github.com/a/fresh", PushedAt: now, StarredAt: now.AddDate(-3, 0, 0)},
+		{URL: "https://github.com/a/stale", PushedAt: now.AddDate(-1, 0, 0), StarredAt: now},
+		{URL: "https://github.com/a/archived", PushedAt: now, StarredAt: now, Archived: true},
+		{URL: "https://github.com/b/fork", PushedAt: now, StarredAt: now, Fork: true, Parent: "https://github.com/a/fresh"},
+		{URL: "https://github.com/c/undated", PushedAt: now},
+	}
+
+	testCases := []struct {
+		opts      CleanupOptions
+		unstarred []string
+	}{
+		{
+			opts:      CleanupOptions{Months: 2},
+			unstarred: []string{"a/stale"},
+		},
+		{
+			opts:      CleanupOptions{Months: 2, Archived: true},
+			unstarred: []string{"a/stale", "a/archived"},
+		},
+		{
+			opts:      CleanupOptions{Months: 24, ByStarred: true},
+			unstarred: []string{"a/fresh"},
+		},
+		{
+			opts:      CleanupOptions{Months: 2, Archived: true, Keep: []string{"https://GitHub.com/a/stale"}},
+			unstarred: []string{"a/archived"},
+		},
+		{
+			opts:      CleanupOptions{Months: 2, Forks: true},
+			unstarred: []string{"a/stale", "b/fork"},
+		},
+		{
+			opts:      CleanupOptions{Forks: true},
+			unstarred: []string{"b/fork"},
+		},
+		{
+			opts:      CleanupOptions{Archived: true},
+			unstarred: []string{"a/archived"},
+		},
+		{
+			opts:      CleanupOptions{},
+			unstarred: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		sm, cleanup := newTestStarManager(t)
+		provider := &fakeProvider{name: "github.com"}
+		sm.Providers = []Provider{provider}
+		sm.Concurrency = 2
+
+		for i := range stars {
+			assert.NoError(t, sm.DB.Save(&stars[i]))
+		}
+
+		result, err := sm.Cleanup(context.Background(), tc.opts)
+		assert.NoError(t, err)
+		assert.NoError(t, result.Err())
+		assert.Equal(t, len(tc.unstarred), result.Removed)
+		assert.ElementsMatch(t, tc.unstarred, provider.unstarred)
+
+		count, err := sm.DB.Count(&Star{})
+		assert.NoError(t, err)
+		assert.Equal(t, len(stars)-len(tc.unstarred), count)
+
+		cleanup()
+	}
+}
+
+func TestCleanupErrors(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	sm.Providers = []Provider{&fakeProvider{name: "github.com", err: errors.New("boom")}}
+
+	old := time.Now().AddDate(-1, 0, 0)
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/one", PushedAt: old}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://gitlab.com/a/two", Provider: "gitlab.com", PushedAt: old}))
+
+	result, err := sm.Cleanup(context.Background(), CleanupOptions{Months: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Matched)
+	assert.Equal(t, 0, result.Removed)
+	assert.Equal(t, 2, result.Failed)
+
+	multi, ok := result.Err().(*MultiError)
+	assert.True(t, ok)
+	assert.Len(t, multi.Errors, 2)
+	assert.Contains(t, multi.Error(), "2 errors occurred")
+	assert.Contains(t, multi.Error(), "removing https://github.com/a/one: boom")
+	assert.Contains(t, multi.Error(), "no provider configured for gitlab.com")
+
+	// Errors are encoded as their messages
+	encoded, err := json.Marshal(result)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `"failed":2`)
+	assert.Contains(t, string(encoded), `{"url":"https://github.com/a/one","error":"boom"}`)
+
+	// Stars that could not be unstarred stay cached
+	count, err := sm.DB.Count(&Star{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestCleanupDryRunAndConfirm(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	provider := &fakeProvider{name: "github.com"}
+	sm.Providers = []Provider{provider}
+
+	old := time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/stale", PushedAt: old, Archived: true}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/archived", PushedAt: time.Now(), Archived: true}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/fresh", PushedAt: time.Now()}))
+
+	opts := CleanupOptions{Months: 2, Archived: true, DryRun: true}
+	result, err := sm.Cleanup(context.Background(), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Matched)
+	assert.Equal(t, 0, result.Removed)
+	assert.Empty(t, provider.unstarred)
+
+	reasons := map[string][]string{}
+	for _, c := range result.Candidates {
+		reasons[c.Star.URL] = c.Reasons
+	}
+	assert.Equal(t, map[string][]string{
+		"https://github.com/a/stale":    {"last pushed 2019-01-02", "archived"},
+		"https://github.com/a/archived": {"archived"},
+	}, reasons)
+
+	sm.DryRun = true
+	removed, err := sm.RemoveStar(context.Background(), result.Candidates[0].Star)
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	assert.Empty(t, provider.unstarred)
+	sm.DryRun = false
+
+	opts.DryRun = false
+	opts.Confirm = func(candidates []*CleanupCandidate) []*CleanupCandidate {
+		confirmed := []*CleanupCandidate{}
+		for _, c := range candidates {
+			if c.Star.URL == "https://github.com/a/stale" {
+				confirmed = append(confirmed, c)
+			}
+		}
+
+		return confirmed
+	}
+
+	result, err = sm.Cleanup(context.Background(), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Removed)
+	assert.Equal(t, 1, result.Skipped)
+	assert.Equal(t, []string{"a/stale"}, provider.unstarred)
+}
+
+func TestSortProjects(t *testing.T) {
+	now := time.Now()
+	stars := []Star{
+		{URL: "https://github.com/b/popular", Stargazers: 100, PushedAt: now.AddDate(0, -1, 0), StarredAt: now.AddDate(-1, 0, 0)},
+		{URL: "https://github.com/a/active", Stargazers: 10, PushedAt: now, StarredAt: now.AddDate(0, -6, 0)},
+		{URL: "https://github.com/C/recent", Stargazers: 1, PushedAt: now.AddDate(-1, 0, 0), StarredAt: now},
+	}
+
+	testCases := []struct {
+		key      SortKey
+		order    SortOrder
+		expected []string
+	}{
+		{key: "", expected: []string{"b/popular", "a/active", "C/recent"}},
+		{key: SortStargazers, order: OrderAsc, expected: []string{"C/recent", "a/active", "b/popular"}},
+		{key: SortStarred, expected: []string{"C/recent", "a/active", "b/popular"}},
+		{key: SortPushed, expected: []string{"a/active", "b/popular", "C/recent"}},
+		{key: SortPushed, order: OrderAsc, expected: []string{"C/recent", "b/popular", "a/active"}},
+		{key: SortName, expected: []string{"a/active", "b/popular", "C/recent"}},
+		{key: SortName, order: OrderDesc, expected: []string{"C/recent", "b/popular", "a/active"}},
+	}
+
+	for _, tc := range testCases {
+		sorted := append([]Star{}, stars...)
+		assert.NoError(t, sortProjects(sorted, tc.key, tc.order))
+
+		names := []string{}
+		for i := range sorted {
+			owner, repo, err := ownerRepo(sorted[i].URL)
+			assert.NoError(t, err)
+			names = append(names, owner+"/"+repo)
+		}
+		assert.Equal(t, tc.expected, names)
+	}
+
+	assert.EqualError(t, sortProjects(stars, "forks", ""), `unknown sort key "forks"`)
+	assert.EqualError(t, sortProjects(stars, SortName, "up"), `unknown sort order "up"`)
+}
@@ -0,0 +1,119 @@
+package starmanager
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// unknown is the key stars without a detected language or license are counted under
+const unknown string = "(none)"
+
+// Stats are breakdowns of the cached stars
+type Stats struct {
+	Total    int `json:"total"`
+	Archived int `json:"archived"`
+	Active   int `json:"active"`
+
+	// Gone is the number of stars whose repositories no longer exist, as of the last
+	// Reconcile
+	Gone int `json:"gone"`
+
+	// MedianDaysSincePush is the median number of whole days since the stars were last
+	// pushed to
+	MedianDaysSincePush int `json:"median_days_since_push"`
+
+	// Languages, Topics, Licenses and Owners count stars per language, canonical topic,
+	// license and owner, most common first
+	Languages []KV `json:"languages"`
+	Topics    []KV `json:"topics"`
+	Licenses  []KV `json:"licenses"`
+	Owners    []KV `json:"owners"`
+
+	// Years counts stars per year they were starred in, oldest first
+	Years []KV `json:"years"`
+}
+
+// Stats computes breakdowns of the cached stars
+func (s *StarManager) Stats(ctx context.Context) (*Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stars, err := s.store().All()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	stats := &Stats{Total: len(stars)}
+	languages := map[string]int{}
+	topics := map[string]int{}
+	licenses := map[string]int{}
+	owners := map[string]int{}
+	years := map[string]int{}
+	sincePush := []time.Duration{}
+	aliases := s.topicAliases()
+
+	for _, star := range stars {
+		if star.Archived {
+			stats.Archived++
+		} else {
+			stats.Active++
+		}
+
+		if star.Gone {
+			stats.Gone++
+		}
+
+		language := star.Language
+		if language == "" {
+			language = unknown
+		}
+		languages[language]++
+
+		license := star.License
+		if license == "" {
+			license = unknown
+		}
+		licenses[license]++
+
+		for _, topic := range aliases.CanonicalTopics(star.Topics) {
+			topics[topic]++
+		}
+
+		if owner, _, err := star.OwnerRepo(); err == nil {
+			owners[owner]++
+		}
+
+		if !star.StarredAt.IsZero() {
+			years[strconv.Itoa(star.StarredAt.Year())]++
+		}
+
+		if !star.PushedAt.IsZero() {
+			sincePush = append(sincePush, now.Sub(star.PushedAt))
+		}
+	}
+
+	if len(sincePush) > 0 {
+		sort.Slice(sincePush, func(i, j int) bool { return sincePush[i] < sincePush[j] })
+
+		middle := len(sincePush) / 2
+		median := sincePush[middle]
+		if len(sincePush)%2 == 0 {
+			median = (sincePush[middle-1] + sincePush[middle]) / 2
+		}
+
+		stats.MedianDaysSincePush = int(median.Hours() / 24)
+	}
+
+	stats.Languages = sortedCounts(languages)
+	stats.Topics = sortedCounts(topics)
+	stats.Licenses = sortedCounts(licenses)
+	stats.Owners = sortedCounts(owners)
+	stats.Years = sortedCounts(years)
+	sort.Slice(stats.Years, func(i, j int) bool { return stats.Years[i].Key < stats.Years[j].Key })
+
+	return stats, nil
+}
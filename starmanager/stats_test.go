@@ -0,0 +1,36 @@
+package starmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	now := time.Now()
+	stars := []Star{
+		{URL: "https://github.com/a/one", Language: "go", License: "MIT", Topics: []string{"cli"}, StarredAt: time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC), PushedAt: now.Add(-10 * 24 * time.Hour)},
+		{URL: "https://github.com/a/two", Language: "go", Topics: []string{"cli", "tui"}, StarredAt: time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC), PushedAt: now.Add(-20 * 24 * time.Hour)},
+		{URL: "https://github.com/b/three", Archived: true, StarredAt: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for i := range stars {
+		assert.NoError(t, sm.DB.Save(&stars[i]))
+	}
+
+	stats, err := sm.Stats(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stats.Total)
+	assert.Equal(t, 1, stats.Archived)
+	assert.Equal(t, 2, stats.Active)
+	assert.Equal(t, []KV{{"go", 2}, {"(none)", 1}}, stats.Languages)
+	assert.Equal(t, []KV{{"cli", 2}, {"tui", 1}}, stats.Topics)
+	assert.Equal(t, []KV{{"(none)", 2}, {"MIT", 1}}, stats.Licenses)
+	assert.Equal(t, []KV{{"a", 2}, {"b", 1}}, stats.Owners)
+	assert.Equal(t, []KV{{"2019", 1}, {"2021", 2}}, stats.Years)
+	assert.Equal(t, 15, stats.MedianDaysSincePush)
+}
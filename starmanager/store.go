@@ -0,0 +1,262 @@
+package starmanager
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+)
+
+// ErrStarNotFound is returned by stores for stars that are not cached
+var ErrStarNotFound = storm.ErrNotFound
+
+// StarStore keeps the cached stars, keyed by their URL. Stars are returned ordered by URL.
+// Local metadata such as annotations, snapshots and the graveyard always stays in the
+// storm database.
+type StarStore interface {
+	// Save inserts a star or replaces the star with the same URL
+	Save(star *Star) error
+
+	// Get returns the star with the given URL, or ErrStarNotFound
+	Get(url string) (*Star, error)
+
+	// All returns every star
+	All() ([]*Star, error)
+
+	// Query returns the stars matching all of the given storm matchers
+	Query(matchers ...q.Matcher) ([]*Star, error)
+
+	// Delete removes the star with the given URL, if it is cached
+	Delete(url string) error
+
+	// Count returns the number of stars
+	Count() (int, error)
+}
+
+// StarIterator is implemented by stores that can hand out stars a few at a time instead of
+// loading all of them at once, see ForEachStar
+type StarIterator interface {
+	// Each calls fn with every star matching all of the given storm matchers, ordered by
+	// URL, stopping at the first error fn returns
+	Each(fn func(star *Star) error, matchers ...q.Matcher) error
+}
+
+// eachStar calls fn with every star in a store matching all matchers, streaming them if
+// the store supports it
+func eachStar(store StarStore, fn func(star *Star) error, matchers ...q.Matcher) error {
+	if iterator, ok := store.(StarIterator); ok {
+		return iterator.Each(fn, matchers...)
+	}
+
+	stars, err := store.Query(matchers...)
+	if err != nil {
+		return err
+	}
+
+	for _, star := range stars {
+		if err := fn(star); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// store returns where the stars are kept, the storm database unless Store is set
+func (s *StarManager) store() StarStore {
+	if s.Store != nil {
+		return s.Store
+	}
+
+	return NewBoltStore(s.DB)
+}
+
+// storeIn returns where the stars are kept within a storm transaction. Stars kept outside
+// the storm database are not part of the transaction.
+func (s *StarManager) storeIn(tx storm.Node) StarStore {
+	if s.Store != nil {
+		return s.Store
+	}
+
+	return NewBoltStore(tx)
+}
+
+// clearStars removes every cached star
+func (s *StarManager) clearStars() error {
+	if s.Store == nil {
+		return dropBucket(s.DB, &Star{})
+	}
+
+	stars, err := s.Store.All()
+	if err != nil {
+		return err
+	}
+
+	for _, star := range stars {
+		if err := s.Store.Delete(star.URL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BoltStore keeps stars in a storm node, the default
+type BoltStore struct {
+	node storm.Node
+}
+
+// NewBoltStore returns a store keeping stars in the given storm node
+func NewBoltStore(node storm.Node) *BoltStore {
+	return &BoltStore{node: node}
+}
+
+// Save inserts or replaces a star
+func (b *BoltStore) Save(star *Star) error {
+	return b.node.Save(star)
+}
+
+// Get returns the star with the given URL
+func (b *BoltStore) Get(url string) (*Star, error) {
+	star := &Star{}
+	if err := b.node.One("URL", url, star); err != nil {
+		return nil, err
+	}
+
+	return star, nil
+}
+
+// All returns every star
+func (b *BoltStore) All() ([]*Star, error) {
+	stars := []*Star{}
+	if err := b.node.All(&stars); err != nil {
+		return nil, err
+	}
+
+	return stars, nil
+}
+
+// Query returns the stars matching all matchers
+func (b *BoltStore) Query(matchers ...q.Matcher) ([]*Star, error) {
+	stars := []*Star{}
+	if err := b.node.Select(matchers...).Find(&stars); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return stars, nil
+}
+
+// Each calls fn with every star matching all matchers, decoding one star at a time while
+// walking a single cursor. The iteration runs in a read transaction, so fn must not write to
+// the store.
+func (b *BoltStore) Each(fn func(star *Star) error, matchers ...q.Matcher) error {
+	return b.node.Select(matchers...).Each(&Star{}, func(record interface{}) error {
+		return fn(record.(*Star))
+	})
+}
+
+// Delete removes the star with the given URL. The cached star is loaded first so that it is
+// removed from every index.
+func (b *BoltStore) Delete(url string) error {
+	star, err := b.Get(url)
+	if err == storm.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return b.node.DeleteStruct(star)
+}
+
+// Count returns the number of stars
+func (b *BoltStore) Count() (int, error) {
+	return b.node.Count(&Star{})
+}
+
+// MemoryStore keeps stars in memory, e.g. for tests. Stars are copied in and out, but the
+// slices and maps they hold are shared.
+type MemoryStore struct {
+	sync.RWMutex
+	stars map[string]Star
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{stars: map[string]Star{}}
+}
+
+// Save inserts or replaces a star
+func (m *MemoryStore) Save(star *Star) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.stars[star.URL] = *star
+	return nil
+}
+
+// Get returns the star with the given URL
+func (m *MemoryStore) Get(url string) (*Star, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	star, ok := m.stars[url]
+	if !ok {
+		return nil, ErrStarNotFound
+	}
+
+	return &star, nil
+}
+
+// All returns every star
+func (m *MemoryStore) All() ([]*Star, error) {
+	return m.Query()
+}
+
+// Query returns the stars matching all matchers
+func (m *MemoryStore) Query(matchers ...q.Matcher) ([]*Star, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return matchStars(m.stars, matchers)
+}
+
+// Delete removes the star with the given URL
+func (m *MemoryStore) Delete(url string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.stars, url)
+	return nil
+}
+
+// Count returns the number of stars
+func (m *MemoryStore) Count() (int, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return len(m.stars), nil
+}
+
+// matchStars returns the stars matching all matchers, ordered by URL, for stores that
+// evaluate storm matchers themselves
+func matchStars(stars map[string]Star, matchers []q.Matcher) ([]*Star, error) {
+	matched := []*Star{}
+	matcher := q.And(matchers...)
+
+	for _, star := range stars {
+		star := star
+		ok, err := matcher.Match(&star)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			matched = append(matched, &star)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].URL < matched[j].URL })
+
+	return matched, nil
+}
@@ -0,0 +1,173 @@
+package starmanager
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/asdine/storm/q"
+)
+
+// sqlSchema creates the table of SQLStore. Besides the whole star as JSON, the fields most
+// useful in queries get columns of their own.
+const sqlSchema = `CREATE TABLE IF NOT EXISTS stars (
+	url         TEXT PRIMARY KEY,
+	provider    TEXT NOT NULL,
+	language    TEXT NOT NULL,
+	description TEXT NOT NULL,
+	stargazers  INTEGER NOT NULL,
+	archived    BOOLEAN NOT NULL,
+	pushed_at   TEXT NOT NULL,
+	starred_at  TEXT NOT NULL,
+	data        TEXT NOT NULL
+)`
+
+// SQLStore keeps stars in a SQLite database, so they can also be queried with SQL:
+//
+//	SELECT url, stargazers FROM stars WHERE language = 'go' ORDER BY stargazers DESC;
+//
+// The database is opened by the caller with a SQLite driver of their choice. Times are
+// stored as RFC 3339 text. Queries with storm matchers are evaluated in memory.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns a store keeping stars in the stars table of a SQLite database,
+// creating the table if needed
+func NewSQLStore(db *sql.DB) (*SQLStore, error) {
+	if _, err := db.Exec(sqlSchema); err != nil {
+		return nil, err
+	}
+
+	return &SQLStore{db: db}, nil
+}
+
+// Save inserts or replaces a star
+func (s *SQLStore) Save(star *Star) error {
+	data, err := json.Marshal(star)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		`INSERT OR REPLACE INTO stars (url, provider, language, description, stargazers, archived, pushed_at, starred_at, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		star.URL,
+		star.ProviderName(),
+		star.Language,
+		star.Description,
+		star.Stargazers,
+		star.Archived,
+		formatTime(star.PushedAt),
+		formatTime(star.StarredAt),
+		string(data),
+	)
+
+	return err
+}
+
+// Get returns the star with the given URL
+func (s *SQLStore) Get(url string) (*Star, error) {
+	var data string
+	if err := s.db.QueryRow(`SELECT data FROM stars WHERE url = ?`, url).Scan(&data); err == sql.ErrNoRows {
+		return nil, ErrStarNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	star := &Star{}
+	if err := json.Unmarshal([]byte(data), star); err != nil {
+		return nil, err
+	}
+
+	return star, nil
+}
+
+// All returns every star
+func (s *SQLStore) All() ([]*Star, error) {
+	rows, err := s.db.Query(`SELECT data FROM stars ORDER BY url`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stars := []*Star{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		star := &Star{}
+		if err := json.Unmarshal([]byte(data), star); err != nil {
+			return nil, err
+		}
+
+		stars = append(stars, star)
+	}
+
+	return stars, rows.Err()
+}
+
+// Query returns the stars matching all matchers
+func (s *SQLStore) Query(matchers ...q.Matcher) ([]*Star, error) {
+	stars, err := s.All()
+	if err != nil {
+		return nil, err
+	}
+
+	byURL := make(map[string]Star, len(stars))
+	for _, star := range stars {
+		byURL[star.URL] = *star
+	}
+
+	return matchStars(byURL, matchers)
+}
+
+// Each calls fn with every star matching all matchers while reading them from the database.
+// fn must not write to the store, as the rows are still being read.
+func (s *SQLStore) Each(fn func(star *Star) error, matchers ...q.Matcher) error {
+	rows, err := s.db.Query(`SELECT data FROM stars ORDER BY url`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	matcher := q.And(matchers...)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+
+		star := &Star{}
+		if err := json.Unmarshal([]byte(data), star); err != nil {
+			return err
+		}
+
+		if ok, err := matcher.Match(star); err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+
+		if err := fn(star); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Delete removes the star with the given URL
+func (s *SQLStore) Delete(url string) error {
+	_, err := s.db.Exec(`DELETE FROM stars WHERE url = ?`, url)
+	return err
+}
+
+// Count returns the number of stars
+func (s *SQLStore) Count() (int, error) {
+	count := 0
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM stars`).Scan(&count)
+
+	return count, err
+}
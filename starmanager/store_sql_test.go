@@ -0,0 +1,45 @@
+package starmanager
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLStore(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// Every connection to :memory: opens a database of its own
+	db.SetMaxOpenConns(1)
+
+	store, err := NewSQLStore(db)
+	assert.NoError(t, err)
+
+	testStore(t, store)
+
+	// The columns can be queried with SQL
+	rows, err := db.Query(`SELECT url FROM stars WHERE language = 'go' AND stargazers > 10 AND pushed_at >= '2020-01-01'`)
+	assert.NoError(t, err)
+	defer rows.Close()
+
+	urls := []string{}
+	for rows.Next() {
+		url := ""
+		assert.NoError(t, rows.Scan(&url))
+		urls = append(urls, url)
+	}
+	assert.NoError(t, rows.Err())
+	assert.Equal(t, []string{"https://github.com/gkze/stars"}, urls)
+
+	// Creating the store again keeps the stars
+	store, err = NewSQLStore(db)
+	assert.NoError(t, err)
+
+	count, err := store.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
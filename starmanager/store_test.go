@@ -0,0 +1,177 @@
+package starmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/q"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStore checks the behavior every StarStore shares
+func testStore(t *testing.T, store StarStore) {
+	count, err := store.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	_, err = store.Get("https://github.com/gkze/stars")
+	assert.Equal(t, ErrStarNotFound, err)
+
+	stars, err := store.All()
+	assert.NoError(t, err)
+	assert.Empty(t, stars)
+
+	pushedAt := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, star := range []*Star{
+		{URL: "https://github.com/gkze/stars", Language: "go", Stargazers: 10, PushedAt: pushedAt},
+		{URL: "https://github.com/acme/archived", Language: "python", Archived: true},
+		{URL: "https://gitlab.com/acme/tool", Language: "go", Topics: []string{"cli"}},
+	} {
+		assert.NoError(t, store.Save(star))
+	}
+
+	// Saving again replaces the star
+	assert.NoError(t, store.Save(&Star{URL: "https://github.com/gkze/stars", Language: "go", Stargazers: 11, PushedAt: pushedAt}))
+
+	count, err = store.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	star, err := store.Get("https://github.com/gkze/stars")
+	assert.NoError(t, err)
+	assert.Equal(t, 11, star.Stargazers)
+	assert.True(t, pushedAt.Equal(star.PushedAt))
+
+	stars, err = store.All()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"https://github.com/acme/archived",
+		"https://github.com/gkze/stars",
+		"https://gitlab.com/acme/tool",
+	}, starURLs(stars))
+
+	stars, err = store.Query(q.Eq("Language", "go"), q.Not(q.Eq("Archived", true)))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://github.com/gkze/stars", "https://gitlab.com/acme/tool"}, starURLs(stars))
+
+	stars, err = store.Query(q.Eq("Language", "rust"))
+	assert.NoError(t, err)
+	assert.Empty(t, stars)
+
+	// Streamed stars come in the same order
+	streamed := []*Star{}
+	assert.NoError(t, eachStar(store, func(star *Star) error {
+		streamed = append(streamed, star)
+		return nil
+	}, q.Eq("Language", "go")))
+	assert.Equal(t, []string{"https://github.com/gkze/stars", "https://gitlab.com/acme/tool"}, starURLs(streamed))
+
+	streamed = []*Star{}
+	assert.Equal(t, ErrStopIteration, eachStar(store, func(star *Star) error {
+		streamed = append(streamed, star)
+		return ErrStopIteration
+	}))
+	assert.Len(t, streamed, 1)
+
+	assert.NoError(t, store.Delete("https://github.com/acme/archived"))
+	assert.NoError(t, store.Delete("https://github.com/acme/missing"))
+
+	// Deleted stars are gone from queries on indexed fields too
+	stars, err = store.Query(q.Eq("Archived", true))
+	assert.NoError(t, err)
+	assert.Empty(t, stars)
+
+	count, err = store.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestBoltStore(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	testStore(t, NewBoltStore(sm.DB))
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestForEachStar(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	for i, name := range []string{"e", "b", "d", "a", "c"} {
+		assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/acme/" + name, Language: "go", Stargazers: i}))
+	}
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/acme/rusty", Language: "rust"}))
+	assert.NoError(t, sm.SaveAnnotation(&Annotation{URL: "https://github.com/acme/d", Tags: []string{"toolbox"}}))
+
+	names := func(opts ProjectOptions) []string {
+		names := []string{}
+		assert.NoError(t, sm.ForEachStar(context.Background(), opts, func(star *Star) error {
+			names = append(names, projectName(star))
+			return nil
+		}))
+
+		return names
+	}
+
+	assert.Equal(t, []string{"acme/a", "acme/b", "acme/c", "acme/d", "acme/e"}, names(ProjectOptions{Sort: SortURL, Language: "go"}))
+	assert.Equal(t, []string{"acme/a", "acme/b", "acme/c"}, names(ProjectOptions{Sort: SortURL, Language: "go", Count: 3}))
+	assert.Equal(t, []string{"acme/d"}, names(ProjectOptions{Sort: SortURL, Tags: []string{"toolbox"}}))
+
+	// Other orders load the stars first
+	assert.Equal(t, []string{"acme/c", "acme/a"}, names(ProjectOptions{Language: "go", Count: 2}))
+	assert.Equal(t, []string{"acme/rusty", "acme/e"}, names(ProjectOptions{Sort: SortURL, Order: OrderDesc, Count: 2}))
+
+	// No matches are not an error
+	assert.Empty(t, names(ProjectOptions{Sort: SortURL, Language: "haskell"}))
+
+	err := sm.ForEachStar(context.Background(), ProjectOptions{Sort: SortURL}, func(star *Star) error {
+		return ErrStarNotFound
+	})
+	assert.Equal(t, ErrStarNotFound, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, sm.ForEachStar(ctx, ProjectOptions{Sort: SortURL}, func(star *Star) error { return nil }))
+}
+
+func TestStarManagerWithStore(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	store := NewMemoryStore()
+	sm.Store = store
+
+	assert.NoError(t, store.Save(&Star{URL: "https://github.com/gkze/stars", Language: "go"}))
+	assert.NoError(t, store.Save(&Star{URL: "https://github.com/acme/tool", Language: "rust"}))
+	assert.NoError(t, sm.SaveAnnotation(&Annotation{URL: "https://github.com/gkze/stars", Tags: []string{"toolbox"}}))
+
+	stars, err := sm.GetProjects(context.Background(), ProjectOptions{Language: "go"})
+	assert.NoError(t, err)
+	assert.Len(t, stars, 1)
+	assert.Equal(t, "https://github.com/gkze/stars", stars[0].URL)
+
+	// The stars are not kept in the db
+	count, err := sm.DB.Count(&Star{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	cleared, err := sm.ClearStars(context.Background(), ClearOptions{Language: "rust"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cleared)
+
+	assert.NoError(t, sm.ClearCache(false))
+
+	count, err = store.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// Local metadata stays in the db
+	annotation, err := sm.GetAnnotation("https://github.com/gkze/stars")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"toolbox"}, annotation.Tags)
+}
@@ -0,0 +1,247 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/google/go-github/v25/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// SubscriptionNode - the name of the storm node holding the repositories the user watches
+const SubscriptionNode string = "subscriptions"
+
+// Subscription is a GitHub repository the user watches, i.e. gets notified about
+type Subscription struct {
+	URL string `storm:"id"`
+
+	// Repository is the state of the repository as of the last SyncSubscriptions, kept as a
+	// star so that the cleanup rules for stars apply to subscriptions as well. It has no
+	// starred date.
+	Repository Star
+
+	// SyncedAt is when the subscription was last synced
+	SyncedAt time.Time
+}
+
+// SubscriptionComparison cross-references the repositories the user watches with their stars
+type SubscriptionComparison struct {
+	// Unstarred are the watched repositories that are not starred
+	Unstarred []*Subscription `json:"unstarred"`
+
+	// Unwatched are the GitHub stars whose repositories are not watched
+	Unwatched []*Star `json:"unwatched"`
+}
+
+// subscriptions returns the storm node holding watched repositories
+func (s *StarManager) subscriptions() storm.Node {
+	return s.DB.From(SubscriptionNode)
+}
+
+// Subscriptions returns the repositories the user watches as of the last SyncSubscriptions,
+// sorted by URL
+func (s *StarManager) Subscriptions(ctx context.Context) ([]*Subscription, error) {
+	subscriptions := []*Subscription{}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.subscriptions().All(&subscriptions); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].URL < subscriptions[j].URL })
+
+	return subscriptions, nil
+}
+
+// fetchSubscriptions fetches all repositories the authenticated user watches from GitHub
+func (s *StarManager) fetchSubscriptions(ctx context.Context) ([]*Subscription, error) {
+	gh := &GitHubProvider{Host: webHost(s.Host), Client: s.Client}
+	t := &throttle{}
+	now := time.Now()
+	subscriptions := []*Subscription{}
+
+	for page := 1; page != 0; {
+		var (
+			repos []*github.Repository
+			resp  *github.Response
+		)
+
+		err := withRetry(ctx, t, fmt.Sprintf("page %d of watched repositories", page), func() error {
+			var err error
+			repos, resp, err = s.Client.Activity.ListWatched(ctx, "", &github.ListOptions{Page: page, PerPage: PageSize})
+			if err != nil {
+				return githubRateLimitError(err)
+			}
+
+			t.observe(githubRate(resp))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, repo := range repos {
+			if repo.HTMLURL == nil || repo.StargazersCount == nil || repo.Archived == nil {
+				continue
+			}
+
+			star := gh.star(&github.StarredRepository{Repository: repo})
+			star.setOwnerRepo()
+			subscriptions = append(subscriptions, &Subscription{URL: star.URL, Repository: *star, SyncedAt: now})
+		}
+
+		page = resp.NextPage
+	}
+
+	return subscriptions, nil
+}
+
+// SyncSubscriptions fetches the repositories the user watches on GitHub and replaces the
+// stored ones with them. It returns the number of watched repositories.
+func (s *StarManager) SyncSubscriptions(ctx context.Context) (int, error) {
+	subscriptions, err := s.fetchSubscriptions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.DB.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	subscriptionsTx := tx.From(SubscriptionNode)
+	if err := dropBucket(subscriptionsTx, &Subscription{}); err != nil {
+		return 0, err
+	}
+
+	for _, subscription := range subscriptions {
+		if err := subscriptionsTx.Save(subscription); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	log.Printf("Synced %d watched repositories", len(subscriptions))
+	return len(subscriptions), nil
+}
+
+// CompareSubscriptions returns the watched repositories that are not starred and the GitHub
+// stars that are not watched, as of the last SyncSubscriptions
+func (s *StarManager) CompareSubscriptions(ctx context.Context) (*SubscriptionComparison, error) {
+	subscriptions, err := s.Subscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stars, err := s.store().All()
+	if err != nil {
+		return nil, err
+	}
+
+	starred := map[string]bool{}
+	for _, star := range stars {
+		starred[strings.ToLower(star.URL)] = true
+	}
+
+	watched := map[string]bool{}
+	comparison := &SubscriptionComparison{Unstarred: []*Subscription{}, Unwatched: []*Star{}}
+
+	for _, subscription := range subscriptions {
+		watched[strings.ToLower(subscription.URL)] = true
+
+		if !starred[strings.ToLower(subscription.URL)] {
+			comparison.Unstarred = append(comparison.Unstarred, subscription)
+		}
+	}
+
+	host := webHost(s.Host)
+	for _, star := range stars {
+		if star.Provider != "" && star.Provider != host {
+			continue
+		}
+
+		if !watched[strings.ToLower(star.URL)] {
+			comparison.Unwatched = append(comparison.Unwatched, star)
+		}
+	}
+
+	sort.Slice(comparison.Unwatched, func(i, j int) bool { return comparison.Unwatched[i].URL < comparison.Unwatched[j].URL })
+
+	return comparison, nil
+}
+
+// Unwatch stops watching a repository on GitHub and forgets the subscription. In dry run mode
+// nothing is changed and false is returned.
+func (s *StarManager) Unwatch(ctx context.Context, url string) (bool, error) {
+	owner, repo, err := ownerRepo(url)
+	if err != nil {
+		return false, err
+	}
+
+	if s.DryRun {
+		log.Printf("Would unwatch %s", url)
+		return false, nil
+	}
+
+	// Repositories that no longer exist are not watched anymore either
+	resp, err := s.Client.Activity.DeleteRepositorySubscription(ctx, owner, repo)
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		log.Printf("An error occurred while attempting to unwatch %s: %s", url, err.Error())
+		return false, githubRateLimitError(err)
+	}
+
+	if err := s.subscriptions().DeleteStruct(&Subscription{URL: url}); err != nil && err != storm.ErrNotFound {
+		return false, err
+	}
+
+	log.Printf("Unwatched %s", url)
+
+	return true, nil
+}
+
+// SubscriptionCleanupCandidates returns the watched repositories CleanupSubscriptions would
+// unwatch with the given options, which select them like the stars Cleanup removes. As
+// watched repositories have no starred date, ByStarred and starred ages never match them.
+func (s *StarManager) SubscriptionCleanupCandidates(ctx context.Context, opts CleanupOptions) ([]*CleanupCandidate, error) {
+	subscriptions, err := s.Subscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]*Star, len(subscriptions))
+	for i, subscription := range subscriptions {
+		repos[i] = &subscription.Repository
+	}
+
+	log.Printf("Filtering watched repositories to unwatch (from %d)...", len(repos))
+	return s.cleanupCandidates(repos, opts)
+}
+
+// CleanupSubscriptions unwatches the watched repositories matching the given options, as
+// selected by SubscriptionCleanupCandidates. A non-nil error is only returned if the cleanup
+// could not run at all; repositories that could not be unwatched are reported in the result.
+func (s *StarManager) CleanupSubscriptions(ctx context.Context, opts CleanupOptions) (*CleanupResult, error) {
+	candidates, err := s.SubscriptionCleanupCandidates(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.removeCandidates(ctx, "watched repositories", candidates, opts, func(candidate *CleanupCandidate) error {
+		_, err := s.Unwatch(ctx, candidate.Star.URL)
+		return err
+	})
+
+	return result, ctx.Err()
+}
@@ -0,0 +1,95 @@
+package starmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptions(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	pushed := func(months int) string {
+		return time.Now().AddDate(0, -months, 0).UTC().Format(time.RFC3339)
+	}
+
+	mu := sync.Mutex{}
+	unwatched := []string{}
+
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodDelete:
+			mu.Lock()
+			unwatched = append(unwatched, r.URL.Path)
+			mu.Unlock()
+
+			if r.URL.Path == "/repos/a/deleted/subscription" {
+				http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Query().Get("page") == "2":
+			fmt.Fprintf(w, `[{"html_url": "https://github.com/a/deleted", "stargazers_count": 1, "archived": true, "pushed_at": %q}]`, pushed(1))
+		default:
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/user/subscriptions?page=2>; rel="next"`, r.Host))
+			fmt.Fprintf(w, `[
+				{"html_url": "https://github.com/a/starred", "stargazers_count": 10, "archived": false, "pushed_at": %q},
+				{"html_url": "https://github.com/a/stale", "stargazers_count": 5, "archived": false, "pushed_at": %q}
+			]`, pushed(1), pushed(24))
+		}
+	}))
+	defer closeGitHub()
+
+	sm.Host = GitHub
+	sm.Client = client
+
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/starred", Provider: "github.com"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/quiet", Provider: "github.com"}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://gitlab.com/b/elsewhere", Provider: "gitlab.com"}))
+
+	count, err := sm.SyncSubscriptions(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	subscriptions, err := sm.Subscriptions(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, subscriptions, 3)
+	assert.Equal(t, "https://github.com/a/deleted", subscriptions[0].URL)
+	assert.Equal(t, "deleted", subscriptions[0].Repository.RepoName)
+	assert.True(t, subscriptions[0].Repository.Archived)
+
+	comparison, err := sm.CompareSubscriptions(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, comparison.Unstarred, 2)
+	assert.Equal(t, "https://github.com/a/deleted", comparison.Unstarred[0].URL)
+	assert.Equal(t, "https://github.com/a/stale", comparison.Unstarred[1].URL)
+	assert.Len(t, comparison.Unwatched, 1)
+	assert.Equal(t, "https://github.com/a/quiet", comparison.Unwatched[0].URL)
+
+	result, err := sm.CleanupSubscriptions(context.Background(), CleanupOptions{Months: 12, ByStarred: true, DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Matched)
+
+	result, err = sm.CleanupSubscriptions(context.Background(), CleanupOptions{Months: 12, Archived: true, DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Matched)
+	assert.Empty(t, unwatched)
+
+	result, err = sm.CleanupSubscriptions(context.Background(), CleanupOptions{Months: 12, Archived: true})
+	assert.NoError(t, err)
+	assert.NoError(t, result.Err())
+	assert.Equal(t, 2, result.Removed)
+	assert.ElementsMatch(t, []string{"/repos/a/deleted/subscription", "/repos/a/stale/subscription"}, unwatched)
+
+	subscriptions, err = sm.Subscriptions(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, subscriptions, 1)
+	assert.Equal(t, "https://github.com/a/starred", subscriptions[0].URL)
+}
@@ -0,0 +1,686 @@
+package starmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// SyncNode - the name of the storm node holding sync bookkeeping
+	SyncNode string = "sync"
+
+	// MaxIncrementalAge - how long after the last full sync incremental syncs are trusted
+	// before falling back to a full sync
+	MaxIncrementalAge time.Duration = 7 * 24 * time.Hour
+
+	// starredAccept - the media types needed to get starred_at timestamps and topics
+	starredAccept string = "application/vnd.github.v3.star+json, application/vnd.github.mercy-preview+json"
+)
+
+// SyncOptions configures how Sync fetches and stores stars
+type SyncOptions struct {
+	// Prune removes cached stars that are no longer starred upstream. Pruning is skipped
+	// if any page failed to fetch, since the set of seen stars would be incomplete.
+	Prune bool
+
+	// Activity additionally fetches the weekly commit activity of every star
+	Activity bool
+
+	// Languages additionally fetches the full language breakdown of every star
+	Languages bool
+
+	// Releases additionally fetches the latest release of every star
+	Releases bool
+
+	// Readmes additionally downloads the README of every star in ReadmeFormat, Markdown if
+	// unset
+	Readmes      bool
+	ReadmeFormat ReadmeFormat
+
+	// Lists additionally syncs the GitHub lists the stars are organized in
+	Lists bool
+
+	// Incremental sends conditional requests using the ETags recorded by the previous
+	// sync, so that unchanged pages are neither downloaded nor counted against the rate
+	// limit. A full sync is performed instead if there is no previous sync state or the
+	// last full sync is older than MaxIncrementalAge.
+	Incremental bool
+
+	// Resume continues an interrupted sync, skipping the pages it already saved
+	Resume bool
+}
+
+// SyncError describes a single page or star that failed to sync
+type SyncError struct {
+	// Provider is the name of the provider the failure occurred with
+	Provider string
+
+	// Page is the page number the failure occurred on
+	Page int
+
+	// URL is the URL of the star that failed to save, empty for page fetch failures
+	URL string
+
+	// Err is the underlying error
+	Err error
+}
+
+func (e *SyncError) Error() string {
+	if e.URL != "" {
+		return fmt.Sprintf("saving %s (page %d): %v", e.URL, e.Page, e.Err)
+	}
+
+	return fmt.Sprintf("fetching page %d from %s: %v", e.Page, e.Provider, e.Err)
+}
+
+// MarshalJSON encodes the failure along with the message of the underlying error
+func (e *SyncError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Provider string `json:"provider"`
+		Page     int    `json:"page"`
+		URL      string `json:"url,omitempty"`
+		Error    string `json:"error"`
+	}{e.Provider, e.Page, e.URL, e.Err.Error()})
+}
+
+// SyncResult summarizes the outcome of a sync
+type SyncResult struct {
+	Added     int           `json:"added"`
+	Updated   int           `json:"updated"`
+	Removed   int           `json:"removed"`
+	Failed    int           `json:"failed"`
+	Unchanged int           `json:"unchanged"`
+	Resumed   int           `json:"resumed"`
+	Duration  time.Duration `json:"duration"`
+	Errors    []*SyncError  `json:"errors"`
+
+	// Diff is what changed since the previous sync, nil on the first sync
+	Diff *SyncDiff `json:"diff"`
+}
+
+// Succeeded reports whether every page and star synced without error
+func (r *SyncResult) Succeeded() bool {
+	return r.Err() == nil
+}
+
+// PageState records what a page of stars looked like when it was last fetched
+type PageState struct {
+	ID       string `storm:"id"`
+	Provider string `storm:"index"`
+	Page     int
+	ETag     string
+	URLs     []string
+	SyncedAt time.Time
+}
+
+// SyncMeta records when the stars of a provider were last synced
+type SyncMeta struct {
+	// Provider is the name of the provider
+	Provider      string `storm:"id"`
+	FullAt        time.Time
+	IncrementalAt time.Time
+
+	// StartedAt is when the latest sync started, and LastPage the page count it found.
+	// If the sync was interrupted, pages saved since StartedAt can be skipped on resume.
+	StartedAt time.Time
+	LastPage  int
+}
+
+// Interrupted reports whether the latest sync was started but never finished
+func (m *SyncMeta) Interrupted() bool {
+	return !m.StartedAt.IsZero() && m.StartedAt.After(m.FullAt) && m.StartedAt.After(m.IncrementalAt)
+}
+
+// syncState accumulates results from concurrently synced pages
+type syncState struct {
+	sync.Mutex
+	result *SyncResult
+	seen   map[string]bool
+}
+
+func (st *syncState) fail(err *SyncError) {
+	st.Lock()
+	defer st.Unlock()
+
+	log.Printf("Sync error: %v", err.Error())
+	st.result.Failed++
+	st.result.Errors = append(st.result.Errors, err)
+}
+
+// providerSync is the state of syncing a single provider
+type providerSync struct {
+	*syncState
+	provider    Provider
+	pages       map[int]*PageState
+	throttle    *throttle
+	incremental bool
+	complete    bool
+
+	// resumeFrom is the start of the interrupted sync being resumed, pages saved after
+	// which are not fetched again
+	resumeFrom time.Time
+}
+
+// syncNode returns the storm node holding sync bookkeeping
+func (s *StarManager) syncNode() storm.Node {
+	return s.DB.From(SyncNode)
+}
+
+// resetSyncState forgets the recorded page states, forcing the next sync to fetch every page.
+// It must be called whenever stars are removed from the cache behind the sync's back.
+func (s *StarManager) resetSyncState() error {
+	return dropBucket(s.syncNode(), &PageState{})
+}
+
+// LastSync returns when the stars of the given provider were last synced
+func (s *StarManager) LastSync(provider string) (*SyncMeta, error) {
+	meta := &SyncMeta{Provider: provider}
+	if err := s.syncNode().One("Provider", provider, meta); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// SaveStar saves a single star fetched from a provider to the local cache, reporting whether
+// it was newly added (as opposed to updated). Enrichments and list memberships are fetched
+// separately, so they are carried over from the cached star.
+func (s *StarManager) SaveStar(star *Star) (bool, error) {
+	return saveStar(s.store(), star, s.topicAliases())
+}
+
+// saveStar saves a star fetched from a provider to the given store with its topics made
+// canonical by the given aliases, see SaveStar
+func saveStar(stars StarStore, star *Star, aliases TopicAliases) (bool, error) {
+	existing, err := stars.Get(star.URL)
+	added := false
+	if err == storm.ErrNotFound {
+		existing, added = &Star{}, true
+	} else if err != nil {
+		return false, err
+	}
+
+	star.Activity = existing.Activity
+	star.ActivityAt = existing.ActivityAt
+	star.Languages = existing.Languages
+	star.LanguagesAt = existing.LanguagesAt
+
+	// Only some providers report releases along with the stars
+	if star.LatestRelease == "" && star.ReleasedAt.IsZero() {
+		star.LatestRelease = existing.LatestRelease
+		star.ReleasedAt = existing.ReleasedAt
+	}
+
+	// GitHub's REST API does not report the parents of forks, see FetchParents
+	if star.Fork && star.Parent == "" {
+		star.Parent = existing.Parent
+	}
+
+	star.Lists = existing.Lists
+	star.setOwnerRepo()
+
+	if len(star.Topics) > 0 {
+		star.Topics = aliases.CanonicalTopics(star.Topics)
+	}
+
+	if err := stars.Save(star); err != nil {
+		return false, err
+	}
+
+	log.Printf("Saved %s (with topics %s)\n", star.URL, star.Topics)
+	return added, nil
+}
+
+// savePage saves a page of stars along with its sync state in a single transaction, so that
+// the workers syncing pages take the database lock once per page instead of once per star.
+// Stars that cannot be saved are recorded in the sync state. A page that is only partially
+// saved because the sync was canceled is not recorded, so that it is fetched again next time.
+func (s *StarManager) savePage(ctx context.Context, page int, starPage *StarPage, ps *providerSync) error {
+	name := ps.provider.Name()
+
+	_, span := tracer().Start(ctx, "save page")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("provider", name),
+		attribute.Int("page", page),
+		attribute.Int("stars", len(starPage.Stars)),
+	)
+
+	log.Printf("Attempting to save starred projects on page %d of %s...\n", page, name)
+
+	tx, err := s.DB.Begin(true)
+	if err != nil {
+		recordError(span, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	stars := s.storeIn(tx)
+	urls := make([]string, 0, len(starPage.Stars))
+	added, updated := 0, 0
+	failures := []*SyncError{}
+
+	for _, star := range starPage.Stars {
+		if ctx.Err() != nil {
+			break
+		}
+
+		urls = append(urls, star.URL)
+
+		ps.Lock()
+		ps.seen[star.URL] = true
+		ps.Unlock()
+
+		isNew, err := saveStar(stars, star, s.topicAliases())
+		if err != nil {
+			failures = append(failures, &SyncError{Provider: name, Page: page, URL: star.URL, Err: err})
+			span.RecordError(err)
+			continue
+		}
+
+		if isNew {
+			added++
+		} else {
+			updated++
+		}
+	}
+
+	if ctx.Err() == nil {
+		state := &PageState{
+			ID:       fmt.Sprintf("%s:%d", name, page),
+			Provider: name,
+			Page:     page,
+			ETag:     starPage.ETag,
+			URLs:     urls,
+			SyncedAt: time.Now(),
+		}
+		if err := tx.From(SyncNode).Save(state); err != nil {
+			recordError(span, err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		recordError(span, err)
+		return err
+	}
+
+	for _, failure := range failures {
+		ps.fail(failure)
+	}
+	starsSaved.WithLabelValues(name).Add(float64(added + updated))
+
+	ps.Lock()
+	ps.result.Added += added
+	ps.result.Updated += updated
+	ps.Unlock()
+
+	return ctx.Err()
+}
+
+// syncPage fetches and saves a single page, skipping it if it has not changed since the
+// previous sync
+func (s *StarManager) syncPage(ctx context.Context, page int, ps *providerSync) (*StarPage, error) {
+	etag := ""
+	previous, known := ps.pages[page]
+	if ps.incremental && known {
+		etag = previous.ETag
+	}
+
+	if known && !ps.resumeFrom.IsZero() && previous.SyncedAt.After(ps.resumeFrom) {
+		log.Printf("Page %d of %s was saved before the sync was interrupted", page, ps.provider.Name())
+
+		ps.Lock()
+		for _, url := range previous.URLs {
+			ps.seen[url] = true
+		}
+		ps.result.Resumed++
+		ps.Unlock()
+
+		return &StarPage{NotModified: true}, nil
+	}
+
+	starPage, err := listStarsWithRetry(ctx, ps.provider, ps.throttle, page, etag)
+	if err != nil {
+		return nil, err
+	}
+	pagesFetched.WithLabelValues(ps.provider.Name()).Inc()
+
+	if starPage.NotModified {
+		log.Printf("Page %d of %s has not changed since the last sync", page, ps.provider.Name())
+
+		ps.Lock()
+		for _, url := range previous.URLs {
+			ps.seen[url] = true
+		}
+		ps.result.Unchanged++
+		ps.Unlock()
+
+		return starPage, nil
+	}
+
+	if len(starPage.Stars) == 0 {
+		return starPage, nil
+	}
+
+	if err := s.savePage(ctx, page, starPage, ps); err != nil {
+		return nil, err
+	}
+
+	return starPage, nil
+}
+
+// Sync fetches all of the user's stars from every configured provider and saves them to the
+// local cache. A non-nil error is only returned if the sync could not run at all or was
+// canceled through the context, in which case the result covers the work done so far;
+// failures of individual providers, pages or stars are reported in the result.
+func (s *StarManager) Sync(ctx context.Context, opts SyncOptions) (*SyncResult, error) {
+	ctx, span := tracer().Start(ctx, "sync")
+	defer span.End()
+
+	start := time.Now()
+	st := &syncState{result: &SyncResult{}, seen: map[string]bool{}}
+
+	states := []*PageState{}
+	if err := s.syncNode().All(&states); err != nil {
+		return nil, err
+	}
+
+	synced := []*providerSync{}
+	for _, p := range s.Providers {
+		ps := &providerSync{
+			syncState: st,
+			provider:  p,
+			pages:     map[int]*PageState{},
+			throttle:  &throttle{},
+		}
+		for _, state := range states {
+			if state.Provider == p.Name() {
+				ps.pages[state.Page] = state
+			}
+		}
+
+		if err := s.syncProvider(ctx, ps, opts); err != nil {
+			if len(s.Providers) == 1 {
+				return nil, err
+			}
+
+			ps.fail(&SyncError{Provider: p.Name(), Page: 1, Err: err})
+			continue
+		}
+
+		synced = append(synced, ps)
+	}
+
+	if len(synced) == 0 && len(s.Providers) > 0 {
+		return nil, fmt.Errorf("could not sync stars from any provider")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return st.result, err
+	}
+
+	if opts.Prune {
+		for _, ps := range synced {
+			if err := s.prune(ps); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.Activity {
+		if _, err := s.FetchActivity(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Languages {
+		if _, err := s.FetchLanguages(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Releases {
+		if _, err := s.FetchReleases(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Readmes {
+		if _, err := s.FetchReadmes(ctx, opts.ReadmeFormat); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Lists {
+		if _, err := s.SyncLists(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if st.result.Added+st.result.Updated+st.result.Removed > 0 {
+		if err := s.Reindex(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	previous, err := s.latestSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := s.TakeSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if previous != nil {
+		st.result.Diff = diffSnapshots(previous, snapshot)
+	}
+
+	st.result.Duration = time.Since(start)
+	syncDuration.Observe(st.result.Duration.Seconds())
+	span.SetAttributes(
+		attribute.Int("added", st.result.Added),
+		attribute.Int("updated", st.result.Updated),
+		attribute.Int("removed", st.result.Removed),
+		attribute.Int("failed", st.result.Failed),
+	)
+
+	log.Printf(
+		"Synced stars in %s: %d added, %d updated, %d removed, %d failed, %d pages unchanged, %d pages resumed",
+		st.result.Duration,
+		st.result.Added,
+		st.result.Updated,
+		st.result.Removed,
+		st.result.Failed,
+		st.result.Unchanged,
+		st.result.Resumed,
+	)
+
+	return st.result, nil
+}
+
+// syncProvider syncs the stars of a single provider. The first page is fetched up front to
+// determine the page count, after which the remaining pages are fetched by a bounded pool of
+// workers, or one after another for a SequentialProvider. An error is only returned if the
+// first page could not be fetched.
+func (s *StarManager) syncProvider(ctx context.Context, ps *providerSync, opts SyncOptions) error {
+	name := ps.provider.Name()
+
+	ctx, span := tracer().Start(ctx, "sync provider")
+	defer span.End()
+	span.SetAttributes(attribute.String("provider", name))
+
+	meta, err := s.LastSync(name)
+	if err != nil {
+		return err
+	}
+
+	lastPage := 0
+	for page := range ps.pages {
+		if page > lastPage {
+			lastPage = page
+		}
+	}
+
+	if opts.Incremental {
+		if len(ps.pages) == 0 || time.Since(meta.FullAt) > MaxIncrementalAge {
+			log.Printf("Sync state of %s is missing or stale, performing a full sync", name)
+		} else {
+			ps.incremental = true
+		}
+	}
+
+	sequential := false
+	if sp, ok := ps.provider.(SequentialProvider); ok {
+		sequential = sp.Sequential()
+	}
+
+	if meta.Interrupted() {
+		if opts.Resume && sequential {
+			log.Printf("The sync of %s cannot be resumed as its pages have to be fetched in order, syncing it again", name)
+		} else if opts.Resume {
+			log.Printf("Resuming the sync of %s started at %s", name, meta.StartedAt.Format(time.RFC3339))
+			ps.resumeFrom = meta.StartedAt
+			if meta.LastPage > lastPage {
+				lastPage = meta.LastPage
+			}
+		} else {
+			log.Printf("The previous sync of %s was interrupted, it can be resumed with the resume option", name)
+		}
+	}
+
+	if ps.resumeFrom.IsZero() {
+		meta.StartedAt = time.Now()
+		if err := s.syncNode().Save(meta); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Attempting to save first page of %s...", name)
+	first, err := s.syncPage(ctx, 1, ps)
+	if err != nil {
+		return fmt.Errorf("fetching first page of stars from %s: %v", name, err)
+	}
+
+	// Unchanged pages carry no pagination headers, so fall back to the previous page count
+	if !first.NotModified {
+		lastPage = first.LastPage
+
+		meta.LastPage = lastPage
+		if err := s.syncNode().Save(meta); err != nil {
+			return err
+		}
+	}
+
+	// Pages after the last one are followed one by one below
+	var last *StarPage
+	if lastPage <= 1 || sequential {
+		lastPage, last = 1, first
+	}
+
+	log.Printf("Attempting to save the rest of the pages of %s...", name)
+	runPool(ctx, s.concurrency(), lastPage-1, func(job int) {
+		page := job + 2
+
+		starPage, err := s.syncPage(ctx, page, ps)
+		if err != nil {
+			ps.fail(&SyncError{Provider: name, Page: page, Err: err})
+			return
+		}
+
+		if page == lastPage {
+			last = starPage
+		}
+	})
+
+	// Stars added since the previous sync may have spilled over onto new pages
+	for last != nil {
+		page := last.NextPage
+		if last.NotModified && len(ps.pages[lastPage].URLs) >= PageSize {
+			page = lastPage + 1
+		}
+
+		if page == 0 {
+			break
+		}
+
+		starPage, err := s.syncPage(ctx, page, ps)
+		if err != nil {
+			ps.fail(&SyncError{Provider: name, Page: page, Err: err})
+			break
+		}
+
+		lastPage, last = page, starPage
+	}
+
+	ps.complete = true
+	for _, e := range ps.result.Errors {
+		if e.Provider == name && e.URL == "" {
+			ps.complete = false
+		}
+	}
+
+	// Leave the sync marked as interrupted so that it can be resumed
+	if !ps.complete {
+		return nil
+	}
+
+	return s.finishSync(lastPage, meta, ps)
+}
+
+// finishSync drops the state of pages beyond the last page and records the sync time
+func (s *StarManager) finishSync(lastPage int, meta *SyncMeta, ps *providerSync) error {
+	for page, state := range ps.pages {
+		if page > lastPage && lastPage > 0 {
+			if err := s.syncNode().DeleteStruct(state); err != nil {
+				return err
+			}
+		}
+	}
+
+	if ps.incremental {
+		meta.IncrementalAt = time.Now()
+	} else {
+		meta.FullAt = time.Now()
+	}
+
+	return s.syncNode().Save(meta)
+}
+
+// prune removes cached stars of a provider that were not seen during a complete sync
+func (s *StarManager) prune(ps *providerSync) error {
+	name := ps.provider.Name()
+	if !ps.complete {
+		log.Printf("Not pruning stars from %s since some pages could not be fetched", name)
+		return nil
+	}
+
+	cached, err := s.store().All()
+	if err != nil {
+		return err
+	}
+
+	for _, star := range cached {
+		if star.ProviderName() != name || ps.seen[star.URL] {
+			continue
+		}
+
+		if err := s.store().Delete(star.URL); err != nil {
+			return err
+		}
+
+		log.Printf("Pruned %s", star.URL)
+		ps.result.Removed++
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+package starmanager
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// starredPage renders a page of the starred repositories API response for the given names
+func starredPage(names ...string) string {
+	body := "["
+	for i, name := range names {
+		if i > 0 {
+			body += ","
+		}
+
+		body += fmt.Sprintf(`{
+			"starred_at": "2019-01-0%dT00:00:00Z",
+			"repo": {
+				"html_url": "https://github.com/%s",
+				"language": "Go",
+				"stargazers_count": %d,
+				"archived": false,
+				"pushed_at": "2019-06-01T00:00:00Z",
+				"topics": ["cli"]
+			}
+		}`, i+1, name, 10*(i+1))
+	}
+
+	return body + "]"
+}
+
+// serveTestStars starts a fake GitHub API serving the given pages of stars for user "test",
+// advertising lastPage as the last page, and points the StarManager's client at it
+func serveTestStars(t *testing.T, sm *StarManager, lastPage int, pages map[string]string) func() {
+	client, closeGitHub := newTestGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		body, ok := pages[page]
+		if !ok {
+			http.Error(w, `{"message": "boom"}`, http.StatusInternalServerError)
+			return
+		}
+
+		etag := fmt.Sprintf(`"%x"`, md5.Sum([]byte(body)))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set(
+			"Link",
+			fmt.Sprintf(`<%s/users/test/starred?page=%d>; rel="last"`, "http://"+r.Host, lastPage),
+		)
+		fmt.Fprint(w, body)
+	}))
+
+	sm.Host = GitHub
+	sm.Username = "test"
+	sm.Client = client
+	sm.Providers = []Provider{&GitHubProvider{Host: "github.com", Client: client, Username: "test"}}
+
+	return closeGitHub
+}
+
+func TestSync(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/one", Stargazers: 1}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/unstarred"}))
+
+	defer serveTestStars(t, sm, 2, map[string]string{
+		"1": starredPage("a/one", "a/two"),
+		"2": starredPage("a/three"),
+	})()
+
+	result, err := sm.Sync(context.Background(), SyncOptions{Prune: true})
+	assert.NoError(t, err)
+	assert.True(t, result.Succeeded())
+	assert.Equal(t, 2, result.Added)
+	assert.Equal(t, 1, result.Updated)
+	assert.Equal(t, 1, result.Removed)
+	assert.Nil(t, result.Diff)
+
+	stars := []Star{}
+	assert.NoError(t, sm.DB.All(&stars))
+	assert.Len(t, stars, 3)
+
+	one := Star{}
+	assert.NoError(t, sm.DB.One("URL", "https://github.com/a/one", &one))
+	assert.Equal(t, 10, one.Stargazers)
+	assert.Equal(t, "go", one.Language)
+	assert.Equal(t, 2019, one.StarredAt.Year())
+
+	snapshots, err := sm.Snapshots(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 1)
+	assert.Len(t, snapshots[0].Stars, 3)
+}
+
+func TestSyncPartialFailure(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/unstarred"}))
+
+	// Page 2 is advertised by the Link header but fails to fetch
+	pages := map[string]string{"1": starredPage("a/one")}
+	defer serveTestStars(t, sm, 2, pages)()
+
+	result, err := sm.Sync(context.Background(), SyncOptions{Prune: true})
+	assert.NoError(t, err)
+	assert.False(t, result.Succeeded())
+	assert.Equal(t, 1, result.Added)
+	assert.Equal(t, 1, result.Failed)
+	assert.Equal(t, 2, result.Errors[0].Page)
+
+	encoded, err := json.Marshal(result.Errors[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `"provider":"github.com","page":2`)
+
+	// Nothing is pruned when the sync is incomplete
+	assert.Equal(t, 0, result.Removed)
+	count, err := sm.DB.Count(&Star{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	meta, err := sm.LastSync("github.com")
+	assert.NoError(t, err)
+	assert.True(t, meta.Interrupted())
+
+	// Resuming only fetches the page that failed
+	pages["2"] = starredPage("a/two")
+	result, err = sm.Sync(context.Background(), SyncOptions{Prune: true, Resume: true})
+	assert.NoError(t, err)
+	assert.True(t, result.Succeeded())
+	assert.Equal(t, 1, result.Resumed)
+	assert.Equal(t, 1, result.Added)
+	assert.Equal(t, 1, result.Removed)
+
+	meta, err = sm.LastSync("github.com")
+	assert.NoError(t, err)
+	assert.False(t, meta.Interrupted())
+}
+
+// failingStore fails to save the star with the given URL
+type failingStore struct {
+	*MemoryStore
+	url string
+}
+
+func (f *failingStore) Save(star *Star) error {
+	if star.URL == f.url {
+		return errors.New("disk full")
+	}
+
+	return f.MemoryStore.Save(star)
+}
+
+func TestSavePage(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	sm.Store = &failingStore{MemoryStore: NewMemoryStore(), url: "https://github.com/a/two"}
+	ps := &providerSync{
+		syncState: &syncState{result: &SyncResult{}, seen: map[string]bool{}},
+		provider:  &GitHubProvider{Host: "github.com"},
+		pages:     map[int]*PageState{},
+		throttle:  &throttle{},
+	}
+	starPage := &StarPage{ETag: `"page"`, Stars: []*Star{
+		{URL: "https://github.com/a/one"},
+		{URL: "https://github.com/a/two"},
+	}}
+
+	// Stars that cannot be saved are reported, the rest of the page is saved
+	assert.NoError(t, sm.savePage(context.Background(), 3, starPage, ps))
+	assert.Equal(t, 1, ps.result.Added)
+	assert.Equal(t, 1, ps.result.Failed)
+	assert.Equal(t, "https://github.com/a/two", ps.result.Errors[0].URL)
+	assert.True(t, ps.seen["https://github.com/a/two"])
+
+	count, err := sm.store().Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	states := []*PageState{}
+	assert.NoError(t, sm.syncNode().All(&states))
+	assert.Len(t, states, 1)
+	assert.Equal(t, 3, states[0].Page)
+	assert.Equal(t, `"page"`, states[0].ETag)
+	assert.Len(t, states[0].URLs, 2)
+
+	// Canceled pages are not recorded
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, sm.savePage(ctx, 4, starPage, ps))
+
+	states = []*PageState{}
+	assert.NoError(t, sm.syncNode().All(&states))
+	assert.Len(t, states, 1)
+}
+
+func TestSyncIncremental(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	pages := map[string]string{
+		"1": starredPage("a/one", "a/two"),
+		"2": starredPage("a/three"),
+	}
+	defer serveTestStars(t, sm, 2, pages)()
+
+	// Without previous state an incremental sync is a full sync
+	result, err := sm.Sync(context.Background(), SyncOptions{Incremental: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Added)
+	assert.Equal(t, 0, result.Unchanged)
+
+	pages["2"] = starredPage("a/three", "a/four")
+
+	result, err = sm.Sync(context.Background(), SyncOptions{Incremental: true, Prune: true})
+	assert.NoError(t, err)
+	assert.True(t, result.Succeeded())
+	assert.Equal(t, 1, result.Unchanged)
+	assert.Equal(t, 1, result.Added)
+	assert.Equal(t, 1, result.Updated)
+	assert.Equal(t, 0, result.Removed)
+	assert.Equal(t, []string{"https://github.com/a/four"}, result.Diff.Starred)
+
+	count, err := sm.DB.Count(&Star{})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	meta, err := sm.LastSync("github.com")
+	assert.NoError(t, err)
+	assert.False(t, meta.FullAt.IsZero())
+	assert.False(t, meta.IncrementalAt.IsZero())
+}
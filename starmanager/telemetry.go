@@ -0,0 +1,125 @@
+package starmanager
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName - the name of the OpenTelemetry tracer spans of syncs, API calls and database
+// writes are recorded with
+const TracerName string = "github.com/gkze/stars/starmanager"
+
+// The metrics of syncs and of the requests made to providers, registered with the default
+// Prometheus registry
+var (
+	pagesFetched = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stars_pages_fetched_total",
+		Help: "Pages of stars fetched from providers, including unchanged ones",
+	}, []string{"provider"})
+	starsSaved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stars_saved_total",
+		Help: "Stars saved to the cache while syncing",
+	}, []string{"provider"})
+	apiRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stars_api_requests_total",
+		Help: "Requests made to provider APIs, by response status code",
+	}, []string{"host", "code"})
+	apiErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stars_api_errors_total",
+		Help: "Requests to provider APIs that failed, by kind: network, rate_limit, client or server",
+	}, []string{"host", "kind"})
+	apiDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "stars_api_request_duration_seconds",
+		Help:    "How long requests to provider APIs took",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"host"})
+	rateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "stars_rate_limit_remaining",
+		Help: "Requests left before the rate limit of a provider resets, as last reported",
+	}, []string{"host"})
+	syncDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "stars_sync_duration_seconds",
+		Help:    "How long syncs took",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+	})
+)
+
+// tracer returns the tracer of the globally registered OpenTelemetry tracer provider, which
+// drops spans unless a program registers one with otel.SetTracerProvider
+func tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// recordError marks a span as failed with the given error
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// instrumentedTransport records metrics and a span for every request to a provider
+type instrumentedTransport struct {
+	base http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+
+	ctx, span := tracer().Start(req.Context(), "HTTP "+req.Method, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("http.method", req.Method),
+		attribute.String("http.url", req.URL.String()),
+	)
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	apiDuration.WithLabelValues(host).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		recordError(span, err)
+		apiRequests.WithLabelValues(host, "").Inc()
+		apiErrors.WithLabelValues(host, "network").Inc()
+
+		return resp, err
+	}
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	apiRequests.WithLabelValues(host, strconv.Itoa(resp.StatusCode)).Inc()
+
+	if rate := rateFromHeaders(resp.Header); !rate.Reset.IsZero() {
+		rateLimitRemaining.WithLabelValues(host).Set(float64(rate.Remaining))
+	}
+
+	if kind := errorKind(resp); kind != "" {
+		span.SetAttributes(attribute.String("error.kind", kind))
+		span.SetStatus(codes.Error, resp.Status)
+		apiErrors.WithLabelValues(host, kind).Inc()
+	}
+
+	return resp, nil
+}
+
+// errorKind classifies failed responses for the stars_api_errors_total metric, returning
+// an empty string for successful ones
+func errorKind(resp *http.Response) string {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return "rate_limit"
+	case resp.StatusCode == http.StatusForbidden && (resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0"):
+		return "rate_limit"
+	case resp.StatusCode >= 500:
+		return "server"
+	case resp.StatusCode >= 400:
+		return "client"
+	}
+
+	return ""
+}
@@ -0,0 +1,91 @@
+package starmanager
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSyncMetrics(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	defer serveTestStars(t, sm, 2, map[string]string{
+		"1": starredPage("a/one", "a/two"),
+		"2": starredPage("a/three"),
+	})()
+
+	spans := tracetest.NewSpanRecorder()
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+
+	syncs := func() uint64 {
+		m := &dto.Metric{}
+		assert.NoError(t, syncDuration.Write(m))
+		return m.GetHistogram().GetSampleCount()
+	}
+
+	count := syncs()
+	pages := testutil.ToFloat64(pagesFetched.WithLabelValues("github.com"))
+	saved := testutil.ToFloat64(starsSaved.WithLabelValues("github.com"))
+
+	_, err := sm.Sync(context.Background(), SyncOptions{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, pages+2, testutil.ToFloat64(pagesFetched.WithLabelValues("github.com")))
+	assert.Equal(t, saved+3, testutil.ToFloat64(starsSaved.WithLabelValues("github.com")))
+	assert.Equal(t, count+1, syncs())
+
+	names := map[string]int{}
+	for _, span := range spans.Ended() {
+		names[span.Name()]++
+	}
+	assert.Equal(t, map[string]int{"sync": 1, "sync provider": 1, "save page": 2}, names)
+}
+
+func TestInstrumentedTransport(t *testing.T) {
+	statuses := []int{http.StatusOK, http.StatusBadGateway, http.StatusTooManyRequests, http.StatusNotFound}
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(4999-requests))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.WriteHeader(statuses[requests])
+		requests++
+	}))
+	defer server.Close()
+
+	spans := tracetest.NewSpanRecorder()
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+
+	client := &http.Client{Transport: &instrumentedTransport{base: http.DefaultTransport}}
+	for range statuses {
+		resp, err := client.Get(server.URL)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	u, _ := url.Parse(server.URL)
+	host := u.Host
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(apiRequests.WithLabelValues(host, "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(apiRequests.WithLabelValues(host, "502")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(apiErrors.WithLabelValues(host, "server")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(apiErrors.WithLabelValues(host, "rate_limit")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(apiErrors.WithLabelValues(host, "client")))
+	assert.Equal(t, float64(4996), testutil.ToFloat64(rateLimitRemaining.WithLabelValues(host)))
+	assert.Len(t, spans.Ended(), len(statuses))
+	assert.Equal(t, "HTTP GET", spans.Ended()[0].Name())
+}
@@ -0,0 +1,150 @@
+package starmanager
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultTopicAliases are the aliases of common topics that are always applied, on top of
+// which WithTopicAliases adds more
+var DefaultTopicAliases = TopicAliases{
+	"golang":            "go",
+	"command-line":      "cli",
+	"commandline":       "cli",
+	"command-line-tool": "cli",
+	"cli-tool":          "cli",
+	"js":                "javascript",
+	"ts":                "typescript",
+	"py":                "python",
+	"python3":           "python",
+	"k8s":               "kubernetes",
+	"postgres":          "postgresql",
+}
+
+// TopicAliases maps topics to the topic they stand for, e.g. golang to go, so that stars
+// tagged with either are counted and matched as one. Keys and values are normalized, see
+// NormalizeTopic.
+type TopicAliases map[string]string
+
+// NewTopicAliases returns DefaultTopicAliases extended with the given aliases, which take
+// precedence: a default alias of a topic the given aliases map to is dropped, so that e.g.
+// go=golang reverses the default golang=go. Topics are normalized and chains of aliases
+// resolved to their end.
+func NewTopicAliases(aliases map[string]string) TopicAliases {
+	user := map[string]string{}
+	for alias, topic := range aliases {
+		user[NormalizeTopic(alias)] = NormalizeTopic(topic)
+	}
+
+	merged := TopicAliases{}
+	for alias, topic := range DefaultTopicAliases {
+		merged[alias] = topic
+	}
+
+	for alias, topic := range user {
+		if _, ok := user[topic]; !ok {
+			delete(merged, topic)
+		}
+
+		merged[alias] = topic
+	}
+
+	resolved := TopicAliases{}
+	for alias := range merged {
+		if topic := merged.resolve(alias); topic != alias {
+			resolved[alias] = topic
+		}
+	}
+
+	return resolved
+}
+
+// resolve follows a chain of aliases to its end. The topics of a cycle of aliases all resolve
+// to the first of them in sort order.
+func (a TopicAliases) resolve(topic string) string {
+	seen := map[string]bool{}
+	for !seen[topic] {
+		seen[topic] = true
+
+		next, ok := a[topic]
+		if !ok {
+			return topic
+		}
+
+		topic = next
+	}
+
+	first := topic
+	for t := a[topic]; t != topic; t = a[t] {
+		if t < first {
+			first = t
+		}
+	}
+
+	return first
+}
+
+// ParseTopicAliases parses aliases given as alias=topic pairs separated by commas or
+// whitespace, e.g. "golang=go command-line=cli"
+func ParseTopicAliases(s string) (map[string]string, error) {
+	aliases := map[string]string{}
+
+	for _, pair := range splitTokens(s) {
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 || i == len(pair)-1 {
+			return nil, fmt.Errorf("invalid topic alias %q, expected alias=topic", pair)
+		}
+
+		aliases[pair[:i]] = pair[i+1:]
+	}
+
+	return aliases, nil
+}
+
+// NormalizeTopic lowercases a topic and folds underscores and spaces into single hyphens,
+// like GitHub does for topics, so that e.g. "Command_Line" becomes "command-line"
+func NormalizeTopic(topic string) string {
+	fields := strings.FieldsFunc(strings.ToLower(topic), func(r rune) bool {
+		return r == '-' || r == '_' || r == ' ' || r == '\t'
+	})
+
+	return strings.Join(fields, "-")
+}
+
+// Canonical returns the normalized topic a topic stands for
+func (a TopicAliases) Canonical(topic string) string {
+	topic = NormalizeTopic(topic)
+	if canonical, ok := a[topic]; ok {
+		return canonical
+	}
+
+	return topic
+}
+
+// CanonicalTopics returns the canonical topics of the given ones in their order, without
+// duplicates or empty topics
+func (a TopicAliases) CanonicalTopics(topics []string) []string {
+	canonical := make([]string, 0, len(topics))
+	seen := map[string]bool{}
+
+	for _, topic := range topics {
+		topic = a.Canonical(topic)
+		if topic == "" || seen[topic] {
+			continue
+		}
+
+		seen[topic] = true
+		canonical = append(canonical, topic)
+	}
+
+	return canonical
+}
+
+// topicAliases returns the topic aliases of the StarManager, DefaultTopicAliases if unset
+func (s *StarManager) topicAliases() TopicAliases {
+	if s.TopicAliases != nil {
+		return s.TopicAliases
+	}
+
+	return DefaultTopicAliases
+}
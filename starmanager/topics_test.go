@@ -0,0 +1,116 @@
+package starmanager
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTopic(t *testing.T) {
+	testCases := map[string]string{
+		"go":                 "go",
+		"Command_Line":       "command-line",
+		" machine learning ": "machine-learning",
+		"--a__b--":           "a-b",
+		"":                   "",
+	}
+
+	for topic, expected := range testCases {
+		assert.Equal(t, expected, NormalizeTopic(topic), topic)
+	}
+}
+
+func TestTopicAliases(t *testing.T) {
+	aliases := NewTopicAliases(map[string]string{"Shell_Script": "shell", "golang": "golang"})
+
+	assert.Equal(t, "shell", aliases.Canonical("shell-script"))
+	assert.Equal(t, "cli", aliases.Canonical("Command_Line"))
+	assert.Equal(t, "golang", aliases.Canonical("golang"))
+	assert.Equal(t, "go", DefaultTopicAliases.Canonical("golang"))
+	assert.Equal(t, []string{"go", "cli", "rust"}, DefaultTopicAliases.CanonicalTopics([]string{"golang", "CLI", "go", "command-line", "rust", "_"}))
+
+	// Aliases reversing a default one replace it instead of swapping the topics
+	reversed := NewTopicAliases(map[string]string{"go": "golang"})
+	assert.Equal(t, "golang", reversed.Canonical("go"))
+	assert.Equal(t, "golang", reversed.Canonical("golang"))
+	assert.Equal(t, []string{"golang"}, reversed.CanonicalTopics([]string{"go", "golang"}))
+
+	chained := NewTopicAliases(map[string]string{"a": "b", "b": "c", "x": "y", "y": "x"})
+	assert.Equal(t, "c", chained.Canonical("a"))
+	assert.Equal(t, "x", chained.Canonical("x"))
+	assert.Equal(t, "x", chained.Canonical("y"))
+
+	parsed, err := ParseTopicAliases("golang=go, command-line=cli")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"golang": "go", "command-line": "cli"}, parsed)
+
+	for _, invalid := range []string{"golang", "=go", "golang="} {
+		_, err := ParseTopicAliases(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestNewOptionsTopicAliases(t *testing.T) {
+	defer os.Unsetenv(TopicAliasesEnv)
+
+	os.Setenv(TopicAliasesEnv, "nvim=neovim")
+	o := newOptions(WithTopicAliases(map[string]string{"vim-plugin": "vim"}))
+	assert.Equal(t, map[string]string{"nvim": "neovim", "vim-plugin": "vim"}, o.topicAliases)
+
+	os.Setenv(TopicAliasesEnv, "nvim")
+	assert.Empty(t, newOptions().topicAliases)
+}
+
+func TestTopicsMerged(t *testing.T) {
+	sm, cleanup := newTestStarManager(t)
+	defer cleanup()
+
+	sm.TopicAliases = NewTopicAliases(map[string]string{"shell-script": "shell"})
+
+	// Stars cached before topics were normalized
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/one", Topics: []string{"golang", "command-line"}}))
+	assert.NoError(t, sm.DB.Save(&Star{URL: "https://github.com/a/two", Topics: []string{"Go", "shell_script"}}))
+
+	_, err := sm.SaveStar(&Star{URL: "https://github.com/a/three", Topics: []string{"CLI", "golang", "go"}})
+	assert.NoError(t, err)
+
+	saved, err := sm.store().Get("https://github.com/a/three")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cli", "go"}, saved.Topics)
+
+	topics, err := sm.GetTopics(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []KV{{"go", 3}, {"cli", 2}, {"shell", 1}}, topics)
+
+	testCases := []struct {
+		opts     ProjectOptions
+		expected []string
+	}{
+		{opts: ProjectOptions{Topic: "go", Sort: SortURL}, expected: []string{"https://github.com/a/one", "https://github.com/a/three", "https://github.com/a/two"}},
+		{opts: ProjectOptions{Query: "topic:command_line", Sort: SortURL}, expected: []string{"https://github.com/a/one", "https://github.com/a/three"}},
+		{opts: ProjectOptions{Topics: []string{"shell-script"}}, expected: []string{"https://github.com/a/two"}},
+		{opts: ProjectOptions{ExcludeTopics: []string{"commandline"}}, expected: []string{"https://github.com/a/two"}},
+	}
+
+	for _, tc := range testCases {
+		projects, err := sm.findProjects(context.Background(), tc.opts)
+		assert.NoError(t, err)
+
+		urls := []string{}
+		for _, p := range projects {
+			urls = append(urls, p.URL)
+		}
+		assert.Equal(t, tc.expected, urls)
+	}
+
+	policy, err := ParsePolicy([]byte("rules:\n  - name: shell\n    action: unstar\n    when:\n      topic: shell-script\n"))
+	assert.NoError(t, err)
+
+	candidates, err := sm.CleanupCandidates(context.Background(), CleanupOptions{Policy: policy})
+	assert.NoError(t, err)
+	assert.Len(t, candidates, 1)
+	assert.Equal(t, "https://github.com/a/two", candidates[0].Star.URL)
+	assert.Equal(t, []string{"Go", "shell_script"}, candidates[0].Star.Topics)
+}
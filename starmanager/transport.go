@@ -0,0 +1,160 @@
+package starmanager
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultHTTPRetries - how many times requests hitting a secondary rate limit are retried
+// by default
+const DefaultHTTPRetries int = 2
+
+// httpClient returns the client requests to providers are made with: the one given with
+// WithHTTPClient or one using the proxy from the environment, with retries, logging and the
+// timeout as configured
+func (o *options) httpClient() (*http.Client, error) {
+	client := &http.Client{}
+	if o.client != nil {
+		copied := *o.client
+		client = &copied
+	}
+
+	transport := client.Transport
+	if transport == nil && o.client != nil {
+		transport = http.DefaultTransport
+	} else if transport == nil {
+		base := http.DefaultTransport.(*http.Transport).Clone()
+		if o.proxy != "" {
+			proxy, err := url.Parse(o.proxy)
+			if err != nil {
+				return nil, err
+			}
+
+			base.Proxy = http.ProxyURL(proxy)
+		}
+
+		transport = base
+	}
+
+	// Every attempt is recorded in the metrics, so that retried requests are counted
+	transport = &instrumentedTransport{base: transport}
+
+	if o.logRequests {
+		transport = &loggingTransport{base: transport}
+	}
+
+	if o.retries > 0 {
+		transport = &retryTransport{base: transport, retries: o.retries}
+	}
+
+	client.Transport = transport
+	if o.timeout > 0 {
+		client.Timeout = o.timeout
+	}
+
+	return client, nil
+}
+
+// retryTransport retries requests hitting a secondary rate limit (HTTP 429, or 403 with a
+// Retry-After header), with exponential backoff and jitter. Waits longer than retryMaxDelay
+// are left to the callers, which pause all requests to a provider on rate limits. Network
+// and server errors are retried by the callers with withRetry only, so that failing requests
+// are not retried by both.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	delay := retryBaseDelay
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt == t.retries || !retryableResponse(resp, err) {
+			return resp, err
+		}
+
+		// Requests whose body cannot be sent again are not retried
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err
+		}
+
+		wait := delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+		if resp != nil && resp.Header.Get("Retry-After") != "" {
+			wait = retryAfter(resp)
+		}
+
+		if wait > retryMaxDelay {
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		log.Printf("Retrying %s %s in %s (attempt %d of %d)", req.Method, req.URL, wait, attempt+1, t.retries)
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		if delay *= 2; delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
+
+// retryableResponse reports whether a request hit a secondary rate limit and may succeed
+// if retried
+func retryableResponse(resp *http.Response, err error) bool {
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode == http.StatusForbidden:
+		return resp.Header.Get("Retry-After") != ""
+	}
+
+	return false
+}
+
+// loggingTransport logs every request with its status and duration, for debugging
+type loggingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	took := time.Since(start).Round(time.Millisecond)
+
+	if err != nil {
+		log.Printf("%s %s failed after %s: %v", req.Method, req.URL, took, err)
+		return resp, err
+	}
+
+	remaining := ""
+	if rate := rateFromHeaders(resp.Header); !rate.Reset.IsZero() {
+		remaining = ", " + strconv.Itoa(rate.Remaining) + " requests left"
+	}
+
+	log.Printf("%s %s: %s in %s%s", req.Method, req.URL, resp.Status, took, remaining)
+	return resp, nil
+}
@@ -0,0 +1,103 @@
+package starmanager
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newStatusServer returns a server answering with the given statuses in order, then with
+// 200, and recording the bodies of the requests
+func newStatusServer(statuses []int, header http.Header, bodies *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*bodies = append(*bodies, string(body))
+
+		if len(*bodies) <= len(statuses) {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+
+			w.WriteHeader(statuses[len(*bodies)-1])
+			return
+		}
+
+		w.Write([]byte("ok"))
+	}))
+}
+
+func TestRetryTransport(t *testing.T) {
+	testCases := []struct {
+		statuses []int
+		header   http.Header
+		retries  int
+		requests int
+		status   int
+	}{
+		{statuses: []int{429, 429}, retries: 2, requests: 3, status: 200},
+		{statuses: []int{429, 429, 429}, retries: 2, requests: 3, status: 429},
+		// Server errors are retried by the callers
+		{statuses: []int{502}, retries: 2, requests: 1, status: 502},
+		{statuses: []int{403}, header: http.Header{"Retry-After": {"0"}}, retries: 2, requests: 2, status: 200},
+		// Long waits are left to the callers
+		{statuses: []int{403}, header: http.Header{"Retry-After": {"60"}}, retries: 2, requests: 1, status: 403},
+		{statuses: []int{403}, retries: 2, requests: 1, status: 403},
+		{statuses: []int{404}, retries: 2, requests: 1, status: 404},
+		{statuses: []int{429}, retries: 0, requests: 1, status: 429},
+	}
+
+	for _, tc := range testCases {
+		bodies := []string{}
+		server := newStatusServer(tc.statuses, tc.header, &bodies)
+
+		client, err := newOptions(WithRetries(tc.retries)).httpClient()
+		assert.NoError(t, err)
+
+		resp, err := client.Post(server.URL, "text/plain", strings.NewReader("query"))
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, tc.status, resp.StatusCode)
+		assert.Len(t, bodies, tc.requests)
+		for _, body := range bodies {
+			assert.Equal(t, "query", body)
+		}
+
+		server.Close()
+	}
+}
+
+func TestHTTPClientOptions(t *testing.T) {
+	client, err := newOptions().httpClient()
+	assert.NoError(t, err)
+	assert.Zero(t, client.Timeout)
+
+	retry, ok := client.Transport.(*retryTransport)
+	assert.True(t, ok)
+	assert.Equal(t, DefaultHTTPRetries, retry.retries)
+
+	client, err = newOptions(WithProxy("http://proxy.example.com:3128"), WithTimeout(time.Minute), WithRequestLogging()).httpClient()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, client.Timeout)
+
+	logging, ok := client.Transport.(*retryTransport).base.(*loggingTransport)
+	assert.True(t, ok)
+
+	req, err := http.NewRequest(http.MethodGet, "https://api.github.com/user", nil)
+	assert.NoError(t, err)
+
+	proxy, err := logging.base.(*instrumentedTransport).base.(*http.Transport).Proxy(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "proxy.example.com:3128", proxy.Host)
+
+	// Given clients are used as they are, with metrics and retries on top
+	custom := &http.Client{Transport: &loggingTransport{base: http.DefaultTransport}}
+	client, err = newOptions(WithHTTPClient(custom)).httpClient()
+	assert.NoError(t, err)
+	assert.Equal(t, custom.Transport, client.Transport.(*retryTransport).base.(*instrumentedTransport).base)
+}
@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+)
+
+// keyCode identifies the keys the interface reacts to
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyInterrupt
+)
+
+// key is a single key press, r holding the character typed for keyRune
+type key struct {
+	code keyCode
+	r    rune
+}
+
+// escapeSequences maps the escape sequences terminals send for special keys, without the
+// leading escape
+var escapeSequences = map[string]keyCode{
+	"[A":  keyUp,
+	"[B":  keyDown,
+	"OA":  keyUp,
+	"OB":  keyDown,
+	"[5~": keyPageUp,
+	"[6~": keyPageDown,
+	"[H":  keyHome,
+	"[F":  keyEnd,
+	"OH":  keyHome,
+	"OF":  keyEnd,
+	"[1~": keyHome,
+	"[4~": keyEnd,
+}
+
+// parseKeys decodes the keys in a chunk of terminal input. Unknown escape sequences and
+// control characters are dropped.
+func parseKeys(b []byte) []key {
+	keys := []key{}
+
+	for len(b) > 0 {
+		switch b[0] {
+		case 0x1b:
+			if len(b) == 1 {
+				return append(keys, key{code: keyEscape})
+			}
+
+			// Escape sequences end with a letter or a tilde
+			if b[1] == '[' || b[1] == 'O' {
+				end := bytes.IndexFunc(b[2:], func(r rune) bool { return unicode.IsLetter(r) || r == '~' })
+				if end >= 0 {
+					if code, ok := escapeSequences[string(b[1:end+3])]; ok {
+						keys = append(keys, key{code: code})
+					}
+
+					b = b[end+3:]
+					continue
+				}
+			}
+
+			keys = append(keys, key{code: keyEscape})
+			b = b[1:]
+		case '\r', '\n':
+			keys = append(keys, key{code: keyEnter})
+			b = b[1:]
+		case 0x7f, 0x08:
+			keys = append(keys, key{code: keyBackspace})
+			b = b[1:]
+		case 0x03:
+			keys = append(keys, key{code: keyInterrupt})
+			b = b[1:]
+		case 0x0e:
+			keys = append(keys, key{code: keyDown})
+			b = b[1:]
+		case 0x10:
+			keys = append(keys, key{code: keyUp})
+			b = b[1:]
+		default:
+			r, n := utf8.DecodeRune(b)
+			if r != utf8.RuneError && unicode.IsPrint(r) {
+				keys = append(keys, key{code: keyRune, r: r})
+			}
+
+			b = b[n:]
+		}
+	}
+
+	return keys
+}
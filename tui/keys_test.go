@@ -0,0 +1,27 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeys(t *testing.T) {
+	testCases := []struct {
+		input string
+		keys  []key
+	}{
+		{input: "jk", keys: []key{{code: keyRune, r: 'j'}, {code: keyRune, r: 'k'}}},
+		{input: "\x1b[A\x1b[B", keys: []key{{code: keyUp}, {code: keyDown}}},
+		{input: "\x1b[5~\x1b[6~", keys: []key{{code: keyPageUp}, {code: keyPageDown}}},
+		{input: "\x1bOH\x1b[4~", keys: []key{{code: keyHome}, {code: keyEnd}}},
+		{input: "\x1b", keys: []key{{code: keyEscape}}},
+		{input: "\x1bq", keys: []key{{code: keyEscape}, {code: keyRune, r: 'q'}}},
+		{input: "\r\x7f\x03", keys: []key{{code: keyEnter}, {code: keyBackspace}, {code: keyInterrupt}}},
+		{input: "\x1b[Z\x01é", keys: []key{{code: keyRune, r: 'é'}}},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.keys, parseKeys([]byte(tc.input)), "%q", tc.input)
+	}
+}
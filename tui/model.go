@@ -0,0 +1,519 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gkze/stars/starmanager"
+)
+
+// detailHeight is the number of lines of the detail pane
+const detailHeight int = 7
+
+// help lists the keys available while browsing
+const help string = "↑/↓ move  / filter  t tag  p pin  o open  u unstar  q quit"
+
+// Item is a star listed by the interface, along with why it was selected for cleanup if the
+// interface reviews cleanup candidates
+type Item struct {
+	Star    starmanager.Star
+	Reasons []string
+}
+
+// Backend is what the interface reads and changes stars through, usually a StarManager
+type Backend interface {
+	GetAnnotations() (map[string]*starmanager.Annotation, error)
+	GetAnnotation(url string) (*starmanager.Annotation, error)
+	Tag(url string, tags ...string) error
+	Untag(url string, tags ...string) error
+	Pin(url string) error
+	Unpin(url string) error
+	RemoveStar(ctx context.Context, star *starmanager.Star) (bool, error)
+}
+
+// mode is what key presses currently do
+type mode int
+
+const (
+	modeBrowse mode = iota
+	modeFilter
+	modeTag
+	modeConfirm
+)
+
+// model is the state of the interface, updated by key presses and rendered by view
+type model struct {
+	ctx     context.Context
+	backend Backend
+	open    func(url string) error
+
+	items       []*Item
+	annotations map[string]*starmanager.Annotation
+
+	// visible are the items matching the filter, best match first
+	visible []*Item
+	filter  string
+
+	cursor int
+	offset int
+
+	mode    mode
+	input   string
+	message string
+}
+
+// newModel creates the state of the interface listing the given items
+func newModel(ctx context.Context, items []*Item, backend Backend, open func(url string) error) (*model, error) {
+	annotations, err := backend.GetAnnotations()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &model{ctx: ctx, backend: backend, open: open, items: items, annotations: annotations}
+	m.refilter()
+
+	return m, nil
+}
+
+// annotation returns the annotation of an item, which is empty if it has none
+func (m *model) annotation(item *Item) *starmanager.Annotation {
+	if a, ok := m.annotations[item.Star.URL]; ok {
+		return a
+	}
+
+	return &starmanager.Annotation{URL: item.Star.URL}
+}
+
+// current returns the item under the cursor, nil if nothing matches the filter
+func (m *model) current() *Item {
+	if len(m.visible) == 0 {
+		return nil
+	}
+
+	return m.visible[m.cursor]
+}
+
+// searchText returns the text of an item the filter is matched against
+func (m *model) searchText(item *Item) string {
+	fields := []string{
+		strings.TrimPrefix(item.Star.URL, "https://"),
+		item.Star.Description,
+		item.Star.Language,
+		strings.Join(item.Star.Topics, " "),
+		strings.Join(m.annotation(item).Tags, " "),
+	}
+
+	return strings.ToLower(strings.Join(fields, " "))
+}
+
+// refilter updates the visible items after the filter or the items changed, keeping the
+// cursor on the same item if it is still visible
+func (m *model) refilter() {
+	selected := m.current()
+
+	type match struct {
+		item  *Item
+		score int
+	}
+
+	matches := []match{}
+	for _, item := range m.items {
+		if score, ok := fuzzyScore(m.filter, m.searchText(item)); ok {
+			matches = append(matches, match{item: item, score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	m.visible = make([]*Item, len(matches))
+	m.cursor = 0
+	for i, match := range matches {
+		m.visible[i] = match.item
+		if match.item == selected {
+			m.cursor = i
+		}
+	}
+}
+
+// fuzzyScore matches every whitespace separated term of a lowercase pattern against a
+// lowercase text as a subsequence. Matches of consecutive characters and at the start of
+// words score higher.
+func fuzzyScore(pattern, text string) (int, bool) {
+	total := 0
+
+	for _, term := range strings.Fields(pattern) {
+		score, pos, last := 0, 0, -2
+		for _, r := range term {
+			i := strings.IndexRune(text[pos:], r)
+			if i < 0 {
+				return 0, false
+			}
+
+			i += pos
+			score++
+			if i == last+1 {
+				score += 4
+			} else if i == 0 {
+				score += 2
+			} else if prev, _ := utf8.DecodeLastRuneInString(text[:i]); !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+				score += 2
+			}
+
+			last = i
+			pos = i + utf8.RuneLen(r)
+		}
+
+		total += score
+	}
+
+	return total, true
+}
+
+// move moves the cursor by the given number of items, staying within the visible items
+func (m *model) move(by int) {
+	m.cursor += by
+
+	if m.cursor >= len(m.visible) {
+		m.cursor = len(m.visible) - 1
+	}
+
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+}
+
+// update applies a key press, returning whether the interface should quit
+func (m *model) update(k key, pageSize int) bool {
+	if k.code == keyInterrupt {
+		return true
+	}
+
+	switch m.mode {
+	case modeFilter:
+		m.updateFilter(k)
+	case modeTag:
+		m.updateTag(k)
+	case modeConfirm:
+		m.updateConfirm(k)
+	default:
+		return m.updateBrowse(k, pageSize)
+	}
+
+	return false
+}
+
+// updateBrowse handles key presses while browsing
+func (m *model) updateBrowse(k key, pageSize int) bool {
+	m.message = ""
+
+	switch {
+	case k.code == keyUp || k.r == 'k':
+		m.move(-1)
+	case k.code == keyDown || k.r == 'j':
+		m.move(1)
+	case k.code == keyPageUp:
+		m.move(-pageSize)
+	case k.code == keyPageDown:
+		m.move(pageSize)
+	case k.code == keyHome || k.r == 'g':
+		m.move(-len(m.visible))
+	case k.code == keyEnd || k.r == 'G':
+		m.move(len(m.visible))
+	case k.r == '/':
+		m.mode = modeFilter
+	case k.code == keyEscape:
+		m.filter = ""
+		m.refilter()
+	case k.r == 'q':
+		return true
+	}
+
+	item := m.current()
+	if item == nil {
+		return false
+	}
+
+	switch {
+	case k.code == keyEnter || k.r == 'o':
+		if err := m.open(item.Star.URL); err != nil {
+			m.message = err.Error()
+		}
+	case k.r == 't':
+		m.mode = modeTag
+		m.input = ""
+	case k.r == 'p':
+		m.togglePin(item)
+	case k.r == 'u':
+		if m.annotation(item).Protected {
+			m.message = fmt.Sprintf("%s is pinned, unpin it first", item.Star.URL)
+		} else {
+			m.mode = modeConfirm
+		}
+	}
+
+	return false
+}
+
+// updateFilter handles key presses while typing the filter, which applies as it is typed
+func (m *model) updateFilter(k key) {
+	switch k.code {
+	case keyEnter:
+		m.mode = modeBrowse
+	case keyEscape:
+		m.mode = modeBrowse
+		m.filter = ""
+	case keyBackspace:
+		if m.filter != "" {
+			_, n := utf8.DecodeLastRuneInString(m.filter)
+			m.filter = m.filter[:len(m.filter)-n]
+		}
+	case keyRune:
+		m.filter += string(unicode.ToLower(k.r))
+	}
+
+	m.refilter()
+}
+
+// updateTag handles key presses while typing tags. Tags prefixed with a minus are removed.
+func (m *model) updateTag(k key) {
+	switch k.code {
+	case keyEscape:
+		m.mode = modeBrowse
+	case keyBackspace:
+		if m.input != "" {
+			_, n := utf8.DecodeLastRuneInString(m.input)
+			m.input = m.input[:len(m.input)-n]
+		}
+	case keyRune:
+		m.input += string(k.r)
+	case keyEnter:
+		m.mode = modeBrowse
+		m.tag(m.current(), strings.Fields(m.input))
+	}
+}
+
+// updateConfirm handles the answer to whether the current star should be unstarred
+func (m *model) updateConfirm(k key) {
+	m.mode = modeBrowse
+
+	if k.r == 'y' || k.r == 'Y' {
+		m.unstar(m.current())
+	}
+}
+
+// reload refreshes the annotation of an item after it changed
+func (m *model) reload(item *Item) {
+	annotation, err := m.backend.GetAnnotation(item.Star.URL)
+	if err != nil {
+		m.message = err.Error()
+		return
+	}
+
+	m.annotations[item.Star.URL] = annotation
+}
+
+// tag adds and removes tags of an item
+func (m *model) tag(item *Item, tags []string) {
+	add, remove := []string{}, []string{}
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, "-") {
+			remove = append(remove, strings.TrimPrefix(tag, "-"))
+		} else {
+			add = append(add, tag)
+		}
+	}
+
+	if len(add) > 0 {
+		if err := m.backend.Tag(item.Star.URL, add...); err != nil {
+			m.message = err.Error()
+			return
+		}
+	}
+
+	if len(remove) > 0 {
+		if err := m.backend.Untag(item.Star.URL, remove...); err != nil {
+			m.message = err.Error()
+			return
+		}
+	}
+
+	m.reload(item)
+}
+
+// togglePin protects an item from cleanup, or stops protecting it
+func (m *model) togglePin(item *Item) {
+	pin, verb := m.backend.Pin, "Pinned"
+	if m.annotation(item).Protected {
+		pin, verb = m.backend.Unpin, "Unpinned"
+	}
+
+	if err := pin(item.Star.URL); err != nil {
+		m.message = err.Error()
+		return
+	}
+
+	m.message = fmt.Sprintf("%s %s", verb, item.Star.URL)
+	m.reload(item)
+}
+
+// unstar removes an item's star and drops it from the list
+func (m *model) unstar(item *Item) {
+	removed, err := m.backend.RemoveStar(m.ctx, &item.Star)
+	if err != nil {
+		m.message = err.Error()
+		return
+	}
+
+	if !removed {
+		m.message = fmt.Sprintf("Would remove %s", item.Star.URL)
+		return
+	}
+
+	for i, other := range m.items {
+		if other == item {
+			m.items = append(m.items[:i], m.items[i+1:]...)
+			break
+		}
+	}
+
+	// The cursor moves on to the next item
+	cursor := m.cursor
+	m.refilter()
+	m.cursor = cursor
+	m.move(0)
+
+	m.message = fmt.Sprintf("Removed %s, undo with stars undo", item.Star.URL)
+}
+
+// truncate shortens a line to the given width
+func truncate(line string, width int) string {
+	if utf8.RuneCountInString(line) <= width {
+		return line
+	}
+
+	if width <= 1 {
+		return string([]rune(line)[:width])
+	}
+
+	return string([]rune(line)[:width-1]) + "…"
+}
+
+// view renders the interface for a terminal of the given size: the list of items, the
+// detail pane of the current item and a status line
+func (m *model) view(width, height int) []string {
+	listHeight := height - detailHeight - 3
+	if listHeight < 1 {
+		listHeight = 1
+	}
+
+	// The list scrolls to keep the cursor visible
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+listHeight {
+		m.offset = m.cursor - listHeight + 1
+	}
+
+	lines := make([]string, 0, height)
+
+	header := fmt.Sprintf("stars: %d of %d", len(m.visible), len(m.items))
+	if m.filter != "" || m.mode == modeFilter {
+		header += "  /" + m.filter
+	}
+	lines = append(lines, truncate(header, width))
+
+	for i := m.offset; i < m.offset+listHeight; i++ {
+		if i >= len(m.visible) {
+			lines = append(lines, "")
+			continue
+		}
+
+		item := m.visible[i]
+		marker := " "
+		if m.annotation(item).Protected {
+			marker = "*"
+		}
+
+		line := truncate(fmt.Sprintf(
+			"%s %-40s %7d  %s",
+			marker,
+			strings.TrimPrefix(item.Star.URL, "https://"),
+			item.Star.Stargazers,
+			item.Star.Description,
+		), width)
+
+		if i == m.cursor {
+			line = "\x1b[7m" + line + strings.Repeat(" ", width-utf8.RuneCountInString(line)) + "\x1b[0m"
+		}
+
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, strings.Repeat("─", width))
+	for _, line := range m.detail() {
+		lines = append(lines, truncate(line, width))
+	}
+
+	status := help
+	switch {
+	case m.mode == modeFilter:
+		status = "Filter: " + m.filter
+	case m.mode == modeTag:
+		status = "Tags (-tag removes): " + m.input
+	case m.mode == modeConfirm:
+		status = fmt.Sprintf("Unstar %s? [y/N]", m.current().Star.URL)
+	case m.message != "":
+		status = m.message
+	}
+
+	return append(lines, truncate(status, width))
+}
+
+// detail renders the detail pane of the current item
+func (m *model) detail() []string {
+	lines := make([]string, 0, detailHeight)
+
+	if item := m.current(); item != nil {
+		star := item.Star
+		annotation := m.annotation(item)
+
+		facts := []string{}
+		if star.Language != "" {
+			facts = append(facts, star.Language)
+		}
+		facts = append(facts, strconv.Itoa(star.Stargazers)+" stars")
+		if !star.PushedAt.IsZero() {
+			facts = append(facts, "pushed "+star.PushedAt.Format("2006-01-02"))
+		}
+		if star.Archived {
+			facts = append(facts, "archived")
+		}
+		if annotation.Protected {
+			facts = append(facts, "pinned")
+		}
+
+		lines = append(lines,
+			star.URL,
+			star.Description,
+			strings.Join(facts, " · "),
+			"Topics: "+strings.Join(star.Topics, ", "),
+			"Tags: "+strings.Join(annotation.Tags, ", "),
+			"Notes: "+strings.ReplaceAll(annotation.Notes, "\n", " "),
+		)
+
+		if len(item.Reasons) > 0 {
+			lines = append(lines, "Cleanup: "+strings.Join(item.Reasons, ", "))
+		}
+	}
+
+	for len(lines) < detailHeight {
+		lines = append(lines, "")
+	}
+
+	return lines
+}
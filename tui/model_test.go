@@ -0,0 +1,236 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gkze/stars/starmanager"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBackend keeps annotations in memory and records removed stars
+type fakeBackend struct {
+	annotations map[string]*starmanager.Annotation
+	removed     []string
+	dryRun      bool
+}
+
+func (f *fakeBackend) GetAnnotations() (map[string]*starmanager.Annotation, error) {
+	annotations := map[string]*starmanager.Annotation{}
+	for url, a := range f.annotations {
+		annotations[url] = a
+	}
+
+	return annotations, nil
+}
+
+func (f *fakeBackend) GetAnnotation(url string) (*starmanager.Annotation, error) {
+	if a, ok := f.annotations[url]; ok {
+		copied := *a
+		return &copied, nil
+	}
+
+	return &starmanager.Annotation{URL: url}, nil
+}
+
+func (f *fakeBackend) annotate(url string, change func(a *starmanager.Annotation)) error {
+	a, _ := f.GetAnnotation(url)
+	change(a)
+	f.annotations[url] = a
+
+	return nil
+}
+
+func (f *fakeBackend) Tag(url string, tags ...string) error {
+	return f.annotate(url, func(a *starmanager.Annotation) { a.Tags = append(a.Tags, tags...) })
+}
+
+func (f *fakeBackend) Untag(url string, tags ...string) error {
+	return f.annotate(url, func(a *starmanager.Annotation) {
+		kept := []string{}
+		for _, tag := range a.Tags {
+			if !containsString(tags, tag) {
+				kept = append(kept, tag)
+			}
+		}
+		a.Tags = kept
+	})
+}
+
+func (f *fakeBackend) Pin(url string) error {
+	return f.annotate(url, func(a *starmanager.Annotation) { a.Protected = true })
+}
+
+func (f *fakeBackend) Unpin(url string) error {
+	return f.annotate(url, func(a *starmanager.Annotation) { a.Protected = false })
+}
+
+func (f *fakeBackend) RemoveStar(ctx context.Context, star *starmanager.Star) (bool, error) {
+	if f.dryRun {
+		return false, nil
+	}
+
+	f.removed = append(f.removed, star.URL)
+	return true, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// typeKeys presses a key for every character of the text
+func typeKeys(m *model, text string) {
+	for _, r := range text {
+		m.update(key{code: keyRune, r: r}, 10)
+	}
+}
+
+func newTestModel(t *testing.T) (*model, *fakeBackend, *[]string) {
+	items := []*Item{
+		{Star: starmanager.Star{URL: "https://github.com/spf13/cobra", Description: "A Commander for modern Go CLI interactions", Language: "go"}},
+		{Star: starmanager.Star{URL: "https://github.com/sirupsen/logrus", Description: "Structured logger", Language: "go"}},
+		{Star: starmanager.Star{URL: "https://github.com/old/thing", Description: "Abandoned"}, Reasons: []string{"archived"}},
+	}
+
+	backend := &fakeBackend{annotations: map[string]*starmanager.Annotation{
+		"https://github.com/sirupsen/logrus": {URL: "https://github.com/sirupsen/logrus", Protected: true},
+	}}
+
+	opened := []string{}
+	m, err := newModel(context.Background(), items, backend, func(url string) error {
+		opened = append(opened, url)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	return m, backend, &opened
+}
+
+func TestFuzzyScore(t *testing.T) {
+	_, ok := fuzzyScore("cbr", "github.com/spf13/cobra")
+	assert.True(t, ok)
+
+	_, ok = fuzzyScore("cobra x", "github.com/spf13/cobra")
+	assert.False(t, ok)
+
+	score, ok := fuzzyScore("", "anything")
+	assert.True(t, ok)
+	assert.Equal(t, 0, score)
+
+	// Consecutive matches at word starts beat scattered ones
+	exact, _ := fuzzyScore("log", "sirupsen/logrus")
+	scattered, _ := fuzzyScore("log", "a lot of gophers")
+	assert.True(t, exact > scattered)
+}
+
+func TestModelNavigationAndFilter(t *testing.T) {
+	m, _, opened := newTestModel(t)
+
+	assert.Len(t, m.visible, 3)
+	assert.Equal(t, "https://github.com/spf13/cobra", m.current().Star.URL)
+
+	m.update(key{code: keyDown}, 10)
+	m.update(key{code: keyRune, r: 'j'}, 10)
+	m.update(key{code: keyDown}, 10)
+	assert.Equal(t, "https://github.com/old/thing", m.current().Star.URL)
+
+	m.update(key{code: keyRune, r: 'g'}, 10)
+	assert.Equal(t, 0, m.cursor)
+
+	// The filter applies as it is typed and Escape clears it
+	typeKeys(m, "/logr")
+	assert.Equal(t, modeFilter, m.mode)
+	assert.Len(t, m.visible, 1)
+	assert.Equal(t, "https://github.com/sirupsen/logrus", m.current().Star.URL)
+
+	m.update(key{code: keyEnter}, 10)
+	assert.Equal(t, modeBrowse, m.mode)
+
+	m.update(key{code: keyEnter}, 10)
+	assert.Equal(t, []string{"https://github.com/sirupsen/logrus"}, *opened)
+
+	m.update(key{code: keyEscape}, 10)
+	assert.Len(t, m.visible, 3)
+	assert.Equal(t, "https://github.com/sirupsen/logrus", m.current().Star.URL)
+
+	typeKeys(m, "/zzz")
+	assert.Empty(t, m.visible)
+	assert.Nil(t, m.current())
+	m.update(key{code: keyEnter}, 10)
+	assert.False(t, m.update(key{code: keyRune, r: 'o'}, 10))
+
+	assert.True(t, m.update(key{code: keyRune, r: 'q'}, 10))
+	assert.True(t, m.update(key{code: keyInterrupt}, 10))
+}
+
+func TestModelActions(t *testing.T) {
+	m, backend, _ := newTestModel(t)
+
+	// Tags are added, or removed with a minus
+	typeKeys(m, "tcli tools")
+	m.update(key{code: keyEnter}, 10)
+	assert.Equal(t, []string{"cli", "tools"}, backend.annotations["https://github.com/spf13/cobra"].Tags)
+
+	typeKeys(m, "t-tools")
+	m.update(key{code: keyEnter}, 10)
+	assert.Equal(t, []string{"cli"}, m.annotation(m.current()).Tags)
+
+	// Pinned stars cannot be unstarred
+	m.update(key{code: keyDown}, 10)
+	typeKeys(m, "u")
+	assert.Equal(t, modeBrowse, m.mode)
+	assert.Contains(t, m.message, "pinned")
+
+	typeKeys(m, "p")
+	assert.False(t, backend.annotations["https://github.com/sirupsen/logrus"].Protected)
+
+	typeKeys(m, "un")
+	assert.Empty(t, backend.removed)
+
+	typeKeys(m, "uy")
+	assert.Equal(t, []string{"https://github.com/sirupsen/logrus"}, backend.removed)
+	assert.Len(t, m.items, 2)
+	assert.Equal(t, "https://github.com/old/thing", m.current().Star.URL)
+
+	backend.dryRun = true
+	typeKeys(m, "uy")
+	assert.Len(t, m.items, 2)
+	assert.Contains(t, m.message, "Would remove")
+}
+
+func TestModelView(t *testing.T) {
+	m, _, _ := newTestModel(t)
+	m.open = func(url string) error { return errors.New("no browser") }
+
+	lines := m.view(60, 14)
+	assert.Len(t, lines, 14)
+	assert.Equal(t, "stars: 3 of 3", lines[0])
+	assert.Contains(t, lines[1], "github.com/spf13/cobra")
+	assert.Contains(t, lines[2], "* github.com/sirupsen/logrus")
+	assert.Equal(t, help, lines[13])
+
+	for _, line := range lines {
+		assert.True(t, len([]rune(strings.Replace(strings.Replace(line, "\x1b[7m", "", 1), "\x1b[0m", "", 1))) <= 60, line)
+	}
+
+	// The detail pane follows the cursor, and short lists scroll to it
+	typeKeys(m, "G")
+	lines = m.view(60, 14)
+	assert.Equal(t, "Cleanup: archived", lines[12])
+	assert.Contains(t, lines[3], "github.com/old/thing")
+
+	lines = m.view(60, 12)
+	assert.Contains(t, lines[1], "github.com/sirupsen/logrus")
+	assert.Contains(t, lines[2], "github.com/old/thing")
+
+	typeKeys(m, "o")
+	assert.Equal(t, "no browser", m.view(60, 14)[13])
+}
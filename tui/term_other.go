@@ -0,0 +1,22 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package tui
+
+import (
+	"errors"
+	"os"
+)
+
+// makeRaw is not supported on this platform
+func makeRaw(fd int) (func() error, error) {
+	return nil, errors.New("the interactive mode is not supported on this platform")
+}
+
+// size is not supported on this platform
+func size(fd int) (int, int, error) {
+	return 0, 0, errors.New("the interactive mode is not supported on this platform")
+}
+
+// notifyResize is not supported on this platform
+func notifyResize(ch chan<- os.Signal) {}
@@ -0,0 +1,91 @@
+// Package tui is an interactive terminal interface for reviewing stars: it lists stars with
+// fuzzy filtering and a detail pane, and tags, pins, opens or unstars them with single keys.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// Options - the parameters of Run
+type Options struct {
+	// Open opens a star's URL, usually in the browser
+	Open func(url string) error
+}
+
+// Run shows the interface on a terminal until it is quit or the context is canceled
+func Run(ctx context.Context, in, out *os.File, items []*Item, backend Backend, opts Options) error {
+	if opts.Open == nil {
+		opts.Open = func(url string) error { return fmt.Errorf("cannot open %s", url) }
+	}
+
+	m, err := newModel(ctx, items, backend, opts.Open)
+	if err != nil {
+		return err
+	}
+
+	restore, err := makeRaw(int(in.Fd()))
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	// The interface is drawn on the alternate screen, leaving the scrollback untouched
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan []key)
+	errs := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			keys <- parseKeys(buf[:n])
+		}
+	}()
+
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	defer signal.Stop(resize)
+
+	for {
+		width, height, err := size(int(out.Fd()))
+		if err != nil {
+			return err
+		}
+
+		draw(out, m.view(width, height))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		case <-resize:
+		case pressed := <-keys:
+			for _, k := range pressed {
+				if m.update(k, height-detailHeight-3) {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// draw replaces the screen with the given lines
+func draw(out io.Writer, lines []string) {
+	fmt.Fprint(out, "\x1b[H\x1b[2J"+strings.Join(lines, "\r\n"))
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2020, 1, 15, 10, 30, 15, 0, time.UTC)
+
+	testCases := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"@every 90m", now.Add(90 * time.Minute)},
+		{"* * * * *", time.Date(2020, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2020, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2020, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 */6 * * *", time.Date(2020, 1, 15, 12, 0, 0, 0, time.UTC)},
+		{"15,45 9-17 * * *", time.Date(2020, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 3 * * 1-5", time.Date(2020, 1, 16, 3, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2020, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2020, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+
+		// Restricting both days matches either
+		{"0 0 1 * 5", time.Date(2020, 1, 17, 0, 0, 0, 0, time.UTC)},
+
+		// Schedules that never match have no next time
+		{"0 0 31 2 *", time.Time{}},
+	}
+
+	for _, tc := range testCases {
+		schedule, err := ParseSchedule(tc.spec)
+		assert.NoError(t, err, tc.spec)
+		assert.Equal(t, tc.expected, schedule.Next(now), tc.spec)
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "@every", "@every -1h", "@often"} {
+		_, err := ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
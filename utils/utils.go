@@ -1,7 +1,11 @@
 package utils
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/afero"
 )
@@ -38,3 +42,87 @@ func CreateIfNotExists(path string, mode os.FileMode, fs afero.Fs) error {
 
 	return nil
 }
+
+// ParseAge parses a human-friendly age such as "30d", "2w", "6m" or "1y" and returns the
+// point in time that lies that far before now
+func ParseAge(age string, now time.Time) (time.Time, error) {
+	if len(age) < 2 {
+		return time.Time{}, fmt.Errorf("invalid age %q", age)
+	}
+
+	n, err := strconv.Atoi(age[:len(age)-1])
+	if err != nil || n < 0 {
+		return time.Time{}, fmt.Errorf("invalid age %q", age)
+	}
+
+	switch age[len(age)-1] {
+	case 'd':
+		return now.AddDate(0, 0, -n), nil
+	case 'w':
+		return now.AddDate(0, 0, -7*n), nil
+	case 'm':
+		return now.AddDate(0, -n, 0), nil
+	case 'y':
+		return now.AddDate(-n, 0, 0), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid age unit in %q (expected one of d, w, m, y)", age)
+}
+
+// sparks are the block characters used to draw sparklines, from lowest to highest
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline renders a series of values as a sparkline of at most width characters. If
+// there are more values than width, consecutive values are summed into buckets.
+func Sparkline(values []int, width int) string {
+	if len(values) == 0 || width <= 0 {
+		return ""
+	}
+
+	size := (len(values) + width - 1) / width
+	buckets := make([]int, 0, width)
+	for i := 0; i < len(values); i += size {
+		sum := 0
+		for j := i; j < i+size && j < len(values); j++ {
+			sum += values[j]
+		}
+		buckets = append(buckets, sum)
+	}
+
+	max := 0
+	for _, b := range buckets {
+		if b > max {
+			max = b
+		}
+	}
+
+	line := make([]rune, len(buckets))
+	for i, b := range buckets {
+		if max == 0 {
+			line[i] = sparks[0]
+			continue
+		}
+
+		line[i] = sparks[b*(len(sparks)-1)/max]
+	}
+
+	return string(line)
+}
+
+// Bar renders value as a horizontal bar relative to max, at most width characters long.
+// Non-zero values are always drawn at least one character long.
+func Bar(value, max, width int) string {
+	if value <= 0 || max <= 0 || width <= 0 {
+		return ""
+	}
+
+	n := value * width / max
+	if n < 1 {
+		n = 1
+	}
+	if n > width {
+		n = width
+	}
+
+	return strings.Repeat("█", n)
+}
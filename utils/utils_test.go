@@ -3,6 +3,7 @@ package utils
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
@@ -75,3 +76,71 @@ func TestCreateIfNotExists(t *testing.T) {
 		doTestCreateIfNotExists(t, tc.path, tc.mode, tc.exists)
 	}
 }
+
+func TestParseAge(t *testing.T) {
+	now := time.Date(2020, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		age      string
+		expected time.Time
+		err      bool
+	}{
+		{age: "30d", expected: time.Date(2020, 2, 14, 0, 0, 0, 0, time.UTC)},
+		{age: "2w", expected: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{age: "6m", expected: time.Date(2019, 9, 15, 0, 0, 0, 0, time.UTC)},
+		{age: "1y", expected: time.Date(2019, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{age: "1", err: true},
+		{age: "y", err: true},
+		{age: "-1y", err: true},
+		{age: "1h", err: true},
+	}
+
+	for _, tc := range testCases {
+		cutoff, err := ParseAge(tc.age, now)
+		if tc.err {
+			assert.Error(t, err, tc.age)
+			continue
+		}
+
+		assert.NoError(t, err, tc.age)
+		assert.Equal(t, tc.expected, cutoff, tc.age)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	testCases := []struct {
+		values   []int
+		width    int
+		expected string
+	}{
+		{values: []int{}, width: 10, expected: ""},
+		{values: []int{0, 0, 0}, width: 10, expected: "▁▁▁"},
+		{values: []int{0, 1, 2, 3, 4, 5, 6, 7}, width: 8, expected: "▁▂▃▄▅▆▇█"},
+		{values: []int{1, 1, 0, 0, 2, 2}, width: 3, expected: "▄▁█"},
+		{values: []int{1, 2, 3}, width: 0, expected: ""},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, Sparkline(tc.values, tc.width))
+	}
+}
+
+func TestBar(t *testing.T) {
+	testCases := []struct {
+		value    int
+		max      int
+		width    int
+		expected string
+	}{
+		{value: 10, max: 10, width: 5, expected: "█████"},
+		{value: 5, max: 10, width: 4, expected: "██"},
+		{value: 1, max: 100, width: 4, expected: "█"},
+		{value: 0, max: 10, width: 4, expected: ""},
+		{value: 20, max: 10, width: 3, expected: "███"},
+		{value: 1, max: 0, width: 3, expected: ""},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, Bar(tc.value, tc.max, tc.width))
+	}
+}